@@ -0,0 +1,61 @@
+package api
+
+import (
+	"API_VentasGO/internal/money"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// findUser answers 404 and returns false when the user of the path does not exist.
+func (h *handler) findUser(ctx *gin.Context) bool {
+	if _, err := h.userService.Get(ctx.Param("id")); err != nil {
+		fail(ctx, err)
+		return false
+	}
+	return true
+}
+
+// handleReadAccount handles GET /users/:id/account
+func (h *handler) handleReadAccount(ctx *gin.Context) {
+	if !h.findUser(ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h.accountService.Get(ctx.Param("id")))
+}
+
+// handleCreatePayment handles POST /users/:id/payments
+func (h *handler) handleCreatePayment(ctx *gin.Context) {
+	// request payload
+	// amount accepts every form of money.FromJSON; plain decimals are read in
+	// currency, which defaults to money.DefaultCurrency. reference is
+	// optional, and a payment with the same reference is only taken once.
+	var req struct {
+		Amount    json.RawMessage `json:"amount"`
+		Currency  string          `json:"currency"`
+		Reference string          `json:"reference"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, invalidBody(err))
+		return
+	}
+	amount, err := money.FromJSON(req.Amount, req.Currency)
+	if err != nil {
+		fail(ctx, withField("amount", err))
+		return
+	}
+
+	if !h.findUser(ctx) {
+		return
+	}
+
+	entry, err := h.accountService.Pay(ctx.Param("id"), amount, req.Reference)
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, entry)
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"API_VentasGO/internal/auth"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// principalKey is the gin context key of the authenticated principal.
+const principalKey = "principal"
+
+// anonymous is the principal of every request when authentication is
+// disabled. It has the admin role, so disabling authentication opens every
+// endpoint.
+var anonymous = &auth.Principal{Subject: "anonymous", Method: "none", Roles: []string{"admin"}}
+
+// guest is the principal of the requests to public routes. It has no roles.
+var guest = &auth.Principal{Subject: "guest", Method: "none"}
+
+// authenticate rejects requests without valid credentials with 401, and
+// stores the principal of the others in the context for the handlers.
+// Every request is logged with its principal and request ID once it is
+// answered. A nil authenticator lets every request through as anonymous.
+// Requests to the public routes go through as guest.
+func authenticate(a *auth.Authenticator, public ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		p := anonymous
+		var err error
+		switch {
+		case slices.Contains(public, ctx.FullPath()):
+			p = guest
+		case a != nil:
+			p, err = a.Authenticate(ctx.Request)
+		}
+
+		logger := requestLogger(ctx)
+		fields := []zap.Field{
+			zap.String("method", ctx.Request.Method),
+			zap.String("path", ctx.Request.URL.Path),
+		}
+		if err != nil {
+			ctx.Header("WWW-Authenticate", `Bearer realm="api"`)
+			fail(ctx, err)
+			logger.Warn("unauthenticated request", append(fields, zap.Int("status", ctx.Writer.Status()), zap.Error(err))...)
+			return
+		}
+
+		ctx.Set(principalKey, p)
+		ctx.Next()
+
+		logger.Info("request", append(fields,
+			zap.Int("status", ctx.Writer.Status()),
+			zap.String("principal", p.Subject),
+			zap.String("auth_method", p.Method),
+			zap.Duration("latency", time.Since(start)),
+		)...)
+	}
+}
+
+// unauthenticated is the principal of requests that did not go through
+// authenticate. It has no roles, so a route registered without it is closed
+// rather than open.
+var unauthenticated = &auth.Principal{Subject: "unauthenticated", Method: "none"}
+
+// principal returns the principal authenticate stored for the request, or
+// unauthenticated if there is none.
+func principal(ctx *gin.Context) *auth.Principal {
+	if p, ok := ctx.Value(principalKey).(*auth.Principal); ok {
+		return p
+	}
+	return unauthenticated
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"API_VentasGO/internal/policy"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrincipal_FailsClosed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	p := principal(ctx)
+	require.Empty(t, p.Roles)
+	require.Error(t, policy.Default().Check(p, policy.SalesRead))
+
+	ctx.Set(principalKey, anonymous)
+	require.Same(t, anonymous, principal(ctx))
+
+	// A route registered without authenticate answers 403.
+	e := gin.New()
+	e.GET("/sales", authorize(policy.Default(), policy.SalesRead), func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/sales", nil))
+	require.Equal(t, http.StatusForbidden, resp.Code)
+
+	// Through authenticate, disabled authentication still opens it.
+	e = gin.New()
+	e.Use(authenticate(nil))
+	e.GET("/sales", authorize(policy.Default(), policy.SalesRead), func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/sales", nil))
+	require.Equal(t, http.StatusOK, resp.Code)
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"API_VentasGO/internal/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authorize rejects with 403 the requests of principals without perm.
+func authorize(p *policy.Policy, perm string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if err := p.Check(principal(ctx), perm); err != nil {
+			fail(ctx, err)
+		}
+	}
+}
+
+// authorizeOwner is like authorize, but also lets through principals with the
+// :own variant of perm when they are the user of the path parameter param.
+func authorizeOwner(p *policy.Policy, perm string, param string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if err := p.CheckOwn(principal(ctx), perm, ctx.Param(param)); err != nil {
+			fail(ctx, err)
+		}
+	}
+}
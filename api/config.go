@@ -0,0 +1,382 @@
+package api
+
+import (
+	"API_VentasGO/internal/auth"
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/policy"
+	"API_VentasGO/internal/ratelimit"
+	"API_VentasGO/internal/sale"
+	"API_VentasGO/internal/tax"
+	"API_VentasGO/internal/user"
+	"API_VentasGO/internal/wal"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// config holds the settings InitRoutes reads from the environment.
+type config struct {
+	// dataDir is where the durable storages keep their files.
+	// When empty, users and sales are kept in memory only.
+	dataDir string
+
+	// wal configures the write-ahead log of the durable storages.
+	wal wal.Options
+
+	// payment decides the initial status of new sales.
+	payment sale.PaymentAuthorizer
+
+	// reconcileEvery is the period of the sales summary reconciliation.
+	// Zero disables it.
+	reconcileEvery time.Duration
+
+	// idempotencyTTL is how long responses to idempotent requests are kept.
+	idempotencyTTL time.Duration
+
+	// taxRules computes the taxes of new sales. When nil, sales are untaxed.
+	taxRules *tax.Rules
+
+	// creditLimit is the most a user may owe on their credit account.
+	creditLimit money.Money
+
+	// auth authenticates every request. When nil, authentication is disabled.
+	auth *auth.Authenticator
+
+	// policy decides what each role may do.
+	policy *policy.Policy
+
+	// signer issues the access tokens of logged in users. When nil, the
+	// /auth endpoints are not served.
+	signer *auth.JWTSigner
+
+	// accessTTL is how long the access tokens of logged in users last.
+	accessTTL time.Duration
+
+	// login configures lockouts and refresh tokens.
+	login user.LoginOptions
+
+	// rateLimits limits the requests of each client. When nil, clients are
+	// not limited.
+	rateLimits *ratelimit.Config
+
+	// trustedProxies are the proxies whose X-Forwarded-For is believed when
+	// telling the IP address of a client.
+	trustedProxies []string
+}
+
+// loadConfig reads the configuration from the environment:
+//
+//	DATA_DIR        directory for durable storage (empty keeps data in memory)
+//	WAL_SYNC        always | interval | never (default always)
+//	WAL_SYNC_EVERY  flush period for WAL_SYNC=interval, e.g. 500ms (default 1s)
+//	SNAPSHOT_EVERY  records between snapshots (default 1000, 0 disables them)
+//	PAYMENT_MODE    pending | rules | simulated (default pending)
+//	SUMMARY_RECONCILE_EVERY  period to repair drifted sales summaries (default off)
+//	IDEMPOTENCY_TTL  how long Idempotency-Key responses are kept (default 24h)
+//	TAX_RULES       path of the tax.Rules JSON file (default none: sales are untaxed)
+//	CREDIT_LIMIT    most a user may owe on account, e.g. 50000 or "500 USD" (default 0)
+//	AUTH_API_KEYS   JSON list of auth.APIKey, e.g. [{"name":"backoffice","key":"...","roles":["admin"]}]
+//	AUTH_JWT_SECRET        HS256 secret of bearer tokens, at least 32 bytes
+//	AUTH_JWT_PUBLIC_KEY    path of the PEM RSA public key of RS256 bearer tokens
+//	AUTH_JWT_ISSUER, AUTH_JWT_AUDIENCE  required iss and aud of bearer tokens (default any)
+//	AUTH_DISABLED   true lets every request through unauthenticated (default false)
+//	AUTH_POLICY     path of the policy.Policy JSON file (default policy.Default)
+//	AUTH_ACCESS_TTL    lifetime of the access tokens of POST /auth/login (default 15m)
+//	AUTH_REFRESH_TTL   lifetime of the sessions of POST /auth/login (default 720h)
+//	AUTH_LOCKOUT_AFTER failed logins in a row that lock an account (default 5, 0 disables it)
+//	AUTH_LOCKOUT_FOR   how long a locked account rejects logins (default 15m)
+//	RATE_LIMITS     path of the ratelimit.Config JSON file (default none: only failed authentications are limited)
+//	TRUSTED_PROXIES comma-separated proxy IPs or CIDRs allowed to set X-Forwarded-For (default none)
+//
+// PAYMENT_MODE=rules reads a sale.RuleAuthorizer as JSON from PAYMENT_RULES.
+// PAYMENT_MODE=simulated reads PAYMENT_SEED, PAYMENT_LATENCY, PAYMENT_JITTER,
+// PAYMENT_FAILURE_RATE, PAYMENT_REJECT_RATE and PAYMENT_PENDING_RATE.
+// At least one of AUTH_API_KEYS, AUTH_JWT_SECRET and AUTH_JWT_PUBLIC_KEY
+// must be set unless AUTH_DISABLED is true. Users log in only when
+// AUTH_JWT_SECRET is set, since it signs their access tokens.
+func loadConfig() (*config, error) {
+	cfg := &config{
+		dataDir: os.Getenv("DATA_DIR"),
+		wal: wal.Options{
+			Sync:          wal.SyncAlways,
+			SyncEvery:     time.Second,
+			SnapshotEvery: 1000,
+		},
+	}
+
+	switch v := os.Getenv("WAL_SYNC"); v {
+	case "", "always":
+	case "interval":
+		cfg.wal.Sync = wal.SyncInterval
+	case "never":
+		cfg.wal.Sync = wal.SyncNever
+	default:
+		return nil, fmt.Errorf("invalid WAL_SYNC %q", v)
+	}
+
+	if v := os.Getenv("WAL_SYNC_EVERY"); v != "" {
+		d, err := envDuration("WAL_SYNC_EVERY")
+		if err != nil {
+			return nil, err
+		}
+		cfg.wal.SyncEvery = d
+	}
+
+	n, err := envInt64("SNAPSHOT_EVERY", int64(cfg.wal.SnapshotEvery))
+	if err != nil {
+		return nil, err
+	}
+	cfg.wal.SnapshotEvery = int(n)
+
+	if cfg.reconcileEvery, err = envDuration("SUMMARY_RECONCILE_EVERY"); err != nil {
+		return nil, err
+	}
+
+	if cfg.idempotencyTTL, err = envDuration("IDEMPOTENCY_TTL"); err != nil {
+		return nil, err
+	}
+	if cfg.idempotencyTTL <= 0 {
+		cfg.idempotencyTTL = 24 * time.Hour
+	}
+
+	if path := os.Getenv("TAX_RULES"); path != "" {
+		if cfg.taxRules, err = tax.Load(path); err != nil {
+			return nil, fmt.Errorf("invalid TAX_RULES: %w", err)
+		}
+	}
+
+	if cfg.creditLimit, err = envMoney("CREDIT_LIMIT"); err != nil {
+		return nil, err
+	}
+
+	if path := os.Getenv("RATE_LIMITS"); path != "" {
+		if cfg.rateLimits, err = ratelimit.Load(path); err != nil {
+			return nil, fmt.Errorf("invalid RATE_LIMITS: %w", err)
+		}
+	}
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			cfg.trustedProxies = append(cfg.trustedProxies, proxy)
+		}
+	}
+
+	payment, err := loadPayment()
+	if err != nil {
+		return nil, err
+	}
+	cfg.payment = payment
+
+	if cfg.auth, cfg.signer, err = loadAuth(); err != nil {
+		return nil, err
+	}
+	if err := loadLogin(cfg); err != nil {
+		return nil, err
+	}
+
+	cfg.policy = policy.Default()
+	if path := os.Getenv("AUTH_POLICY"); path != "" {
+		if cfg.policy, err = policy.Load(path); err != nil {
+			return nil, fmt.Errorf("invalid AUTH_POLICY: %w", err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// minJWTSecretLength is the shortest HS256 secret accepted.
+const minJWTSecretLength = 32
+
+// loadAuth builds the authenticator from the AUTH_* variables, and the
+// signer of access tokens when AUTH_JWT_SECRET is set.
+func loadAuth() (*auth.Authenticator, *auth.JWTSigner, error) {
+	if disabled, _ := strconv.ParseBool(os.Getenv("AUTH_DISABLED")); disabled {
+		return nil, nil, nil
+	}
+
+	a := &auth.Authenticator{}
+	if v := os.Getenv("AUTH_API_KEYS"); v != "" {
+		var keys []auth.APIKey
+		if err := json.Unmarshal([]byte(v), &keys); err != nil {
+			return nil, nil, fmt.Errorf("invalid AUTH_API_KEYS: %w", err)
+		}
+		ring, err := auth.NewKeyRing(keys)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid AUTH_API_KEYS: %w", err)
+		}
+		a.Keys = ring
+	}
+
+	jwt := auth.JWTConfig{
+		HMACSecret: []byte(os.Getenv("AUTH_JWT_SECRET")),
+		Issuer:     os.Getenv("AUTH_JWT_ISSUER"),
+		Audience:   os.Getenv("AUTH_JWT_AUDIENCE"),
+	}
+	if n := len(jwt.HMACSecret); n > 0 && n < minJWTSecretLength {
+		return nil, nil, fmt.Errorf("invalid AUTH_JWT_SECRET: shorter than %d bytes", minJWTSecretLength)
+	}
+	if path := os.Getenv("AUTH_JWT_PUBLIC_KEY"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid AUTH_JWT_PUBLIC_KEY: %w", err)
+		}
+		if jwt.RSAPublicKey, err = auth.ParseRSAPublicKey(data); err != nil {
+			return nil, nil, fmt.Errorf("invalid AUTH_JWT_PUBLIC_KEY: %w", err)
+		}
+	}
+	if len(jwt.HMACSecret) > 0 || jwt.RSAPublicKey != nil {
+		verifier, err := auth.NewJWTVerifier(jwt)
+		if err != nil {
+			return nil, nil, err
+		}
+		a.Tokens = verifier
+	}
+	var signer *auth.JWTSigner
+	if len(jwt.HMACSecret) > 0 {
+		var err error
+		if signer, err = auth.NewJWTSigner(jwt); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if a.Keys == nil && a.Tokens == nil {
+		return nil, nil, errors.New("no credentials configured: set AUTH_API_KEYS, AUTH_JWT_SECRET or AUTH_JWT_PUBLIC_KEY, or AUTH_DISABLED=true")
+	}
+	return a, signer, nil
+}
+
+// loadLogin reads the AUTH_* variables of user logins.
+func loadLogin(cfg *config) error {
+	var err error
+	if cfg.accessTTL, err = envDuration("AUTH_ACCESS_TTL"); err != nil {
+		return err
+	}
+	if cfg.accessTTL <= 0 {
+		cfg.accessTTL = 15 * time.Minute
+	}
+
+	cfg.login = user.DefaultLoginOptions()
+	refreshTTL, err := envDuration("AUTH_REFRESH_TTL")
+	if err != nil {
+		return err
+	}
+	if refreshTTL > 0 {
+		cfg.login.RefreshTTL = refreshTTL
+	}
+	lockout, err := envDuration("AUTH_LOCKOUT_FOR")
+	if err != nil {
+		return err
+	}
+	if lockout > 0 {
+		cfg.login.Lockout = lockout
+	}
+	n, err := envInt64("AUTH_LOCKOUT_AFTER", int64(cfg.login.MaxFailures))
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return errors.New("invalid AUTH_LOCKOUT_AFTER: must not be negative")
+	}
+	cfg.login.MaxFailures = int(n)
+	return nil
+}
+
+// loadPayment builds the PaymentAuthorizer selected by PAYMENT_MODE.
+func loadPayment() (sale.PaymentAuthorizer, error) {
+	switch v := os.Getenv("PAYMENT_MODE"); v {
+	case "", "pending":
+		return sale.AlwaysPending{}, nil
+	case "rules":
+		var rules sale.RuleAuthorizer
+		if err := json.Unmarshal([]byte(os.Getenv("PAYMENT_RULES")), &rules); err != nil {
+			return nil, fmt.Errorf("invalid PAYMENT_RULES: %w", err)
+		}
+		return &rules, nil
+	case "simulated":
+		var gw sale.SimulatedGatewayConfig
+		var err error
+		if gw.Seed, err = envInt64("PAYMENT_SEED", 1); err != nil {
+			return nil, err
+		}
+		if gw.Latency, err = envDuration("PAYMENT_LATENCY"); err != nil {
+			return nil, err
+		}
+		if gw.Jitter, err = envDuration("PAYMENT_JITTER"); err != nil {
+			return nil, err
+		}
+		if gw.FailureRate, err = envFloat("PAYMENT_FAILURE_RATE"); err != nil {
+			return nil, err
+		}
+		if gw.RejectRate, err = envFloat("PAYMENT_REJECT_RATE"); err != nil {
+			return nil, err
+		}
+		if gw.PendingRate, err = envFloat("PAYMENT_PENDING_RATE"); err != nil {
+			return nil, err
+		}
+		return sale.NewSimulatedGateway(gw), nil
+	default:
+		return nil, fmt.Errorf("invalid PAYMENT_MODE %q", v)
+	}
+}
+
+// envInt64 parses the integer variable key, or returns def when it is unset.
+func envInt64(key string, def int64) (int64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+// envDuration parses the duration variable key, or returns zero when it is unset.
+func envDuration(key string) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// envFloat parses the float variable key, or returns zero when it is unset.
+func envFloat(key string) (float64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return f, nil
+}
+
+// envMoney parses the amount variable key, a decimal optionally followed by a
+// currency code, or returns zero in money.DefaultCurrency when it is unset.
+func envMoney(key string) (money.Money, error) {
+	value, currency, _ := strings.Cut(strings.TrimSpace(os.Getenv(key)), " ")
+	if value == "" {
+		return money.Money{Currency: money.DefaultCurrency}, nil
+	}
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	m, err := money.Parse(value, currency)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if m.Minor < 0 {
+		return money.Money{}, fmt.Errorf("invalid %s: must not be negative", key)
+	}
+	return m, nil
+}
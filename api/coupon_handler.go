@@ -0,0 +1,84 @@
+package api
+
+import (
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/promotion"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleCreateCoupon handles POST /coupons
+func (h *handler) handleCreateCoupon(ctx *gin.Context) {
+	// request payload
+	// kind is percent, with a percent such as "12.5", or fixed, with an
+	// amount. amount and min_purchase accept every form of money.FromJSON;
+	// plain decimals are read in currency, which defaults to
+	// money.DefaultCurrency. valid_from and valid_until are RFC 3339 times,
+	// and the limits and windows are unbounded when omitted. Coupons are
+	// active unless active is false.
+	var req struct {
+		Code           string          `json:"code"`
+		Kind           string          `json:"kind"`
+		Percent        decimal         `json:"percent"`
+		Amount         json.RawMessage `json:"amount"`
+		MinPurchase    json.RawMessage `json:"min_purchase"`
+		Currency       string          `json:"currency"`
+		ValidFrom      time.Time       `json:"valid_from"`
+		ValidUntil     time.Time       `json:"valid_until"`
+		MaxUses        int             `json:"max_uses"`
+		MaxUsesPerUser int             `json:"max_uses_per_user"`
+		Active         *bool           `json:"active"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, invalidBody(err))
+		return
+	}
+
+	c := &promotion.Coupon{
+		Code:           req.Code,
+		Kind:           req.Kind,
+		Percent:        string(req.Percent),
+		ValidFrom:      req.ValidFrom,
+		ValidUntil:     req.ValidUntil,
+		MaxUses:        req.MaxUses,
+		MaxUsesPerUser: req.MaxUsesPerUser,
+		Active:         req.Active == nil || *req.Active,
+	}
+	if len(req.Amount) > 0 {
+		amount, err := money.FromJSON(req.Amount, req.Currency)
+		if err != nil {
+			fail(ctx, withField("amount", err))
+			return
+		}
+		c.Amount = amount
+	}
+	if len(req.MinPurchase) > 0 {
+		minPurchase, err := money.FromJSON(req.MinPurchase, req.Currency)
+		if err != nil {
+			fail(ctx, withField("min_purchase", err))
+			return
+		}
+		c.MinPurchase = minPurchase
+	}
+
+	if err := h.promotionService.Create(c); err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, c)
+}
+
+// handleReadCoupon handles GET /coupons/:code
+func (h *handler) handleReadCoupon(ctx *gin.Context) {
+	c, err := h.promotionService.Get(ctx.Param("code"))
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, c)
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/promotion"
+	"API_VentasGO/internal/sale"
+	"errors"
+	"fmt"
+)
+
+// couponRedeemer adapts promotion.Service to the sale.Promotions interface,
+// so the sale service redeems coupons in-process.
+type couponRedeemer struct {
+	promotions *promotion.Service
+}
+
+// Redeem returns an error wrapping sale.ErrCouponNotFound if the coupon does
+// not exist, and sale.ErrCouponNotApplicable if it cannot be used on the sale.
+func (c couponRedeemer) Redeem(code, saleID, userID string, amount money.Money) (money.Money, error) {
+	discount, err := c.promotions.Redeem(code, saleID, userID, amount)
+	switch {
+	case err == nil:
+		return discount, nil
+	case errors.Is(err, promotion.ErrNotFound):
+		return money.Money{}, fmt.Errorf("%w: %s", sale.ErrCouponNotFound, code)
+	case errors.Is(err, promotion.ErrInactive), errors.Is(err, promotion.ErrExpired),
+		errors.Is(err, promotion.ErrMinimumNotReached), errors.Is(err, promotion.ErrUsageLimit),
+		errors.Is(err, promotion.ErrUserUsageLimit), errors.Is(err, money.ErrCurrencyMismatch):
+		return money.Money{}, fmt.Errorf("%w: %v", sale.ErrCouponNotApplicable, err)
+	default:
+		return money.Money{}, err
+	}
+}
+
+// Release gives back the use of the coupon by the sale.
+func (c couponRedeemer) Release(code, saleID string) error {
+	return c.promotions.Release(code, saleID)
+}
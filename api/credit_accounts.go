@@ -0,0 +1,37 @@
+package api
+
+import (
+	"API_VentasGO/internal/account"
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/sale"
+	"errors"
+	"fmt"
+)
+
+// creditAccounts adapts account.Service to the sale.Accounts interface, so
+// the sale service charges account sales in-process.
+type creditAccounts struct {
+	accounts *account.Service
+}
+
+// Charge returns an error wrapping sale.ErrCreditLimitExceeded if the user
+// cannot owe amount more.
+func (c creditAccounts) Charge(userID, saleID string, amount money.Money) error {
+	_, err := c.accounts.Charge(userID, saleID, amount)
+	if errors.Is(err, account.ErrCreditLimitExceeded) {
+		return fmt.Errorf("%w: %v", sale.ErrCreditLimitExceeded, err)
+	}
+	return err
+}
+
+// Refund credits a refund of an account sale.
+func (c creditAccounts) Refund(userID, refundID string, amount money.Money) error {
+	_, err := c.accounts.Refund(userID, refundID, amount)
+	return err
+}
+
+// Reverse takes back the charge of a sale that could not be stored.
+func (c creditAccounts) Reverse(userID, saleID string, amount money.Money) error {
+	_, err := c.accounts.Reverse(userID, saleID, amount)
+	return err
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// decimal is a request field that takes a decimal as a JSON number or a
+// string, such as 12.5 or "12.5". It keeps the text of the decimal for the
+// services to parse; null leaves it empty.
+type decimal string
+
+// UnmarshalJSON accepts a JSON number or a string holding one, and fails for
+// anything else, which answers the request 400 through invalidBody.
+func (d *decimal) UnmarshalJSON(data []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("%s is not a decimal number", data)
+	}
+	*d = decimal(n)
+	return nil
+}
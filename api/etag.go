@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errInvalidIfMatch is returned when the If-Match header is not "*" or a
+// single strong ETag issued by this API.
+var errInvalidIfMatch = errors.New(`If-Match must be "*" or a single ETag`)
+
+// errConflictingVersion is returned when If-Match and expected_version ask
+// for different versions.
+var errConflictingVersion = errors.New("If-Match and expected_version disagree")
+
+// etag returns the entity tag of a resource at the given version.
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// setETag sets the ETag header of the response to the given version.
+func setETag(ctx *gin.Context, version int) {
+	ctx.Header("ETag", etag(version))
+}
+
+// expectedVersion resolves the version a PATCH is conditional on, from the
+// If-Match header and the expected_version body field. It returns nil when
+// the update is unconditional.
+func expectedVersion(ctx *gin.Context, body *int) (*int, error) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return body, nil
+	}
+
+	raw, err := strconv.Unquote(header)
+	if err != nil {
+		return nil, errInvalidIfMatch
+	}
+	version, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, errInvalidIfMatch
+	}
+
+	if body != nil && *body != version {
+		return nil, errConflictingVersion
+	}
+	return &version, nil
+}
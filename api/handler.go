@@ -1,50 +1,70 @@
 package api
 
 import (
+	"API_VentasGO/internal/account"
+	"API_VentasGO/internal/auth"
+	"API_VentasGO/internal/inventory"
 	"API_VentasGO/internal/metadata"
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/policy"
+	"API_VentasGO/internal/product"
+	"API_VentasGO/internal/promotion"
 	"API_VentasGO/internal/sale"
 	"API_VentasGO/internal/user"
-	"bytes"
-	"errors"
+	"encoding/json"
+	"fmt"
 	"net/http"
-	"net/http/httptest"
-	"os"
-	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.uber.org/zap"
 )
 
 // handler holds the user service and implements HTTP handlers for user CRUD.
+// Handlers reach users and sales through users and sales, which check the
+// principal of the request against the policy; userService and saleService
+// are kept for lookups the handlers make on their own behalf.
 type handler struct {
-	userService     *user.Service
-	saleService     *sale.Service
-	metadataService *metadata.Service
+	users            *policy.Users
+	sales            *policy.Sales
+	userService      *user.Service
+	saleService      *sale.Service
+	productService   *product.Service
+	inventoryService *inventory.Service
+	metadataService  *metadata.Service
+	promotionService *promotion.Service
+	accountService   *account.Service
+
+	// signer issues the access tokens of logged in users, lasting accessTTL.
+	signer    *auth.JWTSigner
+	accessTTL time.Duration
 }
 
 // handleCreate handles POST /users
 func (h *handler) handleCreateUser(ctx *gin.Context) {
 	// request payload
 	var req struct {
-		Name     string `json:"name"`
-		Address  string `json:"address"`
-		NickName string `json:"nickname"`
+		Name         string `json:"name"`
+		Address      string `json:"address"`
+		NickName     string `json:"nickname"`
+		Jurisdiction string `json:"jurisdiction"`
 	}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		fail(ctx, invalidBody(err))
 		return
 	}
 
 	u := &user.User{
-		Name:     req.Name,
-		Address:  req.Address,
-		NickName: req.NickName,
+		Name:         req.Name,
+		Address:      req.Address,
+		NickName:     req.NickName,
+		Jurisdiction: req.Jurisdiction,
 	}
-	if err := h.userService.Create(u); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.users.Create(principal(ctx), u); err != nil {
+		fail(ctx, err)
 		return
 	}
 
+	setETag(ctx, u.Version)
 	ctx.JSON(http.StatusCreated, u)
 }
 
@@ -52,17 +72,13 @@ func (h *handler) handleCreateUser(ctx *gin.Context) {
 func (h *handler) handleReadUser(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	u, err := h.userService.Get(id)
+	u, err := h.users.Get(principal(ctx), id)
 	if err != nil {
-		if errors.Is(err, user.ErrNotFound) {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		fail(ctx, err)
 		return
 	}
 
+	setETag(ctx, u.Version)
 	ctx.JSON(http.StatusOK, u)
 }
 
@@ -71,23 +87,26 @@ func (h *handler) handleUpdateUser(ctx *gin.Context) {
 	id := ctx.Param("id")
 
 	// bind partial update fields
-	var fields *user.UpdateFields
+	var fields user.UpdateFields
 	if err := ctx.ShouldBindJSON(&fields); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		fail(ctx, invalidBody(err))
 		return
 	}
 
-	u, err := h.userService.Update(id, fields)
+	version, err := expectedVersion(ctx, fields.ExpectedVersion)
 	if err != nil {
-		if errors.Is(err, user.ErrNotFound) {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
+		fail(ctx, err)
+		return
+	}
+	fields.ExpectedVersion = version
 
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	u, err := h.users.Update(principal(ctx), id, &fields)
+	if err != nil {
+		fail(ctx, err)
 		return
 	}
 
+	setETag(ctx, u.Version)
 	ctx.JSON(http.StatusOK, u)
 }
 
@@ -95,149 +114,147 @@ func (h *handler) handleUpdateUser(ctx *gin.Context) {
 func (h *handler) handleDeleteUser(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	if err := h.userService.Delete(id); err != nil {
-		if errors.Is(err, user.ErrNotFound) {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.users.Delete(principal(ctx), id); err != nil {
+		fail(ctx, err)
 		return
 	}
 
 	ctx.Status(http.StatusNoContent)
 }
 
-func validarUsuario(id string, engine *gin.Engine) (int, error) {
-	if os.Getenv("MODO") != "testing" {
-		resp, err := http.Get("http://localhost:9090/users/" + id)
-		if err != nil {
-			return 0, err
-		}
-		defer resp.Body.Close()
-		return resp.StatusCode, nil
-	} else {
-		resp, err := http.NewRequest(http.MethodGet, "http://localhost:9090/users/"+id, bytes.NewReader(nil))
-		req := httptest.NewRecorder()
-		engine.ServeHTTP(req, resp)
-		defer resp.Body.Close()
-		return req.Code, err
-	}
-}
-
 // handleCreate handles POST /sales
 func (h *handler) handleCreateSale(ctx *gin.Context) {
 	// request payload
+	// A sale is either a list of items, priced from the catalog, or a bare
+	// amount. amount and unit_price accept every form of money.FromJSON;
+	// plain decimals are read in currency, which defaults to
+	// money.DefaultCurrency. unit_price is optional and, when given, must be
+	// the current catalog price. installment_plan takes a count and an
+	// interest_rate percentage, as a number or a string. payment_method is
+	// optional; see sale.PaymentMethod for the fields of each type.
+	// coupon_code optionally applies a coupon before taxes.
 	var req struct {
-		UserId string  `json:"user_id"`
-		Amount float32 `json:"amount"`
+		UserId   string          `json:"user_id"`
+		Amount   json.RawMessage `json:"amount"`
+		Currency string          `json:"currency"`
+		Items    []struct {
+			ProductID string          `json:"product_id"`
+			Quantity  int64           `json:"quantity"`
+			UnitPrice json.RawMessage `json:"unit_price"`
+		} `json:"items"`
+		Plan *struct {
+			Count        int     `json:"count"`
+			InterestRate decimal `json:"interest_rate"`
+		} `json:"installment_plan"`
+		Payment    *sale.PaymentMethod `json:"payment_method"`
+		CouponCode string              `json:"coupon_code"`
 	}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		h.saleService.Logger.Error("error", zap.Error(err))
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	if req.Amount <= 0 {
-		h.saleService.Logger.Error("error", zap.Error(sale.ErrInvalidAmoun))
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": sale.ErrInvalidAmoun})
+		fail(ctx, invalidBody(err))
 		return
 	}
-	sale := &sale.Sale{
-		UserId: req.UserId,
-		Amount: req.Amount,
-	}
-	var errCode int
-	var err error
-	if os.Getenv("MODO") == "testing" {
-		engine, exists := ctx.Get("engine")
-		if !exists {
-			ctx.JSON(500, gin.H{"error": "Invalid engine type"})
-		}
 
-		eng, ok := engine.(*gin.Engine)
-		if !ok {
-			ctx.JSON(500, gin.H{"error": "Invalid engine type"})
+	newSale := &sale.Sale{UserId: req.UserId, Payment: req.Payment}
+	if len(req.Items) > 0 {
+		if len(req.Amount) > 0 {
+			fail(ctx, errAmountFromItems)
+			return
+		}
+		for i, item := range req.Items {
+			var unitPrice money.Money
+			if len(item.UnitPrice) > 0 {
+				var err error
+				if unitPrice, err = money.FromJSON(item.UnitPrice, req.Currency); err != nil {
+					fail(ctx, withField(fmt.Sprintf("items[%d].unit_price", i), err))
+					return
+				}
+			}
+			newSale.Items = append(newSale.Items, sale.Item{
+				ProductID: item.ProductID,
+				Quantity:  item.Quantity,
+				UnitPrice: unitPrice,
+			})
 		}
-		errCode, err = validarUsuario(sale.UserId, eng)
 	} else {
-		errCode, err = validarUsuario(sale.UserId, nil)
+		amount, err := money.FromJSON(req.Amount, req.Currency)
+		if err != nil {
+			fail(ctx, withField("amount", err))
+			return
+		}
+		if !amount.IsPositive() {
+			fail(ctx, sale.ErrInvalidAmoun)
+			return
+		}
+		newSale.Amount = amount
 	}
-
-	if err != nil {
-		h.saleService.Logger.Error("error", zap.Error(err))
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	if req.Plan != nil {
+		newSale.Plan = &sale.InstallmentPlan{
+			Count:        req.Plan.Count,
+			InterestRate: string(req.Plan.InterestRate),
+		}
 	}
-	if errCode == http.StatusNotFound {
-		h.saleService.Logger.Error("error", zap.Error(errors.New("user not found")))
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "user not found"})
-		return
-
+	if req.CouponCode != "" {
+		newSale.Discount = &sale.Discount{Code: req.CouponCode}
 	}
 
-	if err := h.saleService.Create(sale); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.sales.Create(principal(ctx), newSale); err != nil {
+		fail(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, sale)
-}
-
-func checkStatus(status string) bool {
-	estados := map[string]string{"pending": "", "approved": "", "rejected": "", "": ""}
-	_, ok := estados[status]
-	return ok
+	setETag(ctx, newSale.Version)
+	ctx.JSON(http.StatusCreated, newSale)
 }
 
 // handleRead handles GET /sales?user_id
+// It accepts repeated status values, created_from/created_to and
+// updated_from/updated_to (RFC 3339 or YYYY-MM-DD, both ends inclusive),
+// min_amount/max_amount in currency, plus limit, cursor, sort (created_at,
+// updated_at or amount) and order (asc or desc).
 func (h *handler) handleReadSale(ctx *gin.Context) {
 	type SaleResponse struct {
-		Metadata *metadata.Metadata `json:"metadata"`
-		Results  []*sale.Sale       `json:"results"`
+		Metadata   *metadata.Metadata `json:"metadata"`
+		Results    []*sale.Sale       `json:"results"`
+		NextCursor *string            `json:"next_cursor"`
 	}
 
-	id := ctx.Query("user_id")
-	status := strings.ToLower(ctx.Query("status"))
-
-	if !checkStatus(status) {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": sale.ErrInvalidStatus})
+	criteria, page, err := parseSalesQuery(ctx)
+	if err != nil {
+		fail(ctx, err)
 		return
 	}
-	_, err := h.userService.Get(id)
+	id := criteria.UserId
+
+	result, err := h.sales.ListSales(principal(ctx), criteria, page)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(ctx, err)
 		return
 	}
 
-	m := &metadata.Metadata{
-		Quantity:     0,
-		Approved:     0,
-		Pending:      0,
-		Rejected:     0,
-		Total_amount: float32(0),
+	if _, err := h.userService.Get(id); err != nil {
+		fail(ctx, err)
+		return
 	}
 
-	for {
-		err := h.metadataService.Create(m, id)
-		if err == nil {
-			break
+	// Without filters the summary is the user's read model; otherwise it
+	// summarizes only the matching sales.
+	m := metadata.FromSale(result.Metadata)
+	if criteria.IsUnfiltered() {
+		if m, err = h.metadataService.Summary(id); err != nil {
+			fail(ctx, err)
+			return
 		}
 	}
-
-	sales, meta := h.saleService.GetUserSales(id, status)
-	m.Quantity = int(meta["quantity"])
-	m.Approved = int(meta["approved"])
-	m.Rejected = int(meta["rejected"])
-	m.Pending = int(meta["pending"])
-	m.Total_amount = meta["total_amount"]
 	response := SaleResponse{
 		Metadata: m,
+		Results:  result.Results,
 	}
-	if sales != nil {
-		response.Results = sales
-	} else {
+	if response.Results == nil {
 		response.Results = make([]*sale.Sale, 0)
 	}
+	if result.NextCursor != "" {
+		response.NextCursor = &result.NextCursor
+	}
 
 	ctx.JSON(http.StatusOK, response)
 }
@@ -247,36 +264,26 @@ func (h *handler) handleUpdateSale(ctx *gin.Context) {
 	id := ctx.Param("id")
 
 	// bind partial update fields
-	var fields *sale.UpdateFields
+	var fields sale.UpdateFields
 	if err := ctx.ShouldBindJSON(&fields); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		fail(ctx, invalidBody(err))
 		return
 	}
 
-	updated_sale, err := h.saleService.Update(id, fields)
+	version, err := expectedVersion(ctx, fields.ExpectedVersion)
 	if err != nil {
-		if errors.Is(err, sale.ErrNotFound) {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-
-		if errors.Is(err, sale.ErrInvalidStatus) {
-			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
-			return
-		}
-
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(ctx, err)
 		return
 	}
+	fields.ExpectedVersion = version
 
-	/*for {
-		_, err := h.metadataService.Update(updated_sale.Status, updated_sale.UserId)
-		if err != nil {
-			continue
-		}
-		break
-	}*/
+	updated_sale, err := h.sales.Update(principal(ctx), id, &fields)
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
 
+	setETag(ctx, updated_sale.Version)
 	ctx.JSON(http.StatusOK, updated_sale)
 }
 
@@ -284,16 +291,12 @@ func (h *handler) handleUpdateSale(ctx *gin.Context) {
 func (h *handler) handleReadOneSale(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	s, err := h.saleService.Get(id)
+	s, err := h.sales.Get(principal(ctx), id)
 	if err != nil {
-		if errors.Is(err, sale.ErrNotFound) {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		fail(ctx, err)
 		return
 	}
 
+	setETag(ctx, s.Version)
 	ctx.JSON(http.StatusOK, s)
 }
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"API_VentasGO/internal/idempotency"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// idempotencyHeader is the request header that carries the idempotency key.
+const idempotencyHeader = "Idempotency-Key"
+
+// replayedHeader marks responses replayed from the idempotency store.
+const replayedHeader = "Idempotent-Replayed"
+
+// idempotent makes a POST safe to retry when the client sends an
+// Idempotency-Key header: the first request with a key runs the handler, and
+// later ones with the same key and body get the stored response back instead
+// of creating the resource again. Reusing a key with another body answers 422.
+// Requests racing on a key wait for the first one to finish.
+//
+// Server errors are not stored, so the request can be retried.
+func idempotent(store *idempotency.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(idempotencyHeader)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			fail(ctx, invalidBody(err))
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Keys are scoped to the principal and the path, so the same key
+		// may be used for a user and for a sale, or for the refunds of two
+		// sales, and clients never get the responses of each other back.
+		path := ctx.Request.URL.Path
+		key = principal(ctx).Subject + " " + path + " " + key
+		stored, err := store.Begin(ctx.Request.Context(), key, fingerprint(ctx.Request.Method, path, body))
+		if err != nil {
+			if !errors.Is(err, idempotency.ErrFingerprintMismatch) {
+				// The request was given up while waiting for the first one.
+				err = fmt.Errorf("%w: %v", errIdempotencyPending, err)
+			}
+			fail(ctx, err)
+			return
+		}
+		if stored != nil {
+			for k, v := range stored.Header {
+				ctx.Writer.Header()[k] = v
+			}
+			ctx.Header(replayedHeader, "true")
+			ctx.Data(stored.Status, stored.Header.Get("Content-Type"), stored.Body)
+			ctx.Abort()
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = w
+		completed := false
+		defer func() {
+			if !completed {
+				store.Abort(key)
+			}
+		}()
+
+		ctx.Next()
+
+		if w.Status() >= http.StatusInternalServerError {
+			return
+		}
+		// The request ID belongs to the request that got the response, so
+		// replays keep the ID of their own request.
+		header := w.Header().Clone()
+		header.Del(requestIDHeader)
+		store.Complete(key, idempotency.Response{
+			Status: w.Status(),
+			Header: header,
+			Body:   w.body.Bytes(),
+		})
+		completed = true
+	}
+}
+
+// fingerprint identifies a request by its method, path and body. JSON bodies
+// are compared by value, so whitespace and key order do not matter.
+func fingerprint(method, path string, body []byte) string {
+	var v any
+	if err := json.Unmarshal(body, &v); err == nil {
+		if canonical, err := json.Marshal(v); err == nil {
+			body = canonical
+		}
+	}
+
+	h := sha256.New()
+	io.WriteString(h, method+" "+path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter keeps a copy of the response body written through it.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
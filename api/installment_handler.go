@@ -0,0 +1,56 @@
+package api
+
+import (
+	"API_VentasGO/internal/sale"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleReadInstallments handles GET /sales/:id/installments
+func (h *handler) handleReadInstallments(ctx *gin.Context) {
+	installments, err := h.sales.Installments(principal(ctx), ctx.Param("id"))
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+	if installments == nil {
+		installments = []sale.Installment{}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"results": installments})
+}
+
+// handleUpdateInstallment handles PATCH /sales/:id/installments/:number
+// status is paid or overdue.
+func (h *handler) handleUpdateInstallment(ctx *gin.Context) {
+	number, err := strconv.Atoi(ctx.Param("number"))
+	if err != nil {
+		fail(ctx, withField("number", fmt.Errorf("%w: installment number must be an integer", errInvalidParameter)))
+		return
+	}
+
+	var fields sale.InstallmentFields
+	if err := ctx.ShouldBindJSON(&fields); err != nil {
+		fail(ctx, invalidBody(err))
+		return
+	}
+
+	version, err := expectedVersion(ctx, fields.ExpectedVersion)
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+	fields.ExpectedVersion = version
+
+	installment, updated, err := h.sales.UpdateInstallment(principal(ctx), ctx.Param("id"), number, &fields)
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	setETag(ctx, updated.Version)
+	ctx.JSON(http.StatusOK, installment)
+}
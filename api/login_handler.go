@@ -0,0 +1,143 @@
+package api
+
+import (
+	"API_VentasGO/internal/user"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// customerRole is the role of the access tokens of logged in users.
+const customerRole = "user"
+
+// tokenResponse is the answer to a login or a refresh.
+type tokenResponse struct {
+	AccessToken  string     `json:"access_token"`
+	TokenType    string     `json:"token_type"`
+	ExpiresIn    int64      `json:"expires_in"`
+	RefreshToken string     `json:"refresh_token"`
+	User         *user.User `json:"user"`
+}
+
+// issueTokens answers a new access token for u along with its refresh token.
+func (h *handler) issueTokens(ctx *gin.Context, status int, u *user.User, refresh string) {
+	access, err := h.signer.Sign(u.ID, []string{customerRole}, h.accessTTL)
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(status, tokenResponse{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(h.accessTTL / time.Second),
+		RefreshToken: refresh,
+		User:         u,
+	})
+}
+
+// handleRegister handles POST /auth/register
+// It creates a user who logs in with email and password.
+func (h *handler) handleRegister(ctx *gin.Context) {
+	var req struct {
+		Name         string `json:"name"`
+		Address      string `json:"address"`
+		NickName     string `json:"nickname"`
+		Jurisdiction string `json:"jurisdiction"`
+		Email        string `json:"email"`
+		Password     string `json:"password"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, invalidBody(err))
+		return
+	}
+
+	u := &user.User{
+		Name:         req.Name,
+		Address:      req.Address,
+		NickName:     req.NickName,
+		Jurisdiction: req.Jurisdiction,
+		Email:        req.Email,
+	}
+	if err := h.userService.Register(u, req.Password); err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	setETag(ctx, u.Version)
+	ctx.JSON(http.StatusCreated, u)
+}
+
+// handleLogin handles POST /auth/login
+// It answers an access token and a refresh token for email and password.
+// Locked accounts are answered 429 with Retry-After.
+func (h *handler) handleLogin(ctx *gin.Context) {
+	var req struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, invalidBody(err))
+		return
+	}
+
+	u, refresh, err := h.userService.Login(req.Email, req.Password)
+	if err != nil {
+		var locked *user.LockedError
+		if errors.As(err, &locked) {
+			wait := math.Ceil(time.Until(locked.Until).Seconds())
+			ctx.Header("Retry-After", strconv.Itoa(max(int(wait), 1)))
+		}
+		fail(ctx, err)
+		return
+	}
+
+	h.issueTokens(ctx, http.StatusOK, u, refresh)
+}
+
+// handleRefresh handles POST /auth/refresh
+// It rotates refresh_token, answering a new access token and the refresh
+// token that replaces it.
+func (h *handler) handleRefresh(ctx *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, invalidBody(err))
+		return
+	}
+
+	u, refresh, err := h.userService.Refresh(req.RefreshToken)
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	h.issueTokens(ctx, http.StatusOK, u, refresh)
+}
+
+// handleLogout handles POST /auth/logout
+// It revokes the session of refresh_token, or every session of its user when
+// all is true. Access tokens already issued last until they expire.
+func (h *handler) handleLogout(ctx *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+		All          bool   `json:"all"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, invalidBody(err))
+		return
+	}
+
+	if err := h.userService.Revoke(req.RefreshToken, req.All); err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,242 @@
+package api
+
+import (
+	"API_VentasGO/internal/account"
+	"API_VentasGO/internal/auth"
+	"API_VentasGO/internal/idempotency"
+	"API_VentasGO/internal/inventory"
+	"API_VentasGO/internal/metadata"
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/policy"
+	"API_VentasGO/internal/product"
+	"API_VentasGO/internal/promotion"
+	"API_VentasGO/internal/sale"
+	"API_VentasGO/internal/user"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// problemContentType is the media type of every error response (RFC 7807).
+const problemContentType = "application/problem+json"
+
+// Problem is the body of every error response: an RFC 7807 problem details
+// object extended with a machine-readable code, the fields at fault and the
+// ID of the request. Detail is the human-readable message; clients should
+// branch on Code, which does not change with the wording.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"`
+
+	// Permission is the permission a 403 is missing.
+	Permission string `json:"permission,omitempty"`
+}
+
+// FieldError names a request field, or query parameter, at fault.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors of the API itself, mapped like the domain errors.
+var (
+	errInvalidBody         = errors.New("invalid request body")
+	errInvalidParameter    = errors.New("invalid parameter")
+	errAmountFromItems     = errors.New("amount is computed from items and cannot be set")
+	errRouteNotFound       = errors.New("route not found")
+	errRateLimited         = errors.New("rate limit exceeded")
+	errQuotaExceeded       = errors.New("daily quota exceeded")
+	errTooManyAuthFailures = errors.New("too many failed authentications")
+	errIdempotencyPending  = errors.New("a request with this idempotency key is in progress")
+)
+
+// mapping is the status and code of the errors matching err.
+type mapping struct {
+	err    error
+	status int
+	code   string
+	// field, when set, is the request field the error is about.
+	field string
+}
+
+// mappings maps the errors of the domain packages to their status and code.
+// They are matched in order with errors.Is; errors matching none answer 500.
+var mappings = []mapping{
+	{policy.ErrForbidden, http.StatusForbidden, "forbidden", ""},
+	{auth.ErrMissingCredentials, http.StatusUnauthorized, "missing_credentials", ""},
+	{auth.ErrInvalidAPIKey, http.StatusUnauthorized, "invalid_api_key", ""},
+	{auth.ErrTokenExpired, http.StatusUnauthorized, "token_expired", ""},
+	{auth.ErrInvalidToken, http.StatusUnauthorized, "invalid_token", ""},
+	{errRateLimited, http.StatusTooManyRequests, "rate_limited", ""},
+	{errQuotaExceeded, http.StatusTooManyRequests, "quota_exceeded", ""},
+	{errTooManyAuthFailures, http.StatusTooManyRequests, "too_many_auth_failures", ""},
+	{errRouteNotFound, http.StatusNotFound, "route_not_found", ""},
+	{errInvalidBody, http.StatusBadRequest, "invalid_body", ""},
+	{errInvalidParameter, http.StatusBadRequest, "invalid_parameter", ""},
+	{errAmountFromItems, http.StatusBadRequest, "amount_not_allowed", "amount"},
+	{errInvalidIfMatch, http.StatusBadRequest, "invalid_if_match", ""},
+	{errConflictingVersion, http.StatusBadRequest, "conflicting_version", "expected_version"},
+	{errIdempotencyPending, http.StatusConflict, "idempotency_key_in_progress", ""},
+	{idempotency.ErrFingerprintMismatch, http.StatusUnprocessableEntity, "idempotency_key_reused", ""},
+
+	{user.ErrNotFound, http.StatusNotFound, "user_not_found", ""},
+	{user.ErrEmptyID, http.StatusBadRequest, "empty_user_id", ""},
+	{user.ErrVersionMismatch, http.StatusPreconditionFailed, "version_mismatch", ""},
+	{user.ErrDuplicateEmail, http.StatusConflict, "duplicate_email", "email"},
+	{user.ErrInvalidEmail, http.StatusBadRequest, "invalid_email", "email"},
+	{user.ErrInvalidPassword, http.StatusBadRequest, "invalid_password", "password"},
+	{user.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials", ""},
+	{user.ErrAccountLocked, http.StatusTooManyRequests, "account_locked", ""},
+	{user.ErrInvalidRefreshToken, http.StatusUnauthorized, "invalid_refresh_token", "refresh_token"},
+
+	{sale.ErrNotFound, http.StatusNotFound, "sale_not_found", ""},
+	{sale.ErrEmptyID, http.StatusBadRequest, "empty_sale_id", ""},
+	{sale.ErrVersionMismatch, http.StatusPreconditionFailed, "version_mismatch", ""},
+	{sale.ErrInvalidAmoun, http.StatusBadRequest, "invalid_amount", "amount"},
+	{sale.ErrStatusNotFound, http.StatusBadRequest, "unknown_status", "status"},
+	{sale.ErrInvalidStatus, http.StatusConflict, "invalid_status_transition", "status"},
+	{sale.ErrNotValidOperation, http.StatusBadRequest, "invalid_operation", ""},
+	{sale.ErrUserNotFound, http.StatusBadRequest, "unknown_user", "user_id"},
+	{sale.ErrProductNotFound, http.StatusBadRequest, "unknown_product", "items"},
+	{sale.ErrInvalidQuantity, http.StatusBadRequest, "invalid_quantity", "items"},
+	{sale.ErrProductInactive, http.StatusConflict, "product_inactive", "items"},
+	{sale.ErrPriceChanged, http.StatusConflict, "price_changed", "items"},
+	{sale.ErrOutOfStock, http.StatusConflict, "out_of_stock", "items"},
+	{sale.ErrCouponNotFound, http.StatusBadRequest, "unknown_coupon", "coupon_code"},
+	{sale.ErrCouponNotApplicable, http.StatusConflict, "coupon_not_applicable", "coupon_code"},
+	{sale.ErrCreditLimitExceeded, http.StatusConflict, "credit_limit_exceeded", ""},
+	{sale.ErrInvalidInstallmentPlan, http.StatusBadRequest, "invalid_installment_plan", "installment_plan"},
+	{sale.ErrInvalidPaymentMethod, http.StatusBadRequest, "invalid_payment_method", "payment_method"},
+	{sale.ErrPaymentUnavailable, http.StatusServiceUnavailable, "payment_unavailable", ""},
+	{sale.ErrInvalidSort, http.StatusBadRequest, "invalid_sort", "sort"},
+	{sale.ErrInvalidLimit, http.StatusBadRequest, "invalid_limit", "limit"},
+	{sale.ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor", "cursor"},
+	{sale.ErrEmptyRange, http.StatusBadRequest, "empty_range", ""},
+	{sale.ErrNotRefundable, http.StatusConflict, "not_refundable", ""},
+	{sale.ErrInvalidRefund, http.StatusBadRequest, "invalid_refund", "amount"},
+	{sale.ErrRefundExceedsBalance, http.StatusUnprocessableEntity, "refund_exceeds_balance", "amount"},
+	{sale.ErrInstallmentNotFound, http.StatusNotFound, "installment_not_found", ""},
+	{sale.ErrInvalidInstallmentStatus, http.StatusBadRequest, "invalid_installment_status", "status"},
+	{sale.ErrInstallmentTransition, http.StatusConflict, "invalid_installment_transition", "status"},
+	{sale.ErrInstallmentNotDue, http.StatusConflict, "installment_not_due", ""},
+	{sale.ErrInstallmentsClosed, http.StatusConflict, "installments_closed", ""},
+
+	{metadata.ErrEmptyIDMetadata, http.StatusBadRequest, "empty_user_id", "user_id"},
+	{metadata.ErrNotFoundMetadata, http.StatusNotFound, "summary_not_found", ""},
+	{metadata.ErrNotValidOperation, http.StatusBadRequest, "invalid_operation", ""},
+
+	{product.ErrNotFound, http.StatusNotFound, "product_not_found", ""},
+	{product.ErrEmptyID, http.StatusBadRequest, "empty_product_id", ""},
+	{product.ErrVersionMismatch, http.StatusPreconditionFailed, "version_mismatch", ""},
+	{product.ErrDuplicateSKU, http.StatusConflict, "duplicate_sku", "sku"},
+	{product.ErrEmptySKU, http.StatusBadRequest, "empty_sku", "sku"},
+	{product.ErrEmptyName, http.StatusBadRequest, "empty_name", "name"},
+	{product.ErrInvalidPrice, http.StatusBadRequest, "invalid_price", "price"},
+	{inventory.ErrInvalidQuantity, http.StatusBadRequest, "invalid_quantity", "quantity"},
+	{inventory.ErrInsufficientStock, http.StatusConflict, "insufficient_stock", "quantity"},
+
+	{promotion.ErrNotFound, http.StatusNotFound, "coupon_not_found", ""},
+	{promotion.ErrDuplicateCode, http.StatusConflict, "duplicate_coupon_code", "code"},
+	{promotion.ErrEmptyCode, http.StatusBadRequest, "empty_coupon_code", "code"},
+	{promotion.ErrInvalidCoupon, http.StatusBadRequest, "invalid_coupon", ""},
+
+	{account.ErrDuplicateEntry, http.StatusConflict, "duplicate_payment", "reference"},
+	{account.ErrPaymentExceedsBalance, http.StatusConflict, "payment_exceeds_balance", "amount"},
+	{account.ErrInvalidPayment, http.StatusBadRequest, "invalid_payment", "amount"},
+
+	{money.ErrCurrencyMismatch, http.StatusBadRequest, "currency_mismatch", "currency"},
+	{money.ErrUnknownCurrency, http.StatusBadRequest, "unknown_currency", "currency"},
+	{money.ErrInvalidAmount, http.StatusBadRequest, "invalid_amount", ""},
+	{money.ErrOverflow, http.StatusBadRequest, "amount_out_of_range", ""},
+}
+
+// fieldError is an error about one field of the request.
+type fieldError struct {
+	field string
+	err   error
+}
+
+func (e *fieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.field, e.err)
+}
+
+func (e *fieldError) Unwrap() error {
+	return e.err
+}
+
+// withField ties err to a field of the request, which the problem of err
+// lists. Errors that map to no status answer 400 when tied to a field.
+func withField(field string, err error) error {
+	return &fieldError{field: field, err: err}
+}
+
+// invalidBody wraps an error binding the request body in errInvalidBody,
+// naming the field when the body has a value of the wrong type.
+func invalidBody(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return withField(typeErr.Field, fmt.Errorf("%w: %v", errInvalidBody, err))
+	}
+	return fmt.Errorf("%w: %v", errInvalidBody, err)
+}
+
+// newProblem builds the problem of err. Errors matching no mapping are
+// answered 500 without their message, which may reveal internals.
+func newProblem(err error) *Problem {
+	p := &Problem{Type: "about:blank", Status: http.StatusInternalServerError, Code: "internal_error", Detail: "internal server error"}
+	field := ""
+	for _, m := range mappings {
+		if errors.Is(err, m.err) {
+			p.Status, p.Code, p.Detail, field = m.status, m.code, err.Error(), m.field
+			break
+		}
+	}
+
+	var fe *fieldError
+	var filter *sale.FilterError
+	switch {
+	case errors.As(err, &fe):
+		field = fe.field
+	case errors.As(err, &filter):
+		field = filter.Param
+	}
+	if field != "" && p.Status == http.StatusInternalServerError {
+		p.Status, p.Code, p.Detail = http.StatusBadRequest, "invalid_field", err.Error()
+	}
+	if field != "" {
+		p.Fields = []FieldError{{Field: field, Message: p.Detail}}
+	}
+
+	var denied *policy.DeniedError
+	if errors.As(err, &denied) {
+		p.Permission = denied.Permission
+	}
+
+	p.Title = http.StatusText(p.Status)
+	return p
+}
+
+// fail aborts the request with the problem of err. Server errors are
+// logged, since their detail is not answered.
+func fail(ctx *gin.Context, err error) {
+	p := newProblem(err)
+	p.Instance = ctx.Request.URL.Path
+	p.RequestID = ctx.GetString(requestIDKey)
+
+	if p.Status >= http.StatusInternalServerError {
+		requestLogger(ctx).Error("request failed", zap.Int("status", p.Status), zap.Error(err))
+	}
+
+	ctx.Header("Content-Type", problemContentType)
+	ctx.AbortWithStatusJSON(p.Status, p)
+}
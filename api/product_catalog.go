@@ -0,0 +1,34 @@
+package api
+
+import (
+	"API_VentasGO/internal/product"
+	"API_VentasGO/internal/sale"
+	"errors"
+	"fmt"
+)
+
+// productCatalog adapts product.Service to the sale.Catalog interface, so the
+// sale service prices items in-process.
+type productCatalog struct {
+	products *product.Service
+}
+
+// FindProduct returns an error wrapping sale.ErrProductNotFound if the product does not exist.
+func (c productCatalog) FindProduct(id string) (*sale.Product, error) {
+	p, err := c.products.Get(id)
+	if err != nil {
+		if errors.Is(err, product.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", sale.ErrProductNotFound, id)
+		}
+		return nil, err
+	}
+
+	return &sale.Product{
+		ID:       p.ID,
+		SKU:      p.SKU,
+		Name:     p.Name,
+		Category: p.Category,
+		Price:    p.Price,
+		Active:   p.Active,
+	}, nil
+}
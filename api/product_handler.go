@@ -0,0 +1,155 @@
+package api
+
+import (
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/product"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleCreateProduct handles POST /products
+func (h *handler) handleCreateProduct(ctx *gin.Context) {
+	// request payload
+	// price accepts every form of money.FromJSON; plain decimals are read
+	// in currency, which defaults to money.DefaultCurrency. Products are
+	// active unless active is false. category picks the IVA rate of the
+	// product.
+	var req struct {
+		SKU      string          `json:"sku"`
+		Name     string          `json:"name"`
+		Category string          `json:"category"`
+		Price    json.RawMessage `json:"price"`
+		Currency string          `json:"currency"`
+		Active   *bool           `json:"active"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, invalidBody(err))
+		return
+	}
+	price, err := money.FromJSON(req.Price, req.Currency)
+	if err != nil {
+		fail(ctx, withField("price", err))
+		return
+	}
+
+	p := &product.Product{
+		SKU:      req.SKU,
+		Name:     req.Name,
+		Category: req.Category,
+		Price:    price,
+		Active:   req.Active == nil || *req.Active,
+	}
+	if err := h.productService.Create(p); err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	setETag(ctx, p.Version)
+	ctx.JSON(http.StatusCreated, p)
+}
+
+// handleReadProduct handles GET /products/:id
+func (h *handler) handleReadProduct(ctx *gin.Context) {
+	p, err := h.productService.Get(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	setETag(ctx, p.Version)
+	ctx.JSON(http.StatusOK, p)
+}
+
+// handleUpdateProduct handles PATCH /products/:id
+func (h *handler) handleUpdateProduct(ctx *gin.Context) {
+	// bind partial update fields; price is read like in handleCreateProduct
+	var req struct {
+		product.UpdateFields
+		Price    json.RawMessage `json:"price"`
+		Currency string          `json:"currency"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, invalidBody(err))
+		return
+	}
+	fields := &req.UpdateFields
+	if len(req.Price) > 0 {
+		price, err := money.FromJSON(req.Price, req.Currency)
+		if err != nil {
+			fail(ctx, withField("price", err))
+			return
+		}
+		fields.Price = &price
+	}
+
+	version, err := expectedVersion(ctx, fields.ExpectedVersion)
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+	fields.ExpectedVersion = version
+
+	p, err := h.productService.Update(ctx.Param("id"), fields)
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	setETag(ctx, p.Version)
+	ctx.JSON(http.StatusOK, p)
+}
+
+// handleDeleteProduct handles DELETE /products/:id
+func (h *handler) handleDeleteProduct(ctx *gin.Context) {
+	if err := h.productService.Delete(ctx.Param("id")); err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// handleReadStock handles GET /products/:id/stock
+// It answers the stock level of the product and its movement ledger.
+func (h *handler) handleReadStock(ctx *gin.Context) {
+	p, err := h.productService.Get(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"stock":     h.inventoryService.Stock(p.ID),
+		"movements": h.inventoryService.Ledger(p.ID),
+	})
+}
+
+// handleAdjustStock handles POST /products/:id/stock
+// quantity is added to the stock on hand, and may be negative to write
+// stock off; reason is kept in the ledger.
+func (h *handler) handleAdjustStock(ctx *gin.Context) {
+	var req struct {
+		Quantity int64  `json:"quantity"`
+		Reason   string `json:"reason"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, invalidBody(err))
+		return
+	}
+
+	p, err := h.productService.Get(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	stock, err := h.inventoryService.Adjust(p.ID, req.Quantity, req.Reason)
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, stock)
+}
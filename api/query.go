@@ -0,0 +1,110 @@
+package api
+
+import (
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/sale"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dateLayout is the date-only format accepted by the date range filters.
+const dateLayout = "2006-01-02"
+
+// parseSalesQuery reads the filters and the page of GET /sales.
+// Returns a *sale.FilterError naming the parameter that failed to parse.
+func parseSalesQuery(ctx *gin.Context) (sale.Criteria, sale.Page, error) {
+	criteria := sale.Criteria{UserId: ctx.Query("user_id")}
+	page := sale.Page{
+		Sort:   ctx.Query("sort"),
+		Cursor: ctx.Query("cursor"),
+	}
+
+	for _, status := range ctx.QueryArray("status") {
+		criteria.Statuses = append(criteria.Statuses, strings.ToLower(status))
+	}
+
+	var err error
+	if criteria.CreatedFrom, err = parseTime(ctx, "created_from", false); err != nil {
+		return criteria, page, err
+	}
+	if criteria.CreatedTo, err = parseTime(ctx, "created_to", true); err != nil {
+		return criteria, page, err
+	}
+	if criteria.UpdatedFrom, err = parseTime(ctx, "updated_from", false); err != nil {
+		return criteria, page, err
+	}
+	if criteria.UpdatedTo, err = parseTime(ctx, "updated_to", true); err != nil {
+		return criteria, page, err
+	}
+
+	currency := ctx.Query("currency")
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	if criteria.MinAmount, err = parseAmount(ctx, "min_amount", currency); err != nil {
+		return criteria, page, err
+	}
+	if criteria.MaxAmount, err = parseAmount(ctx, "max_amount", currency); err != nil {
+		return criteria, page, err
+	}
+
+	switch order := strings.ToLower(ctx.Query("order")); order {
+	case "", "asc":
+	case "desc":
+		page.Desc = true
+	default:
+		return criteria, page, &sale.FilterError{Param: "order", Err: errors.New("must be asc or desc")}
+	}
+
+	if limit := ctx.Query("limit"); limit != "" {
+		if page.Limit, err = strconv.Atoi(limit); err != nil {
+			return criteria, page, &sale.FilterError{Param: "limit", Err: sale.ErrInvalidLimit}
+		}
+	}
+
+	return criteria, page, nil
+}
+
+// parseTime reads an RFC 3339 timestamp or a date from the query parameter
+// name. Upper bounds are inclusive for clients and converted to the
+// exclusive bounds of sale.Criteria: a date covers the whole day.
+func parseTime(ctx *gin.Context, name string, upper bool) (time.Time, error) {
+	v := ctx.Query(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		if upper {
+			t = t.Add(time.Nanosecond)
+		}
+		return t, nil
+	}
+
+	t, err := time.Parse(dateLayout, v)
+	if err != nil {
+		return time.Time{}, &sale.FilterError{Param: name, Err: errors.New("must be an RFC 3339 timestamp or a YYYY-MM-DD date")}
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// parseAmount reads a decimal amount of currency from the query parameter name.
+func parseAmount(ctx *gin.Context, name string, currency string) (*money.Money, error) {
+	v := ctx.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+
+	m, err := money.Parse(v, currency)
+	if err != nil {
+		return nil, &sale.FilterError{Param: name, Err: err}
+	}
+	return &m, nil
+}
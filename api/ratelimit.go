@@ -0,0 +1,110 @@
+package api
+
+import (
+	"API_VentasGO/internal/auth"
+	"API_VentasGO/internal/ratelimit"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// rateLimit answers 429 with Retry-After to clients over the limit of the
+// route or over their daily quota, and tells them what is left of the
+// bucket of the route in the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers. Limiter errors are logged and let the request
+// through, so a shared backend that is down does not take the API with it.
+func rateLimit(l ratelimit.Limiter, cfg *ratelimit.Config, logger *zap.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		client := clientKey(ctx)
+
+		if limit, bucket, ok := cfg.Limit(ctx.Request.Method + " " + ctx.FullPath()); ok {
+			d, err := l.Allow(client+" "+bucket, limit)
+			if err != nil {
+				logger.Error("failed to check rate limit", zap.String("client", client), zap.Error(err))
+			} else {
+				setRateLimit(ctx, d)
+				if !d.Allowed {
+					tooManyRequests(ctx, d, errRateLimited)
+					return
+				}
+			}
+		}
+
+		if quota := cfg.Quota(client); quota > 0 {
+			d, err := l.Consume(client, quota)
+			if err != nil {
+				logger.Error("failed to check daily quota", zap.String("client", client), zap.Error(err))
+			} else if !d.Allowed {
+				setRateLimit(ctx, d)
+				tooManyRequests(ctx, d, errQuotaExceeded)
+				return
+			}
+		}
+
+		ctx.Next()
+	}
+}
+
+// throttleAuthFailures answers 429 with Retry-After to the IP addresses that
+// failed authentication more often than limit allows, before their
+// credentials are checked, and counts every 401 against the IP that got it.
+// It runs before authenticate, so API keys, tokens and passwords cannot be
+// guessed faster than limit. Limiter errors are logged and let the request
+// through, like in rateLimit.
+func throttleAuthFailures(l ratelimit.Limiter, limit ratelimit.Limit) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := "ip:" + ctx.ClientIP() + " auth_failures"
+
+		d, err := l.Peek(key, limit)
+		if err != nil {
+			requestLogger(ctx).Error("failed to check authentication failures", zap.Error(err))
+		} else if !d.Allowed {
+			tooManyRequests(ctx, d, errTooManyAuthFailures)
+			return
+		}
+
+		ctx.Next()
+
+		if ctx.Writer.Status() == http.StatusUnauthorized {
+			if _, err := l.Allow(key, limit); err != nil {
+				requestLogger(ctx).Error("failed to count authentication failure", zap.Error(err))
+			}
+		}
+	}
+}
+
+// clientKey names the client of a request for rate limiting: its API key,
+// its user, or else its IP address.
+func clientKey(ctx *gin.Context) string {
+	p := principal(ctx)
+	switch p.Method {
+	case auth.MethodAPIKey:
+		return "key:" + p.Subject
+	case auth.MethodJWT:
+		return "user:" + p.Subject
+	default:
+		return "ip:" + ctx.ClientIP()
+	}
+}
+
+// setRateLimit sets the RateLimit-* headers of a decision.
+func setRateLimit(ctx *gin.Context, d ratelimit.Decision) {
+	ctx.Header("RateLimit-Limit", strconv.FormatInt(d.Limit, 10))
+	ctx.Header("RateLimit-Remaining", strconv.FormatInt(d.Remaining, 10))
+	ctx.Header("RateLimit-Reset", strconv.FormatInt(ceilSeconds(d.Reset), 10))
+}
+
+// tooManyRequests aborts the request with 429 and the Retry-After of d.
+func tooManyRequests(ctx *gin.Context, d ratelimit.Decision, err error) {
+	ctx.Header("Retry-After", strconv.FormatInt(max(ceilSeconds(d.RetryAfter), 1), 10))
+	fail(ctx, err)
+}
+
+// ceilSeconds rounds d up to whole seconds.
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
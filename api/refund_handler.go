@@ -0,0 +1,78 @@
+package api
+
+import (
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/sale"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleCreateRefund handles POST /sales/:id/refunds
+// amount accepts every form of money.FromJSON, with plain decimals read in
+// currency or else in the currency of the sale. Without amount, everything
+// left on the sale is refunded.
+func (h *handler) handleCreateRefund(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	var req struct {
+		Amount          json.RawMessage `json:"amount"`
+		Currency        string          `json:"currency"`
+		Reason          string          `json:"reason"`
+		ExpectedVersion *int            `json:"expected_version"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, invalidBody(err))
+		return
+	}
+
+	fields := &sale.RefundFields{Reason: req.Reason}
+	if len(req.Amount) > 0 {
+		currency := req.Currency
+		if currency == "" {
+			s, err := h.sales.Get(principal(ctx), id)
+			if err != nil {
+				fail(ctx, err)
+				return
+			}
+			currency = s.Amount.Currency
+		}
+		amount, err := money.FromJSON(req.Amount, currency)
+		if err != nil {
+			fail(ctx, withField("amount", err))
+			return
+		}
+		fields.Amount = &amount
+	}
+
+	version, err := expectedVersion(ctx, req.ExpectedVersion)
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+	fields.ExpectedVersion = version
+
+	refund, updated, err := h.sales.Refund(principal(ctx), id, fields)
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+
+	setETag(ctx, updated.Version)
+	ctx.JSON(http.StatusCreated, refund)
+}
+
+// handleReadRefunds handles GET /sales/:id/refunds
+func (h *handler) handleReadRefunds(ctx *gin.Context) {
+	refunds, err := h.sales.Refunds(principal(ctx), ctx.Param("id"))
+	if err != nil {
+		fail(ctx, err)
+		return
+	}
+	if refunds == nil {
+		refunds = []sale.Refund{}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"results": refunds})
+}
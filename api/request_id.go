@@ -0,0 +1,50 @@
+package api
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// requestIDHeader carries the ID of a request, in both directions.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDKey and loggerKey are the gin context keys of the request ID and
+// of the logger of the request.
+const (
+	requestIDKey = "request_id"
+	loggerKey    = "logger"
+)
+
+// validRequestID matches the client request IDs that are kept. Others are
+// replaced, so they cannot forge log lines or bloat them.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestID gives every request an ID, taken from X-Request-ID when the
+// client sent a valid one and generated otherwise. The ID is echoed in the
+// X-Request-ID response header, in error responses and in the logs of the
+// request.
+func requestID(logger *zap.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+
+		ctx.Set(requestIDKey, id)
+		ctx.Set(loggerKey, logger.With(zap.String("request_id", id)))
+		ctx.Header(requestIDHeader, id)
+		ctx.Next()
+	}
+}
+
+// requestLogger returns the logger of the request, which tags its entries
+// with the request ID.
+func requestLogger(ctx *gin.Context) *zap.Logger {
+	if logger, ok := ctx.Get(loggerKey); ok {
+		return logger.(*zap.Logger)
+	}
+	return zap.NewNop()
+}
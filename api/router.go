@@ -1,38 +1,195 @@
 package api
 
 import (
+	"API_VentasGO/internal/account"
+	"API_VentasGO/internal/idempotency"
+	"API_VentasGO/internal/inventory"
 	"API_VentasGO/internal/metadata"
+	"API_VentasGO/internal/policy"
+	"API_VentasGO/internal/product"
+	"API_VentasGO/internal/promotion"
+	"API_VentasGO/internal/ratelimit"
 	"API_VentasGO/internal/sale"
 	"API_VentasGO/internal/user"
+	"fmt"
+	"path/filepath"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 // InitRoutes registers all user CRUD endpoints on the given Gin engine.
 // It initializes the storage, service, and handler, then binds each HTTP
 // method and path to the appropriate handler function.
-func InitRoutes(e *gin.Engine) {
-	userStorage := user.NewLocalStorage()
+// Storages are durable when DATA_DIR is set, and every route requires the
+// credentials configured by the AUTH_* variables; see loadConfig. What each
+// principal may do is decided by the roles of AUTH_POLICY: user and sale
+// operations go through the policy wrappers, and the other routes check
+// their permission before the handler runs. The /auth routes, served when
+// AUTH_JWT_SECRET is set, are public: they register users and log them in.
+// Clients are rate limited per RATE_LIMITS once authenticated, and IP
+// addresses that keep failing authentication are throttled before. Every
+// request gets an ID, and every error is answered as application/problem+json;
+// see Problem.
+func InitRoutes(e *gin.Engine) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := e.SetTrustedProxies(cfg.trustedProxies); err != nil {
+		return fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+
+	var userStorage user.Storage = user.NewLocalStorage()
+	var saleStorage sale.Storage = sale.NewLocalStorage()
+	var productStorage product.Storage = product.NewLocalStorage()
+	var inventoryStorage inventory.Storage = inventory.NewLocalStorage()
+	var promotionStorage promotion.Storage = promotion.NewLocalStorage()
+	var accountStorage account.Storage = account.NewLocalStorage()
+	if cfg.dataDir != "" {
+		users, err := user.NewFileStorage(filepath.Join(cfg.dataDir, "users"), cfg.wal)
+		if err != nil {
+			return err
+		}
+		sales, err := sale.NewFileStorage(filepath.Join(cfg.dataDir, "sales"), cfg.wal)
+		if err != nil {
+			users.Close()
+			return err
+		}
+		products, err := product.NewFileStorage(filepath.Join(cfg.dataDir, "products"), cfg.wal)
+		if err != nil {
+			users.Close()
+			sales.Close()
+			return err
+		}
+		stock, err := inventory.NewFileStorage(filepath.Join(cfg.dataDir, "inventory"), cfg.wal)
+		if err != nil {
+			users.Close()
+			sales.Close()
+			products.Close()
+			return err
+		}
+		coupons, err := promotion.NewFileStorage(filepath.Join(cfg.dataDir, "promotions"), cfg.wal)
+		if err != nil {
+			users.Close()
+			sales.Close()
+			products.Close()
+			stock.Close()
+			return err
+		}
+		accounts, err := account.NewFileStorage(filepath.Join(cfg.dataDir, "accounts"), cfg.wal)
+		if err != nil {
+			users.Close()
+			sales.Close()
+			products.Close()
+			stock.Close()
+			coupons.Close()
+			return err
+		}
+		userStorage, saleStorage, productStorage, inventoryStorage, promotionStorage, accountStorage = users, sales, products, stock, coupons, accounts
+	}
+
 	userService := user.NewService(userStorage, nil)
-	saleStorage := sale.NewLocalStorage()
-	saleService := sale.NewService(saleStorage, nil, nil)
+	userService.SetLoginOptions(cfg.login)
+	productService := product.NewService(productStorage, nil)
+	inventoryService := inventory.NewService(inventoryStorage, nil)
+	promotionService := promotion.NewService(promotionStorage, nil)
+	accountService := account.NewService(accountStorage, cfg.creditLimit, nil)
+	var taxes sale.TaxEngine
+	if cfg.taxRules != nil {
+		taxes = taxEngine{rules: cfg.taxRules, users: userService}
+	}
+	saleService := sale.NewService(saleStorage, userFinder{users: userService}, productCatalog{products: productService}, stockKeeper{inventory: inventoryService}, taxes, couponRedeemer{promotions: promotionService}, creditAccounts{accounts: accountService}, cfg.payment, nil)
 	metadataStorage := metadata.NewLocalStorage()
-	metadataService := metadata.NewService(metadataStorage)
+	metadataService := metadata.NewService(metadataStorage, saleService)
+	saleService.AddObserver(metadataService)
+	if cfg.reconcileEvery > 0 {
+		go reconcileSummaries(metadataService, saleService.Logger, cfg.reconcileEvery)
+	}
+
+	if cfg.auth == nil {
+		saleService.Logger.Warn("authentication is disabled: every endpoint is open")
+	}
+	limiter := ratelimit.NewMemory()
+	failures := ratelimit.DefaultAuthFailures
+	if cfg.rateLimits != nil {
+		failures = cfg.rateLimits.AuthFailures
+	}
+	e.Use(requestID(saleService.Logger))
+	e.Use(throttleAuthFailures(limiter, failures))
+	e.Use(authenticate(cfg.auth, "/auth/register", "/auth/login", "/auth/refresh", "/auth/logout"))
+	if cfg.rateLimits != nil {
+		e.Use(rateLimit(limiter, cfg.rateLimits, saleService.Logger))
+	}
 
+	access := cfg.policy
 	h := handler{
-		userService:     userService,
-		saleService:     saleService,
-		metadataService: metadataService,
+		users:            policy.NewUsers(userService, access),
+		sales:            policy.NewSales(saleService, access),
+		userService:      userService,
+		saleService:      saleService,
+		productService:   productService,
+		inventoryService: inventoryService,
+		metadataService:  metadataService,
+		promotionService: promotionService,
+		accountService:   accountService,
+		signer:           cfg.signer,
+		accessTTL:        cfg.accessTTL,
+	}
+
+	idempotencyStore := idempotency.NewStore(cfg.idempotencyTTL)
+
+	e.NoRoute(func(ctx *gin.Context) { fail(ctx, errRouteNotFound) })
+
+	if cfg.signer != nil {
+		e.POST("/auth/register", h.handleRegister)
+		e.POST("/auth/login", h.handleLogin)
+		e.POST("/auth/refresh", h.handleRefresh)
+		e.POST("/auth/logout", h.handleLogout)
+	} else {
+		saleService.Logger.Info("user logins are disabled: set AUTH_JWT_SECRET to enable them")
 	}
 
-	e.POST("/users", h.handleCreateUser)
+	e.POST("/users", idempotent(idempotencyStore), h.handleCreateUser)
 	e.GET("/users/:id", h.handleReadUser)
 	e.PATCH("/users/:id", h.handleUpdateUser)
 	e.DELETE("/users/:id", h.handleDeleteUser)
+	e.GET("/users/:id/account", authorizeOwner(access, policy.AccountsRead, "id"), h.handleReadAccount)
+	e.POST("/users/:id/payments", authorize(access, policy.AccountsPay), idempotent(idempotencyStore), h.handleCreatePayment)
 
-	e.POST("/sales", h.handleCreateSale)
+	e.POST("/products", authorize(access, policy.ProductsWrite), h.handleCreateProduct)
+	e.GET("/products/:id", authorize(access, policy.ProductsRead), h.handleReadProduct)
+	e.PATCH("/products/:id", authorize(access, policy.ProductsWrite), h.handleUpdateProduct)
+	e.DELETE("/products/:id", authorize(access, policy.ProductsWrite), h.handleDeleteProduct)
+	e.GET("/products/:id/stock", authorize(access, policy.StockRead), h.handleReadStock)
+	e.POST("/products/:id/stock", authorize(access, policy.StockAdjust), h.handleAdjustStock)
+
+	e.POST("/coupons", authorize(access, policy.CouponsWrite), h.handleCreateCoupon)
+	e.GET("/coupons/:code", authorize(access, policy.CouponsRead), h.handleReadCoupon)
+
+	e.POST("/sales", idempotent(idempotencyStore), h.handleCreateSale)
 	e.GET("/sales", h.handleReadSale)
 	e.PATCH("/sales/:id", h.handleUpdateSale)
 	e.GET("/sales/:id", h.handleReadOneSale)
+	e.POST("/sales/:id/refunds", idempotent(idempotencyStore), h.handleCreateRefund)
+	e.GET("/sales/:id/refunds", h.handleReadRefunds)
+	e.GET("/sales/:id/installments", h.handleReadInstallments)
+	e.PATCH("/sales/:id/installments/:number", h.handleUpdateInstallment)
 
+	return nil
+}
+
+// reconcileSummaries periodically repairs the sales summaries that drifted
+// from the raw sales, logging every repair.
+func reconcileSummaries(s *metadata.Service, logger *zap.Logger, every time.Duration) {
+	for range time.Tick(every) {
+		drifts, err := s.ReconcileAll()
+		if err != nil {
+			logger.Error("failed to reconcile sales summaries", zap.Error(err))
+		}
+		for _, d := range drifts {
+			logger.Warn("repaired sales summary drift", zap.String("user_id", d.UserId), zap.Any("stored", d.Stored), zap.Any("expected", d.Expected))
+		}
+	}
 }
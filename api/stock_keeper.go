@@ -0,0 +1,38 @@
+package api
+
+import (
+	"API_VentasGO/internal/inventory"
+	"API_VentasGO/internal/sale"
+	"errors"
+	"fmt"
+)
+
+// stockKeeper adapts inventory.Service to the sale.Inventory interface, so
+// the sale service reserves stock in-process.
+type stockKeeper struct {
+	inventory *inventory.Service
+}
+
+// Reserve returns an error wrapping sale.ErrOutOfStock if an item is not available.
+func (k stockKeeper) Reserve(saleID string, items []sale.Item) error {
+	lines := make([]inventory.Line, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, inventory.Line{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+
+	if err := k.inventory.Reserve(saleID, lines); err != nil {
+		if errors.Is(err, inventory.ErrInsufficientStock) {
+			return fmt.Errorf("%w: %v", sale.ErrOutOfStock, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (k stockKeeper) Commit(saleID string) error {
+	return k.inventory.Commit(saleID)
+}
+
+func (k stockKeeper) Release(saleID string) error {
+	return k.inventory.Release(saleID)
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"API_VentasGO/internal/sale"
+	"API_VentasGO/internal/tax"
+	"API_VentasGO/internal/user"
+	"errors"
+	"fmt"
+)
+
+// taxEngine adapts tax.Rules to the sale.TaxEngine interface, taking the
+// jurisdiction of the buyer from user.Service.
+type taxEngine struct {
+	rules *tax.Rules
+	users *user.Service
+}
+
+// Compute returns an error wrapping sale.ErrUserNotFound if the buyer does not exist.
+func (e taxEngine) Compute(userID string, lines []tax.Line) ([]tax.TaxLine, error) {
+	u, err := e.users.Get(userID)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", sale.ErrUserNotFound, userID)
+		}
+		return nil, err
+	}
+
+	return e.rules.Compute(lines, u.Jurisdiction)
+}
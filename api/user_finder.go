@@ -0,0 +1,25 @@
+package api
+
+import (
+	"API_VentasGO/internal/sale"
+	"API_VentasGO/internal/user"
+	"errors"
+	"fmt"
+)
+
+// userFinder adapts user.Service to the sale.UserService interface, so the
+// sale service checks users in-process.
+type userFinder struct {
+	users *user.Service
+}
+
+// FindUser returns an error wrapping sale.ErrUserNotFound if the user does not exist.
+func (f userFinder) FindUser(id string) error {
+	if _, err := f.users.Get(id); err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return fmt.Errorf("%w: %s", sale.ErrUserNotFound, id)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package account
+
+import (
+	"API_VentasGO/internal/money"
+	"slices"
+	"time"
+)
+
+// Entry kinds.
+const (
+	// KindCharge is an approved sale charged to the account.
+	KindCharge = "charge"
+
+	// KindPayment is a payment made by the user.
+	KindPayment = "payment"
+
+	// KindRefund gives back a refund of a charged sale.
+	KindRefund = "refund"
+
+	// KindReversal takes back a charge whose sale could not be stored.
+	KindReversal = "reversal"
+)
+
+// Entry is an immutable movement of an account. Amount is positive for what
+// the user owes more, charges, and negative for what they owe less.
+// Reference is the sale of charges and reversals, the refund of refunds, and
+// an optional reference of the payer for payments; no two entries of an
+// account share a kind and reference.
+type Entry struct {
+	ID        string      `json:"id"`
+	Kind      string      `json:"kind"`
+	Reference string      `json:"reference,omitempty"`
+	Amount    money.Money `json:"amount"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// Account is the statement of a user. Balance is what the user owes, the sum
+// of the amounts of its Entries, and Available what they can still buy on
+// credit below Limit.
+type Account struct {
+	UserID    string      `json:"user_id"`
+	Limit     money.Money `json:"limit"`
+	Balance   money.Money `json:"balance"`
+	Available money.Money `json:"available"`
+	Entries   []Entry     `json:"entries"`
+}
+
+// ledger is the list of entries of an account and their running balance.
+type ledger struct {
+	entries []Entry
+	balance money.Money
+}
+
+// clone returns a copy of the ledger entries.
+func (l *ledger) clone() []Entry {
+	return slices.Clone(l.entries)
+}
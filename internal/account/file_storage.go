@@ -0,0 +1,116 @@
+package account
+
+import (
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/wal"
+	"encoding/json"
+	"sync"
+)
+
+// record is a single entry of the accounts write-ahead log.
+type record struct {
+	UserID string `json:"user_id"`
+	Entry  Entry  `json:"entry"`
+}
+
+// FileStorage is a durable Storage backed by a write-ahead log.
+// Every entry is checked against memory, appended to the log and then
+// applied in memory; the log is periodically compacted into a snapshot, and
+// both are replayed when the storage is opened again. Reads are served from
+// memory.
+type FileStorage struct {
+	// mu serializes writes so the log order matches the memory order, and
+	// nothing changes between checking an entry and appending it.
+	mu  sync.Mutex
+	mem *LocalStorage
+	log *wal.Log
+}
+
+// NewFileStorage opens the accounts stored in dir, replaying the snapshot and
+// the log to restore the state left by the previous run.
+func NewFileStorage(dir string, opts wal.Options) (*FileStorage, error) {
+	log, err := wal.Open(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FileStorage{
+		mem: NewLocalStorage(),
+		log: log,
+	}
+
+	if err := log.Replay(f.loadSnapshot, f.apply); err != nil {
+		log.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// loadSnapshot restores the entries of every account stored in a snapshot.
+func (f *FileStorage) loadSnapshot(data []byte) error {
+	var accounts map[string][]Entry
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return err
+	}
+	for userID, entries := range accounts {
+		for _, e := range entries {
+			if err := f.mem.Append(userID, e, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// apply replays a single log record over the in-memory state. Entries were
+// checked when they were written, so they are not checked again.
+func (f *FileStorage) apply(data []byte) error {
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return err
+	}
+	return f.mem.Append(r.UserID, r.Entry, nil)
+}
+
+// write appends r to the log, applies it in memory and compacts the log
+// when it grew past the configured threshold.
+func (f *FileStorage) write(r record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := f.log.Append(data); err != nil {
+		return err
+	}
+	if err := f.apply(data); err != nil {
+		return err
+	}
+
+	f.log.Compact(func() ([]byte, error) {
+		return json.Marshal(f.mem.all())
+	})
+	return nil
+}
+
+// Append durably adds an entry to the account of a user.
+// It fails like LocalStorage.Append, without writing anything.
+func (f *FileStorage) Append(userID string, entry Entry, check Check) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.mem.append(userID, entry, check, true); err != nil {
+		return err
+	}
+	return f.write(record{UserID: userID, Entry: entry})
+}
+
+// Entries returns the entries of the account of a user and its balance.
+func (f *FileStorage) Entries(userID string) ([]Entry, money.Money) {
+	return f.mem.Entries(userID)
+}
+
+// Close flushes and closes the underlying log.
+func (f *FileStorage) Close() error {
+	return f.log.Close()
+}
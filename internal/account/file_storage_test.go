@@ -0,0 +1,82 @@
+package account
+
+import (
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/wal"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStorage_SurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	opts := wal.Options{Sync: wal.SyncAlways, SnapshotEvery: 2}
+	limit := money.New(100000, "ARS")
+
+	storage, err := NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	s := NewService(storage, limit, nil)
+
+	_, err = s.Charge("ana", "sale-1", money.New(70000, "ARS"))
+	require.NoError(t, err)
+	_, err = s.Charge("ana", "sale-2", money.New(40000, "ARS"))
+	require.ErrorIs(t, err, ErrCreditLimitExceeded)
+	_, err = s.Pay("ana", money.New(20000, "ARS"), "")
+	require.NoError(t, err)
+	_, err = s.Charge("beto", "sale-3", money.New(5000, "ARS"))
+	require.NoError(t, err)
+	require.NoError(t, storage.Close())
+
+	storage, err = NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	defer storage.Close()
+	s = NewService(storage, limit, nil)
+
+	got := s.Get("ana")
+	require.Equal(t, money.New(50000, "ARS"), got.Balance)
+	require.Len(t, got.Entries, 2)
+	require.Equal(t, money.New(5000, "ARS"), s.Get("beto").Balance)
+
+	_, err = s.Charge("ana", "sale-1", money.New(1, "ARS"))
+	require.ErrorIs(t, err, ErrDuplicateEntry)
+	_, err = s.Charge("ana", "sale-4", money.New(50001, "ARS"))
+	require.ErrorIs(t, err, ErrCreditLimitExceeded)
+}
+
+func TestFileStorage_SkipsRecordsCoveredBySnapshot(t *testing.T) {
+	dir := t.TempDir()
+	opts := wal.Options{Sync: wal.SyncAlways, SnapshotEvery: 3}
+	limit := money.New(100000, "ARS")
+
+	storage, err := NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	s := NewService(storage, limit, nil)
+
+	_, err = s.Charge("ana", "sale-1", money.New(70000, "ARS"))
+	require.NoError(t, err)
+	_, err = s.Pay("ana", money.New(20000, "ARS"), "")
+	require.NoError(t, err)
+
+	// Put the log back after the write that compacts it, as if a crash had
+	// interrupted the compaction before it emptied the log.
+	path := filepath.Join(dir, "wal.log")
+	log, err := os.ReadFile(path)
+	require.NoError(t, err)
+	_, err = s.Charge("beto", "sale-2", money.New(5000, "ARS"))
+	require.NoError(t, err)
+	require.NoError(t, storage.Close())
+	require.NoError(t, os.WriteFile(path, log, 0o644))
+
+	storage, err = NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	defer storage.Close()
+	s = NewService(storage, limit, nil)
+
+	// The payment without a reference is not counted twice.
+	got := s.Get("ana")
+	require.Equal(t, money.New(50000, "ARS"), got.Balance)
+	require.Len(t, got.Entries, 2)
+	require.Equal(t, money.New(5000, "ARS"), s.Get("beto").Balance)
+}
@@ -0,0 +1,157 @@
+package account
+
+import (
+	"API_VentasGO/internal/money"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrCreditLimitExceeded is returned when a charge would take the balance of
+// an account past its credit limit.
+var ErrCreditLimitExceeded = errors.New("credit limit exceeded")
+
+// ErrInvalidPayment is returned when a payment amount is not greater than 0.
+var ErrInvalidPayment = errors.New("payment amount must be greater than 0")
+
+// ErrPaymentExceedsBalance is returned when a payment is larger than the
+// balance of the account.
+var ErrPaymentExceedsBalance = errors.New("payment exceeds the account balance")
+
+// Service provides credit account operations on a Storage backend. Every
+// account has the same credit limit, and is kept in its currency.
+type Service struct {
+	// storage is the underlying persistence for account entries.
+	storage Storage
+
+	// limit is the most a user may owe.
+	limit money.Money
+
+	// logger is our observability component to log.
+	logger *zap.Logger
+}
+
+// NewService creates a new Service whose accounts may owe up to limit.
+func NewService(storage Storage, limit money.Money, logger *zap.Logger) *Service {
+	if logger == nil {
+		logger, _ = zap.NewProduction()
+		defer logger.Sync() // flushes buffer, if any
+	}
+
+	return &Service{
+		storage: storage,
+		limit:   limit,
+		logger:  logger,
+	}
+}
+
+// Get returns the account of a user with its statement, oldest entry first.
+// Users who never bought on credit have an empty account.
+func (s *Service) Get(userID string) *Account {
+	entries, balance := s.storage.Entries(userID)
+	if balance.Currency == "" {
+		balance.Currency = s.limit.Currency
+	}
+	if entries == nil {
+		entries = []Entry{}
+	}
+
+	available, err := s.limit.Sub(balance)
+	if err != nil || available.Minor < 0 {
+		available = money.Money{Currency: s.limit.Currency}
+	}
+
+	return &Account{
+		UserID:    userID,
+		Limit:     s.limit,
+		Balance:   balance,
+		Available: available,
+		Entries:   entries,
+	}
+}
+
+// Charge debits a sale to the account of a user.
+// Returns ErrCreditLimitExceeded if the balance would go past the credit
+// limit, money.ErrCurrencyMismatch for amounts in another currency, and
+// ErrDuplicateEntry if the sale was already charged.
+func (s *Service) Charge(userID, saleID string, amount money.Money) (*Entry, error) {
+	return s.append(userID, KindCharge, saleID, amount, func(balance money.Money) error {
+		after, err := balance.Add(amount)
+		if err != nil {
+			return err
+		}
+		cmp, err := after.Cmp(s.limit)
+		if err != nil {
+			return err
+		}
+		if cmp > 0 {
+			available, _ := s.limit.Sub(balance)
+			return fmt.Errorf("%w: %s available", ErrCreditLimitExceeded, available)
+		}
+		return nil
+	})
+}
+
+// Pay credits a payment of the user to their account. reference is optional,
+// and a payment with the same reference is only recorded once.
+// Returns ErrInvalidPayment for amounts that are not positive,
+// ErrPaymentExceedsBalance if the user owes less than amount,
+// money.ErrCurrencyMismatch for amounts in another currency, and
+// ErrDuplicateEntry for repeated references.
+func (s *Service) Pay(userID string, amount money.Money, reference string) (*Entry, error) {
+	if !amount.IsPositive() {
+		return nil, ErrInvalidPayment
+	}
+	return s.append(userID, KindPayment, reference, negate(amount), func(balance money.Money) error {
+		cmp, err := amount.Cmp(balance)
+		if err != nil {
+			return err
+		}
+		if cmp > 0 {
+			return fmt.Errorf("%w: %s owed", ErrPaymentExceedsBalance, balance)
+		}
+		return nil
+	})
+}
+
+// Refund credits a refund of a charged sale to the account of a user.
+// Returns ErrDuplicateEntry if the refund was already credited.
+func (s *Service) Refund(userID, refundID string, amount money.Money) (*Entry, error) {
+	return s.append(userID, KindRefund, refundID, negate(amount), nil)
+}
+
+// Reverse takes back the charge of a sale that could not be stored.
+// Returns ErrDuplicateEntry if the charge was already reversed.
+func (s *Service) Reverse(userID, saleID string, amount money.Money) (*Entry, error) {
+	return s.append(userID, KindReversal, saleID, negate(amount), nil)
+}
+
+// append stores a new entry of the given kind in the account of a user.
+func (s *Service) append(userID, kind, reference string, amount money.Money, check Check) (*Entry, error) {
+	if amount.Currency != s.limit.Currency {
+		return nil, fmt.Errorf("%w: account is in %s, got %s", money.ErrCurrencyMismatch, s.limit.Currency, amount.Currency)
+	}
+
+	entry := Entry{
+		ID:        uuid.NewString(),
+		Kind:      kind,
+		Reference: reference,
+		Amount:    amount,
+		CreatedAt: time.Now(),
+	}
+	if err := s.storage.Append(userID, entry, check); err != nil {
+		if !errors.Is(err, ErrCreditLimitExceeded) && !errors.Is(err, ErrPaymentExceedsBalance) {
+			s.logger.Error("failed to append account entry", zap.Error(err), zap.String("user_id", userID), zap.String("kind", kind))
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// negate returns -m.
+func negate(m money.Money) money.Money {
+	return money.Money{Minor: -m.Minor, Currency: m.Currency}
+}
@@ -0,0 +1,114 @@
+package account
+
+import (
+	"API_VentasGO/internal/money"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_Ledger(t *testing.T) {
+	s := NewService(NewLocalStorage(), money.New(100000, "ARS"), nil)
+
+	got := s.Get("ana")
+	require.Equal(t, money.New(0, "ARS"), got.Balance)
+	require.Equal(t, money.New(100000, "ARS"), got.Available)
+	require.Empty(t, got.Entries)
+
+	_, err := s.Charge("ana", "sale-1", money.New(60000, "ARS"))
+	require.NoError(t, err)
+	_, err = s.Charge("ana", "sale-1", money.New(60000, "ARS"))
+	require.ErrorIs(t, err, ErrDuplicateEntry)
+	_, err = s.Charge("ana", "sale-2", money.New(40001, "ARS"))
+	require.ErrorIs(t, err, ErrCreditLimitExceeded)
+	_, err = s.Charge("ana", "sale-2", money.New(40000, "ARS"))
+	require.NoError(t, err)
+
+	_, err = s.Refund("ana", "refund-1", money.New(10000, "ARS"))
+	require.NoError(t, err)
+	_, err = s.Pay("ana", money.New(30000, "ARS"), "recibo-1")
+	require.NoError(t, err)
+	_, err = s.Reverse("ana", "sale-2", money.New(40000, "ARS"))
+	require.NoError(t, err)
+
+	got = s.Get("ana")
+	require.Equal(t, money.New(20000, "ARS"), got.Balance)
+	require.Equal(t, money.New(80000, "ARS"), got.Available)
+	require.Len(t, got.Entries, 5)
+
+	// The balance is the sum of the entries.
+	sum := money.New(0, "ARS")
+	for _, e := range got.Entries {
+		sum, err = sum.Add(e.Amount)
+		require.NoError(t, err)
+	}
+	require.Equal(t, got.Balance, sum)
+
+	// Entries handed out are copies.
+	got.Entries[0].Amount = money.New(1, "ARS")
+	require.Equal(t, money.New(60000, "ARS"), s.Get("ana").Entries[0].Amount)
+
+	require.Empty(t, s.Get("beto").Entries)
+}
+
+func TestService_Pay(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    money.Money
+		reference string
+		wantErr   error
+	}{
+		{name: "part of the balance", amount: money.New(1000, "ARS")},
+		{name: "whole balance", amount: money.New(5000, "ARS")},
+		{name: "more than the balance", amount: money.New(5001, "ARS"), wantErr: ErrPaymentExceedsBalance},
+		{name: "zero", amount: money.New(0, "ARS"), wantErr: ErrInvalidPayment},
+		{name: "another currency", amount: money.New(1000, "USD"), wantErr: money.ErrCurrencyMismatch},
+		{name: "repeated reference", amount: money.New(1000, "ARS"), reference: "recibo-1", wantErr: ErrDuplicateEntry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(NewLocalStorage(), money.New(100000, "ARS"), nil)
+			_, err := s.Charge("ana", "sale-1", money.New(5000, "ARS"))
+			require.NoError(t, err)
+			_, err = s.Pay("ana", money.New(1, "ARS"), "recibo-1")
+			require.NoError(t, err)
+			_, err = s.Charge("ana", "sale-2", money.New(1, "ARS"))
+			require.NoError(t, err)
+
+			entry, err := s.Pay("ana", tt.amount, tt.reference)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				require.Equal(t, money.New(5000, "ARS"), s.Get("ana").Balance)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, KindPayment, entry.Kind)
+			require.Equal(t, -tt.amount.Minor, entry.Amount.Minor)
+			require.Equal(t, money.New(5000-tt.amount.Minor, "ARS"), s.Get("ana").Balance)
+		})
+	}
+}
+
+func TestService_ConcurrentChargesNeverExceedLimit(t *testing.T) {
+	s := NewService(NewLocalStorage(), money.New(50000, "ARS"), nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.Charge("ana", fmt.Sprintf("sale-%d", i), money.New(10000, "ARS"))
+			if err != nil {
+				require.ErrorIs(t, err, ErrCreditLimitExceeded)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	got := s.Get("ana")
+	require.Equal(t, money.New(50000, "ARS"), got.Balance)
+	require.Len(t, got.Entries, 5)
+}
@@ -0,0 +1,129 @@
+package account
+
+import (
+	"API_VentasGO/internal/money"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// ErrDuplicateEntry is returned when an account already has an entry of the
+// same kind and reference.
+var ErrDuplicateEntry = errors.New("duplicate account entry")
+
+// Check vets an entry against the balance of the account before it is
+// appended. A non-nil error rejects the entry.
+type Check func(balance money.Money) error
+
+type Storage interface {
+	Append(userID string, entry Entry, check Check) error
+	Entries(userID string) ([]Entry, money.Money)
+}
+
+// shardCount is the number of independent partitions of LocalStorage.
+const shardCount = 32
+
+// shard is a partition of LocalStorage guarded by its own lock.
+type shard struct {
+	mu sync.Mutex
+	m  map[string]*ledger
+}
+
+// LocalStorage provides an in-memory implementation for storing account
+// ledgers. It is safe for concurrent use: ledgers are spread across shards
+// by user, and entries are checked and appended under the lock of the shard,
+// so concurrent charges never go past a limit.
+// Entries are copied on the way in and out, and never changed once appended.
+type LocalStorage struct {
+	shards [shardCount]*shard
+}
+
+// NewLocalStorage instantiates a new LocalStorage with empty shards.
+func NewLocalStorage() *LocalStorage {
+	l := &LocalStorage{}
+	for i := range l.shards {
+		l.shards[i] = &shard{m: make(map[string]*ledger)}
+	}
+	return l
+}
+
+// shardFor returns the shard that owns the account of the given user.
+func (l *LocalStorage) shardFor(userID string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(userID))
+	return l.shards[h.Sum32()%shardCount]
+}
+
+// Append adds an entry to the account of a user, creating the account on its
+// first entry. check, if not nil, is called with the current balance first.
+// Returns ErrDuplicateEntry if the account has an entry with the same kind
+// and reference, money errors if the entry is in another currency than the
+// account, and the error of check.
+func (l *LocalStorage) Append(userID string, entry Entry, check Check) error {
+	return l.append(userID, entry, check, false)
+}
+
+// append implements Append. With dryRun it only reports whether Append would succeed.
+func (l *LocalStorage) append(userID string, entry Entry, check Check, dryRun bool) error {
+	sh := l.shardFor(userID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	led, ok := sh.m[userID]
+	if !ok {
+		led = &ledger{balance: money.Money{Currency: entry.Amount.Currency}}
+	}
+
+	if entry.Reference != "" {
+		for _, e := range led.entries {
+			if e.Kind == entry.Kind && e.Reference == entry.Reference {
+				return fmt.Errorf("%w: %s %s", ErrDuplicateEntry, entry.Kind, entry.Reference)
+			}
+		}
+	}
+	balance, err := led.balance.Add(entry.Amount)
+	if err != nil {
+		return err
+	}
+	if check != nil {
+		if err := check(led.balance); err != nil {
+			return err
+		}
+	}
+	if dryRun {
+		return nil
+	}
+
+	led.entries = append(led.entries, entry)
+	led.balance = balance
+	sh.m[userID] = led
+	return nil
+}
+
+// Entries returns a copy of the entries of the account of a user, oldest
+// first, and its balance. Users without entries have an empty account.
+func (l *LocalStorage) Entries(userID string) ([]Entry, money.Money) {
+	sh := l.shardFor(userID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	led, ok := sh.m[userID]
+	if !ok {
+		return nil, money.Money{}
+	}
+	return led.clone(), led.balance
+}
+
+// all returns a copy of the entries of every account, by user.
+func (l *LocalStorage) all() map[string][]Entry {
+	accounts := make(map[string][]Entry)
+	for _, sh := range l.shards {
+		sh.mu.Lock()
+		for userID, led := range sh.m {
+			accounts[userID] = led.clone()
+		}
+		sh.mu.Unlock()
+	}
+	return accounts
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader is the request header that carries an API key.
+const APIKeyHeader = "X-API-Key"
+
+// Authenticator authenticates requests by their API key or their JWT bearer
+// token. A nil Keys or Tokens rejects that kind of credentials.
+type Authenticator struct {
+	Keys   *KeyRing
+	Tokens *JWTVerifier
+}
+
+// Authenticate returns the principal of a request, read from the X-API-Key
+// header or from an "Authorization: Bearer" token.
+// Returns ErrMissingCredentials when the request carries neither, and
+// ErrInvalidAPIKey, ErrInvalidToken or ErrTokenExpired for credentials that
+// are not accepted.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		if a.Keys == nil {
+			return nil, ErrInvalidAPIKey
+		}
+		return a.Keys.Authenticate(key)
+	}
+
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return nil, ErrMissingCredentials
+	}
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, fmt.Errorf("%w: want a Bearer token", ErrInvalidToken)
+	}
+	if a.Tokens == nil {
+		return nil, fmt.Errorf("%w: bearer tokens are not accepted", ErrInvalidToken)
+	}
+	return a.Tokens.Verify(strings.TrimSpace(token))
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKeyRing(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []APIKey
+		wantErr error
+	}{
+		{name: "valid", keys: []APIKey{{Name: "backoffice", Key: "0123456789abcdef"}, {Name: "caja", Key: "fedcba9876543210"}}},
+		{name: "without name", keys: []APIKey{{Key: "0123456789abcdef"}}, wantErr: ErrInvalidKeys},
+		{name: "short key", keys: []APIKey{{Name: "backoffice", Key: "0123"}}, wantErr: ErrInvalidKeys},
+		{name: "repeated key", keys: []APIKey{{Name: "backoffice", Key: "0123456789abcdef"}, {Name: "caja", Key: "0123456789abcdef"}}, wantErr: ErrInvalidKeys},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyRing(tt.keys)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	keys, err := NewKeyRing([]APIKey{{Name: "backoffice", Key: "0123456789abcdef", Roles: []string{"admin"}}})
+	require.NoError(t, err)
+	tokens, err := NewJWTVerifier(JWTConfig{HMACSecret: secret})
+	require.NoError(t, err)
+	token := sign(t, AlgHS256, secret, map[string]any{"sub": "ana", "exp": time.Now().Add(time.Hour).Unix()})
+
+	tests := []struct {
+		name          string
+		authenticator *Authenticator
+		headers       map[string]string
+		want          *Principal
+		wantErr       error
+	}{
+		{
+			name:          "API key",
+			authenticator: &Authenticator{Keys: keys, Tokens: tokens},
+			headers:       map[string]string{APIKeyHeader: "0123456789abcdef"},
+			want:          &Principal{Subject: "backoffice", Method: MethodAPIKey, Roles: []string{"admin"}},
+		},
+		{
+			name:          "bearer token",
+			authenticator: &Authenticator{Keys: keys, Tokens: tokens},
+			headers:       map[string]string{"Authorization": "bearer " + token},
+			want:          &Principal{Subject: "ana", Method: MethodJWT},
+		},
+		{name: "nothing", authenticator: &Authenticator{Keys: keys, Tokens: tokens}, wantErr: ErrMissingCredentials},
+		{name: "unknown key", authenticator: &Authenticator{Keys: keys}, headers: map[string]string{APIKeyHeader: "fedcba9876543210"}, wantErr: ErrInvalidAPIKey},
+		{name: "keys not configured", authenticator: &Authenticator{Tokens: tokens}, headers: map[string]string{APIKeyHeader: "0123456789abcdef"}, wantErr: ErrInvalidAPIKey},
+		{name: "tokens not configured", authenticator: &Authenticator{Keys: keys}, headers: map[string]string{"Authorization": "Bearer " + token}, wantErr: ErrInvalidToken},
+		{name: "basic auth", authenticator: &Authenticator{Keys: keys, Tokens: tokens}, headers: map[string]string{"Authorization": "Basic YW5hOnNlY3JldA=="}, wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			got, err := tt.authenticator.Authenticate(req)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
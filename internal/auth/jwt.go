@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// JWT signing algorithms.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+)
+
+// ErrInvalidToken is returned when a bearer token is malformed, signed with
+// an algorithm or key that is not configured, or its claims do not hold.
+var ErrInvalidToken = errors.New("invalid token")
+
+// ErrTokenExpired is returned when a bearer token is past its expiration.
+var ErrTokenExpired = errors.New("token expired")
+
+// clockSkew is how far apart the clocks of the issuer and this server may be.
+const clockSkew = 30 * time.Second
+
+// JWTConfig configures the tokens a JWTVerifier accepts. At least one of
+// HMACSecret, for HS256, and RSAPublicKey, for RS256, must be set. Issuer and
+// Audience, when set, must match the iss and aud claims.
+type JWTConfig struct {
+	HMACSecret   []byte
+	RSAPublicKey *rsa.PublicKey
+	Issuer       string
+	Audience     string
+}
+
+// JWTVerifier verifies JWT bearer tokens locally, with the keys it was
+// configured with. Tokens must carry sub and exp claims, and may carry nbf,
+// iss, aud and roles.
+type JWTVerifier struct {
+	cfg JWTConfig
+
+	// now returns the current time. Tests replace it.
+	now func() time.Time
+}
+
+// NewJWTVerifier creates a JWTVerifier.
+// Returns ErrInvalidToken if no key is configured.
+func NewJWTVerifier(cfg JWTConfig) (*JWTVerifier, error) {
+	if len(cfg.HMACSecret) == 0 && cfg.RSAPublicKey == nil {
+		return nil, fmt.Errorf("%w: no HS256 secret nor RS256 key configured", ErrInvalidToken)
+	}
+	return &JWTVerifier{cfg: cfg, now: time.Now}, nil
+}
+
+// ParseRSAPublicKey reads an RSA public key from a PEM block, either PKIX
+// ("PUBLIC KEY") or PKCS #1 ("RSA PUBLIC KEY").
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not an RSA public key")
+	}
+	return rsaKey, nil
+}
+
+// header is the JOSE header of a token.
+type header struct {
+	Alg string `json:"alg"`
+}
+
+// claims are the claims of a token the verifier reads.
+type claims struct {
+	Subject   string    `json:"sub"`
+	Issuer    string    `json:"iss"`
+	Audience  audience  `json:"aud"`
+	ExpiresAt *unixTime `json:"exp"`
+	NotBefore *unixTime `json:"nbf"`
+	Roles     []string  `json:"roles"`
+}
+
+// audience is the aud claim, which may be a string or a list of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// unixTime is a NumericDate claim: seconds since the epoch.
+type unixTime struct {
+	time.Time
+}
+
+func (t *unixTime) UnmarshalJSON(data []byte) error {
+	var secs float64
+	if err := json.Unmarshal(data, &secs); err != nil {
+		return err
+	}
+	t.Time = time.Unix(0, int64(secs*float64(time.Second)))
+	return nil
+}
+
+// Verify checks the signature and claims of a token and returns its principal.
+// Returns ErrTokenExpired for expired tokens and ErrInvalidToken for any
+// other problem.
+func (v *JWTVerifier) Verify(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+	if err := v.verifySignature(h.Alg, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	if err := v.checkClaims(&c); err != nil {
+		return nil, err
+	}
+
+	return &Principal{Subject: c.Subject, Method: MethodJWT, Roles: c.Roles}, nil
+}
+
+// verifySignature checks the signature of the signed part of a token with the
+// key of its algorithm. Algorithms without a configured key are rejected, so
+// a token cannot pick a weaker check than the one configured.
+func (v *JWTVerifier) verifySignature(alg, signed string, sig []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+	switch {
+	case alg == AlgHS256 && len(v.cfg.HMACSecret) > 0:
+		mac := hmac.New(sha256.New, v.cfg.HMACSecret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+		return nil
+	case alg == AlgRS256 && v.cfg.RSAPublicKey != nil:
+		if err := rsa.VerifyPKCS1v15(v.cfg.RSAPublicKey, crypto.SHA256, digest[:], sig); err != nil {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: algorithm %q not accepted", ErrInvalidToken, alg)
+	}
+}
+
+// checkClaims checks the subject, validity window, issuer and audience of a token.
+func (v *JWTVerifier) checkClaims(c *claims) error {
+	now := v.now()
+	if c.Subject == "" {
+		return fmt.Errorf("%w: missing sub", ErrInvalidToken)
+	}
+	if c.ExpiresAt == nil {
+		return fmt.Errorf("%w: missing exp", ErrInvalidToken)
+	}
+	if now.After(c.ExpiresAt.Add(clockSkew)) {
+		return ErrTokenExpired
+	}
+	if c.NotBefore != nil && now.Add(clockSkew).Before(c.NotBefore.Time) {
+		return fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	}
+	if v.cfg.Issuer != "" && c.Issuer != v.cfg.Issuer {
+		return fmt.Errorf("%w: issuer %q", ErrInvalidToken, c.Issuer)
+	}
+	if v.cfg.Audience != "" && !slices.Contains(c.Audience, v.cfg.Audience) {
+		return fmt.Errorf("%w: audience %v", ErrInvalidToken, []string(c.Audience))
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a token into v.
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var secret = []byte("0123456789abcdef0123456789abcdef")
+
+// sign builds a token with the given header algorithm, signing it with HMAC
+// when key is a []byte and with RSA when it is an *rsa.PrivateKey.
+func sign(t *testing.T, alg string, key any, claims map[string]any) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWTVerifier_Verify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	valid := func(extra map[string]any) map[string]any {
+		c := map[string]any{"sub": "ana", "exp": now.Add(time.Hour).Unix(), "iss": "ventas", "aud": "api", "roles": []string{"seller"}}
+		for k, v := range extra {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "HS256", token: sign(t, AlgHS256, secret, valid(nil))},
+		{name: "RS256", token: sign(t, AlgRS256, rsaKey, valid(nil))},
+		{name: "audience list", token: sign(t, AlgHS256, secret, valid(map[string]any{"aud": []string{"web", "api"}}))},
+		{name: "within clock skew", token: sign(t, AlgHS256, secret, valid(map[string]any{"exp": now.Add(-10 * time.Second).Unix()}))},
+		{name: "expired", token: sign(t, AlgHS256, secret, valid(map[string]any{"exp": now.Add(-time.Minute).Unix()})), wantErr: ErrTokenExpired},
+		{name: "not valid yet", token: sign(t, AlgHS256, secret, valid(map[string]any{"nbf": now.Add(time.Minute).Unix()})), wantErr: ErrInvalidToken},
+		{name: "without exp", token: sign(t, AlgHS256, secret, valid(map[string]any{"exp": nil})), wantErr: ErrInvalidToken},
+		{name: "without sub", token: sign(t, AlgHS256, secret, valid(map[string]any{"sub": nil})), wantErr: ErrInvalidToken},
+		{name: "other issuer", token: sign(t, AlgHS256, secret, valid(map[string]any{"iss": "otros"})), wantErr: ErrInvalidToken},
+		{name: "other audience", token: sign(t, AlgHS256, secret, valid(map[string]any{"aud": "web"})), wantErr: ErrInvalidToken},
+		{name: "wrong secret", token: sign(t, AlgHS256, []byte("another secret of 32 characters!"), valid(nil)), wantErr: ErrInvalidToken},
+		{name: "wrong RSA key", token: sign(t, AlgRS256, otherKey, valid(nil)), wantErr: ErrInvalidToken},
+		{name: "HS256 with the RSA public key as secret", token: sign(t, AlgHS256, publicDER, valid(nil)), wantErr: ErrInvalidToken},
+		{name: "alg none", token: sign(t, "none", nil, valid(nil)), wantErr: ErrInvalidToken},
+		{name: "malformed", token: "not-a-token", wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewJWTVerifier(JWTConfig{HMACSecret: secret, RSAPublicKey: &rsaKey.PublicKey, Issuer: "ventas", Audience: "api"})
+			require.NoError(t, err)
+			v.now = func() time.Time { return now }
+
+			p, err := v.Verify(tt.token)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, &Principal{Subject: "ana", Method: MethodJWT, Roles: []string{"seller"}}, p)
+		})
+	}
+
+	t.Run("only the configured algorithm", func(t *testing.T) {
+		v, err := NewJWTVerifier(JWTConfig{RSAPublicKey: &rsaKey.PublicKey})
+		require.NoError(t, err)
+		v.now = func() time.Time { return now }
+
+		_, err = v.Verify(sign(t, AlgHS256, publicDER, valid(nil)))
+		require.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("without keys", func(t *testing.T) {
+		_, err := NewJWTVerifier(JWTConfig{})
+		require.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
+
+func TestParseRSAPublicKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pkix, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+
+	for _, block := range []*pem.Block{
+		{Type: "PUBLIC KEY", Bytes: pkix},
+		{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)},
+	} {
+		got, err := ParseRSAPublicKey(pem.EncodeToMemory(block))
+		require.NoError(t, err)
+		require.True(t, rsaKey.PublicKey.Equal(got))
+	}
+
+	_, err = ParseRSAPublicKey([]byte("not a key"))
+	require.Error(t, err)
+}
+
+func TestJWTSigner_Sign(t *testing.T) {
+	cfg := JWTConfig{HMACSecret: secret, Issuer: "ventas", Audience: "api"}
+	signer, err := NewJWTSigner(cfg)
+	require.NoError(t, err)
+	verifier, err := NewJWTVerifier(cfg)
+	require.NoError(t, err)
+
+	token, err := signer.Sign("ana", []string{"user"}, time.Minute)
+	require.NoError(t, err)
+	p, err := verifier.Verify(token)
+	require.NoError(t, err)
+	require.Equal(t, &Principal{Subject: "ana", Method: MethodJWT, Roles: []string{"user"}}, p)
+
+	verifier.now = func() time.Time { return time.Now().Add(time.Minute + clockSkew + time.Second) }
+	_, err = verifier.Verify(token)
+	require.ErrorIs(t, err, ErrTokenExpired)
+
+	other, err := NewJWTVerifier(JWTConfig{HMACSecret: []byte("another secret of thirty-two bytes")})
+	require.NoError(t, err)
+	_, err = other.Verify(token)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = NewJWTSigner(JWTConfig{})
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
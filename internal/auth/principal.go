@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Authentication methods.
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// ErrMissingCredentials is returned when a request carries no credentials.
+var ErrMissingCredentials = errors.New("missing credentials")
+
+// ErrInvalidAPIKey is returned when an API key is not known.
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// ErrInvalidKeys is returned when the API keys configuration is not valid.
+var ErrInvalidKeys = errors.New("invalid API keys")
+
+// Principal is who made a request: the Name of an API key or the subject of
+// a token, with the roles it was granted.
+type Principal struct {
+	Subject string   `json:"subject"`
+	Method  string   `json:"method"`
+	Roles   []string `json:"roles,omitempty"`
+}
+
+// APIKey is a static key and the principal it authenticates.
+type APIKey struct {
+	Name  string   `json:"name"`
+	Key   string   `json:"key"`
+	Roles []string `json:"roles"`
+}
+
+// minKeyLength is the shortest API key accepted, so keys cannot be guessed.
+const minKeyLength = 16
+
+// KeyRing authenticates static API keys. Keys are looked up by their SHA-256
+// hash, so the lookup time does not depend on how much of a key matches.
+type KeyRing struct {
+	keys map[[sha256.Size]byte]Principal
+}
+
+// NewKeyRing builds a KeyRing from a list of keys.
+// Returns ErrInvalidKeys for keys without a name, shorter than 16
+// characters, or repeated.
+func NewKeyRing(keys []APIKey) (*KeyRing, error) {
+	r := &KeyRing{keys: make(map[[sha256.Size]byte]Principal, len(keys))}
+	for _, k := range keys {
+		name := strings.TrimSpace(k.Name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: key without a name", ErrInvalidKeys)
+		}
+		if len(k.Key) < minKeyLength {
+			return nil, fmt.Errorf("%w: key of %s is shorter than %d characters", ErrInvalidKeys, name, minKeyLength)
+		}
+		sum := sha256.Sum256([]byte(k.Key))
+		if _, ok := r.keys[sum]; ok {
+			return nil, fmt.Errorf("%w: key of %s is repeated", ErrInvalidKeys, name)
+		}
+		r.keys[sum] = Principal{Subject: name, Method: MethodAPIKey, Roles: k.Roles}
+	}
+	return r, nil
+}
+
+// Authenticate returns the principal of an API key.
+// Returns ErrInvalidAPIKey for unknown keys.
+func (r *KeyRing) Authenticate(key string) (*Principal, error) {
+	p, ok := r.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	return &p, nil
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// JWTSigner issues HS256 tokens, which the JWTVerifier of the same
+// JWTConfig accepts.
+type JWTSigner struct {
+	cfg JWTConfig
+
+	// now returns the current time. Tests replace it.
+	now func() time.Time
+}
+
+// NewJWTSigner creates a JWTSigner with the HMACSecret, Issuer and Audience
+// of cfg.
+// Returns ErrInvalidToken if no HMACSecret is configured.
+func NewJWTSigner(cfg JWTConfig) (*JWTSigner, error) {
+	if len(cfg.HMACSecret) == 0 {
+		return nil, fmt.Errorf("%w: no HS256 secret configured", ErrInvalidToken)
+	}
+	return &JWTSigner{cfg: cfg, now: time.Now}, nil
+}
+
+// Sign returns a token for subject with roles, valid for ttl.
+func (s *JWTSigner) Sign(subject string, roles []string, ttl time.Duration) (string, error) {
+	now := s.now()
+	c := struct {
+		Subject   string   `json:"sub"`
+		Issuer    string   `json:"iss,omitempty"`
+		Audience  string   `json:"aud,omitempty"`
+		IssuedAt  int64    `json:"iat"`
+		ExpiresAt int64    `json:"exp"`
+		Roles     []string `json:"roles,omitempty"`
+	}{subject, s.cfg.Issuer, s.cfg.Audience, now.Unix(), now.Add(ttl).Unix(), roles}
+
+	h, err := json.Marshal(header{Alg: AlgHS256})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, s.cfg.HMACSecret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
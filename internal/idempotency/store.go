@@ -0,0 +1,152 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrFingerprintMismatch is returned when a key is reused for a different request.
+var ErrFingerprintMismatch = errors.New("idempotency key reused with a different request")
+
+// ErrEmptyKey is returned when trying to use an empty idempotency key.
+var ErrEmptyKey = errors.New("empty idempotency key")
+
+// Response is a stored response, replayed verbatim to retries of the request
+// that produced it.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// entry is the state of one key. done is closed when the request holding the
+// key completes or gives it up; until then response is nil.
+type entry struct {
+	fingerprint string
+	response    *Response
+	expires     time.Time
+	done        chan struct{}
+}
+
+// Store remembers, for a limited time, which request each idempotency key was
+// used for and the response it got.
+// It is safe for concurrent use. Keys live in memory only, so they are
+// forgotten on restart.
+type Store struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[string]*entry
+	nextSweep time.Time
+
+	// now returns the current time; tests replace it.
+	now func() time.Time
+}
+
+// NewStore instantiates a Store that keeps completed keys for ttl.
+func NewStore(ttl time.Duration) *Store {
+	return &Store{
+		ttl:     ttl,
+		entries: make(map[string]*entry),
+		now:     time.Now,
+	}
+}
+
+// Begin claims key for a request with the given fingerprint.
+//
+// If the key is new, Begin returns a nil Response and the caller owns the key
+// until it calls Complete or Abort. If the key already completed with the same
+// fingerprint, Begin returns the stored Response. While another request holds
+// the key, Begin waits for it to finish or for ctx to be done.
+// Returns ErrFingerprintMismatch if the key was used for a different request.
+func (s *Store) Begin(ctx context.Context, key, fingerprint string) (*Response, error) {
+	if key == "" {
+		return nil, ErrEmptyKey
+	}
+
+	for {
+		s.mu.Lock()
+		now := s.now()
+		s.sweep(now)
+
+		e, ok := s.entries[key]
+		if ok && e.response != nil && !now.Before(e.expires) {
+			delete(s.entries, key)
+			ok = false
+		}
+		if !ok {
+			s.entries[key] = &entry{fingerprint: fingerprint, done: make(chan struct{})}
+			s.mu.Unlock()
+			return nil, nil
+		}
+		if e.fingerprint != fingerprint {
+			s.mu.Unlock()
+			return nil, ErrFingerprintMismatch
+		}
+		if e.response != nil {
+			r := e.response.clone()
+			s.mu.Unlock()
+			return r, nil
+		}
+		done := e.done
+		s.mu.Unlock()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Complete stores the response of the request holding key and wakes up the
+// requests waiting on it.
+func (s *Store) Complete(key string, response Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || e.response != nil {
+		return
+	}
+	e.response = response.clone()
+	e.expires = s.now().Add(s.ttl)
+	close(e.done)
+}
+
+// Abort releases key without storing a response, so that the next request
+// with it is processed again.
+func (s *Store) Abort(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || e.response != nil {
+		return
+	}
+	delete(s.entries, key)
+	close(e.done)
+}
+
+// sweep drops the expired keys, at most once per ttl. The caller must hold s.mu.
+func (s *Store) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	for key, e := range s.entries {
+		if e.response != nil && !now.Before(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+	s.nextSweep = now.Add(s.ttl)
+}
+
+func (r Response) clone() *Response {
+	c := Response{Status: r.Status, Header: r.Header.Clone()}
+	if r.Body != nil {
+		c.Body = append([]byte(nil), r.Body...)
+	}
+	return &c
+}
@@ -0,0 +1,101 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Replay(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewStore(time.Hour)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	got, err := s.Begin(ctx, "k", "a")
+	require.NoError(t, err)
+	require.Nil(t, got)
+	s.Complete("k", Response{Status: http.StatusCreated, Header: http.Header{"Etag": {`"1"`}}, Body: []byte(`{"id":"1"}`)})
+
+	got, err = s.Begin(ctx, "k", "a")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, got.Status)
+	require.Equal(t, `"1"`, got.Header.Get("ETag"))
+	require.Equal(t, `{"id":"1"}`, string(got.Body))
+
+	_, err = s.Begin(ctx, "k", "b")
+	require.ErrorIs(t, err, ErrFingerprintMismatch)
+
+	// Once expired, the key can be used for anything.
+	now = now.Add(time.Hour)
+	got, err = s.Begin(ctx, "k", "b")
+	require.NoError(t, err)
+	require.Nil(t, got)
+}
+
+func TestStore_Abort(t *testing.T) {
+	s := NewStore(time.Hour)
+	ctx := context.Background()
+
+	_, err := s.Begin(ctx, "k", "a")
+	require.NoError(t, err)
+	s.Abort("k")
+
+	got, err := s.Begin(ctx, "k", "b")
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	_, err = s.Begin(ctx, "", "a")
+	require.ErrorIs(t, err, ErrEmptyKey)
+}
+
+func TestStore_ConcurrentRequestsRunOnce(t *testing.T) {
+	s := NewStore(time.Hour)
+	ctx := context.Background()
+
+	const requests = 50
+	var (
+		mu   sync.Mutex
+		runs int
+		wg   sync.WaitGroup
+	)
+	bodies := make([]string, requests)
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := s.Begin(ctx, "k", "a")
+			require.NoError(t, err)
+			if got == nil {
+				mu.Lock()
+				runs++
+				mu.Unlock()
+				time.Sleep(10 * time.Millisecond)
+				got = &Response{Status: http.StatusCreated, Body: []byte("sale")}
+				s.Complete("k", *got)
+			}
+			bodies[i] = string(got.Body)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 1, runs)
+	for _, b := range bodies {
+		require.Equal(t, "sale", b)
+	}
+}
+
+func TestStore_WaitHonorsContext(t *testing.T) {
+	s := NewStore(time.Hour)
+	_, err := s.Begin(context.Background(), "k", "a")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = s.Begin(ctx, "k", "a")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
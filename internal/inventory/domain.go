@@ -0,0 +1,49 @@
+package inventory
+
+import "time"
+
+// Movement kinds.
+const (
+	// KindAdjust changes the stock on hand, e.g. when goods are received or
+	// counted. Its Quantity is signed.
+	KindAdjust = "adjust"
+
+	// KindReserve holds stock for a pending sale.
+	KindReserve = "reserve"
+
+	// KindCommit takes reserved stock out of the stock on hand, once the
+	// sale is approved.
+	KindCommit = "commit"
+
+	// KindRelease gives reserved stock back, once the sale is rejected or
+	// cancelled.
+	KindRelease = "release"
+)
+
+// Line is a quantity of a product.
+type Line struct {
+	ProductID string `json:"product_id"`
+	Quantity  int64  `json:"quantity"`
+}
+
+// Stock is the stock level of a product. Available is what new sales may
+// still reserve: the stock on hand minus the stock reserved by pending sales.
+type Stock struct {
+	ProductID string `json:"product_id"`
+	OnHand    int64  `json:"on_hand"`
+	Reserved  int64  `json:"reserved"`
+	Available int64  `json:"available"`
+}
+
+// Movement is an entry of the stock ledger of a product. OnHand and Reserved
+// are the levels right after the movement.
+type Movement struct {
+	ProductID   string    `json:"product_id"`
+	Kind        string    `json:"kind"`
+	Quantity    int64     `json:"quantity"`
+	Reservation string    `json:"reservation,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	OnHand      int64     `json:"on_hand"`
+	Reserved    int64     `json:"reserved"`
+	At          time.Time `json:"at"`
+}
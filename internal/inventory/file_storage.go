@@ -0,0 +1,174 @@
+package inventory
+
+import (
+	"API_VentasGO/internal/wal"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// record is a single entry of the inventory write-ahead log.
+type record struct {
+	Op       string    `json:"op"`
+	ID       string    `json:"id"`
+	Quantity int64     `json:"quantity,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	Lines    []Line    `json:"lines,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+const (
+	opAdjust  = "adjust"
+	opReserve = "reserve"
+	opCommit  = "commit"
+	opRelease = "release"
+)
+
+// FileStorage is a durable Storage backed by a write-ahead log.
+// Every operation is checked against memory, appended to the log and then
+// applied in memory; the log is periodically compacted into a snapshot, and
+// both are replayed when the storage is opened again. Reads are served from
+// memory.
+type FileStorage struct {
+	// mu serializes writes so the log order matches the memory order, and
+	// nothing changes between checking an operation and applying it.
+	mu  sync.Mutex
+	mem *LocalStorage
+	log *wal.Log
+}
+
+// NewFileStorage opens the inventory stored in dir, replaying the snapshot
+// and the log to restore the state left by the previous run.
+func NewFileStorage(dir string, opts wal.Options) (*FileStorage, error) {
+	log, err := wal.Open(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FileStorage{
+		mem: NewLocalStorage(),
+		log: log,
+	}
+
+	if err := log.Replay(f.loadSnapshot, f.apply); err != nil {
+		log.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// loadSnapshot restores the state stored in a snapshot.
+func (f *FileStorage) loadSnapshot(data []byte) error {
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	f.mem.restore(s)
+	return nil
+}
+
+// apply replays a single log record over the in-memory state.
+func (f *FileStorage) apply(data []byte) error {
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return err
+	}
+
+	switch r.Op {
+	case opAdjust:
+		_, err := f.mem.Adjust(r.ID, r.Quantity, r.Reason, r.At)
+		return err
+	case opReserve:
+		return f.mem.Reserve(r.ID, r.Lines, r.At)
+	case opCommit:
+		return f.mem.Commit(r.ID, r.At)
+	case opRelease:
+		return f.mem.Release(r.ID, r.At)
+	}
+	return nil
+}
+
+// write appends r to the log, applies it in memory and compacts the log
+// when it grew past the configured threshold.
+func (f *FileStorage) write(r record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := f.log.Append(data); err != nil {
+		return err
+	}
+	if err := f.apply(data); err != nil {
+		return err
+	}
+
+	f.log.Compact(func() ([]byte, error) {
+		return json.Marshal(f.mem.all())
+	})
+	return nil
+}
+
+// Adjust durably adds quantity to the stock on hand of a product.
+// It fails like LocalStorage.Adjust, without writing anything.
+func (f *FileStorage) Adjust(productID string, quantity int64, reason string, at time.Time) (*Stock, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.mem.adjust(productID, quantity, reason, at, true); err != nil {
+		return nil, err
+	}
+	if err := f.write(record{Op: opAdjust, ID: productID, Quantity: quantity, Reason: reason, At: at}); err != nil {
+		return nil, err
+	}
+	return f.mem.Stock(productID), nil
+}
+
+// Reserve durably holds stock for every line under the reservation id.
+// It fails like LocalStorage.Reserve, without writing anything.
+func (f *FileStorage) Reserve(id string, lines []Line, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.mem.reserve(id, lines, at, true); err != nil {
+		return err
+	}
+	return f.write(record{Op: opReserve, ID: id, Lines: lines, At: at})
+}
+
+// Commit durably takes the stock held by the reservation id out of the stock on hand.
+// Returns ErrReservationNotFound if id is not reserved.
+func (f *FileStorage) Commit(id string, at time.Time) error {
+	return f.settle(id, opCommit, KindCommit, at)
+}
+
+// Release durably gives the stock held by the reservation id back.
+// Returns ErrReservationNotFound if id is not reserved.
+func (f *FileStorage) Release(id string, at time.Time) error {
+	return f.settle(id, opRelease, KindRelease, at)
+}
+
+func (f *FileStorage) settle(id, op, kind string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.mem.settle(id, kind, at, true); err != nil {
+		return err
+	}
+	return f.write(record{Op: op, ID: id, At: at})
+}
+
+// Stock returns the stock level of a product.
+func (f *FileStorage) Stock(productID string) *Stock {
+	return f.mem.Stock(productID)
+}
+
+// Ledger returns a copy of the movements of a product, oldest first.
+func (f *FileStorage) Ledger(productID string) []Movement {
+	return f.mem.Ledger(productID)
+}
+
+// Close flushes and closes the underlying log.
+func (f *FileStorage) Close() error {
+	return f.log.Close()
+}
@@ -0,0 +1,80 @@
+package inventory
+
+import (
+	"API_VentasGO/internal/wal"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// openFileStorage opens a FileStorage in a temporary directory that is closed
+// when the test ends.
+func openFileStorage(t *testing.T) *FileStorage {
+	f, err := NewFileStorage(t.TempDir(), wal.Options{Sync: wal.SyncNever, SnapshotEvery: 50})
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestFileStorage_SurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	opts := wal.Options{Sync: wal.SyncAlways, SnapshotEvery: 3}
+
+	storage, err := NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	s := NewService(storage, nil)
+
+	_, err = s.Adjust("mate", 10, "received")
+	require.NoError(t, err)
+	require.NoError(t, s.Reserve("sale-1", []Line{{ProductID: "mate", Quantity: 3}}))
+	require.NoError(t, s.Reserve("sale-2", []Line{{ProductID: "mate", Quantity: 2}}))
+	require.ErrorIs(t, s.Reserve("sale-3", []Line{{ProductID: "mate", Quantity: 6}}), ErrInsufficientStock)
+	require.NoError(t, s.Commit("sale-1"))
+	require.NoError(t, storage.Close())
+
+	storage, err = NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	defer storage.Close()
+	s = NewService(storage, nil)
+
+	require.Equal(t, &Stock{ProductID: "mate", OnHand: 7, Reserved: 2, Available: 5}, s.Stock("mate"))
+	require.Len(t, s.Ledger("mate"), 4)
+
+	// The open reservation survived the restart.
+	require.NoError(t, s.Release("sale-2"))
+	require.Equal(t, int64(7), s.Stock("mate").Available)
+}
+
+func TestFileStorage_SkipsRecordsCoveredBySnapshot(t *testing.T) {
+	dir := t.TempDir()
+	opts := wal.Options{Sync: wal.SyncAlways, SnapshotEvery: 3}
+
+	storage, err := NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	s := NewService(storage, nil)
+
+	_, err = s.Adjust("mate", 10, "received")
+	require.NoError(t, err)
+	require.NoError(t, s.Reserve("sale-1", []Line{{ProductID: "mate", Quantity: 3}}))
+
+	// Put the log back after the write that compacts it, as if a crash had
+	// interrupted the compaction before it emptied the log.
+	path := filepath.Join(dir, "wal.log")
+	log, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, s.Reserve("sale-2", []Line{{ProductID: "mate", Quantity: 2}}))
+	require.NoError(t, storage.Close())
+	require.NoError(t, os.WriteFile(path, log, 0o644))
+
+	storage, err = NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	defer storage.Close()
+	s = NewService(storage, nil)
+
+	require.Equal(t, &Stock{ProductID: "mate", OnHand: 10, Reserved: 5, Available: 5}, s.Stock("mate"))
+	require.Len(t, s.Ledger("mate"), 3)
+	require.NoError(t, s.Commit("sale-1"))
+	require.Equal(t, int64(7), s.Stock("mate").OnHand)
+}
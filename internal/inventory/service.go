@@ -0,0 +1,81 @@
+package inventory
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Service tracks the stock of the catalog products. Pending sales reserve
+// stock, approved sales commit it and rejected or cancelled sales release it,
+// every step leaving a movement in the ledger of the product.
+type Service struct {
+	// storage is the underlying persistence for stock levels and movements.
+	storage Storage
+
+	// logger is our observability component to log.
+	logger *zap.Logger
+
+	// now returns the time of new movements; tests replace it.
+	now func() time.Time
+}
+
+// NewService creates a new Service.
+func NewService(storage Storage, logger *zap.Logger) *Service {
+	if logger == nil {
+		logger, _ = zap.NewProduction()
+		defer logger.Sync() // flushes buffer, if any
+	}
+
+	return &Service{
+		storage: storage,
+		logger:  logger,
+		now:     time.Now,
+	}
+}
+
+// Adjust adds quantity, which may be negative, to the stock on hand of a
+// product and returns its new stock level. reason is kept in the ledger.
+// Returns ErrInvalidQuantity for a zero quantity, or ErrInsufficientStock if
+// the stock on hand would drop below the reserved stock.
+func (s *Service) Adjust(productID string, quantity int64, reason string) (*Stock, error) {
+	return s.storage.Adjust(productID, quantity, reason, s.now())
+}
+
+// Reserve holds the stock of every line under the reservation id, or none
+// of it if any product falls short.
+// Returns ErrInsufficientStock if a product does not have enough available
+// stock, and ErrInvalidQuantity for non-positive quantities.
+func (s *Service) Reserve(id string, lines []Line) error {
+	return s.storage.Reserve(id, lines, s.now())
+}
+
+// Commit takes the stock held by the reservation id out of the stock on hand.
+// Returns ErrReservationNotFound if id is not reserved.
+func (s *Service) Commit(id string) error {
+	if err := s.storage.Commit(id, s.now()); err != nil {
+		s.logger.Error("failed to commit stock reservation", zap.Error(err), zap.String("reservation", id))
+		return err
+	}
+	return nil
+}
+
+// Release gives the stock held by the reservation id back.
+// Returns ErrReservationNotFound if id is not reserved.
+func (s *Service) Release(id string) error {
+	if err := s.storage.Release(id, s.now()); err != nil {
+		s.logger.Error("failed to release stock reservation", zap.Error(err), zap.String("reservation", id))
+		return err
+	}
+	return nil
+}
+
+// Stock returns the stock level of a product.
+func (s *Service) Stock(productID string) *Stock {
+	return s.storage.Stock(productID)
+}
+
+// Ledger returns the stock movements of a product, oldest first.
+func (s *Service) Ledger(productID string) []Movement {
+	return s.storage.Ledger(productID)
+}
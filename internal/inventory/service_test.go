@@ -0,0 +1,123 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_ReservationLifecycle(t *testing.T) {
+	s := NewService(NewLocalStorage(), nil)
+
+	_, err := s.Adjust("mate", 10, "received")
+	require.NoError(t, err)
+	_, err = s.Adjust("bombilla", 2, "received")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Reserve("sale-1", []Line{{ProductID: "mate", Quantity: 3}, {ProductID: "bombilla", Quantity: 1}}))
+	require.Equal(t, &Stock{ProductID: "mate", OnHand: 10, Reserved: 3, Available: 7}, s.Stock("mate"))
+
+	// All or nothing: bombilla falls short, so mate is not reserved either.
+	err = s.Reserve("sale-2", []Line{{ProductID: "mate", Quantity: 1}, {ProductID: "bombilla", Quantity: 2}})
+	require.ErrorIs(t, err, ErrInsufficientStock)
+	require.Equal(t, int64(3), s.Stock("mate").Reserved)
+
+	require.NoError(t, s.Reserve("sale-3", []Line{{ProductID: "mate", Quantity: 2}, {ProductID: "mate", Quantity: 2}}))
+	require.ErrorIs(t, s.Reserve("sale-3", []Line{{ProductID: "mate", Quantity: 1}}), ErrDuplicateReservation)
+
+	require.NoError(t, s.Commit("sale-1"))
+	require.NoError(t, s.Release("sale-3"))
+	require.ErrorIs(t, s.Commit("sale-1"), ErrReservationNotFound)
+	require.ErrorIs(t, s.Release("sale-9"), ErrReservationNotFound)
+
+	require.Equal(t, &Stock{ProductID: "mate", OnHand: 7, Reserved: 0, Available: 7}, s.Stock("mate"))
+	require.Equal(t, &Stock{ProductID: "bombilla", OnHand: 1, Reserved: 0, Available: 1}, s.Stock("bombilla"))
+
+	var kinds []string
+	for _, m := range s.Ledger("mate") {
+		kinds = append(kinds, fmt.Sprintf("%s %d -> %d/%d", m.Kind, m.Quantity, m.OnHand, m.Reserved))
+	}
+	require.Equal(t, []string{
+		"adjust 10 -> 10/0",
+		"reserve 3 -> 10/3",
+		"reserve 4 -> 10/7",
+		"commit 3 -> 7/4",
+		"release 4 -> 7/0",
+	}, kinds)
+}
+
+func TestService_Adjust(t *testing.T) {
+	s := NewService(NewLocalStorage(), nil)
+
+	_, err := s.Adjust("mate", 0, "")
+	require.ErrorIs(t, err, ErrInvalidQuantity)
+	_, err = s.Adjust("mate", -1, "shrinkage")
+	require.ErrorIs(t, err, ErrInsufficientStock)
+
+	_, err = s.Adjust("mate", 5, "received")
+	require.NoError(t, err)
+	require.NoError(t, s.Reserve("sale-1", []Line{{ProductID: "mate", Quantity: 4}}))
+
+	// Reserved stock cannot be adjusted away.
+	_, err = s.Adjust("mate", -2, "shrinkage")
+	require.ErrorIs(t, err, ErrInsufficientStock)
+	stock, err := s.Adjust("mate", -1, "shrinkage")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), stock.Available)
+
+	require.ErrorIs(t, s.Reserve("sale-2", []Line{{ProductID: "mate", Quantity: 0}}), ErrInvalidQuantity)
+	require.ErrorIs(t, s.Reserve("sale-2", nil), ErrInvalidQuantity)
+	require.Equal(t, "shrinkage", s.Ledger("mate")[2].Reason)
+}
+
+func TestService_ConcurrentReservationsNeverOversell(t *testing.T) {
+	for name, storage := range map[string]Storage{"local": NewLocalStorage(), "file": openFileStorage(t)} {
+		t.Run(name, func(t *testing.T) {
+			s := NewService(storage, nil)
+			const stock = 100
+			_, err := s.Adjust("mate", stock, "received")
+			require.NoError(t, err)
+			_, err = s.Adjust("bombilla", stock, "received")
+			require.NoError(t, err)
+
+			var reserved atomic.Int64
+			var wg sync.WaitGroup
+			for i := range 300 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					id := fmt.Sprintf("sale-%d", i)
+					// Alternate the product order to exercise the lock ordering.
+					lines := []Line{{ProductID: "mate", Quantity: 1}, {ProductID: "bombilla", Quantity: 1}}
+					if i%2 == 0 {
+						lines[0], lines[1] = lines[1], lines[0]
+					}
+					err := s.Reserve(id, lines)
+					if errors.Is(err, ErrInsufficientStock) {
+						return
+					}
+					require.NoError(t, err)
+					reserved.Add(1)
+					if i%3 == 0 {
+						require.NoError(t, s.Release(id))
+						reserved.Add(-1)
+						return
+					}
+					require.NoError(t, s.Commit(id))
+				}()
+			}
+			wg.Wait()
+
+			for _, p := range []string{"mate", "bombilla"} {
+				got := s.Stock(p)
+				require.GreaterOrEqual(t, got.OnHand, int64(0))
+				require.Equal(t, int64(0), got.Reserved)
+				require.Equal(t, stock-reserved.Load(), got.OnHand)
+			}
+		})
+	}
+}
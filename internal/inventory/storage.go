@@ -0,0 +1,353 @@
+package inventory
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrInsufficientStock is returned when there is not enough available stock
+// for a reservation or an adjustment.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrInvalidQuantity is returned for zero adjustments and non-positive reservations.
+var ErrInvalidQuantity = errors.New("invalid stock quantity")
+
+// ErrEmptyID is returned when a reservation or product ID is empty.
+var ErrEmptyID = errors.New("empty inventory ID")
+
+// ErrReservationNotFound is returned when committing or releasing an unknown reservation.
+var ErrReservationNotFound = errors.New("stock reservation not found")
+
+// ErrDuplicateReservation is returned when reserving again under an existing reservation ID.
+var ErrDuplicateReservation = errors.New("duplicate stock reservation")
+
+type Storage interface {
+	Adjust(productID string, quantity int64, reason string, at time.Time) (*Stock, error)
+	Reserve(id string, lines []Line, at time.Time) error
+	Commit(id string, at time.Time) error
+	Release(id string, at time.Time) error
+	Stock(productID string) *Stock
+	Ledger(productID string) []Movement
+}
+
+// shardCount is the number of independent partitions of LocalStorage.
+const shardCount = 32
+
+// level is the stock of a product together with its ledger.
+type level struct {
+	OnHand   int64      `json:"on_hand"`
+	Reserved int64      `json:"reserved"`
+	Ledger   []Movement `json:"ledger"`
+}
+
+// move applies a movement to the level and records it in the ledger.
+func (lv *level) move(m Movement) {
+	switch m.Kind {
+	case KindAdjust:
+		lv.OnHand += m.Quantity
+	case KindReserve:
+		lv.Reserved += m.Quantity
+	case KindCommit:
+		lv.Reserved -= m.Quantity
+		lv.OnHand -= m.Quantity
+	case KindRelease:
+		lv.Reserved -= m.Quantity
+	}
+	m.OnHand, m.Reserved = lv.OnHand, lv.Reserved
+	lv.Ledger = append(lv.Ledger, m)
+}
+
+// shard is a partition of the product stock levels guarded by its own lock.
+type shard struct {
+	mu     sync.Mutex
+	levels map[string]*level
+}
+
+// reservationShard is a partition of the open reservations.
+type reservationShard struct {
+	mu    sync.Mutex
+	lines map[string][]Line
+}
+
+// LocalStorage provides an in-memory implementation for tracking stock.
+// It is safe for concurrent use: stock levels are spread across shards by
+// product ID and open reservations across shards by reservation ID.
+// Operations lock the reservation shard first and then the product shards in
+// index order, so a reservation over several products is all-or-nothing and
+// two of them never deadlock.
+type LocalStorage struct {
+	shards       [shardCount]*shard
+	reservations [shardCount]*reservationShard
+}
+
+// NewLocalStorage instantiates a new LocalStorage with empty shards.
+func NewLocalStorage() *LocalStorage {
+	l := &LocalStorage{}
+	for i := range l.shards {
+		l.shards[i] = &shard{levels: make(map[string]*level)}
+		l.reservations[i] = &reservationShard{lines: make(map[string][]Line)}
+	}
+	return l
+}
+
+// shardIndex hashes key into one of the shardCount partitions.
+func shardIndex(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32() % shardCount
+}
+
+// lockProducts locks the shards of the given products in index order and
+// returns the function that unlocks them.
+func (l *LocalStorage) lockProducts(lines []Line) func() {
+	var idx []uint32
+	for _, line := range lines {
+		idx = append(idx, shardIndex(line.ProductID))
+	}
+	slices.Sort(idx)
+	idx = slices.Compact(idx)
+
+	for _, i := range idx {
+		l.shards[i].mu.Lock()
+	}
+	return func() {
+		for _, i := range idx {
+			l.shards[i].mu.Unlock()
+		}
+	}
+}
+
+// level returns the stock level of a product, creating it if needed.
+// The caller must hold the lock of its shard.
+func (l *LocalStorage) level(productID string) *level {
+	sh := l.shards[shardIndex(productID)]
+	lv, ok := sh.levels[productID]
+	if !ok {
+		lv = &level{}
+		sh.levels[productID] = lv
+	}
+	return lv
+}
+
+// Adjust adds quantity, which may be negative, to the stock on hand of a product.
+// Returns ErrInvalidQuantity for a zero quantity, or ErrInsufficientStock if
+// the stock on hand would drop below the reserved stock.
+func (l *LocalStorage) Adjust(productID string, quantity int64, reason string, at time.Time) (*Stock, error) {
+	return l.adjust(productID, quantity, reason, at, false)
+}
+
+// adjust implements Adjust. With dryRun it only reports whether Adjust would succeed.
+func (l *LocalStorage) adjust(productID string, quantity int64, reason string, at time.Time, dryRun bool) (*Stock, error) {
+	if productID == "" {
+		return nil, ErrEmptyID
+	}
+	if quantity == 0 {
+		return nil, ErrInvalidQuantity
+	}
+
+	unlock := l.lockProducts([]Line{{ProductID: productID}})
+	defer unlock()
+
+	lv := l.level(productID)
+	onHand := lv.OnHand + quantity
+	if (quantity > 0 && onHand < lv.OnHand) || onHand < lv.Reserved {
+		return nil, fmt.Errorf("%w: product %s has %d on hand and %d reserved", ErrInsufficientStock, productID, lv.OnHand, lv.Reserved)
+	}
+	if !dryRun {
+		lv.move(Movement{ProductID: productID, Kind: KindAdjust, Quantity: quantity, Reason: reason, At: at})
+	}
+	return stockOf(productID, lv), nil
+}
+
+// Reserve holds stock for every line under the reservation id. Either every
+// line is reserved or none is. Lines of the same product are merged.
+// Returns ErrInvalidQuantity for non-positive quantities, ErrInsufficientStock
+// if a product does not have enough available stock, or ErrDuplicateReservation
+// if id is already reserved.
+func (l *LocalStorage) Reserve(id string, lines []Line, at time.Time) error {
+	return l.reserve(id, lines, at, false)
+}
+
+// reserve implements Reserve. With dryRun it only reports whether Reserve would succeed.
+func (l *LocalStorage) reserve(id string, lines []Line, at time.Time, dryRun bool) error {
+	if id == "" {
+		return ErrEmptyID
+	}
+	lines, err := merge(lines)
+	if err != nil {
+		return err
+	}
+
+	rs := l.reservations[shardIndex(id)]
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if _, ok := rs.lines[id]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateReservation, id)
+	}
+
+	unlock := l.lockProducts(lines)
+	defer unlock()
+
+	for _, line := range lines {
+		var available int64
+		if lv, ok := l.shards[shardIndex(line.ProductID)].levels[line.ProductID]; ok {
+			available = lv.OnHand - lv.Reserved
+		}
+		if available < line.Quantity {
+			return fmt.Errorf("%w: product %s has %d available, %d requested", ErrInsufficientStock, line.ProductID, available, line.Quantity)
+		}
+	}
+	if dryRun {
+		return nil
+	}
+
+	for _, line := range lines {
+		l.level(line.ProductID).move(Movement{ProductID: line.ProductID, Kind: KindReserve, Quantity: line.Quantity, Reservation: id, At: at})
+	}
+	rs.lines[id] = lines
+	return nil
+}
+
+// Commit takes the stock held by the reservation id out of the stock on hand.
+// Returns ErrReservationNotFound if id is not reserved.
+func (l *LocalStorage) Commit(id string, at time.Time) error {
+	return l.settle(id, KindCommit, at, false)
+}
+
+// Release gives the stock held by the reservation id back.
+// Returns ErrReservationNotFound if id is not reserved.
+func (l *LocalStorage) Release(id string, at time.Time) error {
+	return l.settle(id, KindRelease, at, false)
+}
+
+// settle closes the reservation id with a commit or release movement. With
+// dryRun it only reports whether the reservation exists.
+func (l *LocalStorage) settle(id, kind string, at time.Time, dryRun bool) error {
+	rs := l.reservations[shardIndex(id)]
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	lines, ok := rs.lines[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrReservationNotFound, id)
+	}
+	if dryRun {
+		return nil
+	}
+
+	unlock := l.lockProducts(lines)
+	defer unlock()
+
+	for _, line := range lines {
+		l.level(line.ProductID).move(Movement{ProductID: line.ProductID, Kind: kind, Quantity: line.Quantity, Reservation: id, At: at})
+	}
+	delete(rs.lines, id)
+	return nil
+}
+
+// Stock returns the stock level of a product. Products without movements
+// have no stock.
+func (l *LocalStorage) Stock(productID string) *Stock {
+	unlock := l.lockProducts([]Line{{ProductID: productID}})
+	defer unlock()
+
+	sh := l.shards[shardIndex(productID)]
+	lv, ok := sh.levels[productID]
+	if !ok {
+		return &Stock{ProductID: productID}
+	}
+	return stockOf(productID, lv)
+}
+
+// Ledger returns a copy of the movements of a product, oldest first.
+func (l *LocalStorage) Ledger(productID string) []Movement {
+	unlock := l.lockProducts([]Line{{ProductID: productID}})
+	defer unlock()
+
+	sh := l.shards[shardIndex(productID)]
+	lv, ok := sh.levels[productID]
+	if !ok {
+		return nil
+	}
+	return slices.Clone(lv.Ledger)
+}
+
+// state is the whole content of a LocalStorage, as stored in snapshots.
+type state struct {
+	Levels       map[string]*level `json:"levels"`
+	Reservations map[string][]Line `json:"reservations"`
+}
+
+// all returns a copy of the whole storage.
+func (l *LocalStorage) all() state {
+	s := state{Levels: map[string]*level{}, Reservations: map[string][]Line{}}
+	for _, rs := range l.reservations {
+		rs.mu.Lock()
+		for id, lines := range rs.lines {
+			s.Reservations[id] = slices.Clone(lines)
+		}
+		rs.mu.Unlock()
+	}
+	for _, sh := range l.shards {
+		sh.mu.Lock()
+		for id, lv := range sh.levels {
+			s.Levels[id] = &level{OnHand: lv.OnHand, Reserved: lv.Reserved, Ledger: slices.Clone(lv.Ledger)}
+		}
+		sh.mu.Unlock()
+	}
+	return s
+}
+
+// restore replaces the content of an unused storage.
+func (l *LocalStorage) restore(s state) {
+	for id, lv := range s.Levels {
+		l.shards[shardIndex(id)].levels[id] = lv
+	}
+	for id, lines := range s.Reservations {
+		l.reservations[shardIndex(id)].lines[id] = lines
+	}
+}
+
+func stockOf(productID string, lv *level) *Stock {
+	return &Stock{
+		ProductID: productID,
+		OnHand:    lv.OnHand,
+		Reserved:  lv.Reserved,
+		Available: lv.OnHand - lv.Reserved,
+	}
+}
+
+// merge validates lines and adds up the quantities of each product, sorted
+// by product ID.
+func merge(lines []Line) ([]Line, error) {
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%w: nothing to reserve", ErrInvalidQuantity)
+	}
+
+	var merged []Line
+	for _, line := range lines {
+		if line.ProductID == "" {
+			return nil, ErrEmptyID
+		}
+		if line.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: %d of product %s", ErrInvalidQuantity, line.Quantity, line.ProductID)
+		}
+		i := slices.IndexFunc(merged, func(m Line) bool { return m.ProductID == line.ProductID })
+		if i < 0 {
+			merged = append(merged, line)
+			continue
+		}
+		merged[i].Quantity += line.Quantity
+		if merged[i].Quantity < line.Quantity {
+			return nil, fmt.Errorf("%w: too much of product %s", ErrInvalidQuantity, line.ProductID)
+		}
+	}
+
+	slices.SortFunc(merged, func(a, b Line) int { return cmp.Compare(a.ProductID, b.ProductID) })
+	return merged, nil
+}
@@ -1,10 +1,143 @@
 package metadata
 
+import (
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/sale"
+	"encoding/json"
+)
+
 // Metadata represents a system sale with metadata for auditing and versioning.
+// Counts holds one counter per sale status in sale.Statuses; they are
+// rendered as top-level JSON fields named after each status.
+// Total_amount is exact and kept per currency, net of refunds.
+// Net_amount, Tax_amount and Gross_amount split the amounts the sales were
+// created with, before refunds. ByMethod breaks the sales with a payment
+// method down by its type.
 type Metadata struct {
-	Quantity     int     `json:"quantity"`
-	Approved     int     `json:"approved"`
-	Pending      int     `json:"pending"`
-	Rejected     int     `json:"rejected"`
-	Total_amount float32 `json:"total_amount"`
+	Quantity     int                           `json:"quantity"`
+	Counts       map[string]int                `json:"-"`
+	Total_amount money.Totals                  `json:"total_amount"`
+	Net_amount   money.Totals                  `json:"net_amount"`
+	Tax_amount   money.Totals                  `json:"tax_amount"`
+	Gross_amount money.Totals                  `json:"gross_amount"`
+	ByMethod     map[string]*sale.MethodTotals `json:"by_method"`
+}
+
+// New returns an empty Metadata with a zero counter for every sale status.
+func New() *Metadata {
+	m := &Metadata{
+		Counts:       make(map[string]int, len(sale.Statuses)),
+		Total_amount: money.Totals{},
+		Net_amount:   money.Totals{},
+		Tax_amount:   money.Totals{},
+		Gross_amount: money.Totals{},
+		ByMethod:     map[string]*sale.MethodTotals{},
+	}
+	for _, status := range sale.Statuses {
+		m.Counts[status] = 0
+	}
+	return m
+}
+
+// clone returns a deep copy of the metadata.
+func (m *Metadata) clone() *Metadata {
+	c := *m
+	c.Counts = make(map[string]int, len(m.Counts))
+	for k, v := range m.Counts {
+		c.Counts[k] = v
+	}
+	c.Total_amount = m.Total_amount.Clone()
+	c.Net_amount = m.Net_amount.Clone()
+	c.Tax_amount = m.Tax_amount.Clone()
+	c.Gross_amount = m.Gross_amount.Clone()
+	c.ByMethod = make(map[string]*sale.MethodTotals, len(m.ByMethod))
+	for k, v := range m.ByMethod {
+		c.ByMethod[k] = &sale.MethodTotals{Quantity: v.Quantity, Total_amount: v.Total_amount.Clone()}
+	}
+	return &c
+}
+
+// method returns the totals of a payment method, creating them if needed.
+func (m *Metadata) method(method string) *sale.MethodTotals {
+	t, ok := m.ByMethod[method]
+	if !ok {
+		t = &sale.MethodTotals{Total_amount: money.Totals{}}
+		m.ByMethod[method] = t
+	}
+	return t
+}
+
+// add counts a new sale into the summary.
+func (m *Metadata) add(s *sale.Sale) {
+	net, tax := s.NetAndTax()
+
+	m.Quantity++
+	m.Counts[s.Status]++
+	m.Total_amount.Add(s.Amount)
+	m.Net_amount.Add(net)
+	m.Tax_amount.Add(tax)
+	m.Gross_amount.Add(s.Amount)
+	if s.Payment != nil {
+		t := m.method(s.Payment.Type)
+		t.Quantity++
+		t.Total_amount.Add(s.Amount)
+	}
+}
+
+// MarshalJSON renders the quantity, one field per sale status, the amounts
+// and the breakdown by payment method.
+func (m *Metadata) MarshalJSON() ([]byte, error) {
+	out := make(map[string]any, len(sale.Statuses)+6)
+	out["quantity"] = m.Quantity
+	out["total_amount"] = m.Total_amount
+	out["net_amount"] = m.Net_amount
+	out["tax_amount"] = m.Tax_amount
+	out["gross_amount"] = m.Gross_amount
+	out["by_method"] = m.ByMethod
+	for _, status := range sale.Statuses {
+		out[status] = m.Counts[status]
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON reads the format written by MarshalJSON.
+func (m *Metadata) UnmarshalJSON(data []byte) error {
+	var in map[string]json.RawMessage
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	*m = *New()
+	if v, ok := in["quantity"]; ok {
+		if err := json.Unmarshal(v, &m.Quantity); err != nil {
+			return err
+		}
+	}
+	for key, totals := range map[string]*money.Totals{
+		"total_amount": &m.Total_amount,
+		"net_amount":   &m.Net_amount,
+		"tax_amount":   &m.Tax_amount,
+		"gross_amount": &m.Gross_amount,
+	} {
+		if v, ok := in[key]; ok {
+			if err := json.Unmarshal(v, totals); err != nil {
+				return err
+			}
+		}
+	}
+	if v, ok := in["by_method"]; ok {
+		if err := json.Unmarshal(v, &m.ByMethod); err != nil {
+			return err
+		}
+	}
+	for _, status := range sale.Statuses {
+		if v, ok := in[status]; ok {
+			var n int
+			if err := json.Unmarshal(v, &n); err != nil {
+				return err
+			}
+			m.Counts[status] = n
+		}
+	}
+	return nil
 }
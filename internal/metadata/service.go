@@ -1,29 +1,44 @@
 package metadata
 
-import "strings"
+import (
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/sale"
+	"errors"
+	"maps"
+	"strings"
+)
 
-// Service provides high-level sale management operations on a LocalStorage backend.
+// SalesSource gives access to the raw sales the summaries are built from.
+type SalesSource interface {
+	GetUserSales(id string, status string) ([]*sale.Sale, *sale.Metadata)
+}
+
+// Service maintains the per-user sales summary as a read model: it is updated
+// on every sale creation and status change, so reading it is O(1), and it can
+// be reconciled against the raw sales to detect and repair drift.
+// Summaries are kept in memory only: a user without one, such as every user
+// after a restart over durable sales, gets it built from the raw sales.
 type Service struct {
-	// storage is the underlying persistence for Sale entities.
+	// storage is the underlying persistence for Metadata entities.
 	storage *LocalStorage
+
+	// sales is the source of truth used by reconciliation.
+	sales SalesSource
 }
 
 // NewService creates a new Service.
-func NewService(storage *LocalStorage) *Service {
+func NewService(storage *LocalStorage, sales SalesSource) *Service {
 	return &Service{
 		storage: storage,
+		sales:   sales,
 	}
 }
 
 // Create adds a brand-new metadata to the system.
-// It sets CreatedAt and UpdatedAt to the current time and initializes Version to 1.
-// Returns ErrEmptyID if Metadata.ID is empty.
+// It resets the quantity, the total amount and every status counter.
+// Returns ErrEmptyIDMetadata if userId is empty.
 func (s *Service) Create(metadata *Metadata, userId string) error {
-	metadata.Approved = 0
-	metadata.Pending = 0
-	metadata.Quantity = 0
-	metadata.Rejected = 0
-	metadata.Total_amount = 0.0
+	*metadata = *New()
 
 	err := s.storage.SetMetadata(metadata, userId)
 	if err != nil {
@@ -32,23 +47,36 @@ func (s *Service) Create(metadata *Metadata, userId string) error {
 	return nil
 }
 
-func (s *Service) Update(status string, id string) (*Metadata, error) {
-	existing, err := s.storage.ReadMetadata(id)
-	if err != nil {
-		return nil, err
+// Update moves one sale of the user from one status counter to another.
+// Returns ErrNotValidOperation if the sale state machine does not allow it.
+func (s *Service) Update(from string, to string, id string) (*Metadata, error) {
+	from = strings.ToLower(from)
+	to = strings.ToLower(to)
+	if !sale.CanTransition(from, to) {
+		return nil, ErrNotValidOperation
 	}
 
-	status = strings.ToLower(status)
-	switch status {
-	case "approved":
-		s.changeToApproved(id)
-	case "rejected":
-		s.changeToRejected(id)
-	default:
-		return nil, ErrNotValidOperation
+	return s.upsert(id, func(m *Metadata) {
+		m.Counts[from]--
+		m.Counts[to]++
+	})
+}
+
+// upsert applies fn to the stored summary of the user. A user without one
+// gets it built from the raw sales instead, which already include the change
+// fn counts: observers are notified after the change is stored.
+// Changes racing with the build may be counted twice until the next
+// reconciliation.
+func (s *Service) upsert(userId string, fn func(m *Metadata)) (*Metadata, error) {
+	_, err := s.storage.ReadMetadata(userId)
+	if !errors.Is(err, ErrNotFoundMetadata) {
+		return s.storage.UpsertMetadata(userId, fn)
 	}
 
-	return existing, nil
+	if _, err := s.Reconcile(userId); err != nil {
+		return nil, err
+	}
+	return s.storage.ReadMetadata(userId)
 }
 
 func (s *Service) Get(userId string) *Metadata {
@@ -56,25 +84,150 @@ func (s *Service) Get(userId string) *Metadata {
 	return meta
 }
 
-func (s *Service) IncrementSale(estado string, userId string, totalAmount float32) {
-	s.storage.mapMeta[userId].Quantity++
-	s.storage.mapMeta[userId].Total_amount += totalAmount
-	switch estado {
-	case "approved":
-		s.storage.mapMeta[userId].Approved++
-	case "rejected":
-		s.storage.mapMeta[userId].Rejected++
-	case "pending":
-		s.storage.mapMeta[userId].Pending++
+// Summary returns the sales summary of the user. A user without a stored
+// summary gets one built from the raw sales.
+func (s *Service) Summary(userId string) (*Metadata, error) {
+	meta, err := s.storage.ReadMetadata(userId)
+	if err == nil {
+		return meta, nil
 	}
+
+	if _, err := s.Reconcile(userId); err != nil {
+		return nil, err
+	}
+	return s.storage.ReadMetadata(userId)
+}
+
+// IncrementSale counts a new sale of the user with the given status, paid
+// with method. An empty method leaves the sale out of the breakdown by method.
+// Returns ErrNotValidOperation if the status is unknown.
+func (s *Service) IncrementSale(estado string, userId string, totalAmount money.Money, method string) error {
+	created := &sale.Sale{UserId: userId, Amount: totalAmount, Status: estado}
+	if method != "" {
+		created.Payment = &sale.PaymentMethod{Type: method}
+	}
+	return s.SaleCreated(created)
+}
+
+// SaleCreated implements sale.Observer.
+// Returns ErrNotValidOperation if the status of the sale is unknown.
+func (s *Service) SaleCreated(created *sale.Sale) error {
+	if !sale.IsValidStatus(created.Status) {
+		return ErrNotValidOperation
+	}
+
+	_, err := s.upsert(created.UserId, func(m *Metadata) {
+		m.add(created)
+	})
+	return err
+}
+
+// SaleStatusChanged implements sale.Observer.
+func (s *Service) SaleStatusChanged(sale *sale.Sale, from string) error {
+	_, err := s.Update(from, sale.Status, sale.UserId)
+	return err
 }
 
-func (s *Service) changeToApproved(userId string) {
-	s.storage.mapMeta[userId].Approved++
-	s.storage.mapMeta[userId].Pending--
+// SaleRefunded implements sale.Observer. The refund is taken out of the
+// total amount; the status counters follow SaleStatusChanged.
+func (s *Service) SaleRefunded(sale *sale.Sale, refund *sale.Refund) error {
+	method := paymentType(sale)
+	_, err := s.upsert(sale.UserId, func(m *Metadata) {
+		m.Total_amount.Sub(refund.Amount)
+		if method != "" {
+			m.method(method).Total_amount.Sub(refund.Amount)
+		}
+	})
+	return err
 }
 
-func (s *Service) changeToRejected(userId string) {
-	s.storage.mapMeta[userId].Rejected++
-	s.storage.mapMeta[userId].Pending--
+// paymentType returns the payment method type of the sale, or "" if it has none.
+func paymentType(sale *sale.Sale) string {
+	if sale.Payment == nil {
+		return ""
+	}
+	return sale.Payment.Type
+}
+
+// Drift describes a stored summary that disagreed with the raw sales.
+type Drift struct {
+	UserId   string    `json:"user_id"`
+	Stored   *Metadata `json:"stored"`
+	Expected *Metadata `json:"expected"`
+}
+
+// Reconcile rebuilds the summary of the user from the raw sales and replaces
+// the stored one when they disagree. It returns the drift it repaired, or nil
+// when the summary was already correct.
+// Sales changing while it runs may leave the summary off until the next run.
+func (s *Service) Reconcile(userId string) (*Drift, error) {
+	_, raw := s.sales.GetUserSales(userId, "")
+	expected := FromSale(raw)
+
+	stored, err := s.storage.ReadMetadata(userId)
+	if err == nil && equal(stored, expected) {
+		return nil, nil
+	}
+
+	if err := s.storage.SetMetadata(expected, userId); err != nil {
+		return nil, err
+	}
+	return &Drift{UserId: userId, Stored: stored, Expected: expected}, nil
+}
+
+// ReconcileAll reconciles every stored summary and returns the drift repaired.
+func (s *Service) ReconcileAll() ([]*Drift, error) {
+	var drifts []*Drift
+	for _, id := range s.storage.ids() {
+		d, err := s.Reconcile(id)
+		if err != nil {
+			return drifts, err
+		}
+		if d != nil {
+			drifts = append(drifts, d)
+		}
+	}
+	return drifts, nil
+}
+
+// FromSale converts a summary computed by the sale storage.
+func FromSale(raw *sale.Metadata) *Metadata {
+	m := New()
+	m.Quantity = raw.Quantity
+	for _, status := range sale.Statuses {
+		m.Counts[status] = raw.Counts[status]
+	}
+	m.Total_amount = raw.Total_amount.Clone()
+	m.Net_amount = raw.Net_amount.Clone()
+	m.Tax_amount = raw.Tax_amount.Clone()
+	m.Gross_amount = raw.Gross_amount.Clone()
+	for method, t := range raw.ByMethod {
+		m.ByMethod[method] = &sale.MethodTotals{Quantity: t.Quantity, Total_amount: t.Total_amount.Clone()}
+	}
+	return m
+}
+
+// equal reports whether two summaries hold the same figures.
+func equal(a, b *Metadata) bool {
+	return a.Quantity == b.Quantity &&
+		maps.Equal(nonZero(a.Counts), nonZero(b.Counts)) &&
+		maps.Equal(nonZero(a.Total_amount), nonZero(b.Total_amount)) &&
+		maps.Equal(nonZero(a.Net_amount), nonZero(b.Net_amount)) &&
+		maps.Equal(nonZero(a.Tax_amount), nonZero(b.Tax_amount)) &&
+		maps.Equal(nonZero(a.Gross_amount), nonZero(b.Gross_amount)) &&
+		maps.EqualFunc(a.ByMethod, b.ByMethod, func(x, y *sale.MethodTotals) bool {
+			return x.Quantity == y.Quantity && maps.Equal(nonZero(x.Total_amount), nonZero(y.Total_amount))
+		})
+}
+
+// nonZero drops the zero entries of m, so a missing key equals a zero one.
+func nonZero[M ~map[string]V, V comparable](m M) M {
+	var zero V
+	out := make(M, len(m))
+	for k, v := range m {
+		if v != zero {
+			out[k] = v
+		}
+	}
+	return out
 }
@@ -0,0 +1,200 @@
+package metadata
+
+import (
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/sale"
+	"API_VentasGO/internal/tax"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_FollowsSaleChanges(t *testing.T) {
+	sales := sale.NewService(sale.NewLocalStorage(), nil, nil, nil, nil, nil, nil, nil, nil)
+	s := NewService(NewLocalStorage(), sales)
+	sales.AddObserver(s)
+
+	first := &sale.Sale{UserId: "1", Amount: money.New(1050, "ARS")}
+	require.NoError(t, sales.Create(first))
+	second := &sale.Sale{UserId: "1", Amount: money.New(2000, "ARS")}
+	require.NoError(t, sales.Create(second))
+	require.NoError(t, sales.Create(&sale.Sale{UserId: "2", Amount: money.New(1, "USD")}))
+
+	approved := sale.StatusApproved
+	_, err := sales.Update(first.ID, &sale.UpdateFields{Status: &approved})
+	require.NoError(t, err)
+
+	got, err := s.Summary("1")
+	require.NoError(t, err)
+	require.Equal(t, 2, got.Quantity)
+	require.Equal(t, 1, got.Counts[sale.StatusApproved])
+	require.Equal(t, 1, got.Counts[sale.StatusPending])
+	require.Equal(t, money.New(3050, "ARS"), got.Total_amount.Get("ARS"))
+
+	drift, err := s.Reconcile("1")
+	require.NoError(t, err)
+	require.Nil(t, drift)
+}
+
+func TestService_ReconcileRepairsDrift(t *testing.T) {
+	saleStorage := sale.NewLocalStorage()
+	sales := sale.NewService(saleStorage, nil, nil, nil, nil, nil, nil, nil, nil)
+	storage := NewLocalStorage()
+	s := NewService(storage, sales)
+	sales.AddObserver(s)
+
+	// Sales created by a service without the observer are missing from
+	// the summary.
+	require.NoError(t, sales.Create(&sale.Sale{UserId: "1", Amount: money.New(500, "ARS")}))
+	unobserved := sale.NewService(saleStorage, nil, nil, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, unobserved.Create(&sale.Sale{UserId: "1", Amount: money.New(700, "ARS")}))
+
+	stored, err := storage.ReadMetadata("1")
+	require.NoError(t, err)
+	require.Equal(t, 1, stored.Quantity)
+
+	drifts, err := s.ReconcileAll()
+	require.NoError(t, err)
+	require.Len(t, drifts, 1)
+	require.Equal(t, "1", drifts[0].UserId)
+	require.Equal(t, 1, drifts[0].Stored.Quantity)
+	require.Equal(t, 2, drifts[0].Expected.Quantity)
+
+	repaired, err := s.Summary("1")
+	require.NoError(t, err)
+	require.Equal(t, 2, repaired.Quantity)
+	require.Equal(t, money.New(1200, "ARS"), repaired.Total_amount.Get("ARS"))
+
+	drifts, err = s.ReconcileAll()
+	require.NoError(t, err)
+	require.Empty(t, drifts)
+}
+
+func TestService_SummaryBuildsMissingModel(t *testing.T) {
+	sales := sale.NewService(sale.NewLocalStorage(), nil, nil, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, sales.Create(&sale.Sale{UserId: "1", Amount: money.New(500, "ARS")}))
+	s := NewService(NewLocalStorage(), sales)
+
+	got, err := s.Summary("1")
+	require.NoError(t, err)
+	require.Equal(t, 1, got.Quantity)
+
+	empty, err := s.Summary("nobody")
+	require.NoError(t, err)
+	require.Equal(t, 0, empty.Quantity)
+}
+
+func TestService_BuildsMissingSummaryOnChange(t *testing.T) {
+	// Sales stored before the summaries existed, as after a restart.
+	saleStorage := sale.NewLocalStorage()
+	before := sale.NewService(saleStorage, nil, nil, nil, nil, nil, nil, nil, nil)
+	first := &sale.Sale{UserId: "1", Amount: money.New(500, "ARS")}
+	require.NoError(t, before.Create(first))
+	require.NoError(t, before.Create(&sale.Sale{UserId: "1", Amount: money.New(700, "ARS")}))
+
+	sales := sale.NewService(saleStorage, nil, nil, nil, nil, nil, nil, nil, nil)
+	storage := NewLocalStorage()
+	s := NewService(storage, sales)
+	sales.AddObserver(s)
+
+	approved := sale.StatusApproved
+	_, err := sales.Update(first.ID, &sale.UpdateFields{Status: &approved})
+	require.NoError(t, err)
+	require.NoError(t, sales.Create(&sale.Sale{UserId: "1", Amount: money.New(300, "ARS")}))
+
+	got, err := storage.ReadMetadata("1")
+	require.NoError(t, err)
+	require.Equal(t, 3, got.Quantity)
+	require.Equal(t, 1, got.Counts[sale.StatusApproved])
+	require.Equal(t, 2, got.Counts[sale.StatusPending])
+	require.Equal(t, money.New(1500, "ARS"), got.Total_amount.Get("ARS"))
+
+	drift, err := s.Reconcile("1")
+	require.NoError(t, err)
+	require.Nil(t, drift)
+}
+
+func TestService_SubtractsRefunds(t *testing.T) {
+	sales := sale.NewService(sale.NewLocalStorage(), nil, nil, nil, nil, nil, nil, &sale.RuleAuthorizer{Default: sale.StatusApproved}, nil)
+	s := NewService(NewLocalStorage(), sales)
+	sales.AddObserver(s)
+
+	first := &sale.Sale{UserId: "1", Amount: money.New(1000, "ARS")}
+	require.NoError(t, sales.Create(first))
+	second := &sale.Sale{UserId: "1", Amount: money.New(500, "ARS")}
+	require.NoError(t, sales.Create(second))
+
+	partial := money.New(300, "ARS")
+	_, _, err := sales.Refund(first.ID, &sale.RefundFields{Amount: &partial})
+	require.NoError(t, err)
+	_, _, err = sales.Refund(second.ID, &sale.RefundFields{})
+	require.NoError(t, err)
+
+	got, err := s.Summary("1")
+	require.NoError(t, err)
+	require.Equal(t, 2, got.Quantity)
+	require.Equal(t, 1, got.Counts[sale.StatusPartiallyRefunded])
+	require.Equal(t, 1, got.Counts[sale.StatusRefunded])
+	require.Equal(t, money.New(700, "ARS"), got.Total_amount.Get("ARS"))
+
+	drift, err := s.Reconcile("1")
+	require.NoError(t, err)
+	require.Nil(t, drift)
+}
+
+func TestService_BreaksDownByMethod(t *testing.T) {
+	sales := sale.NewService(sale.NewLocalStorage(), nil, nil, nil, nil, nil, nil, nil, nil)
+	s := NewService(NewLocalStorage(), sales)
+	sales.AddObserver(s)
+
+	cash := &sale.Sale{UserId: "1", Amount: money.New(1000, "ARS"), Payment: &sale.PaymentMethod{Type: sale.MethodCash}}
+	require.NoError(t, sales.Create(cash))
+	require.NoError(t, sales.Create(&sale.Sale{UserId: "1", Amount: money.New(5, "USD"), Payment: &sale.PaymentMethod{Type: sale.MethodCash}}))
+	require.NoError(t, sales.Create(&sale.Sale{UserId: "1", Amount: money.New(700, "ARS"), Payment: &sale.PaymentMethod{Type: sale.MethodWallet, Wallet: "modo"}}))
+	require.NoError(t, sales.Create(&sale.Sale{UserId: "1", Amount: money.New(300, "ARS")}))
+
+	refund := money.New(400, "ARS")
+	_, _, err := sales.Refund(cash.ID, &sale.RefundFields{Amount: &refund})
+	require.NoError(t, err)
+
+	got, err := s.Summary("1")
+	require.NoError(t, err)
+	require.Equal(t, 4, got.Quantity)
+	require.Len(t, got.ByMethod, 2)
+	require.Equal(t, 2, got.ByMethod[sale.MethodCash].Quantity)
+	require.Equal(t, money.New(600, "ARS"), got.ByMethod[sale.MethodCash].Total_amount.Get("ARS"))
+	require.Equal(t, money.New(5, "USD"), got.ByMethod[sale.MethodCash].Total_amount.Get("USD"))
+	require.Equal(t, 1, got.ByMethod[sale.MethodWallet].Quantity)
+
+	drift, err := s.Reconcile("1")
+	require.NoError(t, err)
+	require.Nil(t, drift)
+}
+
+func TestService_SplitsNetAndTax(t *testing.T) {
+	sales := sale.NewService(sale.NewLocalStorage(), nil, nil, nil, flatTax{}, nil, nil, nil, nil)
+	s := NewService(NewLocalStorage(), sales)
+	sales.AddObserver(s)
+
+	require.NoError(t, sales.Create(&sale.Sale{UserId: "1", Amount: money.New(10000, "ARS")}))
+	require.NoError(t, sales.Create(&sale.Sale{UserId: "1", Amount: money.New(5000, "ARS")}))
+
+	got, err := s.Summary("1")
+	require.NoError(t, err)
+	require.Equal(t, money.New(15000, "ARS"), got.Net_amount.Get("ARS"))
+	require.Equal(t, money.New(3150, "ARS"), got.Tax_amount.Get("ARS"))
+	require.Equal(t, money.New(18150, "ARS"), got.Gross_amount.Get("ARS"))
+	require.Equal(t, got.Gross_amount, got.Total_amount)
+
+	drift, err := s.Reconcile("1")
+	require.NoError(t, err)
+	require.Nil(t, drift)
+}
+
+// flatTax charges 21% IVA on every sale.
+type flatTax struct{}
+
+func (flatTax) Compute(_ string, lines []tax.Line) ([]tax.TaxLine, error) {
+	rules := &tax.Rules{IVA: tax.IVA{Default: "21"}}
+	return rules.Compute(lines, "")
+}
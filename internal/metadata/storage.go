@@ -1,6 +1,10 @@
 package metadata
 
-import "errors"
+import (
+	"errors"
+	"hash/fnv"
+	"sync"
+)
 
 // ErrEmpty is returned when trying to store a metada with an empty ID.
 var ErrEmptyIDMetadata = errors.New("empty metadata")
@@ -11,16 +15,38 @@ var ErrNotFoundMetadata = errors.New("metadata not found")
 // ErrNo inValidOperation is returned when the user performs an invalid operation.
 var ErrNotValidOperation = errors.New("invalid operation")
 
+// shardCount is the number of independent partitions of LocalStorage.
+const shardCount = 32
+
+// shard is a partition of LocalStorage guarded by its own lock.
+type shard struct {
+	mu      sync.RWMutex
+	mapMeta map[string]*Metadata
+}
+
 // LocalStorage provides an in-memory implementation for storing sales.
+// It is safe for concurrent use: metadata is spread across shards by user ID
+// so requests for different users do not contend on the same lock.
+// Metadata is copied on the way in and out, so callers never share memory
+// with the store.
 type LocalStorage struct {
-	mapMeta map[string]*Metadata
+	shards [shardCount]*shard
 }
 
-// NewLocalStorage instantiates a new LocalStorage with an empty map.
+// NewLocalStorage instantiates a new LocalStorage with empty shards.
 func NewLocalStorage() *LocalStorage {
-	return &LocalStorage{
-		mapMeta: map[string]*Metadata{},
+	l := &LocalStorage{}
+	for i := range l.shards {
+		l.shards[i] = &shard{mapMeta: map[string]*Metadata{}}
 	}
+	return l
+}
+
+// shardFor returns the shard that owns the metadata of the given user.
+func (l *LocalStorage) shardFor(id string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return l.shards[h.Sum32()%shardCount]
 }
 
 // Set stores or updates a metadata in the local storage.
@@ -29,29 +55,93 @@ func (l *LocalStorage) SetMetadata(metadata *Metadata, id string) error {
 	if id == "" {
 		return ErrEmptyIDMetadata
 	}
-	l.mapMeta[id] = metadata
+
+	m := metadata.clone()
+	sh := l.shardFor(id)
+	sh.mu.Lock()
+	sh.mapMeta[id] = m
+	sh.mu.Unlock()
 	return nil
 }
 
 // Read retrieves a metadata from the local storage by ID.
 // Returns ErrNotFoundMetadata if the metadata is not found.
 func (l *LocalStorage) ReadMetadata(id string) (*Metadata, error) {
-	u, ok := l.mapMeta[id]
+	sh := l.shardFor(id)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	u, ok := sh.mapMeta[id]
 	if !ok {
 		return nil, ErrNotFoundMetadata
 	}
 
-	return u, nil
+	return u.clone(), nil
+}
+
+// UpdateMetadata applies fn to the stored metadata of the given ID while
+// holding its shard lock, so concurrent updates are never lost.
+// Returns a copy of the updated metadata, or ErrNotFoundMetadata if the
+// metadata does not exist.
+func (l *LocalStorage) UpdateMetadata(id string, fn func(metadata *Metadata)) (*Metadata, error) {
+	sh := l.shardFor(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	u, ok := sh.mapMeta[id]
+	if !ok {
+		return nil, ErrNotFoundMetadata
+	}
+
+	fn(u)
+	return u.clone(), nil
+}
+
+// UpsertMetadata is like UpdateMetadata but starts from an empty Metadata
+// when the given ID has none yet.
+func (l *LocalStorage) UpsertMetadata(id string, fn func(metadata *Metadata)) (*Metadata, error) {
+	if id == "" {
+		return nil, ErrEmptyIDMetadata
+	}
+
+	sh := l.shardFor(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	u, ok := sh.mapMeta[id]
+	if !ok {
+		u = New()
+		sh.mapMeta[id] = u
+	}
+
+	fn(u)
+	return u.clone(), nil
+}
+
+// ids returns the IDs of every stored metadata.
+func (l *LocalStorage) ids() []string {
+	var ids []string
+	for _, sh := range l.shards {
+		sh.mu.RLock()
+		for id := range sh.mapMeta {
+			ids = append(ids, id)
+		}
+		sh.mu.RUnlock()
+	}
+	return ids
 }
 
 // Delete removes metadata from the local storage by ID.
 // Returns ErrNotFoundMetadata if the metadata does not exist.
 func (l *LocalStorage) DeleteMetadata(id string) error {
-	_, err := l.ReadMetadata(id)
-	if err != nil {
-		return err
+	sh := l.shardFor(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if _, ok := sh.mapMeta[id]; !ok {
+		return ErrNotFoundMetadata
 	}
 
-	delete(l.mapMeta, id)
+	delete(sh.mapMeta, id)
 	return nil
 }
@@ -0,0 +1,388 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownCurrency is returned for currency codes missing from the currencies table.
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// ErrInvalidAmount is returned when an amount cannot be parsed.
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// ErrCurrencyMismatch is returned when operating on amounts of different currencies.
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// ErrOverflow is returned when an amount does not fit in 64-bit minor units.
+var ErrOverflow = errors.New("amount out of range")
+
+// DefaultCurrency is assumed for amounts sent without a currency code.
+const DefaultCurrency = "ARS"
+
+// currencies maps the supported ISO-4217 codes to their number of decimals.
+var currencies = map[string]int{
+	"ARS": 2,
+	"BRL": 2,
+	"CLP": 0,
+	"EUR": 2,
+	"JPY": 0,
+	"MXN": 2,
+	"PYG": 0,
+	"USD": 2,
+	"UYU": 2,
+}
+
+// Money is an exact amount of a currency, stored as an integer number of
+// minor units (cents for ARS or USD, whole units for CLP).
+//
+// Its JSON form is {"minor_units":150025,"currency":"ARS","value":"1500.25"}.
+// When decoding, the object form may carry either minor_units or value, and a
+// plain number or string such as 1500.25 or "1500.25" is read as a decimal in
+// DefaultCurrency; see FromJSON to decode it in another currency.
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+// New returns an amount of minor units of currency.
+func New(minor int64, currency string) Money {
+	return Money{Minor: minor, Currency: currency}
+}
+
+// Exponent returns the number of decimals of currency.
+// Returns ErrUnknownCurrency if the code is not supported.
+func Exponent(currency string) (int, error) {
+	exp, ok := currencies[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+	return exp, nil
+}
+
+// decimal matches the amounts Parse accepts: decimals with an optional
+// exponent. It keeps out the fractions and hexadecimal forms big.Rat also
+// reads, and bounds the exponent so it cannot blow up the computation.
+var decimal = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d{1,4})?$`)
+
+// Parse reads a decimal amount of currency, such as "1500.25" or "1.5e3".
+// Digits beyond the currency decimals are rounded half away from zero, so
+// "0.125" ARS is 13 cents and "-0.125" ARS is -13 cents.
+func Parse(value string, currency string) (Money, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	exp, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	value = strings.TrimSpace(value)
+	if !decimal.MatchString(value) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+	}
+	r, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+	}
+
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)
+	r.Mul(r, new(big.Rat).SetInt(scale))
+
+	minor, ok := round(r)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrOverflow, value)
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+// round rounds r half away from zero: q = trunc(num/den), plus one unit when
+// the remainder is at least half of den. It reports false when the result
+// does not fit in an int64.
+func round(r *big.Rat) (int64, bool) {
+	num, den := r.Num(), r.Denom()
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	rem.Abs(rem).Mul(rem, big.NewInt(2))
+	if rem.Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+
+	if !q.IsInt64() {
+		return 0, false
+	}
+	return q.Int64(), true
+}
+
+// FromJSON decodes an amount in any of the accepted JSON forms. Plain numbers
+// and strings are read in currency, or in DefaultCurrency when it is empty.
+// Objects carry their own currency; when currency is given, an object in a
+// different one is rejected with ErrCurrencyMismatch.
+func FromJSON(data []byte, currency string) (Money, error) {
+	requested := strings.ToUpper(strings.TrimSpace(currency))
+	currency = requested
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return Money{}, fmt.Errorf("%w: missing", ErrInvalidAmount)
+	}
+
+	switch data[0] {
+	case '{':
+		var obj struct {
+			Minor    *int64  `json:"minor_units"`
+			Value    *string `json:"value"`
+			Currency string  `json:"currency"`
+		}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return Money{}, fmt.Errorf("%w: %v", ErrInvalidAmount, err)
+		}
+		objCurrency := strings.ToUpper(strings.TrimSpace(obj.Currency))
+		if objCurrency == "" {
+			objCurrency = currency
+		}
+		if requested != "" && objCurrency != requested {
+			return Money{}, fmt.Errorf("%w: amount in %s, request in %s", ErrCurrencyMismatch, objCurrency, requested)
+		}
+
+		var m Money
+		switch {
+		case obj.Minor != nil:
+			if _, err := Exponent(objCurrency); err != nil {
+				return Money{}, err
+			}
+			m = Money{Minor: *obj.Minor, Currency: objCurrency}
+		case obj.Value != nil:
+			var err error
+			if m, err = Parse(*obj.Value, objCurrency); err != nil {
+				return Money{}, err
+			}
+		default:
+			return Money{}, fmt.Errorf("%w: needs minor_units or value", ErrInvalidAmount)
+		}
+
+		if obj.Minor != nil && obj.Value != nil {
+			v, err := Parse(*obj.Value, objCurrency)
+			if err != nil {
+				return Money{}, err
+			}
+			if v.Minor != m.Minor {
+				return Money{}, fmt.Errorf("%w: minor_units and value disagree", ErrInvalidAmount)
+			}
+		}
+		return m, nil
+	case '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return Money{}, fmt.Errorf("%w: %v", ErrInvalidAmount, err)
+		}
+		return Parse(s, currency)
+	default:
+		return Parse(string(data), currency)
+	}
+}
+
+// MarshalJSON renders the amount as an object with minor units, currency and
+// the exact decimal value.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Minor    int64  `json:"minor_units"`
+		Currency string `json:"currency"`
+		Value    string `json:"value"`
+	}{m.Minor, m.Currency, m.Decimal()})
+}
+
+// UnmarshalJSON accepts every form described in FromJSON, using DefaultCurrency
+// for plain numbers and strings.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	v, err := FromJSON(data, "")
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Decimal renders the amount as an exact decimal string, e.g. "1500.25".
+func (m Money) Decimal() string {
+	exp := currencies[m.Currency]
+	if exp == 0 {
+		return fmt.Sprintf("%d", m.Minor)
+	}
+
+	sign := ""
+	minor := new(big.Int).SetInt64(m.Minor)
+	if minor.Sign() < 0 {
+		sign = "-"
+		minor.Abs(minor)
+	}
+	digits := fmt.Sprintf("%0*s", exp+1, minor.String())
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// String renders the amount with its currency, e.g. "1500.25 ARS".
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.Minor == 0
+}
+
+// IsPositive reports whether the amount is greater than zero.
+func (m Money) IsPositive() bool {
+	return m.Minor > 0
+}
+
+// Add returns m + o.
+// Returns ErrCurrencyMismatch if the currencies differ.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	sum := m.Minor + o.Minor
+	if (o.Minor > 0 && sum < m.Minor) || (o.Minor < 0 && sum > m.Minor) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Minor: sum, Currency: m.Currency}, nil
+}
+
+// Sub returns m - o.
+// Returns ErrCurrencyMismatch if the currencies differ.
+func (m Money) Sub(o Money) (Money, error) {
+	return m.Add(Money{Minor: -o.Minor, Currency: o.Currency})
+}
+
+// Mul returns m * n.
+// Returns ErrOverflow if the product does not fit in 64-bit minor units.
+func (m Money) Mul(n int64) (Money, error) {
+	if n != 0 && m.Minor != 0 {
+		p := m.Minor * n
+		if p/n != m.Minor || (m.Minor == -1 && n == math.MinInt64) || (n == -1 && m.Minor == math.MinInt64) {
+			return Money{}, ErrOverflow
+		}
+		return Money{Minor: p, Currency: m.Currency}, nil
+	}
+	return Money{Currency: m.Currency}, nil
+}
+
+// Percent returns pct percent of m, rounded half away from zero to minor units.
+// Returns ErrOverflow if the result does not fit in 64-bit minor units.
+func (m Money) Percent(pct *big.Rat) (Money, error) {
+	r := new(big.Rat).SetInt64(m.Minor)
+	r.Mul(r, pct).Quo(r, big.NewRat(100, 1))
+
+	minor, ok := round(r)
+	if !ok {
+		return Money{}, ErrOverflow
+	}
+	return Money{Minor: minor, Currency: m.Currency}, nil
+}
+
+// Split divides m into n parts that add up exactly to m. Parts differ by at
+// most one minor unit, and the larger ones come first.
+// Returns ErrInvalidAmount if n is not greater than 0.
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: cannot split in %d parts", ErrInvalidAmount, n)
+	}
+
+	base, rem := m.Minor/int64(n), m.Minor%int64(n)
+	unit := int64(1)
+	if rem < 0 {
+		unit, rem = -1, -rem
+	}
+
+	parts := make([]Money, n)
+	for i := range parts {
+		parts[i] = Money{Minor: base, Currency: m.Currency}
+		if int64(i) < rem {
+			parts[i].Minor += unit
+		}
+	}
+	return parts, nil
+}
+
+// Cmp compares m and o, returning -1, 0 or +1.
+// Returns ErrCurrencyMismatch if the currencies differ.
+func (m Money) Cmp(o Money) (int, error) {
+	if m.Currency != o.Currency {
+		return 0, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	switch {
+	case m.Minor < o.Minor:
+		return -1, nil
+	case m.Minor > o.Minor:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Totals accumulates exact amounts per currency.
+// Its JSON form is a list of Money sorted by currency code.
+type Totals map[string]int64
+
+// Add accumulates m into the totals of its currency.
+func (t Totals) Add(m Money) {
+	t[m.Currency] += m.Minor
+}
+
+// Sub removes m from the totals of its currency.
+func (t Totals) Sub(m Money) {
+	t[m.Currency] -= m.Minor
+}
+
+// Get returns the total of currency.
+func (t Totals) Get(currency string) Money {
+	return Money{Minor: t[currency], Currency: currency}
+}
+
+// Clone returns a copy of the totals.
+func (t Totals) Clone() Totals {
+	c := make(Totals, len(t))
+	for k, v := range t {
+		c[k] = v
+	}
+	return c
+}
+
+// List returns one Money per currency, sorted by currency code.
+func (t Totals) List() []Money {
+	list := make([]Money, 0, len(t))
+	for currency, minor := range t {
+		list = append(list, Money{Minor: minor, Currency: currency})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Currency < list[j].Currency })
+	return list
+}
+
+// MarshalJSON renders the totals as a list of Money.
+func (t Totals) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.List())
+}
+
+// UnmarshalJSON reads the list written by MarshalJSON.
+func (t *Totals) UnmarshalJSON(data []byte) error {
+	var list []Money
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*t = make(Totals, len(list))
+	for _, m := range list {
+		t.Add(m)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package money
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		currency string
+		want     Money
+		wantErr  error
+	}{
+		{name: "legacy number", input: `1500.25`, want: New(150025, "ARS")},
+		{name: "legacy integer", input: `15000`, want: New(1500000, "ARS")},
+		{name: "legacy exponent", input: `1.5e3`, want: New(150000, "ARS")},
+		{name: "string", input: `"0.1"`, want: New(10, "ARS")},
+		{name: "number in given currency", input: `1500`, currency: "clp", want: New(1500, "CLP")},
+		{name: "object minor units", input: `{"minor_units":150025,"currency":"USD"}`, want: New(150025, "USD")},
+		{name: "object value", input: `{"value":"10.5","currency":"USD"}`, want: New(1050, "USD")},
+		{name: "object defaults currency", input: `{"value":"10.5"}`, currency: "USD", want: New(1050, "USD")},
+		{name: "round half up", input: `"0.125"`, want: New(13, "ARS")},
+		{name: "round down", input: `"0.124"`, want: New(12, "ARS")},
+		{name: "round negative half away from zero", input: `"-0.125"`, want: New(-13, "ARS")},
+		{name: "unknown currency", input: `1`, currency: "XXX", wantErr: ErrUnknownCurrency},
+		{name: "garbage", input: `"abc"`, wantErr: ErrInvalidAmount},
+		{name: "missing", input: `null`, wantErr: ErrInvalidAmount},
+		{name: "object without amount", input: `{"currency":"USD"}`, wantErr: ErrInvalidAmount},
+		{name: "object disagrees", input: `{"minor_units":1,"value":"1"}`, wantErr: ErrInvalidAmount},
+		{name: "overflow", input: `"1e30"`, wantErr: ErrOverflow},
+		{name: "object in another currency", input: `{"value":"10","currency":"USD"}`, currency: "ARS", wantErr: ErrCurrencyMismatch},
+		{name: "object in the given currency", input: `{"value":"10","currency":"usd"}`, currency: "USD", want: New(1000, "USD")},
+		{name: "fraction", input: `"1/3"`, wantErr: ErrInvalidAmount},
+		{name: "hexadecimal", input: `"0x10"`, wantErr: ErrInvalidAmount},
+		{name: "hexadecimal float", input: `"0x1p4"`, wantErr: ErrInvalidAmount},
+		{name: "underscores", input: `"1_000"`, wantErr: ErrInvalidAmount},
+		{name: "huge exponent", input: `"1e999999999"`, wantErr: ErrInvalidAmount},
+		{name: "leading dot", input: `".5"`, want: New(50, "ARS")},
+		{name: "signed exponent", input: `"+15E-1"`, want: New(150, "ARS")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromJSON([]byte(tt.input), tt.currency)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMoney_JSONRoundTrip(t *testing.T) {
+	for _, m := range []Money{New(150025, "ARS"), New(-5, "USD"), New(1500, "CLP"), New(0, "ARS")} {
+		data, err := json.Marshal(m)
+		require.NoError(t, err)
+
+		var got Money
+		require.NoError(t, json.Unmarshal(data, &got))
+		require.Equal(t, m, got)
+	}
+
+	data, err := json.Marshal(New(-5, "USD"))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"minor_units":-5,"currency":"USD","value":"-0.05"}`, string(data))
+}
+
+func TestTotals_Exact(t *testing.T) {
+	totals := Totals{}
+	cent, err := Parse("0.10", "ARS")
+	require.NoError(t, err)
+	for i := 0; i < 100000; i++ {
+		totals.Add(cent)
+	}
+	totals.Add(New(1, "USD"))
+
+	require.Equal(t, "10000.00", totals.Get("ARS").Decimal())
+	require.Equal(t, []Money{New(1000000, "ARS"), New(1, "USD")}, totals.List())
+
+	_, err = New(1, "ARS").Add(New(1, "USD"))
+	require.ErrorIs(t, err, ErrCurrencyMismatch)
+}
+
+func TestMoney_Mul(t *testing.T) {
+	got, err := New(1999, "ARS").Mul(3)
+	require.NoError(t, err)
+	require.Equal(t, New(5997, "ARS"), got)
+
+	got, err = New(1999, "ARS").Mul(0)
+	require.NoError(t, err)
+	require.Equal(t, New(0, "ARS"), got)
+
+	_, err = New(math.MaxInt64/2+1, "ARS").Mul(2)
+	require.ErrorIs(t, err, ErrOverflow)
+	_, err = New(-1, "ARS").Mul(math.MinInt64)
+	require.ErrorIs(t, err, ErrOverflow)
+}
+
+func TestMoney_Percent(t *testing.T) {
+	tests := []struct {
+		amount Money
+		pct    string
+		want   Money
+	}{
+		{amount: New(10000, "ARS"), pct: "15", want: New(1500, "ARS")},
+		{amount: New(999, "ARS"), pct: "12.5", want: New(125, "ARS")},
+		{amount: New(333, "CLP"), pct: "10", want: New(33, "CLP")},
+		{amount: New(-999, "ARS"), pct: "12.5", want: New(-125, "ARS")},
+		{amount: New(12345, "ARS"), pct: "0", want: New(0, "ARS")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.amount.String()+"@"+tt.pct, func(t *testing.T) {
+			pct, _ := new(big.Rat).SetString(tt.pct)
+			got, err := tt.amount.Percent(pct)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := New(math.MaxInt64, "ARS").Percent(big.NewRat(200, 1))
+	require.ErrorIs(t, err, ErrOverflow)
+}
+
+func TestMoney_Split(t *testing.T) {
+	tests := []struct {
+		amount Money
+		n      int
+		want   []int64
+	}{
+		{amount: New(1000, "ARS"), n: 4, want: []int64{250, 250, 250, 250}},
+		{amount: New(1000, "ARS"), n: 3, want: []int64{334, 333, 333}},
+		{amount: New(2, "ARS"), n: 3, want: []int64{1, 1, 0}},
+		{amount: New(-1000, "ARS"), n: 3, want: []int64{-334, -333, -333}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.amount, tt.n), func(t *testing.T) {
+			parts, err := tt.amount.Split(tt.n)
+			require.NoError(t, err)
+
+			var got []int64
+			var sum int64
+			for _, p := range parts {
+				require.Equal(t, tt.amount.Currency, p.Currency)
+				got = append(got, p.Minor)
+				sum += p.Minor
+			}
+			require.Equal(t, tt.want, got)
+			require.Equal(t, tt.amount.Minor, sum)
+		})
+	}
+
+	_, err := New(1000, "ARS").Split(0)
+	require.ErrorIs(t, err, ErrInvalidAmount)
+}
@@ -0,0 +1,194 @@
+package policy
+
+import (
+	"API_VentasGO/internal/auth"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Permissions that can be granted to a role.
+const (
+	UsersCreate = "users:create"
+	UsersRead   = "users:read"
+	UsersUpdate = "users:update"
+	UsersDelete = "users:delete"
+
+	SalesCreate = "sales:create"
+	SalesRead   = "sales:read"
+	// SalesUpdate allows changes to a sale not covered by the permissions of
+	// its target status.
+	SalesUpdate        = "sales:update"
+	SalesApprove       = "sales:approve"
+	SalesReject        = "sales:reject"
+	SalesCancel        = "sales:cancel"
+	SalesRefund        = "sales:refund"
+	InstallmentsUpdate = "installments:update"
+
+	ProductsRead  = "products:read"
+	ProductsWrite = "products:write"
+	StockRead     = "stock:read"
+	StockAdjust   = "stock:adjust"
+	CouponsRead   = "coupons:read"
+	CouponsWrite  = "coupons:write"
+	AccountsRead  = "accounts:read"
+	AccountsPay   = "accounts:pay"
+)
+
+// Own is the suffix of the permissions restricted to the resources of the
+// principal, such as sales:read:own for the sales of the principal's user.
+const Own = ":own"
+
+// All grants every permission.
+const All = "*"
+
+// ownable are the permissions with an :own variant.
+var ownable = map[string]bool{
+	UsersRead:    true,
+	SalesRead:    true,
+	AccountsRead: true,
+}
+
+// permissions are all the permissions known to a Policy.
+var permissions = map[string]bool{
+	UsersCreate: true, UsersRead: true, UsersUpdate: true, UsersDelete: true,
+	SalesCreate: true, SalesRead: true, SalesUpdate: true, SalesApprove: true,
+	SalesReject: true, SalesCancel: true, SalesRefund: true, InstallmentsUpdate: true,
+	ProductsRead: true, ProductsWrite: true, StockRead: true, StockAdjust: true,
+	CouponsRead: true, CouponsWrite: true, AccountsRead: true, AccountsPay: true,
+}
+
+// ErrForbidden is returned when a principal lacks a permission.
+var ErrForbidden = errors.New("forbidden")
+
+// ErrInvalidPolicy is returned when a policy grants unknown permissions.
+var ErrInvalidPolicy = errors.New("invalid policy")
+
+// DeniedError is returned when a principal lacks Permission.
+// It wraps ErrForbidden.
+type DeniedError struct {
+	Permission string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("%v: missing permission %s", ErrForbidden, e.Permission)
+}
+
+func (e *DeniedError) Unwrap() error {
+	return ErrForbidden
+}
+
+// Policy maps role names to the permissions they grant. Its JSON form is
+// {"roles": {"cashier": ["sales:create", "sales:read"], "admin": ["*"]}}.
+// Principals get the union of the permissions of their roles; unknown roles
+// grant nothing.
+type Policy struct {
+	Roles map[string][]string `json:"roles"`
+}
+
+// Default returns the built-in policy:
+//
+//	cashier     creates users and sales, and takes account payments
+//	supervisor  also approves, rejects, cancels and refunds sales
+//	admin       everything, including deleting users
+//	user        reads only their own user, sales and account
+func Default() *Policy {
+	cashier := []string{
+		UsersCreate, UsersRead, UsersUpdate,
+		SalesCreate, SalesRead,
+		ProductsRead, StockRead, CouponsRead,
+		AccountsRead, AccountsPay,
+	}
+	supervisor := append([]string{
+		SalesUpdate, SalesApprove, SalesReject, SalesCancel, SalesRefund,
+		InstallmentsUpdate, StockAdjust,
+	}, cashier...)
+
+	return &Policy{Roles: map[string][]string{
+		"cashier":    cashier,
+		"supervisor": supervisor,
+		"admin":      {All},
+		"user":       {UsersRead + Own, SalesRead + Own, AccountsRead + Own},
+	}}
+}
+
+// Load reads a policy from a JSON file.
+// Returns ErrInvalidPolicy if it grants unknown permissions.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPolicy, err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate checks that every permission granted is known.
+// Returns ErrInvalidPolicy otherwise.
+func (p *Policy) Validate() error {
+	roles := make([]string, 0, len(p.Roles))
+	for role := range p.Roles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	for _, role := range roles {
+		for _, perm := range p.Roles[role] {
+			if !known(perm) {
+				return fmt.Errorf("%w: role %s grants unknown permission %q", ErrInvalidPolicy, role, perm)
+			}
+		}
+	}
+	return nil
+}
+
+// known reports whether perm can be granted.
+func known(perm string) bool {
+	if perm == All || permissions[perm] {
+		return true
+	}
+	base, ok := strings.CutSuffix(perm, Own)
+	return ok && ownable[base]
+}
+
+// grants reports whether a role of p grants perm.
+func (p *Policy) grants(principal *auth.Principal, perm string) bool {
+	for _, role := range principal.Roles {
+		for _, granted := range p.Roles[role] {
+			if granted == All || granted == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Check returns a *DeniedError unless principal has perm.
+func (p *Policy) Check(principal *auth.Principal, perm string) error {
+	if p.grants(principal, perm) {
+		return nil
+	}
+	return &DeniedError{Permission: perm}
+}
+
+// CheckOwn is like Check, but also lets through principals with the :own
+// variant of perm when they are ownerID.
+func (p *Policy) CheckOwn(principal *auth.Principal, perm string, ownerID string) error {
+	if p.grants(principal, perm) {
+		return nil
+	}
+	if ownerID != "" && principal.Subject == ownerID && p.grants(principal, perm+Own) {
+		return nil
+	}
+	return &DeniedError{Permission: perm}
+}
@@ -0,0 +1,130 @@
+package policy
+
+import (
+	"API_VentasGO/internal/auth"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	p := Default()
+	require.NoError(t, p.Validate())
+
+	tests := []struct {
+		name    string
+		roles   []string
+		perm    string
+		allowed bool
+	}{
+		{name: "cashier creates sales", roles: []string{"cashier"}, perm: SalesCreate, allowed: true},
+		{name: "cashier cannot approve", roles: []string{"cashier"}, perm: SalesApprove},
+		{name: "supervisor approves", roles: []string{"supervisor"}, perm: SalesApprove, allowed: true},
+		{name: "supervisor refunds", roles: []string{"supervisor"}, perm: SalesRefund, allowed: true},
+		{name: "supervisor cannot delete users", roles: []string{"supervisor"}, perm: UsersDelete},
+		{name: "admin deletes users", roles: []string{"admin"}, perm: UsersDelete, allowed: true},
+		{name: "roles add up", roles: []string{"user", "cashier"}, perm: SalesCreate, allowed: true},
+		{name: "user cannot read every sale", roles: []string{"user"}, perm: SalesRead},
+		{name: "unknown role", roles: []string{"owner"}, perm: SalesRead},
+		{name: "no roles", perm: SalesRead},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Check(&auth.Principal{Subject: "ana", Roles: tt.roles}, tt.perm)
+			if tt.allowed {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrForbidden)
+			var denied *DeniedError
+			require.ErrorAs(t, err, &denied)
+			require.Equal(t, tt.perm, denied.Permission)
+		})
+	}
+}
+
+func TestStatusPermission(t *testing.T) {
+	// editor may change sales but not approve them.
+	p := &Policy{Roles: map[string][]string{"editor": {SalesUpdate}}}
+	editor := &auth.Principal{Subject: "ana", Roles: []string{"editor"}}
+
+	tests := []struct {
+		status  string
+		want    string
+		allowed bool
+	}{
+		{status: "approved", want: SalesApprove},
+		{status: "APPROVED", want: SalesApprove},
+		{status: "Rejected", want: SalesReject},
+		{status: "CANCELLED", want: SalesCancel},
+		{status: "pending", want: SalesUpdate, allowed: true},
+		{status: "banana", want: SalesUpdate, allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			require.Equal(t, tt.want, statusPermission(tt.status))
+			err := p.Check(editor, statusPermission(tt.status))
+			if tt.allowed {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrForbidden)
+		})
+	}
+}
+
+func TestPolicy_CheckOwn(t *testing.T) {
+	p := Default()
+
+	tests := []struct {
+		name    string
+		roles   []string
+		owner   string
+		allowed bool
+	}{
+		{name: "own sales", roles: []string{"user"}, owner: "ana", allowed: true},
+		{name: "sales of another user", roles: []string{"user"}, owner: "beto"},
+		{name: "no owner", roles: []string{"user"}},
+		{name: "cashier reads any sale", roles: []string{"cashier"}, owner: "beto", allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.CheckOwn(&auth.Principal{Subject: "ana", Roles: tt.roles}, SalesRead, tt.owner)
+			if tt.allowed {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrForbidden)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "valid", data: `{"roles":{"clerk":["sales:create","sales:read:own"],"root":["*"]}}`},
+		{name: "unknown permission", data: `{"roles":{"clerk":["sales:destroy"]}}`, wantErr: true},
+		{name: "own variant of a permission without owner", data: `{"roles":{"clerk":["sales:create:own"]}}`, wantErr: true},
+		{name: "malformed", data: `{"roles":`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.data), 0o600))
+
+			p, err := Load(path)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidPolicy)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, p.Check(&auth.Principal{Roles: []string{"root"}}, UsersDelete))
+			require.Error(t, p.Check(&auth.Principal{Roles: []string{"clerk"}}, SalesApprove))
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package policy
+
+import (
+	"API_VentasGO/internal/auth"
+	"API_VentasGO/internal/sale"
+	"strings"
+)
+
+// statusPermissions are the permissions needed to move a sale to a status.
+// Moves to other statuses need SalesUpdate.
+var statusPermissions = map[string]string{
+	sale.StatusApproved:  SalesApprove,
+	sale.StatusRejected:  SalesReject,
+	sale.StatusCancelled: SalesCancel,
+}
+
+// statusPermission returns the permission needed to move a sale to status.
+// The status is matched regardless of case, like sale.Service applies it.
+func statusPermission(status string) string {
+	if perm, ok := statusPermissions[strings.ToLower(status)]; ok {
+		return perm
+	}
+	return SalesUpdate
+}
+
+// Sales wraps a sale.Service, checking every operation against a Policy.
+// Reads need sales:read, or sales:read:own for the sales of the principal's
+// user.
+type Sales struct {
+	sales  *sale.Service
+	policy *Policy
+}
+
+// NewSales returns a Sales guarding sales with p.
+func NewSales(sales *sale.Service, p *Policy) *Sales {
+	return &Sales{sales: sales, policy: p}
+}
+
+// Create needs sales:create.
+func (s *Sales) Create(principal *auth.Principal, sl *sale.Sale) error {
+	if err := s.policy.Check(principal, SalesCreate); err != nil {
+		return err
+	}
+	return s.sales.Create(sl)
+}
+
+// Get returns the sale id if the principal may read it.
+func (s *Sales) Get(principal *auth.Principal, id string) (*sale.Sale, error) {
+	// Principals that cannot read any sale are denied before the lookup, so
+	// they cannot probe which sales exist.
+	if err := s.policy.CheckOwn(principal, SalesRead, principal.Subject); err != nil {
+		return nil, err
+	}
+	sl, err := s.sales.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.policy.CheckOwn(principal, SalesRead, sl.UserId); err != nil {
+		return nil, err
+	}
+	return sl, nil
+}
+
+// ListSales needs read access to the sales of criteria.UserId.
+func (s *Sales) ListSales(principal *auth.Principal, criteria sale.Criteria, page sale.Page) (*sale.SalesPage, error) {
+	if err := s.policy.CheckOwn(principal, SalesRead, criteria.UserId); err != nil {
+		return nil, err
+	}
+	return s.sales.ListSales(criteria, page)
+}
+
+// Update needs the permission of the status the sale moves to: sales:approve,
+// sales:reject or sales:cancel, and sales:update for anything else.
+func (s *Sales) Update(principal *auth.Principal, id string, fields *sale.UpdateFields) (*sale.Sale, error) {
+	perm := SalesUpdate
+	if fields != nil && fields.Status != nil {
+		perm = statusPermission(*fields.Status)
+	}
+	if err := s.policy.Check(principal, perm); err != nil {
+		return nil, err
+	}
+	return s.sales.Update(id, fields)
+}
+
+// Refund needs sales:refund.
+func (s *Sales) Refund(principal *auth.Principal, id string, fields *sale.RefundFields) (*sale.Refund, *sale.Sale, error) {
+	if err := s.policy.Check(principal, SalesRefund); err != nil {
+		return nil, nil, err
+	}
+	return s.sales.Refund(id, fields)
+}
+
+// Refunds returns the refunds of the sale id if the principal may read it.
+func (s *Sales) Refunds(principal *auth.Principal, id string) ([]sale.Refund, error) {
+	if _, err := s.Get(principal, id); err != nil {
+		return nil, err
+	}
+	return s.sales.Refunds(id)
+}
+
+// Installments returns the installments of the sale id if the principal may
+// read it.
+func (s *Sales) Installments(principal *auth.Principal, id string) ([]sale.Installment, error) {
+	if _, err := s.Get(principal, id); err != nil {
+		return nil, err
+	}
+	return s.sales.Installments(id)
+}
+
+// UpdateInstallment needs installments:update.
+func (s *Sales) UpdateInstallment(principal *auth.Principal, id string, number int, fields *sale.InstallmentFields) (*sale.Installment, *sale.Sale, error) {
+	if err := s.policy.Check(principal, InstallmentsUpdate); err != nil {
+		return nil, nil, err
+	}
+	return s.sales.UpdateInstallment(id, number, fields)
+}
@@ -0,0 +1,49 @@
+package policy
+
+import (
+	"API_VentasGO/internal/auth"
+	"API_VentasGO/internal/user"
+)
+
+// Users wraps a user.Service, checking every operation against a Policy.
+type Users struct {
+	users  *user.Service
+	policy *Policy
+}
+
+// NewUsers returns a Users guarding users with p.
+func NewUsers(users *user.Service, p *Policy) *Users {
+	return &Users{users: users, policy: p}
+}
+
+// Create needs users:create.
+func (u *Users) Create(principal *auth.Principal, usr *user.User) error {
+	if err := u.policy.Check(principal, UsersCreate); err != nil {
+		return err
+	}
+	return u.users.Create(usr)
+}
+
+// Get needs users:read, or users:read:own for the principal's own user.
+func (u *Users) Get(principal *auth.Principal, id string) (*user.User, error) {
+	if err := u.policy.CheckOwn(principal, UsersRead, id); err != nil {
+		return nil, err
+	}
+	return u.users.Get(id)
+}
+
+// Update needs users:update.
+func (u *Users) Update(principal *auth.Principal, id string, fields *user.UpdateFields) (*user.User, error) {
+	if err := u.policy.Check(principal, UsersUpdate); err != nil {
+		return nil, err
+	}
+	return u.users.Update(id, fields)
+}
+
+// Delete needs users:delete.
+func (u *Users) Delete(principal *auth.Principal, id string) error {
+	if err := u.policy.Check(principal, UsersDelete); err != nil {
+		return err
+	}
+	return u.users.Delete(id)
+}
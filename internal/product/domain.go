@@ -0,0 +1,32 @@
+package product
+
+import (
+	"API_VentasGO/internal/money"
+	"time"
+)
+
+// Product represents an item of the catalog with metadata for auditing and versioning.
+type Product struct {
+	ID        string      `json:"id"`
+	SKU       string      `json:"sku"`
+	Name      string      `json:"name"`
+	Category  string      `json:"category"`
+	Price     money.Money `json:"price"`
+	Active    bool        `json:"active"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	Version   int         `json:"version"`
+}
+
+// UpdateFields represents the optional fields for updating a Product.
+// A nil pointer means “no change” for that field.
+// ExpectedVersion, when set, makes the update fail unless the product is
+// still at that version.
+type UpdateFields struct {
+	SKU             *string      `json:"sku"`
+	Name            *string      `json:"name"`
+	Category        *string      `json:"category"`
+	Price           *money.Money `json:"price"`
+	Active          *bool        `json:"active"`
+	ExpectedVersion *int         `json:"expected_version"`
+}
@@ -0,0 +1,169 @@
+package product
+
+import (
+	"API_VentasGO/internal/wal"
+	"encoding/json"
+	"sync"
+)
+
+// record is a single entry of the products write-ahead log.
+type record struct {
+	Op      string   `json:"op"`
+	ID      string   `json:"id"`
+	Product *Product `json:"product,omitempty"`
+}
+
+const (
+	opSet    = "set"
+	opDelete = "delete"
+)
+
+// FileStorage is a durable Storage backed by a write-ahead log.
+// Every Set and Delete is appended to the log before it is applied in memory,
+// the log is periodically compacted into a snapshot, and both are replayed
+// when the storage is opened again.
+type FileStorage struct {
+	// mu serializes writes so the log order matches the memory order.
+	mu  sync.Mutex
+	mem *LocalStorage
+	log *wal.Log
+}
+
+// NewFileStorage opens the products stored in dir, replaying the snapshot and
+// the log to restore the state left by the previous run.
+func NewFileStorage(dir string, opts wal.Options) (*FileStorage, error) {
+	log, err := wal.Open(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FileStorage{
+		mem: NewLocalStorage(),
+		log: log,
+	}
+
+	if err := log.Replay(f.loadSnapshot, f.apply); err != nil {
+		log.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// loadSnapshot restores every product stored in a snapshot.
+func (f *FileStorage) loadSnapshot(data []byte) error {
+	var products []*Product
+	if err := json.Unmarshal(data, &products); err != nil {
+		return err
+	}
+	for _, p := range products {
+		if err := f.mem.Set(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply replays a single log record over the in-memory state.
+func (f *FileStorage) apply(data []byte) error {
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return err
+	}
+
+	switch r.Op {
+	case opSet:
+		return f.mem.Set(r.Product)
+	case opDelete:
+		if err := f.mem.Delete(r.ID); err != nil && err != ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// write appends r to the log, applies it in memory and compacts the log
+// when it grew past the configured threshold.
+func (f *FileStorage) write(r record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := f.log.Append(data); err != nil {
+		return err
+	}
+	if err := f.apply(data); err != nil {
+		return err
+	}
+
+	f.log.Compact(func() ([]byte, error) {
+		return json.Marshal(f.mem.all())
+	})
+	return nil
+}
+
+// Set durably stores or updates a product.
+// Returns ErrEmptyID if the product has an empty ID, or ErrDuplicateSKU if
+// another product uses its SKU.
+func (f *FileStorage) Set(product *Product) error {
+	if product.ID == "" {
+		return ErrEmptyID
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Refuse the write before it reaches the log, where it would fail on replay.
+	if err := f.mem.checkSKU(product); err != nil {
+		return err
+	}
+	return f.write(record{Op: opSet, ID: product.ID, Product: product})
+}
+
+// CompareAndSet durably replaces a product, but only if its stored Version
+// still equals version.
+// Returns ErrNotFound if the product does not exist, ErrVersionMismatch if it
+// changed in the meantime, or ErrDuplicateSKU if another product uses its SKU.
+func (f *FileStorage) CompareAndSet(product *Product, version int) error {
+	if product.ID == "" {
+		return ErrEmptyID
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	current, err := f.mem.Read(product.ID)
+	if err != nil {
+		return err
+	}
+	if current.Version != version {
+		return ErrVersionMismatch
+	}
+	if err := f.mem.checkSKU(product); err != nil {
+		return err
+	}
+	return f.write(record{Op: opSet, ID: product.ID, Product: product})
+}
+
+// Read retrieves a product by ID.
+// Returns ErrNotFound if the product is not found.
+func (f *FileStorage) Read(id string) (*Product, error) {
+	return f.mem.Read(id)
+}
+
+// Delete durably removes a product by ID.
+// Returns ErrNotFound if the product does not exist.
+func (f *FileStorage) Delete(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.mem.Read(id); err != nil {
+		return err
+	}
+	return f.write(record{Op: opDelete, ID: id})
+}
+
+// Close flushes and closes the underlying log.
+func (f *FileStorage) Close() error {
+	return f.log.Close()
+}
@@ -0,0 +1,36 @@
+package product
+
+import (
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/wal"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStorage_SurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	opts := wal.Options{Sync: wal.SyncAlways, SnapshotEvery: 2}
+
+	storage, err := NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	s := NewService(storage, nil)
+
+	p := &Product{SKU: "MATE-01", Name: "Mate", Price: money.New(150000, "ARS"), Active: true}
+	require.NoError(t, s.Create(p))
+	require.ErrorIs(t, s.Create(&Product{SKU: "MATE-01", Name: "Otro", Price: money.New(1, "ARS")}), ErrDuplicateSKU)
+	price := money.New(175000, "ARS")
+	_, err = s.Update(p.ID, &UpdateFields{Price: &price})
+	require.NoError(t, err)
+	require.NoError(t, storage.Close())
+
+	storage, err = NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	got, err := storage.Read(p.ID)
+	require.NoError(t, err)
+	require.Equal(t, price, got.Price)
+	require.Equal(t, 2, got.Version)
+	require.ErrorIs(t, NewService(storage, nil).Create(&Product{SKU: "MATE-01", Name: "Otro", Price: money.New(1, "ARS")}), ErrDuplicateSKU)
+}
@@ -0,0 +1,157 @@
+package product
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrEmptySKU is returned when trying to store a product without a SKU.
+var ErrEmptySKU = errors.New("empty product SKU")
+
+// ErrEmptyName is returned when trying to store a product without a name.
+var ErrEmptyName = errors.New("empty product name")
+
+// ErrInvalidPrice is returned when a product price is not greater than 0.
+var ErrInvalidPrice = errors.New("product price must be greater than 0")
+
+// Service provides high-level product catalog operations on a LocalStorage backend.
+type Service struct {
+	// storage is the underlying persistence for Product entities.
+	storage Storage
+
+	// logger is our observability component to log.
+	logger *zap.Logger
+}
+
+// NewService creates a new Service.
+func NewService(storage Storage, logger *zap.Logger) *Service {
+	if logger == nil {
+		logger, _ = zap.NewProduction()
+		defer logger.Sync() // flushes buffer, if any
+	}
+
+	return &Service{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+// validate checks the fields every stored product must have.
+func validate(product *Product) error {
+	if product.SKU == "" {
+		return ErrEmptySKU
+	}
+	if product.Name == "" {
+		return ErrEmptyName
+	}
+	if !product.Price.IsPositive() {
+		return fmt.Errorf("%w: %s", ErrInvalidPrice, product.Price)
+	}
+	return nil
+}
+
+// Create adds a brand-new product to the catalog.
+// Surrounding spaces are trimmed from the SKU, the name and the category,
+// which is also lowercased.
+// It sets CreatedAt and UpdatedAt to the current time and initializes Version to 1.
+// Returns ErrEmptySKU, ErrEmptyName or ErrInvalidPrice for incomplete
+// products, and ErrDuplicateSKU if another product uses the SKU.
+func (s *Service) Create(product *Product) error {
+	product.SKU = strings.TrimSpace(product.SKU)
+	product.Name = strings.TrimSpace(product.Name)
+	product.Category = strings.ToLower(strings.TrimSpace(product.Category))
+	if err := validate(product); err != nil {
+		return err
+	}
+
+	product.ID = uuid.NewString()
+	now := time.Now()
+	product.CreatedAt = now
+	product.UpdatedAt = now
+	product.Version = 1
+
+	if err := s.storage.Set(product); err != nil {
+		s.logger.Error("failed to set product", zap.Error(err), zap.Any("product", product))
+		return err
+	}
+
+	return nil
+}
+
+// Get retrieves a product by its ID.
+// Returns ErrNotFound if no product exists with the given ID.
+func (s *Service) Get(id string) (*Product, error) {
+	return s.storage.Read(id)
+}
+
+// maxUpdateAttempts bounds how many times Update retries an update without
+// ExpectedVersion that lost a race against a concurrent one.
+const maxUpdateAttempts = 3
+
+// Update modifies an existing product's data.
+// It updates SKU, Name, Category, Price and Active, sets UpdatedAt to now and
+// increments Version. Sales keep the price they were made at.
+// Returns ErrNotFound if the product does not exist, the errors of Create
+// for invalid fields, and ErrVersionMismatch if product.ExpectedVersion is
+// set and the product is at another version.
+func (s *Service) Update(id string, product *UpdateFields) (*Product, error) {
+	for attempt := 1; ; attempt++ {
+		existing, err := s.storage.Read(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if product.ExpectedVersion != nil && *product.ExpectedVersion != existing.Version {
+			return nil, ErrVersionMismatch
+		}
+		version := existing.Version
+
+		if product.SKU != nil {
+			existing.SKU = strings.TrimSpace(*product.SKU)
+		}
+
+		if product.Name != nil {
+			existing.Name = strings.TrimSpace(*product.Name)
+		}
+
+		if product.Category != nil {
+			existing.Category = strings.ToLower(strings.TrimSpace(*product.Category))
+		}
+
+		if product.Price != nil {
+			existing.Price = *product.Price
+		}
+
+		if product.Active != nil {
+			existing.Active = *product.Active
+		}
+
+		if err := validate(existing); err != nil {
+			return nil, err
+		}
+
+		existing.UpdatedAt = time.Now()
+		existing.Version++
+
+		err = s.storage.CompareAndSet(existing, version)
+		if errors.Is(err, ErrVersionMismatch) && product.ExpectedVersion == nil && attempt < maxUpdateAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return existing, nil
+	}
+}
+
+// Delete removes a product from the catalog by its ID.
+// Returns ErrNotFound if the product does not exist.
+func (s *Service) Delete(id string) error {
+	return s.storage.Delete(id)
+}
@@ -0,0 +1,88 @@
+package product
+
+import (
+	"API_VentasGO/internal/money"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_Create(t *testing.T) {
+	tests := []struct {
+		name    string
+		product *Product
+		wantErr error
+	}{
+		{name: "valid", product: &Product{SKU: " MATE-01 ", Name: "Mate", Price: money.New(150000, "ARS"), Active: true}},
+		{name: "empty SKU", product: &Product{Name: "Mate", Price: money.New(150000, "ARS")}, wantErr: ErrEmptySKU},
+		{name: "empty name", product: &Product{SKU: "MATE-01", Price: money.New(150000, "ARS")}, wantErr: ErrEmptyName},
+		{name: "free", product: &Product{SKU: "MATE-01", Name: "Mate", Price: money.New(0, "ARS")}, wantErr: ErrInvalidPrice},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(NewLocalStorage(), nil)
+
+			err := s.Create(tt.product)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.NotEmpty(t, tt.product.ID)
+			require.Equal(t, "MATE-01", tt.product.SKU)
+			require.Equal(t, 1, tt.product.Version)
+
+			got, err := s.Get(tt.product.ID)
+			require.NoError(t, err)
+			require.Equal(t, tt.product, got)
+		})
+	}
+}
+
+func TestService_UniqueSKU(t *testing.T) {
+	s := NewService(NewLocalStorage(), nil)
+
+	mate := &Product{SKU: "MATE-01", Name: "Mate", Price: money.New(150000, "ARS")}
+	require.NoError(t, s.Create(mate))
+	bombilla := &Product{SKU: "BOMB-01", Name: "Bombilla", Price: money.New(50000, "ARS")}
+	require.NoError(t, s.Create(bombilla))
+
+	require.ErrorIs(t, s.Create(&Product{SKU: "MATE-01", Name: "Otro", Price: money.New(1, "ARS")}), ErrDuplicateSKU)
+
+	sku := "MATE-01"
+	_, err := s.Update(bombilla.ID, &UpdateFields{SKU: &sku})
+	require.ErrorIs(t, err, ErrDuplicateSKU)
+
+	// Renaming a SKU frees the old one.
+	sku = "MATE-02"
+	_, err = s.Update(mate.ID, &UpdateFields{SKU: &sku})
+	require.NoError(t, err)
+	require.NoError(t, s.Create(&Product{SKU: "MATE-01", Name: "Mate nuevo", Price: money.New(1, "ARS")}))
+
+	// And so does deleting the product.
+	require.NoError(t, s.Delete(bombilla.ID))
+	require.NoError(t, s.Create(&Product{SKU: "BOMB-01", Name: "Bombilla nueva", Price: money.New(1, "ARS")}))
+}
+
+func TestService_Update(t *testing.T) {
+	s := NewService(NewLocalStorage(), nil)
+	p := &Product{SKU: "MATE-01", Name: "Mate", Price: money.New(150000, "ARS"), Active: true}
+	require.NoError(t, s.Create(p))
+
+	price := money.New(175000, "ARS")
+	inactive := false
+	got, err := s.Update(p.ID, &UpdateFields{Price: &price, Active: &inactive})
+	require.NoError(t, err)
+	require.Equal(t, price, got.Price)
+	require.False(t, got.Active)
+	require.Equal(t, 2, got.Version)
+
+	free := money.New(0, "ARS")
+	_, err = s.Update(p.ID, &UpdateFields{Price: &free})
+	require.ErrorIs(t, err, ErrInvalidPrice)
+
+	stale := 1
+	_, err = s.Update(p.ID, &UpdateFields{Price: &price, ExpectedVersion: &stale})
+	require.ErrorIs(t, err, ErrVersionMismatch)
+}
@@ -0,0 +1,176 @@
+package product
+
+import (
+	"errors"
+	"hash/fnv"
+	"sync"
+)
+
+// ErrNotFound is returned when a product with the given ID is not found.
+var ErrNotFound = errors.New("product not found")
+
+// ErrEmptyID is returned when trying to store a product with an empty ID.
+var ErrEmptyID = errors.New("empty product ID")
+
+// ErrDuplicateSKU is returned when another product already uses the SKU.
+var ErrDuplicateSKU = errors.New("duplicate product SKU")
+
+// ErrVersionMismatch is returned when a product changed since the version the
+// caller based its update on.
+var ErrVersionMismatch = errors.New("product version mismatch")
+
+type Storage interface {
+	Set(product *Product) error
+	Read(id string) (*Product, error)
+	CompareAndSet(product *Product, version int) error
+	Delete(id string) error
+}
+
+// shardCount is the number of independent partitions of LocalStorage.
+const shardCount = 32
+
+// shard is a partition of LocalStorage guarded by its own lock.
+type shard struct {
+	mu sync.RWMutex
+	m  map[string]*Product
+}
+
+// LocalStorage provides an in-memory implementation for storing products.
+// It is safe for concurrent use: products are spread across shards by ID,
+// and a table of SKUs guarded by its own lock keeps SKUs unique.
+// Products are copied on the way in and out, so callers never share memory
+// with the store.
+type LocalStorage struct {
+	shards [shardCount]*shard
+
+	// skus maps every SKU in use to the ID of its product. Writers lock it
+	// before the shard of the product.
+	skuMu sync.Mutex
+	skus  map[string]string
+}
+
+// NewLocalStorage instantiates a new LocalStorage with empty shards.
+func NewLocalStorage() *LocalStorage {
+	l := &LocalStorage{skus: make(map[string]string)}
+	for i := range l.shards {
+		l.shards[i] = &shard{m: make(map[string]*Product)}
+	}
+	return l
+}
+
+// shardFor returns the shard that owns the given product ID.
+func (l *LocalStorage) shardFor(id string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return l.shards[h.Sum32()%shardCount]
+}
+
+// Set stores or updates a product in the local storage.
+// Returns ErrEmptyID if the product has an empty ID, or ErrDuplicateSKU if
+// another product uses its SKU.
+func (l *LocalStorage) Set(product *Product) error {
+	return l.put(product, nil)
+}
+
+// CompareAndSet atomically replaces a stored product, but only if its stored
+// Version still equals version.
+// Returns ErrNotFound if the product does not exist, ErrVersionMismatch if it
+// changed in the meantime, or ErrDuplicateSKU if another product uses its SKU.
+func (l *LocalStorage) CompareAndSet(product *Product, version int) error {
+	return l.put(product, &version)
+}
+
+// put stores product, checking the stored version first when version is not nil.
+func (l *LocalStorage) put(product *Product, version *int) error {
+	if product.ID == "" {
+		return ErrEmptyID
+	}
+
+	l.skuMu.Lock()
+	defer l.skuMu.Unlock()
+	if owner, ok := l.skus[product.SKU]; ok && owner != product.ID {
+		return ErrDuplicateSKU
+	}
+
+	sh := l.shardFor(product.ID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	current, ok := sh.m[product.ID]
+	if version != nil {
+		if !ok {
+			return ErrNotFound
+		}
+		if current.Version != *version {
+			return ErrVersionMismatch
+		}
+	}
+	if ok && current.SKU != product.SKU {
+		delete(l.skus, current.SKU)
+	}
+
+	p := *product
+	sh.m[product.ID] = &p
+	l.skus[product.SKU] = product.ID
+	return nil
+}
+
+// checkSKU returns ErrDuplicateSKU if a product other than product uses its SKU.
+func (l *LocalStorage) checkSKU(product *Product) error {
+	l.skuMu.Lock()
+	defer l.skuMu.Unlock()
+	if owner, ok := l.skus[product.SKU]; ok && owner != product.ID {
+		return ErrDuplicateSKU
+	}
+	return nil
+}
+
+// Read retrieves a product from the local storage by ID.
+// Returns ErrNotFound if the product is not found.
+func (l *LocalStorage) Read(id string) (*Product, error) {
+	sh := l.shardFor(id)
+	sh.mu.RLock()
+	p, ok := sh.m[id]
+	sh.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	c := *p
+	return &c, nil
+}
+
+// Delete removes a product from the local storage by ID.
+// Returns ErrNotFound if the product does not exist.
+func (l *LocalStorage) Delete(id string) error {
+	l.skuMu.Lock()
+	defer l.skuMu.Unlock()
+
+	sh := l.shardFor(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	p, ok := sh.m[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	delete(l.skus, p.SKU)
+	delete(sh.m, id)
+	return nil
+}
+
+// all returns a copy of every stored product.
+func (l *LocalStorage) all() []*Product {
+	var products []*Product
+	for _, sh := range l.shards {
+		sh.mu.RLock()
+		for _, p := range sh.m {
+			c := *p
+			products = append(products, &c)
+		}
+		sh.mu.RUnlock()
+	}
+	return products
+}
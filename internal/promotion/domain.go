@@ -0,0 +1,47 @@
+package promotion
+
+import (
+	"API_VentasGO/internal/money"
+	"time"
+)
+
+// Coupon kinds.
+const (
+	// KindPercent takes Percent percent off the purchase.
+	KindPercent = "percent"
+
+	// KindFixed takes a fixed Amount off the purchase, up to its total.
+	KindFixed = "fixed"
+)
+
+// Coupon is a discount code. MinPurchase, ValidFrom, ValidUntil, MaxUses and
+// MaxUsesPerUser are optional: their zero values mean no restriction.
+// Uses counts the redemptions that were not released. The redemptions
+// themselves are kept by the storages only.
+type Coupon struct {
+	Code           string      `json:"code"`
+	Kind           string      `json:"kind"`
+	Percent        string      `json:"percent,omitempty"`
+	Amount         money.Money `json:"amount"`
+	MinPurchase    money.Money `json:"min_purchase"`
+	ValidFrom      time.Time   `json:"valid_from"`
+	ValidUntil     time.Time   `json:"valid_until"`
+	MaxUses        int         `json:"max_uses"`
+	MaxUsesPerUser int         `json:"max_uses_per_user"`
+	Active         bool        `json:"active"`
+	Uses           int         `json:"uses"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+// Redemption is a use of a coupon by a sale.
+type Redemption struct {
+	UserID   string      `json:"user_id"`
+	Discount money.Money `json:"discount"`
+	At       time.Time   `json:"at"`
+}
+
+// clone returns a copy of the coupon that shares no memory with it.
+func (c *Coupon) clone() *Coupon {
+	cp := *c
+	return &cp
+}
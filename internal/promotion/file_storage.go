@@ -0,0 +1,151 @@
+package promotion
+
+import (
+	"API_VentasGO/internal/wal"
+	"encoding/json"
+	"sync"
+)
+
+// record is a single entry of the coupons write-ahead log.
+type record struct {
+	Op         string      `json:"op"`
+	Code       string      `json:"code"`
+	SaleID     string      `json:"sale_id,omitempty"`
+	Coupon     *Coupon     `json:"coupon,omitempty"`
+	Redemption *Redemption `json:"redemption,omitempty"`
+}
+
+const (
+	opCreate  = "create"
+	opRedeem  = "redeem"
+	opRelease = "release"
+)
+
+// FileStorage is a durable Storage backed by a write-ahead log.
+// Every operation is checked against memory, appended to the log and then
+// applied in memory; the log is periodically compacted into a snapshot, and
+// both are replayed when the storage is opened again. Reads are served from
+// memory.
+type FileStorage struct {
+	// mu serializes writes so the log order matches the memory order, and
+	// nothing changes between checking an operation and applying it.
+	mu  sync.Mutex
+	mem *LocalStorage
+	log *wal.Log
+}
+
+// NewFileStorage opens the coupons stored in dir, replaying the snapshot and
+// the log to restore the state left by the previous run.
+func NewFileStorage(dir string, opts wal.Options) (*FileStorage, error) {
+	log, err := wal.Open(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FileStorage{
+		mem: NewLocalStorage(),
+		log: log,
+	}
+
+	if err := log.Replay(f.loadSnapshot, f.apply); err != nil {
+		log.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// loadSnapshot restores every coupon stored in a snapshot, with its redemptions.
+func (f *FileStorage) loadSnapshot(data []byte) error {
+	var coupons []*stored
+	if err := json.Unmarshal(data, &coupons); err != nil {
+		return err
+	}
+	f.mem.restore(coupons)
+	return nil
+}
+
+// apply replays a single log record over the in-memory state.
+func (f *FileStorage) apply(data []byte) error {
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return err
+	}
+
+	switch r.Op {
+	case opCreate:
+		return f.mem.Create(r.Coupon)
+	case opRedeem:
+		return f.mem.Redeem(r.Code, r.SaleID, *r.Redemption)
+	case opRelease:
+		return f.mem.Release(r.Code, r.SaleID)
+	}
+	return nil
+}
+
+// write appends r to the log, applies it in memory and compacts the log
+// when it grew past the configured threshold.
+func (f *FileStorage) write(r record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := f.log.Append(data); err != nil {
+		return err
+	}
+	if err := f.apply(data); err != nil {
+		return err
+	}
+
+	f.log.Compact(func() ([]byte, error) {
+		return json.Marshal(f.mem.all())
+	})
+	return nil
+}
+
+// Create durably stores a new coupon.
+// It fails like LocalStorage.Create, without writing anything.
+func (f *FileStorage) Create(coupon *Coupon) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.mem.create(coupon, true); err != nil {
+		return err
+	}
+	return f.write(record{Op: opCreate, Code: coupon.Code, Coupon: coupon})
+}
+
+// Read retrieves a coupon by code.
+// Returns ErrNotFound if the coupon is not found.
+func (f *FileStorage) Read(code string) (*Coupon, error) {
+	return f.mem.Read(code)
+}
+
+// Redeem durably records a use of the coupon by a sale.
+// It fails like LocalStorage.Redeem, without writing anything.
+func (f *FileStorage) Redeem(code, saleID string, r Redemption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.mem.redeem(code, saleID, r, true); err != nil {
+		return err
+	}
+	return f.write(record{Op: opRedeem, Code: code, SaleID: saleID, Redemption: &r})
+}
+
+// Release durably gives back the use of the coupon by a sale.
+// It fails like LocalStorage.Release, without writing anything.
+func (f *FileStorage) Release(code, saleID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.mem.release(code, saleID, true); err != nil {
+		return err
+	}
+	return f.write(record{Op: opRelease, Code: code, SaleID: saleID})
+}
+
+// Close flushes and closes the underlying log.
+func (f *FileStorage) Close() error {
+	return f.log.Close()
+}
@@ -0,0 +1,82 @@
+package promotion
+
+import (
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/wal"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStorage_SurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	opts := wal.Options{Sync: wal.SyncAlways, SnapshotEvery: 2}
+
+	storage, err := NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	s := NewService(storage, nil)
+
+	require.NoError(t, s.Create(&Coupon{Code: "PROMO", Kind: KindFixed, Amount: money.New(50000, "ARS"), MaxUses: 2, Active: true}))
+	require.ErrorIs(t, s.Create(&Coupon{Code: "promo", Kind: KindPercent, Percent: "5"}), ErrDuplicateCode)
+	for _, id := range []string{"sale-1", "sale-2"} {
+		_, err = s.Redeem("PROMO", id, "ana", money.New(200000, "ARS"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.Release("PROMO", "sale-1"))
+	require.NoError(t, storage.Close())
+
+	storage, err = NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	defer storage.Close()
+	s = NewService(storage, nil)
+
+	got, err := s.Get("PROMO")
+	require.NoError(t, err)
+	require.Equal(t, 1, got.Uses)
+
+	// The redemptions survived the restart, so they are still enforced.
+	_, err = s.Redeem("PROMO", "sale-2", "ana", money.New(200000, "ARS"))
+	require.ErrorIs(t, err, ErrAlreadyRedeemed)
+	require.ErrorIs(t, s.Release("PROMO", "sale-1"), ErrRedemptionNotFound)
+
+	_, err = s.Redeem("PROMO", "sale-3", "beto", money.New(200000, "ARS"))
+	require.NoError(t, err)
+	_, err = s.Redeem("PROMO", "sale-4", "carla", money.New(200000, "ARS"))
+	require.ErrorIs(t, err, ErrUsageLimit)
+}
+
+func TestFileStorage_SkipsRecordsCoveredBySnapshot(t *testing.T) {
+	dir := t.TempDir()
+	opts := wal.Options{Sync: wal.SyncAlways, SnapshotEvery: 3}
+
+	storage, err := NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	s := NewService(storage, nil)
+
+	require.NoError(t, s.Create(&Coupon{Code: "PROMO", Kind: KindFixed, Amount: money.New(50000, "ARS"), MaxUses: 2, Active: true}))
+	_, err = s.Redeem("PROMO", "sale-1", "ana", money.New(200000, "ARS"))
+	require.NoError(t, err)
+
+	// Put the log back after the write that compacts it, as if a crash had
+	// interrupted the compaction before it emptied the log.
+	path := filepath.Join(dir, "wal.log")
+	log, err := os.ReadFile(path)
+	require.NoError(t, err)
+	_, err = s.Redeem("PROMO", "sale-2", "beto", money.New(200000, "ARS"))
+	require.NoError(t, err)
+	require.NoError(t, storage.Close())
+	require.NoError(t, os.WriteFile(path, log, 0o644))
+
+	storage, err = NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	defer storage.Close()
+	s = NewService(storage, nil)
+
+	got, err := s.Get("PROMO")
+	require.NoError(t, err)
+	require.Equal(t, 2, got.Uses)
+	_, err = s.Redeem("PROMO", "sale-3", "carla", money.New(200000, "ARS"))
+	require.ErrorIs(t, err, ErrUsageLimit)
+}
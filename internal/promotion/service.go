@@ -0,0 +1,192 @@
+package promotion
+
+import (
+	"API_VentasGO/internal/money"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrInvalidCoupon is returned when creating a coupon whose fields are not valid.
+var ErrInvalidCoupon = errors.New("invalid coupon")
+
+// ErrInactive is returned when redeeming a coupon that is not active.
+var ErrInactive = errors.New("coupon is not active")
+
+// ErrExpired is returned when redeeming a coupon outside its validity window.
+var ErrExpired = errors.New("coupon is not valid at this time")
+
+// ErrMinimumNotReached is returned when a purchase is below the minimum of the coupon.
+var ErrMinimumNotReached = errors.New("purchase below the coupon minimum")
+
+// Service provides coupon management and redemption on a Storage backend.
+type Service struct {
+	// storage is the underlying persistence for Coupon entities.
+	storage Storage
+
+	// logger is our observability component to log.
+	logger *zap.Logger
+}
+
+// NewService creates a new Service.
+func NewService(storage Storage, logger *zap.Logger) *Service {
+	if logger == nil {
+		logger, _ = zap.NewProduction()
+		defer logger.Sync() // flushes buffer, if any
+	}
+
+	return &Service{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+// normalizeCode renders a coupon code the way it is stored.
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// validate checks the fields of a new coupon and normalizes its percentage.
+func validate(coupon *Coupon) error {
+	if coupon.Code == "" {
+		return ErrEmptyCode
+	}
+
+	switch coupon.Kind {
+	case KindPercent:
+		pct, ok := new(big.Rat).SetString(strings.TrimSpace(coupon.Percent))
+		if !ok || pct.Sign() <= 0 || pct.Cmp(big.NewRat(100, 1)) > 0 {
+			return fmt.Errorf("%w: percent %q must be greater than 0 and up to 100", ErrInvalidCoupon, coupon.Percent)
+		}
+		if !new(big.Rat).Mul(pct, big.NewRat(100, 1)).IsInt() {
+			return fmt.Errorf("%w: percent %q has more than two decimals", ErrInvalidCoupon, coupon.Percent)
+		}
+		if !coupon.Amount.IsZero() {
+			return fmt.Errorf("%w: percent coupons take no amount", ErrInvalidCoupon)
+		}
+		coupon.Percent = pct.FloatString(2)
+	case KindFixed:
+		if !coupon.Amount.IsPositive() {
+			return fmt.Errorf("%w: amount must be greater than 0", ErrInvalidCoupon)
+		}
+		if coupon.Percent != "" {
+			return fmt.Errorf("%w: fixed coupons take no percent", ErrInvalidCoupon)
+		}
+		if !coupon.MinPurchase.IsZero() && coupon.MinPurchase.Currency != coupon.Amount.Currency {
+			return fmt.Errorf("%w: min_purchase must be in %s", ErrInvalidCoupon, coupon.Amount.Currency)
+		}
+	default:
+		return fmt.Errorf("%w: kind %q, want %s or %s", ErrInvalidCoupon, coupon.Kind, KindPercent, KindFixed)
+	}
+
+	if coupon.MinPurchase.Minor < 0 {
+		return fmt.Errorf("%w: min_purchase must not be negative", ErrInvalidCoupon)
+	}
+	if !coupon.ValidFrom.IsZero() && !coupon.ValidUntil.IsZero() && !coupon.ValidFrom.Before(coupon.ValidUntil) {
+		return fmt.Errorf("%w: valid_from must be before valid_until", ErrInvalidCoupon)
+	}
+	if coupon.MaxUses < 0 || coupon.MaxUsesPerUser < 0 {
+		return fmt.Errorf("%w: usage limits must not be negative", ErrInvalidCoupon)
+	}
+	return nil
+}
+
+// Create adds a brand-new coupon. Its code is trimmed and uppercased, and
+// codes are matched that way on redemption.
+// It sets CreatedAt to the current time and starts with no uses.
+// Returns ErrEmptyCode or ErrInvalidCoupon for invalid coupons, and
+// ErrDuplicateCode if another coupon uses the code.
+func (s *Service) Create(coupon *Coupon) error {
+	coupon.Code = normalizeCode(coupon.Code)
+	coupon.Kind = strings.ToLower(strings.TrimSpace(coupon.Kind))
+	if err := validate(coupon); err != nil {
+		return err
+	}
+
+	coupon.Uses = 0
+	coupon.CreatedAt = time.Now()
+
+	if err := s.storage.Create(coupon); err != nil {
+		s.logger.Error("failed to create coupon", zap.Error(err), zap.String("code", coupon.Code))
+		return err
+	}
+	return nil
+}
+
+// Get retrieves a coupon by its code.
+// Returns ErrNotFound if no coupon exists with the given code.
+func (s *Service) Get(code string) (*Coupon, error) {
+	return s.storage.Read(normalizeCode(code))
+}
+
+// Redeem applies a coupon to a purchase of amount by userID for the sale
+// saleID, counting it against its usage limits, and returns the discount.
+// Percentage discounts are rounded half away from zero, and fixed discounts
+// never exceed the purchase.
+// Returns ErrNotFound for unknown codes, ErrInactive, ErrExpired or
+// ErrMinimumNotReached when the coupon does not apply to the purchase,
+// money.ErrCurrencyMismatch when it is in another currency, and the errors
+// of Storage.Redeem when a usage limit was reached.
+func (s *Service) Redeem(code, saleID, userID string, amount money.Money) (money.Money, error) {
+	code = normalizeCode(code)
+	coupon, err := s.storage.Read(code)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	now := time.Now()
+	if !coupon.Active {
+		return money.Money{}, ErrInactive
+	}
+	if (!coupon.ValidFrom.IsZero() && now.Before(coupon.ValidFrom)) || (!coupon.ValidUntil.IsZero() && !now.Before(coupon.ValidUntil)) {
+		return money.Money{}, ErrExpired
+	}
+	if !coupon.MinPurchase.IsZero() {
+		cmp, err := amount.Cmp(coupon.MinPurchase)
+		if err != nil {
+			return money.Money{}, err
+		}
+		if cmp < 0 {
+			return money.Money{}, fmt.Errorf("%w: %s", ErrMinimumNotReached, coupon.MinPurchase)
+		}
+	}
+
+	discount, err := coupon.discount(amount)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	if err := s.storage.Redeem(code, saleID, Redemption{UserID: userID, Discount: discount, At: now}); err != nil {
+		return money.Money{}, err
+	}
+	return discount, nil
+}
+
+// Release gives back the use of a coupon by a sale, so it counts no more
+// against the usage limits.
+// Returns ErrNotFound for unknown codes, and ErrRedemptionNotFound if the
+// sale did not use the coupon.
+func (s *Service) Release(code, saleID string) error {
+	return s.storage.Release(normalizeCode(code), saleID)
+}
+
+// discount returns the discount of the coupon on a purchase of amount.
+func (c *Coupon) discount(amount money.Money) (money.Money, error) {
+	if c.Kind == KindPercent {
+		pct, _ := new(big.Rat).SetString(c.Percent)
+		return amount.Percent(pct)
+	}
+
+	cmp, err := amount.Cmp(c.Amount)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if cmp < 0 {
+		return amount, nil
+	}
+	return c.Amount, nil
+}
@@ -0,0 +1,203 @@
+package promotion
+
+import (
+	"API_VentasGO/internal/money"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_Create(t *testing.T) {
+	tests := []struct {
+		name    string
+		coupon  *Coupon
+		wantErr error
+	}{
+		{name: "percent", coupon: &Coupon{Code: " verano10 ", Kind: KindPercent, Percent: "10", Active: true}},
+		{name: "fixed", coupon: &Coupon{Code: "verano10", Kind: "FIXED", Amount: money.New(50000, "ARS"), MinPurchase: money.New(200000, "ARS")}},
+		{name: "empty code", coupon: &Coupon{Kind: KindPercent, Percent: "10"}, wantErr: ErrEmptyCode},
+		{name: "unknown kind", coupon: &Coupon{Code: "VERANO10", Kind: "gift"}, wantErr: ErrInvalidCoupon},
+		{name: "zero percent", coupon: &Coupon{Code: "VERANO10", Kind: KindPercent, Percent: "0"}, wantErr: ErrInvalidCoupon},
+		{name: "over 100 percent", coupon: &Coupon{Code: "VERANO10", Kind: KindPercent, Percent: "100.01"}, wantErr: ErrInvalidCoupon},
+		{name: "three decimals", coupon: &Coupon{Code: "VERANO10", Kind: KindPercent, Percent: "10.125"}, wantErr: ErrInvalidCoupon},
+		{name: "free fixed", coupon: &Coupon{Code: "VERANO10", Kind: KindFixed}, wantErr: ErrInvalidCoupon},
+		{name: "minimum in another currency", coupon: &Coupon{Code: "VERANO10", Kind: KindFixed, Amount: money.New(500, "USD"), MinPurchase: money.New(200000, "ARS")}, wantErr: ErrInvalidCoupon},
+		{name: "reversed window", coupon: &Coupon{Code: "VERANO10", Kind: KindPercent, Percent: "10", ValidFrom: time.Now(), ValidUntil: time.Now().Add(-time.Hour)}, wantErr: ErrInvalidCoupon},
+		{name: "negative limit", coupon: &Coupon{Code: "VERANO10", Kind: KindPercent, Percent: "10", MaxUses: -1}, wantErr: ErrInvalidCoupon},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(NewLocalStorage(), nil)
+
+			err := s.Create(tt.coupon)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, "VERANO10", tt.coupon.Code)
+
+			got, err := s.Get("Verano10")
+			require.NoError(t, err)
+			require.Equal(t, tt.coupon, got)
+
+			require.ErrorIs(t, s.Create(&Coupon{Code: "VERANO10", Kind: KindPercent, Percent: "5"}), ErrDuplicateCode)
+		})
+	}
+}
+
+func TestService_Redeem(t *testing.T) {
+	tests := []struct {
+		name         string
+		coupon       *Coupon
+		amount       money.Money
+		wantDiscount money.Money
+		wantErr      error
+	}{
+		{
+			name:         "percent rounds half away from zero",
+			coupon:       &Coupon{Kind: KindPercent, Percent: "12.5", Active: true},
+			amount:       money.New(1004, "ARS"),
+			wantDiscount: money.New(126, "ARS"),
+		},
+		{
+			name:         "fixed",
+			coupon:       &Coupon{Kind: KindFixed, Amount: money.New(50000, "ARS"), Active: true},
+			amount:       money.New(200000, "ARS"),
+			wantDiscount: money.New(50000, "ARS"),
+		},
+		{
+			name:         "fixed capped at the purchase",
+			coupon:       &Coupon{Kind: KindFixed, Amount: money.New(50000, "ARS"), Active: true},
+			amount:       money.New(30000, "ARS"),
+			wantDiscount: money.New(30000, "ARS"),
+		},
+		{
+			name:         "minimum reached",
+			coupon:       &Coupon{Kind: KindPercent, Percent: "10", MinPurchase: money.New(100000, "ARS"), Active: true},
+			amount:       money.New(100000, "ARS"),
+			wantDiscount: money.New(10000, "ARS"),
+		},
+		{
+			name:    "below minimum",
+			coupon:  &Coupon{Kind: KindPercent, Percent: "10", MinPurchase: money.New(100000, "ARS"), Active: true},
+			amount:  money.New(99999, "ARS"),
+			wantErr: ErrMinimumNotReached,
+		},
+		{
+			name:    "fixed in another currency",
+			coupon:  &Coupon{Kind: KindFixed, Amount: money.New(500, "USD"), Active: true},
+			amount:  money.New(200000, "ARS"),
+			wantErr: money.ErrCurrencyMismatch,
+		},
+		{
+			name:    "inactive",
+			coupon:  &Coupon{Kind: KindPercent, Percent: "10"},
+			amount:  money.New(100000, "ARS"),
+			wantErr: ErrInactive,
+		},
+		{
+			name:    "not yet valid",
+			coupon:  &Coupon{Kind: KindPercent, Percent: "10", ValidFrom: time.Now().Add(time.Hour), Active: true},
+			amount:  money.New(100000, "ARS"),
+			wantErr: ErrExpired,
+		},
+		{
+			name:    "expired",
+			coupon:  &Coupon{Kind: KindPercent, Percent: "10", ValidUntil: time.Now().Add(-time.Hour), Active: true},
+			amount:  money.New(100000, "ARS"),
+			wantErr: ErrExpired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(NewLocalStorage(), nil)
+			tt.coupon.Code = "PROMO"
+			require.NoError(t, s.Create(tt.coupon))
+
+			discount, err := s.Redeem("promo", "sale-1", "user-1", tt.amount)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+
+				got, err := s.Get("PROMO")
+				require.NoError(t, err)
+				require.Zero(t, got.Uses)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wantDiscount, discount)
+
+			got, err := s.Get("PROMO")
+			require.NoError(t, err)
+			require.Equal(t, 1, got.Uses)
+		})
+	}
+
+	t.Run("unknown code", func(t *testing.T) {
+		_, err := NewService(NewLocalStorage(), nil).Redeem("NOPE", "sale-1", "user-1", money.New(100, "ARS"))
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestService_UsageLimits(t *testing.T) {
+	s := NewService(NewLocalStorage(), nil)
+	require.NoError(t, s.Create(&Coupon{Code: "PROMO", Kind: KindPercent, Percent: "10", MaxUses: 3, MaxUsesPerUser: 2, Active: true}))
+	amount := money.New(100000, "ARS")
+
+	_, err := s.Redeem("PROMO", "sale-1", "ana", amount)
+	require.NoError(t, err)
+	_, err = s.Redeem("PROMO", "sale-1", "ana", amount)
+	require.ErrorIs(t, err, ErrAlreadyRedeemed)
+	_, err = s.Redeem("PROMO", "sale-2", "ana", amount)
+	require.NoError(t, err)
+	_, err = s.Redeem("PROMO", "sale-3", "ana", amount)
+	require.ErrorIs(t, err, ErrUserUsageLimit)
+	_, err = s.Redeem("PROMO", "sale-4", "beto", amount)
+	require.NoError(t, err)
+	_, err = s.Redeem("PROMO", "sale-5", "carla", amount)
+	require.ErrorIs(t, err, ErrUsageLimit)
+
+	// Releasing a use makes room for another one.
+	require.NoError(t, s.Release("PROMO", "sale-1"))
+	require.ErrorIs(t, s.Release("PROMO", "sale-1"), ErrRedemptionNotFound)
+	_, err = s.Redeem("PROMO", "sale-5", "carla", amount)
+	require.NoError(t, err)
+
+	got, err := s.Get("PROMO")
+	require.NoError(t, err)
+	require.Equal(t, 3, got.Uses)
+}
+
+func TestService_ConcurrentRedeemNeverExceedsLimit(t *testing.T) {
+	s := NewService(NewLocalStorage(), nil)
+	require.NoError(t, s.Create(&Coupon{Code: "PROMO", Kind: KindPercent, Percent: "10", MaxUses: 5, Active: true}))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	redeemed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.Redeem("PROMO", fmt.Sprintf("sale-%d", i), fmt.Sprintf("user-%d", i), money.New(100000, "ARS"))
+			if err == nil {
+				mu.Lock()
+				redeemed++
+				mu.Unlock()
+				return
+			}
+			require.ErrorIs(t, err, ErrUsageLimit)
+		}(i)
+	}
+	wg.Wait()
+
+	require.Equal(t, 5, redeemed)
+	got, err := s.Get("PROMO")
+	require.NoError(t, err)
+	require.Equal(t, 5, got.Uses)
+}
@@ -0,0 +1,228 @@
+package promotion
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// ErrNotFound is returned when a coupon with the given code is not found.
+var ErrNotFound = errors.New("coupon not found")
+
+// ErrEmptyCode is returned when trying to store a coupon with an empty code.
+var ErrEmptyCode = errors.New("empty coupon code")
+
+// ErrDuplicateCode is returned when creating a coupon with a code already in use.
+var ErrDuplicateCode = errors.New("duplicate coupon code")
+
+// ErrUsageLimit is returned when a coupon was already used MaxUses times.
+var ErrUsageLimit = errors.New("coupon usage limit reached")
+
+// ErrUserUsageLimit is returned when the user already used a coupon MaxUsesPerUser times.
+var ErrUserUsageLimit = errors.New("coupon usage limit reached for the user")
+
+// ErrAlreadyRedeemed is returned when a sale redeems the same coupon twice.
+var ErrAlreadyRedeemed = errors.New("coupon already redeemed by the sale")
+
+// ErrRedemptionNotFound is returned when releasing a redemption that does not exist.
+var ErrRedemptionNotFound = errors.New("coupon redemption not found")
+
+type Storage interface {
+	Create(coupon *Coupon) error
+	Read(code string) (*Coupon, error)
+	Redeem(code, saleID string, r Redemption) error
+	Release(code, saleID string) error
+}
+
+// stored is a coupon as kept by the storages, with its redemptions by sale
+// ID. They are needed to enforce the usage limits but grow with every use,
+// so they never leave the storage.
+type stored struct {
+	*Coupon
+	Redemptions map[string]Redemption `json:"redemptions,omitempty"`
+}
+
+// clone returns a copy of s that shares no memory with it.
+func (s *stored) clone() *stored {
+	cp := &stored{Coupon: s.Coupon.clone(), Redemptions: make(map[string]Redemption, len(s.Redemptions))}
+	for id, r := range s.Redemptions {
+		cp.Redemptions[id] = r
+	}
+	return cp
+}
+
+// usesBy returns how many redemptions of the coupon belong to userID.
+func (s *stored) usesBy(userID string) int {
+	n := 0
+	for _, r := range s.Redemptions {
+		if r.UserID == userID {
+			n++
+		}
+	}
+	return n
+}
+
+// shardCount is the number of independent partitions of LocalStorage.
+const shardCount = 32
+
+// shard is a partition of LocalStorage guarded by its own lock.
+type shard struct {
+	mu sync.Mutex
+	m  map[string]*stored
+}
+
+// LocalStorage provides an in-memory implementation for storing coupons.
+// It is safe for concurrent use: coupons are spread across shards by code,
+// and usage limits are checked and counted under the lock of the shard, so
+// concurrent redemptions never exceed them.
+// Coupons are copied on the way in and out, so callers never share memory
+// with the store.
+type LocalStorage struct {
+	shards [shardCount]*shard
+}
+
+// NewLocalStorage instantiates a new LocalStorage with empty shards.
+func NewLocalStorage() *LocalStorage {
+	l := &LocalStorage{}
+	for i := range l.shards {
+		l.shards[i] = &shard{m: make(map[string]*stored)}
+	}
+	return l
+}
+
+// shardFor returns the shard that owns the given coupon code.
+func (l *LocalStorage) shardFor(code string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(code))
+	return l.shards[h.Sum32()%shardCount]
+}
+
+// Create stores a new coupon, without redemptions.
+// Returns ErrEmptyCode if the coupon has an empty code, or ErrDuplicateCode if
+// another coupon uses it.
+func (l *LocalStorage) Create(coupon *Coupon) error {
+	return l.create(coupon, false)
+}
+
+// create implements Create. With dryRun it only reports whether Create would succeed.
+func (l *LocalStorage) create(coupon *Coupon, dryRun bool) error {
+	if coupon.Code == "" {
+		return ErrEmptyCode
+	}
+
+	sh := l.shardFor(coupon.Code)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if _, ok := sh.m[coupon.Code]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateCode, coupon.Code)
+	}
+	if !dryRun {
+		sh.m[coupon.Code] = &stored{Coupon: coupon.clone(), Redemptions: map[string]Redemption{}}
+	}
+	return nil
+}
+
+// Read retrieves a coupon from the local storage by code.
+// Returns ErrNotFound if the coupon is not found.
+func (l *LocalStorage) Read(code string) (*Coupon, error) {
+	sh := l.shardFor(code)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	c, ok := sh.m[code]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return c.Coupon.clone(), nil
+}
+
+// Redeem records a use of the coupon by a sale, unless it would exceed the
+// usage limits of the coupon.
+// Returns ErrNotFound if the coupon does not exist, ErrUsageLimit or
+// ErrUserUsageLimit if a limit was reached, or ErrAlreadyRedeemed if the
+// sale already used the coupon.
+func (l *LocalStorage) Redeem(code, saleID string, r Redemption) error {
+	return l.redeem(code, saleID, r, false)
+}
+
+// redeem implements Redeem. With dryRun it only reports whether Redeem would succeed.
+func (l *LocalStorage) redeem(code, saleID string, r Redemption, dryRun bool) error {
+	sh := l.shardFor(code)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	c, ok := sh.m[code]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, ok := c.Redemptions[saleID]; ok {
+		return fmt.Errorf("%w: %s", ErrAlreadyRedeemed, saleID)
+	}
+	if c.MaxUses > 0 && c.Uses >= c.MaxUses {
+		return fmt.Errorf("%w: %d uses", ErrUsageLimit, c.MaxUses)
+	}
+	if c.MaxUsesPerUser > 0 && c.usesBy(r.UserID) >= c.MaxUsesPerUser {
+		return fmt.Errorf("%w: %d uses", ErrUserUsageLimit, c.MaxUsesPerUser)
+	}
+	if dryRun {
+		return nil
+	}
+
+	c.Redemptions[saleID] = r
+	c.Uses++
+	return nil
+}
+
+// Release gives back the use of the coupon by a sale.
+// Returns ErrNotFound if the coupon does not exist, or ErrRedemptionNotFound
+// if the sale did not use it.
+func (l *LocalStorage) Release(code, saleID string) error {
+	return l.release(code, saleID, false)
+}
+
+// release implements Release. With dryRun it only reports whether Release would succeed.
+func (l *LocalStorage) release(code, saleID string, dryRun bool) error {
+	sh := l.shardFor(code)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	c, ok := sh.m[code]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, ok := c.Redemptions[saleID]; !ok {
+		return fmt.Errorf("%w: %s", ErrRedemptionNotFound, saleID)
+	}
+	if dryRun {
+		return nil
+	}
+
+	delete(c.Redemptions, saleID)
+	c.Uses--
+	return nil
+}
+
+// all returns a copy of every stored coupon, with its redemptions.
+func (l *LocalStorage) all() []*stored {
+	var coupons []*stored
+	for _, sh := range l.shards {
+		sh.mu.Lock()
+		for _, c := range sh.m {
+			coupons = append(coupons, c.clone())
+		}
+		sh.mu.Unlock()
+	}
+	return coupons
+}
+
+// restore stores the coupons of a snapshot, with their redemptions.
+func (l *LocalStorage) restore(coupons []*stored) {
+	for _, c := range coupons {
+		sh := l.shardFor(c.Code)
+		sh.mu.Lock()
+		sh.m[c.Code] = c.clone()
+		sh.mu.Unlock()
+	}
+}
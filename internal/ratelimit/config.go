@@ -0,0 +1,136 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidConfig is returned when a rate limits configuration is not valid.
+var ErrInvalidConfig = errors.New("invalid rate limits")
+
+// Config sets the limits of every client. Its JSON form is
+//
+//	{"default": {"rate": 10, "burst": 20},
+//	 "routes": {"POST /sales": {"rate": 2, "burst": 5}},
+//	 "daily_quota": 10000,
+//	 "quotas": {"key:partner": 500},
+//	 "auth_failures": {"rate": 0.1, "burst": 10}}
+//
+// Routes are "METHOD /path", with the path pattern the route was registered
+// with, and each client has a bucket of its own for each of them. The other
+// routes share the Default bucket of the client; a zero Default leaves them
+// unlimited. A positive DailyQuota caps the requests of each client per day,
+// and Quotas overrides it for some clients; a zero there exempts the client.
+// AuthFailures is the bucket of failed authentications of each IP address,
+// which defaults to DefaultAuthFailures.
+type Config struct {
+	Default      Limit            `json:"default"`
+	Routes       map[string]Limit `json:"routes"`
+	DailyQuota   int64            `json:"daily_quota"`
+	Quotas       map[string]int64 `json:"quotas"`
+	AuthFailures Limit            `json:"auth_failures"`
+}
+
+// DefaultAuthFailures lets an IP address fail authentication 10 times in a
+// row, and then once every 10 seconds.
+var DefaultAuthFailures = Limit{Rate: 0.1, Burst: 10}
+
+// Load reads a configuration from a JSON file.
+// Returns ErrInvalidConfig if it is not valid.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate checks every limit and quota, defaults the burst of limits
+// without one to their rate rounded up, and AuthFailures to
+// DefaultAuthFailures.
+// Returns ErrInvalidConfig otherwise.
+func (c *Config) Validate() error {
+	if c.Default != (Limit{}) {
+		if err := c.Default.validate(); err != nil {
+			return fmt.Errorf("default: %w", err)
+		}
+	}
+
+	routes := make([]string, 0, len(c.Routes))
+	for route := range c.Routes {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		method, path, ok := strings.Cut(route, " ")
+		if !ok || method == "" || method != strings.ToUpper(method) || !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("%w: route %q is not METHOD /path", ErrInvalidConfig, route)
+		}
+		limit := c.Routes[route]
+		if err := limit.validate(); err != nil {
+			return fmt.Errorf("route %s: %w", route, err)
+		}
+		c.Routes[route] = limit
+	}
+
+	if c.AuthFailures == (Limit{}) {
+		c.AuthFailures = DefaultAuthFailures
+	} else if err := c.AuthFailures.validate(); err != nil {
+		return fmt.Errorf("auth_failures: %w", err)
+	}
+
+	if c.DailyQuota < 0 {
+		return fmt.Errorf("%w: negative daily quota", ErrInvalidConfig)
+	}
+	for client, quota := range c.Quotas {
+		if quota < 0 {
+			return fmt.Errorf("%w: negative daily quota of %s", ErrInvalidConfig, client)
+		}
+	}
+	return nil
+}
+
+// validate checks a limit and defaults its burst.
+func (l *Limit) validate() error {
+	if !(l.Rate > 0) || math.IsInf(l.Rate, 0) {
+		return fmt.Errorf("%w: rate must be greater than 0", ErrInvalidConfig)
+	}
+	if l.Burst < 0 {
+		return fmt.Errorf("%w: negative burst", ErrInvalidConfig)
+	}
+	if l.Burst == 0 {
+		l.Burst = int(math.Ceil(l.Rate))
+	}
+	return nil
+}
+
+// Limit returns the limit of route and the name of its bucket: route itself
+// for the routes with a limit of their own, and "default" for the others.
+// It reports false when the route is not limited.
+func (c *Config) Limit(route string) (Limit, string, bool) {
+	if limit, ok := c.Routes[route]; ok {
+		return limit, route, true
+	}
+	return c.Default, "default", c.Default != (Limit{})
+}
+
+// Quota returns the daily quota of client, or zero when it has none.
+func (c *Config) Quota(client string) int64 {
+	if quota, ok := c.Quotas[client]; ok {
+		return quota
+	}
+	return c.DailyQuota
+}
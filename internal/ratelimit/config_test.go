@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "valid", data: `{"default":{"rate":10,"burst":20},"routes":{"POST /sales":{"rate":0.5}},"daily_quota":100,"quotas":{"key:partner":0}}`},
+		{name: "zero rate", data: `{"routes":{"POST /sales":{"rate":0,"burst":5}}}`, wantErr: true},
+		{name: "negative burst", data: `{"default":{"rate":1,"burst":-1}}`, wantErr: true},
+		{name: "route without method", data: `{"routes":{"/sales":{"rate":1}}}`, wantErr: true},
+		{name: "lower case method", data: `{"routes":{"post /sales":{"rate":1}}}`, wantErr: true},
+		{name: "negative quota", data: `{"quotas":{"key:partner":-1}}`, wantErr: true},
+		{name: "malformed", data: `{"default":`, wantErr: true},
+		{name: "zero auth failures rate", data: `{"auth_failures":{"burst":5}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "limits.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.data), 0o600))
+
+			c, err := Load(path)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidConfig)
+				return
+			}
+			require.NoError(t, err)
+
+			limit, name, ok := c.Limit("POST /sales")
+			require.True(t, ok)
+			require.Equal(t, "POST /sales", name)
+			require.Equal(t, Limit{Rate: 0.5, Burst: 1}, limit)
+
+			limit, name, ok = c.Limit("GET /sales")
+			require.True(t, ok)
+			require.Equal(t, "default", name)
+			require.Equal(t, Limit{Rate: 10, Burst: 20}, limit)
+
+			require.Equal(t, int64(0), c.Quota("key:partner"))
+			require.Equal(t, int64(100), c.Quota("user:ana"))
+			require.Equal(t, DefaultAuthFailures, c.AuthFailures)
+		})
+	}
+
+	var c Config
+	require.NoError(t, c.Validate())
+	_, _, ok := c.Limit("GET /sales")
+	require.False(t, ok)
+	require.Equal(t, DefaultAuthFailures, c.AuthFailures)
+
+	c = Config{AuthFailures: Limit{Rate: 1}}
+	require.NoError(t, c.Validate())
+	require.Equal(t, Limit{Rate: 1, Burst: 1}, c.AuthFailures)
+}
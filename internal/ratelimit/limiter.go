@@ -0,0 +1,44 @@
+package ratelimit
+
+import "time"
+
+// Limit is a token bucket: it holds up to Burst requests and refills at Rate
+// requests per second.
+type Limit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// Decision is the outcome of taking a request from a bucket or a quota.
+type Decision struct {
+	// Allowed reports whether the request may go on.
+	Allowed bool
+
+	// Limit is the size of the bucket or quota, and Remaining how many
+	// requests are left in it.
+	Limit     int64
+	Remaining int64
+
+	// Reset is how long until the bucket is full or the quota starts over.
+	Reset time.Duration
+
+	// RetryAfter is how long to wait before a denied request is allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter keeps the token buckets and daily quotas of clients. Memory keeps
+// them in process; a shared backend implements Limiter to enforce limits
+// across every instance of the API.
+type Limiter interface {
+	// Allow takes a request from the bucket of key, created full with limit
+	// on first use.
+	Allow(key string, limit Limit) (Decision, error)
+
+	// Peek reports what Allow would decide for the bucket of key, without
+	// taking a request from it.
+	Peek(key string, limit Limit) (Decision, error)
+
+	// Consume counts a request against the daily quota of key, which starts
+	// over at midnight UTC.
+	Consume(key string, quota int64) (Decision, error)
+}
@@ -0,0 +1,170 @@
+package ratelimit
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+// shardCount is the number of independent partitions of Memory.
+const shardCount = 32
+
+// sweepEvery is how often a shard forgets the buckets that refilled and the
+// quotas of past days.
+const sweepEvery = time.Minute
+
+// bucket is the state of a token bucket at updated.
+type bucket struct {
+	tokens  float64
+	limit   Limit
+	updated time.Time
+}
+
+// counter is the use of a daily quota on day, a midnight UTC.
+type counter struct {
+	day  time.Time
+	used int64
+}
+
+// shard is a partition of Memory guarded by its own lock.
+type shard struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	quotas    map[string]*counter
+	nextSweep time.Time
+}
+
+// Memory is an in-process Limiter. It is safe for concurrent use: keys are
+// spread across shards so clients do not contend on the same lock. Buckets
+// and quotas are forgotten on restart.
+type Memory struct {
+	shards [shardCount]*shard
+
+	// now returns the current time. Tests replace it.
+	now func() time.Time
+}
+
+// NewMemory instantiates a Memory with empty shards.
+func NewMemory() *Memory {
+	m := &Memory{now: time.Now}
+	for i := range m.shards {
+		m.shards[i] = &shard{buckets: make(map[string]*bucket), quotas: make(map[string]*counter)}
+	}
+	return m
+}
+
+// shardFor returns the shard that owns key.
+func (m *Memory) shardFor(key string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return m.shards[h.Sum32()%shardCount]
+}
+
+// Allow takes a request from the bucket of key. A bucket whose limit changed
+// starts over full with the new limit.
+func (m *Memory) Allow(key string, limit Limit) (Decision, error) {
+	return m.take(key, limit, true), nil
+}
+
+// Peek reports what Allow would decide for the bucket of key.
+func (m *Memory) Peek(key string, limit Limit) (Decision, error) {
+	return m.take(key, limit, false), nil
+}
+
+// take decides whether the bucket of key has a request left, and takes it
+// when spend is true.
+func (m *Memory) take(key string, limit Limit, spend bool) Decision {
+	now := m.now()
+	sh := m.shardFor(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.sweep(now)
+
+	burst := float64(limit.Burst)
+	b, ok := sh.buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{tokens: burst, limit: limit, updated: now}
+		// Peeking at a missing bucket does not create it.
+		if spend {
+			sh.buckets[key] = b
+		}
+	}
+	b.refill(now)
+
+	d := Decision{Limit: int64(limit.Burst)}
+	if b.tokens >= 1 {
+		if spend {
+			b.tokens--
+		}
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	d.Remaining = int64(math.Floor(b.tokens))
+	d.Reset = seconds((burst - b.tokens) / limit.Rate)
+	return d
+}
+
+// refill adds the tokens earned since the last update, up to the burst.
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+		b.updated = now
+	}
+}
+
+// Consume counts a request against the daily quota of key.
+func (m *Memory) Consume(key string, quota int64) (Decision, error) {
+	now := m.now()
+	day := now.UTC().Truncate(24 * time.Hour)
+	reset := day.Add(24 * time.Hour).Sub(now)
+
+	sh := m.shardFor(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.sweep(now)
+
+	c, ok := sh.quotas[key]
+	if !ok || !c.day.Equal(day) {
+		c = &counter{day: day}
+		sh.quotas[key] = c
+	}
+
+	d := Decision{Limit: quota, Reset: reset}
+	if c.used < quota {
+		c.used++
+		d.Allowed = true
+	} else {
+		d.RetryAfter = reset
+	}
+	d.Remaining = quota - c.used
+	return d, nil
+}
+
+// sweep forgets, at most once per sweepEvery, the buckets that are full
+// again and the quotas of past days: they behave like missing ones.
+func (sh *shard) sweep(now time.Time) {
+	if now.Before(sh.nextSweep) {
+		return
+	}
+	for key, b := range sh.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(sh.buckets, key)
+		}
+	}
+	day := now.UTC().Truncate(24 * time.Hour)
+	for key, c := range sh.quotas {
+		if c.day.Before(day) {
+			delete(sh.quotas, key)
+		}
+	}
+	sh.nextSweep = now.Add(sweepEvery)
+}
+
+// seconds converts a number of seconds to a Duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory_Allow(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	limit := Limit{Rate: 2, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		d, err := m.Allow("key:partner", limit)
+		require.NoError(t, err)
+		require.True(t, d.Allowed)
+		require.Equal(t, int64(3), d.Limit)
+		require.Equal(t, int64(i), d.Remaining)
+	}
+
+	d, err := m.Allow("key:partner", limit)
+	require.NoError(t, err)
+	require.False(t, d.Allowed)
+	require.Equal(t, 500*time.Millisecond, d.RetryAfter)
+	require.Equal(t, 1500*time.Millisecond, d.Reset)
+
+	// Other keys have buckets of their own.
+	d, err = m.Allow("key:other", limit)
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+
+	// The bucket refills at Rate, up to Burst.
+	now = now.Add(500 * time.Millisecond)
+	d, err = m.Allow("key:partner", limit)
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	require.Equal(t, int64(0), d.Remaining)
+
+	now = now.Add(time.Hour)
+	d, err = m.Allow("key:partner", limit)
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	require.Equal(t, int64(2), d.Remaining)
+}
+
+func TestMemory_Peek(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	limit := Limit{Rate: 1, Burst: 2}
+
+	d, err := m.Peek("ip:192.0.2.1", limit)
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	require.Equal(t, int64(2), d.Remaining)
+	require.Empty(t, m.shardFor("ip:192.0.2.1").buckets)
+
+	for range 2 {
+		_, err := m.Allow("ip:192.0.2.1", limit)
+		require.NoError(t, err)
+		d, err = m.Peek("ip:192.0.2.1", limit)
+		require.NoError(t, err)
+	}
+	require.False(t, d.Allowed)
+	require.Equal(t, time.Second, d.RetryAfter)
+
+	// Peeking takes nothing, so the bucket refills as usual.
+	now = now.Add(time.Second)
+	d, err = m.Peek("ip:192.0.2.1", limit)
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	d, err = m.Allow("ip:192.0.2.1", limit)
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+}
+
+func TestMemory_Consume(t *testing.T) {
+	now := time.Date(2026, 3, 1, 18, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+
+	for i := 1; i >= 0; i-- {
+		d, err := m.Consume("user:ana", 2)
+		require.NoError(t, err)
+		require.True(t, d.Allowed)
+		require.Equal(t, int64(i), d.Remaining)
+		require.Equal(t, 6*time.Hour, d.Reset)
+	}
+
+	d, err := m.Consume("user:ana", 2)
+	require.NoError(t, err)
+	require.False(t, d.Allowed)
+	require.Equal(t, 6*time.Hour, d.RetryAfter)
+
+	// The quota starts over at midnight UTC, and old days are swept.
+	now = now.Add(6 * time.Hour)
+	d, err = m.Consume("user:ana", 2)
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	require.Equal(t, int64(1), d.Remaining)
+}
+
+func TestMemory_Sweep(t *testing.T) {
+	now := time.Now()
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+
+	_, err := m.Allow("ip:10.0.0.1", Limit{Rate: 1, Burst: 1})
+	require.NoError(t, err)
+	_, err = m.Consume("ip:10.0.0.1", 5)
+	require.NoError(t, err)
+
+	now = now.Add(48 * time.Hour)
+	_, err = m.Allow("ip:10.0.0.2", Limit{Rate: 1, Burst: 1})
+	require.NoError(t, err)
+
+	for _, sh := range m.shards {
+		sh.sweep(now)
+		for key := range sh.buckets {
+			require.NotEqual(t, "ip:10.0.0.1", key)
+		}
+		require.Empty(t, sh.quotas)
+	}
+}
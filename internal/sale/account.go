@@ -0,0 +1,67 @@
+package sale
+
+import (
+	"API_VentasGO/internal/money"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// ErrCreditLimitExceeded is returned when a sale charged to the account of
+// its user would take them past their credit limit.
+var ErrCreditLimitExceeded = errors.New("credit limit exceeded")
+
+// Accounts is the view of the credit accounts that sale needs. Sales paid
+// with MethodAccount are charged under their ID, and their refunds are
+// credited under the refund ID.
+// Charge returns an error wrapping ErrCreditLimitExceeded when the user
+// cannot owe that much more.
+type Accounts interface {
+	Charge(userID, saleID string, amount money.Money) error
+	Refund(userID, refundID string, amount money.Money) error
+	Reverse(userID, saleID string, amount money.Money) error
+}
+
+// chargesAccount reports whether the sale is paid with the account of its user.
+func chargesAccount(sale *Sale) bool {
+	return sale.Payment != nil && sale.Payment.Type == MethodAccount
+}
+
+// checkAccount rejects account sales when there are no credit accounts.
+func (s *Service) checkAccount(sale *Sale) error {
+	if chargesAccount(sale) && s.accounts == nil {
+		return fmt.Errorf("%w: account sales need credit accounts", ErrNotValidOperation)
+	}
+	return nil
+}
+
+// chargeAccount charges an approved account sale to the account of its user.
+func (s *Service) chargeAccount(sale *Sale) error {
+	if !chargesAccount(sale) || sale.Status != StatusApproved {
+		return nil
+	}
+	return s.accounts.Charge(sale.UserId, sale.ID, sale.Amount)
+}
+
+// reverseAccount takes back the charge of an account sale that could not be
+// created. Failures are only logged.
+func (s *Service) reverseAccount(sale *Sale) {
+	if !chargesAccount(sale) || sale.Status != StatusApproved {
+		return
+	}
+	if err := s.accounts.Reverse(sale.UserId, sale.ID, sale.Amount); err != nil {
+		s.Logger.Error("failed to reverse account charge", zap.Error(err), zap.String("sale_id", sale.ID))
+	}
+}
+
+// refundAccount credits a refund of an account sale to the account of its
+// user. Failures are only logged, since the refund is already stored.
+func (s *Service) refundAccount(sale *Sale, refund *Refund) {
+	if !chargesAccount(sale) {
+		return
+	}
+	if err := s.accounts.Refund(sale.UserId, refund.ID, refund.Amount); err != nil {
+		s.Logger.Error("failed to credit refund to account", zap.Error(err), zap.String("sale_id", sale.ID), zap.String("refund_id", refund.ID))
+	}
+}
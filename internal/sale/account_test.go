@@ -0,0 +1,104 @@
+package sale
+
+import (
+	"API_VentasGO/internal/money"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// mockAccounts charges up to limit and records the account calls it receives.
+type mockAccounts struct {
+	limit   int64
+	balance int64
+	calls   []string
+}
+
+func (m *mockAccounts) Charge(userID, saleID string, amount money.Money) error {
+	if m.balance+amount.Minor > m.limit {
+		return ErrCreditLimitExceeded
+	}
+	m.balance += amount.Minor
+	m.calls = append(m.calls, "charge")
+	return nil
+}
+
+func (m *mockAccounts) Refund(userID, refundID string, amount money.Money) error {
+	m.balance -= amount.Minor
+	m.calls = append(m.calls, "refund")
+	return nil
+}
+
+func (m *mockAccounts) Reverse(userID, saleID string, amount money.Money) error {
+	m.balance -= amount.Minor
+	m.calls = append(m.calls, "reverse")
+	return nil
+}
+
+func TestService_AccountSales(t *testing.T) {
+	tests := []struct {
+		name        string
+		amount      int64
+		payment     *PaymentMethod
+		wantErr     error
+		wantBalance int64
+		wantCalls   []string
+	}{
+		{name: "charged and approved", amount: 6000, payment: &PaymentMethod{Type: MethodAccount}, wantBalance: 6000, wantCalls: []string{"charge"}},
+		{name: "up to the limit", amount: 10000, payment: &PaymentMethod{Type: MethodAccount}, wantBalance: 10000, wantCalls: []string{"charge"}},
+		{name: "over the limit", amount: 10001, payment: &PaymentMethod{Type: MethodAccount}, wantErr: ErrCreditLimitExceeded},
+		{name: "with details", amount: 6000, payment: &PaymentMethod{Type: MethodAccount, CardLast4: "4242"}, wantErr: ErrInvalidPaymentMethod},
+		{name: "other methods are not charged", amount: 6000, payment: &PaymentMethod{Type: MethodCash}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewLocalStorage()
+			accounts := &mockAccounts{limit: 10000}
+			s := NewService(storage, nil, nil, nil, nil, nil, accounts, nil, nil)
+
+			input := &Sale{UserId: "1", Amount: money.New(tt.amount, "ARS"), Payment: tt.payment}
+			err := s.Create(input)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				sales, _ := storage.ReadSales(Criteria{UserId: "1"})
+				require.Empty(t, sales)
+				require.Zero(t, accounts.balance)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, StatusApproved, input.Status)
+			require.Equal(t, tt.wantBalance, accounts.balance)
+			require.Equal(t, tt.wantCalls, accounts.calls)
+		})
+	}
+
+	t.Run("refunds are credited", func(t *testing.T) {
+		accounts := &mockAccounts{limit: 10000}
+		s := NewService(NewLocalStorage(), nil, nil, nil, nil, nil, accounts, nil, nil)
+		input := &Sale{UserId: "1", Amount: money.New(6000, "ARS"), Payment: &PaymentMethod{Type: MethodAccount}}
+		require.NoError(t, s.Create(input))
+
+		amount := money.New(2500, "ARS")
+		_, _, err := s.Refund(input.ID, &RefundFields{Amount: &amount})
+		require.NoError(t, err)
+		require.Equal(t, int64(3500), accounts.balance)
+		require.Equal(t, []string{"charge", "refund"}, accounts.calls)
+	})
+
+	t.Run("storage failure reverses the charge", func(t *testing.T) {
+		accounts := &mockAccounts{limit: 10000}
+		storage := &mockStorageSale{mockSetSale: func(sale *Sale) error { return errors.New("disk full") }}
+		s := NewService(storage, nil, nil, nil, nil, nil, accounts, nil, nil)
+		err := s.Create(&Sale{UserId: "1", Amount: money.New(6000, "ARS"), Payment: &PaymentMethod{Type: MethodAccount}})
+		require.Error(t, err)
+		require.Zero(t, accounts.balance)
+		require.Equal(t, []string{"charge", "reverse"}, accounts.calls)
+	})
+
+	t.Run("without accounts", func(t *testing.T) {
+		err := NewService(NewLocalStorage(), nil, nil, nil, nil, nil, nil, nil, nil).Create(&Sale{UserId: "1", Amount: money.New(6000, "ARS"), Payment: &PaymentMethod{Type: MethodAccount}})
+		require.ErrorIs(t, err, ErrNotValidOperation)
+	})
+}
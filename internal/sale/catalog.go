@@ -0,0 +1,87 @@
+package sale
+
+import (
+	"API_VentasGO/internal/money"
+	"errors"
+	"fmt"
+)
+
+// ErrProductNotFound is returned when a sale item references a product that does not exist.
+var ErrProductNotFound = errors.New("product not found")
+
+// ErrProductInactive is returned when a sale item references a product that is not for sale.
+var ErrProductInactive = errors.New("product is not active")
+
+// ErrInvalidQuantity is returned when a sale item quantity is not greater than 0.
+var ErrInvalidQuantity = errors.New("item quantity must be greater than 0")
+
+// ErrPriceChanged is returned when the unit price a client saw for an item is
+// not the current catalog price.
+var ErrPriceChanged = errors.New("item price changed")
+
+// Product is the view of a catalog product that sale needs.
+type Product struct {
+	ID       string
+	SKU      string
+	Name     string
+	Category string
+	Price    money.Money
+	Active   bool
+}
+
+// Catalog is the view of the product catalog that sale needs.
+// FindProduct returns an error wrapping ErrProductNotFound when the product does not exist.
+type Catalog interface {
+	FindProduct(id string) (*Product, error)
+}
+
+// price fills in the items of the sale from the catalog and sets its Amount
+// to their total. A non-zero UnitPrice on an item is the price the client
+// expects to pay, and must match the catalog.
+// Returns ErrProductNotFound, ErrProductInactive, ErrInvalidQuantity or
+// ErrPriceChanged for items that cannot be sold, and money errors when the
+// items do not add up in a single currency.
+func price(catalog Catalog, sale *Sale) error {
+	if catalog == nil {
+		return fmt.Errorf("%w: sales with items need a product catalog", ErrNotValidOperation)
+	}
+
+	var total money.Money
+	for i := range sale.Items {
+		item := &sale.Items[i]
+		if item.Quantity <= 0 {
+			return fmt.Errorf("%w: item %d", ErrInvalidQuantity, i)
+		}
+
+		p, err := catalog.FindProduct(item.ProductID)
+		if err != nil {
+			return err
+		}
+		if !p.Active {
+			return fmt.Errorf("%w: %s", ErrProductInactive, p.ID)
+		}
+		if item.UnitPrice != (money.Money{}) && item.UnitPrice != p.Price {
+			return fmt.Errorf("%w: %s costs %s, not %s", ErrPriceChanged, p.ID, p.Price, item.UnitPrice)
+		}
+
+		subtotal, err := p.Price.Mul(item.Quantity)
+		if err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+
+		item.SKU = p.SKU
+		item.Name = p.Name
+		item.Category = p.Category
+		item.UnitPrice = p.Price
+		item.Subtotal = subtotal
+
+		if i == 0 {
+			total = subtotal
+		} else if total, err = total.Add(subtotal); err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+
+	sale.Amount = total
+	return nil
+}
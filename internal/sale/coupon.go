@@ -0,0 +1,114 @@
+package sale
+
+import (
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/tax"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"go.uber.org/zap"
+)
+
+// ErrCouponNotFound is returned when a sale uses a coupon code that does not exist.
+var ErrCouponNotFound = errors.New("coupon not found")
+
+// ErrCouponNotApplicable is returned when a coupon exists but cannot be used
+// on the sale: it is inactive, expired, below its minimum purchase or out of
+// uses.
+var ErrCouponNotApplicable = errors.New("coupon not applicable")
+
+// Promotions is the view of the coupons that sale needs. Coupon uses are
+// redeemed under the ID of the sale that holds them.
+// Redeem returns the discount of the coupon on amount, and an error wrapping
+// ErrCouponNotFound or ErrCouponNotApplicable when it cannot be used.
+type Promotions interface {
+	Redeem(code, saleID, userID string, amount money.Money) (money.Money, error)
+	Release(code, saleID string) error
+}
+
+// Discount is the coupon applied to a sale and the amount it took off the
+// price of its items, before taxes.
+type Discount struct {
+	Code   string      `json:"code"`
+	Amount money.Money `json:"amount"`
+}
+
+// redeemCoupon redeems the coupon of a new sale, whose Amount is the price
+// of its items, and takes the discount off the Amount.
+// Returns ErrCouponNotApplicable for discounts that leave nothing to pay.
+func (s *Service) redeemCoupon(sale *Sale) error {
+	if s.promotions == nil {
+		return fmt.Errorf("%w: sales with coupons need promotions", ErrNotValidOperation)
+	}
+
+	discount, err := s.promotions.Redeem(sale.Discount.Code, sale.ID, sale.UserId, sale.Amount)
+	if err != nil {
+		return err
+	}
+	sale.Discount.Amount = discount
+
+	amount, err := sale.Amount.Sub(discount)
+	if err == nil && !amount.IsPositive() {
+		err = fmt.Errorf("%w: %s takes off the whole sale", ErrCouponNotApplicable, sale.Discount.Code)
+	}
+	if err != nil {
+		s.releaseCoupon(sale)
+		return err
+	}
+	sale.Amount = amount
+	return nil
+}
+
+// settleCoupon gives back the coupon use of a sale that left pending for
+// rejected or cancelled. Failures are only logged, since the status change
+// is already stored.
+func (s *Service) settleCoupon(sale *Sale) {
+	if sale.Status == StatusRejected || sale.Status == StatusCancelled {
+		s.releaseCoupon(sale)
+	}
+}
+
+// releaseCoupon gives back the coupon use of a sale, if it has one.
+func (s *Service) releaseCoupon(sale *Sale) {
+	if sale.Discount == nil || s.promotions == nil {
+		return
+	}
+	if err := s.promotions.Release(sale.Discount.Code, sale.ID); err != nil {
+		s.Logger.Error("failed to release sale coupon", zap.Error(err), zap.String("sale_id", sale.ID), zap.String("code", sale.Discount.Code))
+	}
+}
+
+// discountLines spreads a discount over the net lines of a sale in
+// proportion to their amounts. Shares are rounded down on the running total
+// of the lines, so no line takes more than its amount and the lines add up
+// exactly to their total minus the discount.
+func discountLines(lines []tax.Line, discount money.Money) error {
+	var total money.Money
+	for i, line := range lines {
+		var err error
+		if i == 0 {
+			total = line.Amount
+		} else if total, err = total.Add(line.Amount); err != nil {
+			return err
+		}
+	}
+	if total.IsZero() {
+		return nil
+	}
+
+	running, taken := new(big.Int), new(big.Int)
+	for i := range lines {
+		running.Add(running, big.NewInt(lines[i].Amount.Minor))
+		upTo := new(big.Int).Mul(running, big.NewInt(discount.Minor))
+		upTo.Quo(upTo, big.NewInt(total.Minor))
+		share := money.New(new(big.Int).Sub(upTo, taken).Int64(), discount.Currency)
+		taken = upTo
+
+		var err error
+		if lines[i].Amount, err = lines[i].Amount.Sub(share); err != nil {
+			return err
+		}
+	}
+	return nil
+}
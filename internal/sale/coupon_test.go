@@ -0,0 +1,148 @@
+package sale
+
+import (
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/tax"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// mockPromotions takes a fixed discount off with coupon PROMO and records
+// the coupon calls it receives.
+type mockPromotions struct {
+	discount money.Money
+	calls    []string
+}
+
+func (m *mockPromotions) Redeem(code, saleID, userID string, amount money.Money) (money.Money, error) {
+	if code != "PROMO" {
+		return money.Money{}, ErrCouponNotFound
+	}
+	m.calls = append(m.calls, "redeem")
+	return m.discount, nil
+}
+
+func (m *mockPromotions) Release(code, saleID string) error {
+	m.calls = append(m.calls, "release")
+	return nil
+}
+
+func TestService_CouponFollowsStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		initial   string
+		to        string
+		wantCalls []string
+	}{
+		{name: "pending keeps the coupon", initial: StatusPending, wantCalls: []string{"redeem"}},
+		{name: "approved on creation keeps the coupon", initial: StatusApproved, wantCalls: []string{"redeem"}},
+		{name: "rejected on creation releases", initial: StatusRejected, wantCalls: []string{"redeem", "release"}},
+		{name: "approval keeps the coupon", initial: StatusPending, to: StatusApproved, wantCalls: []string{"redeem"}},
+		{name: "rejection releases", initial: StatusPending, to: StatusRejected, wantCalls: []string{"redeem", "release"}},
+		{name: "cancellation releases", initial: StatusPending, to: StatusCancelled, wantCalls: []string{"redeem", "release"}},
+		{name: "refund keeps the coupon", initial: StatusApproved, to: StatusRefunded, wantCalls: []string{"redeem"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			promotions := &mockPromotions{discount: money.New(2500, "ARS")}
+			authorizer := &RuleAuthorizer{Default: tt.initial}
+			s := NewService(NewLocalStorage(), nil, nil, nil, nil, promotions, nil, authorizer, nil)
+
+			input := &Sale{UserId: "1", Amount: money.New(10000, "ARS"), Discount: &Discount{Code: "PROMO"}}
+			require.NoError(t, s.Create(input))
+			require.Equal(t, money.New(7500, "ARS"), input.Amount)
+			require.Equal(t, &Discount{Code: "PROMO", Amount: money.New(2500, "ARS")}, input.Discount)
+			switch tt.to {
+			case "":
+			case StatusRefunded:
+				_, _, err := s.Refund(input.ID, &RefundFields{})
+				require.NoError(t, err)
+			default:
+				_, err := s.Update(input.ID, &UpdateFields{Status: &tt.to})
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tt.wantCalls, promotions.calls)
+		})
+	}
+
+	t.Run("unknown coupon", func(t *testing.T) {
+		storage := NewLocalStorage()
+		s := NewService(storage, nil, nil, nil, nil, &mockPromotions{}, nil, nil, nil)
+		err := s.Create(&Sale{UserId: "1", Amount: money.New(10000, "ARS"), Discount: &Discount{Code: "NOPE"}})
+		require.ErrorIs(t, err, ErrCouponNotFound)
+		sales, _ := storage.ReadSales(Criteria{UserId: "1"})
+		require.Empty(t, sales)
+	})
+
+	t.Run("discount of the whole sale", func(t *testing.T) {
+		promotions := &mockPromotions{discount: money.New(10000, "ARS")}
+		s := NewService(NewLocalStorage(), nil, nil, nil, nil, promotions, nil, nil, nil)
+		err := s.Create(&Sale{UserId: "1", Amount: money.New(10000, "ARS"), Discount: &Discount{Code: "PROMO"}})
+		require.ErrorIs(t, err, ErrCouponNotApplicable)
+		require.Equal(t, []string{"redeem", "release"}, promotions.calls)
+	})
+
+	t.Run("payment failure releases", func(t *testing.T) {
+		promotions := &mockPromotions{discount: money.New(2500, "ARS")}
+		s := NewService(NewLocalStorage(), nil, nil, nil, nil, promotions, nil, NewSimulatedGateway(SimulatedGatewayConfig{FailureRate: 1}), nil)
+		err := s.Create(&Sale{UserId: "1", Amount: money.New(10000, "ARS"), Discount: &Discount{Code: "PROMO"}})
+		require.ErrorIs(t, err, ErrPaymentUnavailable)
+		require.Equal(t, []string{"redeem", "release"}, promotions.calls)
+	})
+
+	t.Run("without promotions", func(t *testing.T) {
+		err := NewService(NewLocalStorage(), nil, nil, nil, nil, nil, nil, nil, nil).Create(&Sale{UserId: "1", Amount: money.New(10000, "ARS"), Discount: &Discount{Code: "PROMO"}})
+		require.ErrorIs(t, err, ErrNotValidOperation)
+	})
+}
+
+func TestService_CouponBeforeTaxes(t *testing.T) {
+	rules := &tax.Rules{IVA: tax.IVA{Default: "21", Categories: map[string]string{"food": "10.5"}}}
+	require.NoError(t, rules.Validate())
+	catalog := mockCatalog{
+		"yerba": {ID: "yerba", Category: "food", Price: money.New(100001, "ARS"), Active: true},
+		"mate":  {ID: "mate", Price: money.New(200002, "ARS"), Active: true},
+	}
+	promotions := &mockPromotions{discount: money.New(1000, "ARS")}
+	s := NewService(NewLocalStorage(), nil, catalog, nil, mockTaxes{rules: rules}, promotions, nil, nil, nil)
+
+	input := &Sale{UserId: "1", Items: []Item{{ProductID: "yerba", Quantity: 1}, {ProductID: "mate", Quantity: 1}}, Discount: &Discount{Code: "PROMO"}}
+	require.NoError(t, s.Create(input))
+
+	// The discount is split 333/667 between the lines, which are taxed net of it.
+	require.Equal(t, money.New(299003, "ARS"), input.Taxes.Net)
+	require.Equal(t, money.New(99668, "ARS"), input.Taxes.Lines[0].Base)
+	require.Equal(t, money.New(199335, "ARS"), input.Taxes.Lines[1].Base)
+	require.Equal(t, money.New(10465+41860, "ARS"), input.Taxes.Tax)
+	require.Equal(t, money.New(299003+10465+41860, "ARS"), input.Amount)
+}
+
+func TestDiscountLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []int64
+		discount int64
+		want     []int64
+	}{
+		{name: "single line", lines: []int64{1000}, discount: 100, want: []int64{900}},
+		{name: "proportional", lines: []int64{1000, 3000}, discount: 400, want: []int64{900, 2700}},
+		{name: "rounding never takes a line below zero", lines: []int64{1, 1, 1}, discount: 2, want: []int64{1, 0, 0}},
+		{name: "whole amount", lines: []int64{300, 700}, discount: 1000, want: []int64{0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := make([]tax.Line, len(tt.lines))
+			for i, amount := range tt.lines {
+				lines[i] = tax.Line{Amount: money.New(amount, "ARS")}
+			}
+
+			require.NoError(t, discountLines(lines, money.New(tt.discount, "ARS")))
+
+			for i, want := range tt.want {
+				require.Equal(t, money.New(want, "ARS"), lines[i].Amount)
+			}
+		})
+	}
+}
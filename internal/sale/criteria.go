@@ -0,0 +1,120 @@
+package sale
+
+import (
+	"API_VentasGO/internal/money"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// FilterError is returned when a Criteria is invalid. Param names the
+// offending filter as it appears in the query string.
+type FilterError struct {
+	Param string
+	Err   error
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("invalid %s: %v", e.Param, e.Err)
+}
+
+func (e *FilterError) Unwrap() error {
+	return e.Err
+}
+
+// ErrEmptyRange is returned when the lower bound of a range is after its upper bound.
+var ErrEmptyRange = errors.New("lower bound is after upper bound")
+
+// Criteria selects the sales of one user. Zero values mean "no filter":
+// an empty Statuses accepts every status and zero times or nil amounts
+// leave that side of the range open.
+//
+// Time ranges are half-open: From is inclusive and To is exclusive.
+// Amount ranges are inclusive and only match sales in their currency.
+type Criteria struct {
+	UserId   string
+	Statuses []string
+
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	UpdatedFrom time.Time
+	UpdatedTo   time.Time
+
+	MinAmount *money.Money
+	MaxAmount *money.Money
+}
+
+// Validate checks the criteria, returning a *FilterError naming the first
+// offending parameter.
+func (c Criteria) Validate() error {
+	for _, status := range c.Statuses {
+		if !IsValidStatus(status) {
+			return &FilterError{Param: "status", Err: fmt.Errorf("%w: %q", ErrStatusNotFound, status)}
+		}
+	}
+
+	if !c.CreatedFrom.IsZero() && !c.CreatedTo.IsZero() && !c.CreatedFrom.Before(c.CreatedTo) {
+		return &FilterError{Param: "created_from", Err: ErrEmptyRange}
+	}
+	if !c.UpdatedFrom.IsZero() && !c.UpdatedTo.IsZero() && !c.UpdatedFrom.Before(c.UpdatedTo) {
+		return &FilterError{Param: "updated_from", Err: ErrEmptyRange}
+	}
+
+	if c.MinAmount != nil && c.MaxAmount != nil {
+		cmp, err := c.MinAmount.Cmp(*c.MaxAmount)
+		if err != nil {
+			return &FilterError{Param: "max_amount", Err: err}
+		}
+		if cmp > 0 {
+			return &FilterError{Param: "min_amount", Err: ErrEmptyRange}
+		}
+	}
+
+	return nil
+}
+
+// IsUnfiltered reports whether the criteria select every sale of the user.
+func (c Criteria) IsUnfiltered() bool {
+	return len(c.Statuses) == 0 &&
+		c.CreatedFrom.IsZero() && c.CreatedTo.IsZero() &&
+		c.UpdatedFrom.IsZero() && c.UpdatedTo.IsZero() &&
+		c.MinAmount == nil && c.MaxAmount == nil
+}
+
+// matches reports whether the sale satisfies the criteria. Status and
+// CreatedAt are also checked here, although storages usually resolve them
+// through their indexes.
+func (c Criteria) matches(sale *Sale) bool {
+	if sale.UserId != c.UserId {
+		return false
+	}
+	if len(c.Statuses) > 0 && !slices.Contains(c.Statuses, sale.Status) {
+		return false
+	}
+	if !inRange(sale.CreatedAt, c.CreatedFrom, c.CreatedTo) || !inRange(sale.UpdatedAt, c.UpdatedFrom, c.UpdatedTo) {
+		return false
+	}
+	if c.MinAmount != nil {
+		if cmp, err := sale.Amount.Cmp(*c.MinAmount); err != nil || cmp < 0 {
+			return false
+		}
+	}
+	if c.MaxAmount != nil {
+		if cmp, err := sale.Amount.Cmp(*c.MaxAmount); err != nil || cmp > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// inRange reports whether t is in [from, to), treating zero bounds as open.
+func inRange(t, from, to time.Time) bool {
+	if !from.IsZero() && t.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !t.Before(to) {
+		return false
+	}
+	return true
+}
@@ -1,28 +1,148 @@
 package sale
 
-import "time"
+import (
+	"API_VentasGO/internal/money"
+	"slices"
+	"time"
+)
 
 // User represents a system sale with metadata for auditing and versioning.
 type Sale struct {
-	ID        string    `json:"id"`
-	UserId    string    `json:"user_id"`
-	Amount    float32   `json:"amount"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Version   int       `json:"version"`
+	ID        string           `json:"id"`
+	UserId    string           `json:"user_id"`
+	Amount    money.Money      `json:"amount"`
+	Items     []Item           `json:"items,omitempty"`
+	Refunds   []Refund         `json:"refunds,omitempty"`
+	Plan      *InstallmentPlan `json:"installment_plan,omitempty"`
+	Payment   *PaymentMethod   `json:"payment_method,omitempty"`
+	Discount  *Discount        `json:"discount,omitempty"`
+	Taxes     *Taxes           `json:"taxes,omitempty"`
+	Status    string           `json:"status"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	Version   int              `json:"version"`
+}
+
+// Item is a line of a sale. SKU, Name, Category and UnitPrice are copied
+// from the catalog when the sale is created, so later catalog edits do not
+// change past sales.
+type Item struct {
+	ProductID string      `json:"product_id"`
+	SKU       string      `json:"sku"`
+	Name      string      `json:"name"`
+	Category  string      `json:"category,omitempty"`
+	Quantity  int64       `json:"quantity"`
+	UnitPrice money.Money `json:"unit_price"`
+	Subtotal  money.Money `json:"subtotal"`
+}
+
+// Refund is money given back on an approved sale.
+type Refund struct {
+	ID        string      `json:"id"`
+	SaleID    string      `json:"sale_id"`
+	Amount    money.Money `json:"amount"`
+	Reason    string      `json:"reason,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// Refunded returns the total refunded on the sale, in its currency.
+func (s *Sale) Refunded() money.Money {
+	total := money.Money{Currency: s.Amount.Currency}
+	for _, r := range s.Refunds {
+		total.Minor += r.Amount.Minor
+	}
+	return total
+}
+
+// clone returns a copy of the sale that shares no memory with it.
+func (s *Sale) clone() *Sale {
+	c := *s
+	c.Items = slices.Clone(s.Items)
+	c.Refunds = slices.Clone(s.Refunds)
+	c.Plan = s.Plan.clone()
+	if s.Payment != nil {
+		payment := *s.Payment
+		c.Payment = &payment
+	}
+	if s.Discount != nil {
+		discount := *s.Discount
+		c.Discount = &discount
+	}
+	if s.Taxes != nil {
+		taxes := *s.Taxes
+		taxes.Lines = slices.Clone(s.Taxes.Lines)
+		c.Taxes = &taxes
+	}
+	return &c
 }
 
 // UpdateFields represents the optional fields for updating a Sale.
 // A nil pointer means “no change” for that field.
+// ExpectedVersion, when set, makes the update fail unless the sale is still
+// at that version.
 type UpdateFields struct {
-	Status *string `json:"status"`
+	Status          *string `json:"status"`
+	ExpectedVersion *int    `json:"expected_version"`
 }
 
+// Metadata summarizes a set of sales: how many there are, how many are in
+// each status and their exact total amount per currency, net of refunds.
+// ByMethod breaks the sales with a payment method down by its type.
+// Net_amount, Tax_amount and Gross_amount split the amounts the sales were
+// created with, before refunds.
 type Metadata struct {
-	Quantity     int     `json:"quantity"`
-	Approved     int     `json:"approve"`
-	Pending      int     `json:"pending"`
-	Rejected     int     `json:"rejected"`
-	Total_amount float32 `json:"total_amount"`
+	Quantity     int
+	Counts       map[string]int
+	Total_amount money.Totals
+	Net_amount   money.Totals
+	Tax_amount   money.Totals
+	Gross_amount money.Totals
+	ByMethod     map[string]*MethodTotals
+}
+
+// MethodTotals summarizes the sales paid with one payment method.
+type MethodTotals struct {
+	Quantity     int          `json:"quantity"`
+	Total_amount money.Totals `json:"total_amount"`
+}
+
+// newMetadata returns an empty Metadata with a zero counter for every status.
+func newMetadata() *Metadata {
+	m := &Metadata{
+		Counts:       make(map[string]int, len(Statuses)),
+		Total_amount: money.Totals{},
+		Net_amount:   money.Totals{},
+		Tax_amount:   money.Totals{},
+		Gross_amount: money.Totals{},
+		ByMethod:     map[string]*MethodTotals{},
+	}
+	for _, status := range Statuses {
+		m.Counts[status] = 0
+	}
+	return m
+}
+
+// add counts sale into the summary.
+func (m *Metadata) add(sale *Sale) {
+	net := sale.Amount
+	for _, r := range sale.Refunds {
+		net.Minor -= r.Amount.Minor
+	}
+
+	m.Quantity++
+	m.Counts[sale.Status]++
+	m.Total_amount.Add(net)
+	taxNet, tax := sale.NetAndTax()
+	m.Net_amount.Add(taxNet)
+	m.Tax_amount.Add(tax)
+	m.Gross_amount.Add(sale.Amount)
+	if sale.Payment != nil {
+		t, ok := m.ByMethod[sale.Payment.Type]
+		if !ok {
+			t = &MethodTotals{Total_amount: money.Totals{}}
+			m.ByMethod[sale.Payment.Type] = t
+		}
+		t.Quantity++
+		t.Total_amount.Add(net)
+	}
 }
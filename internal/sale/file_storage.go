@@ -0,0 +1,168 @@
+package sale
+
+import (
+	"API_VentasGO/internal/wal"
+	"encoding/json"
+	"sync"
+)
+
+// record is a single entry of the sales write-ahead log.
+type record struct {
+	Op   string `json:"op"`
+	ID   string `json:"id"`
+	Sale *Sale  `json:"sale,omitempty"`
+}
+
+const (
+	opSet    = "set"
+	opDelete = "delete"
+)
+
+// FileStorage is a durable Storage backed by a write-ahead log.
+// Every SetSale and DeleteSale is appended to the log before it is applied in
+// memory, the log is periodically compacted into a snapshot, and both are
+// replayed when the storage is opened again. Reads are served from memory.
+type FileStorage struct {
+	// mu serializes writes so the log order matches the memory order.
+	mu  sync.Mutex
+	mem *LocalStorage
+	log *wal.Log
+}
+
+// NewFileStorage opens the sales stored in dir, replaying the snapshot and
+// the log to restore the state left by the previous run.
+func NewFileStorage(dir string, opts wal.Options) (*FileStorage, error) {
+	log, err := wal.Open(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FileStorage{
+		mem: NewLocalStorage(),
+		log: log,
+	}
+
+	if err := log.Replay(f.loadSnapshot, f.apply); err != nil {
+		log.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// loadSnapshot restores every sale stored in a snapshot.
+func (f *FileStorage) loadSnapshot(data []byte) error {
+	var sales []*Sale
+	if err := json.Unmarshal(data, &sales); err != nil {
+		return err
+	}
+	for _, s := range sales {
+		if err := f.mem.SetSale(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply replays a single log record over the in-memory state.
+func (f *FileStorage) apply(data []byte) error {
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return err
+	}
+
+	switch r.Op {
+	case opSet:
+		return f.mem.SetSale(r.Sale)
+	case opDelete:
+		if err := f.mem.DeleteSale(r.ID); err != nil && err != ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// write appends r to the log, applies it in memory and compacts the log
+// when it grew past the configured threshold.
+func (f *FileStorage) write(r record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := f.log.Append(data); err != nil {
+		return err
+	}
+	if err := f.apply(data); err != nil {
+		return err
+	}
+
+	f.log.Compact(func() ([]byte, error) {
+		return json.Marshal(f.mem.all())
+	})
+	return nil
+}
+
+// SetSale durably stores or updates a sale.
+// Returns ErrEmptyID if the sale has an empty ID.
+func (f *FileStorage) SetSale(sale *Sale) error {
+	if sale.ID == "" {
+		return ErrEmptyID
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.write(record{Op: opSet, ID: sale.ID, Sale: sale})
+}
+
+// CompareAndSetSale durably replaces a sale, but only if its stored Version
+// still equals version.
+// Returns ErrNotFound if the sale does not exist, or ErrVersionMismatch if it
+// changed in the meantime.
+func (f *FileStorage) CompareAndSetSale(sale *Sale, version int) error {
+	if sale.ID == "" {
+		return ErrEmptyID
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	current, err := f.mem.ReadSale(sale.ID)
+	if err != nil {
+		return err
+	}
+	if current.UserId != sale.UserId {
+		return ErrNotFound
+	}
+	if current.Version != version {
+		return ErrVersionMismatch
+	}
+	return f.write(record{Op: opSet, ID: sale.ID, Sale: sale})
+}
+
+// ReadSale retrieves a sale by ID.
+// Returns ErrNotFound if the sale is not found.
+func (f *FileStorage) ReadSale(id string) (*Sale, error) {
+	return f.mem.ReadSale(id)
+}
+
+// ReadSales returns the sales matching the criteria and their summary.
+func (f *FileStorage) ReadSales(criteria Criteria) ([]*Sale, *Metadata) {
+	return f.mem.ReadSales(criteria)
+}
+
+// DeleteSale durably removes a sale by ID.
+// Returns ErrNotFound if the sale does not exist.
+func (f *FileStorage) DeleteSale(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.mem.ReadSale(id); err != nil {
+		return err
+	}
+	return f.write(record{Op: opDelete, ID: id})
+}
+
+// Close flushes and closes the underlying log.
+func (f *FileStorage) Close() error {
+	return f.log.Close()
+}
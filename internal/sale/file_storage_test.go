@@ -0,0 +1,87 @@
+package sale
+
+import (
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/wal"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStorage_SurvivesRestartAndTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	opts := wal.Options{Sync: wal.SyncAlways, SnapshotEvery: 4}
+	base := time.Now().UTC()
+
+	storage, err := NewFileStorage(dir, opts)
+	require.NoError(t, err)
+
+	newSale := func(id string, offset time.Duration) *Sale {
+		return &Sale{ID: id, UserId: "u", Amount: money.New(100, "ARS"), Status: StatusPending, CreatedAt: base.Add(offset), Version: 1}
+	}
+	approved := newSale("approved", time.Second)
+	require.NoError(t, storage.SetSale(approved))
+	pending := newSale("pending", 2*time.Second)
+	require.NoError(t, storage.SetSale(pending))
+	deleted := newSale("deleted", 3*time.Second)
+	require.NoError(t, storage.SetSale(deleted))
+
+	update := *approved
+	update.Status = StatusApproved
+	update.Version = 2
+	require.NoError(t, storage.CompareAndSetSale(&update, 1))
+	require.ErrorIs(t, storage.CompareAndSetSale(&update, 1), ErrVersionMismatch)
+	require.NoError(t, storage.DeleteSale(deleted.ID))
+	last := newSale("last", 4*time.Second)
+	require.NoError(t, storage.SetSale(last))
+	require.NoError(t, storage.Close())
+
+	// Kill the last write mid-record.
+	path := filepath.Join(dir, "wal.log")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-5))
+
+	storage, err = NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	got, err := storage.ReadSale(approved.ID)
+	require.NoError(t, err)
+	require.Equal(t, StatusApproved, got.Status)
+	require.Equal(t, 2, got.Version)
+
+	_, err = storage.ReadSale(deleted.ID)
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = storage.ReadSale(last.ID)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// The user and status indexes are rebuilt from the snapshot and the log.
+	ids := func(sales []*Sale) []string {
+		var out []string
+		for _, s := range sales {
+			out = append(out, s.ID)
+		}
+		return out
+	}
+	sales, meta := storage.ReadSales(Criteria{UserId: "u"})
+	require.Equal(t, []string{"approved", "pending"}, ids(sales))
+	require.Equal(t, 1, meta.Counts[StatusApproved])
+	sales, _ = storage.ReadSales(Criteria{UserId: "u", Statuses: []string{StatusPending}})
+	require.Equal(t, []string{"pending"}, ids(sales))
+
+	// Versions survive the restart, so stale updates are still refused.
+	stale := *got
+	stale.Status = StatusCancelled
+	require.ErrorIs(t, storage.CompareAndSetSale(&stale, 1), ErrVersionMismatch)
+	stale.Version = 3
+	require.NoError(t, storage.CompareAndSetSale(&stale, 2))
+
+	// The storage keeps accepting writes after recovery.
+	require.NoError(t, storage.SetSale(last))
+	_, err = storage.ReadSale(last.ID)
+	require.NoError(t, err)
+}
@@ -0,0 +1,107 @@
+package sale
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// indexKey locates a sale inside an index. Keys are ordered by CreatedAt,
+// with the sale ID breaking ties, so every index lists sales oldest first.
+type indexKey struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+func keyOf(sale *Sale) indexKey {
+	return indexKey{CreatedAt: sale.CreatedAt, ID: sale.ID}
+}
+
+func compareKeys(a, b indexKey) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// createdIndex is a list of sales kept sorted by CreatedAt.
+type createdIndex []indexKey
+
+// insert adds k keeping the index sorted.
+func (idx createdIndex) insert(k indexKey) createdIndex {
+	i, _ := slices.BinarySearchFunc(idx, k, compareKeys)
+	return slices.Insert(idx, i, k)
+}
+
+// remove drops k from the index, if present.
+func (idx createdIndex) remove(k indexKey) createdIndex {
+	i, found := slices.BinarySearchFunc(idx, k, compareKeys)
+	if !found {
+		return idx
+	}
+	return slices.Delete(idx, i, i+1)
+}
+
+// between returns the part of the index created in [from, to), treating
+// zero bounds as open.
+func (idx createdIndex) between(from, to time.Time) createdIndex {
+	start, end := 0, len(idx)
+	if !from.IsZero() {
+		start, _ = slices.BinarySearchFunc(idx, from, func(k indexKey, t time.Time) int {
+			return k.CreatedAt.Compare(t)
+		})
+	}
+	if !to.IsZero() {
+		end, _ = slices.BinarySearchFunc(idx, to, func(k indexKey, t time.Time) int {
+			return k.CreatedAt.Compare(t)
+		})
+	}
+	if start > end {
+		return nil
+	}
+	return idx[start:end]
+}
+
+// put stores sale in the shard and adds it to every secondary index,
+// replacing any previous version of it. The caller must hold sh.mu.
+func (sh *shard) put(sale *Sale) {
+	sh.remove(sale.ID)
+
+	sh.mapSale[sale.ID] = sale
+	k := keyOf(sale)
+	sh.byUser[sale.UserId] = sh.byUser[sale.UserId].insert(k)
+
+	byStatus, ok := sh.byUserStatus[sale.UserId]
+	if !ok {
+		byStatus = make(map[string]createdIndex)
+		sh.byUserStatus[sale.UserId] = byStatus
+	}
+	byStatus[sale.Status] = byStatus[sale.Status].insert(k)
+}
+
+// remove deletes the sale with the given ID from the shard and from every
+// secondary index. The caller must hold sh.mu.
+func (sh *shard) remove(id string) {
+	prev, ok := sh.mapSale[id]
+	if !ok {
+		return
+	}
+	delete(sh.mapSale, id)
+
+	k := keyOf(prev)
+	if idx := sh.byUser[prev.UserId].remove(k); len(idx) > 0 {
+		sh.byUser[prev.UserId] = idx
+	} else {
+		delete(sh.byUser, prev.UserId)
+	}
+
+	byStatus := sh.byUserStatus[prev.UserId]
+	if idx := byStatus[prev.Status].remove(k); len(idx) > 0 {
+		byStatus[prev.Status] = idx
+	} else {
+		delete(byStatus, prev.Status)
+	}
+	if len(byStatus) == 0 {
+		delete(sh.byUserStatus, prev.UserId)
+	}
+}
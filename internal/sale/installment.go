@@ -0,0 +1,230 @@
+package sale
+
+import (
+	"API_VentasGO/internal/money"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Installment statuses.
+const (
+	InstallmentPending = "pending"
+	InstallmentPaid    = "paid"
+	InstallmentOverdue = "overdue"
+)
+
+// maxInstallments is the longest installment plan a sale may have.
+const maxInstallments = 60
+
+// maxInterestRate is the highest interest rate, in percent, of an installment plan.
+var maxInterestRate = big.NewRat(1000, 1)
+
+// ErrInvalidInstallmentPlan is returned when creating a sale with an
+// installment plan that cannot be scheduled.
+var ErrInvalidInstallmentPlan = errors.New("invalid installment plan")
+
+// ErrInstallmentNotFound is returned when a sale has no installment with the given number.
+var ErrInstallmentNotFound = errors.New("installment not found")
+
+// ErrInvalidInstallmentStatus is returned for unknown installment statuses.
+var ErrInvalidInstallmentStatus = errors.New("invalid installment status")
+
+// ErrInstallmentTransition is returned for changes an installment cannot
+// take, such as paying it twice.
+var ErrInstallmentTransition = errors.New("installment status change not allowed")
+
+// ErrInstallmentNotDue is returned when marking overdue an installment whose
+// due date has not passed.
+var ErrInstallmentNotDue = errors.New("installment is not due yet")
+
+// ErrInstallmentsClosed is returned when changing the installments of a sale
+// that is not approved or partially refunded.
+var ErrInstallmentsClosed = errors.New("sale installments cannot change")
+
+// InstallmentPlan spreads the payment of a sale over monthly installments.
+// InterestRate is the total interest over the plan, in percent with up to
+// two decimals, charged on top of the sale Amount. Total is Amount plus
+// Interest, and the installment amounts add up exactly to it.
+type InstallmentPlan struct {
+	Count        int           `json:"count"`
+	InterestRate string        `json:"interest_rate"`
+	Interest     money.Money   `json:"interest"`
+	Total        money.Money   `json:"total"`
+	Installments []Installment `json:"installments"`
+}
+
+// Installment is one payment of an installment plan. The first one is due a
+// month after the sale was created.
+type Installment struct {
+	Number  int         `json:"number"`
+	DueDate time.Time   `json:"due_date"`
+	Amount  money.Money `json:"amount"`
+	Status  string      `json:"status"`
+	PaidAt  *time.Time  `json:"paid_at,omitempty"`
+}
+
+// InstallmentFields represents the optional fields for updating an installment.
+// ExpectedVersion, when set, makes the update fail unless the sale is still
+// at that version.
+type InstallmentFields struct {
+	Status          *string `json:"status"`
+	ExpectedVersion *int    `json:"expected_version"`
+}
+
+// clone returns a copy of the plan that shares no memory with it.
+func (p *InstallmentPlan) clone() *InstallmentPlan {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	c.Installments = slices.Clone(p.Installments)
+	for i, in := range c.Installments {
+		if in.PaidAt != nil {
+			paidAt := *in.PaidAt
+			c.Installments[i].PaidAt = &paidAt
+		}
+	}
+	return &c
+}
+
+// schedule fills in the plan of a sale of amount created at createdAt, from
+// its Count and InterestRate.
+// Returns ErrInvalidInstallmentPlan for counts outside 1 to maxInstallments
+// and for negative, too high or too precise interest rates.
+func (p *InstallmentPlan) schedule(amount money.Money, createdAt time.Time) error {
+	if p.Count < 1 || p.Count > maxInstallments {
+		return fmt.Errorf("%w: count must be between 1 and %d", ErrInvalidInstallmentPlan, maxInstallments)
+	}
+
+	rate := strings.TrimSpace(p.InterestRate)
+	if rate == "" {
+		rate = "0"
+	}
+	pct, ok := new(big.Rat).SetString(rate)
+	if !ok || pct.Sign() < 0 || pct.Cmp(maxInterestRate) > 0 {
+		return fmt.Errorf("%w: interest_rate must be a percentage between 0 and %s", ErrInvalidInstallmentPlan, maxInterestRate.FloatString(0))
+	}
+	if hundredths := new(big.Rat).Mul(pct, big.NewRat(100, 1)); !hundredths.IsInt() {
+		return fmt.Errorf("%w: interest_rate has more than two decimals", ErrInvalidInstallmentPlan)
+	}
+
+	interest, err := amount.Percent(pct)
+	if err != nil {
+		return err
+	}
+	total, err := amount.Add(interest)
+	if err != nil {
+		return err
+	}
+	parts, err := total.Split(p.Count)
+	if err != nil {
+		return err
+	}
+
+	p.InterestRate = pct.FloatString(2)
+	p.Interest = interest
+	p.Total = total
+	p.Installments = make([]Installment, p.Count)
+	for i, part := range parts {
+		p.Installments[i] = Installment{
+			Number:  i + 1,
+			DueDate: addMonths(createdAt, i+1),
+			Amount:  part,
+			Status:  InstallmentPending,
+		}
+	}
+	return nil
+}
+
+// addMonths returns the date, at midnight UTC, months after t. Days missing
+// from the target month fall on its last day, so January 31st plus one month
+// is the last day of February.
+func addMonths(t time.Time, months int) time.Time {
+	t = t.UTC()
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(t.Day(), last)-1)
+}
+
+// Installments returns the installments of a sale, in order. Sales without an
+// installment plan have none.
+// Returns ErrNotFound if the sale does not exist.
+func (s *Service) Installments(id string) ([]Installment, error) {
+	sale, err := s.storage.ReadSale(id)
+	if err != nil {
+		return nil, err
+	}
+	if sale.Plan == nil {
+		return nil, nil
+	}
+	return sale.Plan.Installments, nil
+}
+
+// UpdateInstallment marks an installment of an approved or partially
+// refunded sale paid or overdue. Pending installments may become paid, or
+// overdue once their due date has passed, and overdue ones may become paid.
+// It returns the installment and the updated sale.
+// Returns ErrNotFound if the sale does not exist, ErrInstallmentNotFound if it
+// has no such installment, ErrInstallmentsClosed for sales in other statuses,
+// ErrInvalidInstallmentStatus for statuses other than paid and overdue,
+// ErrInstallmentTransition or ErrInstallmentNotDue for changes that are not
+// allowed, and ErrVersionMismatch like Update.
+func (s *Service) UpdateInstallment(id string, number int, fields *InstallmentFields) (*Installment, *Sale, error) {
+	if fields.Status == nil {
+		return nil, nil, fmt.Errorf("%w: missing status", ErrInvalidInstallmentStatus)
+	}
+	to := strings.ToLower(*fields.Status)
+	if to != InstallmentPaid && to != InstallmentOverdue {
+		return nil, nil, fmt.Errorf("%w: %q, want %s or %s", ErrInvalidInstallmentStatus, to, InstallmentPaid, InstallmentOverdue)
+	}
+
+	for attempt := 1; ; attempt++ {
+		existing, err := s.storage.ReadSale(id)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if fields.ExpectedVersion != nil && *fields.ExpectedVersion != existing.Version {
+			return nil, nil, ErrVersionMismatch
+		}
+		version := existing.Version
+
+		if existing.Plan == nil || number < 1 || number > len(existing.Plan.Installments) {
+			return nil, nil, fmt.Errorf("%w: %d", ErrInstallmentNotFound, number)
+		}
+		if existing.Status != StatusApproved && existing.Status != StatusPartiallyRefunded {
+			return nil, nil, fmt.Errorf("%w: sale is %s", ErrInstallmentsClosed, existing.Status)
+		}
+
+		now := time.Now()
+		installment := &existing.Plan.Installments[number-1]
+		switch {
+		case to == InstallmentPaid && installment.Status != InstallmentPaid:
+			installment.PaidAt = &now
+		case to == InstallmentOverdue && installment.Status == InstallmentPending:
+			if now.Before(installment.DueDate) {
+				return nil, nil, fmt.Errorf("%w: due on %s", ErrInstallmentNotDue, installment.DueDate.Format(time.DateOnly))
+			}
+		default:
+			return nil, nil, fmt.Errorf("%w: installment %d is %s", ErrInstallmentTransition, number, installment.Status)
+		}
+		installment.Status = to
+
+		existing.UpdatedAt = now
+		existing.Version++
+
+		err = s.storage.CompareAndSetSale(existing, version)
+		if errors.Is(err, ErrVersionMismatch) && fields.ExpectedVersion == nil && attempt < maxUpdateAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return installment, existing, nil
+	}
+}
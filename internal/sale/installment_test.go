@@ -0,0 +1,171 @@
+package sale
+
+import (
+	"API_VentasGO/internal/money"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_CreateWithInstallments(t *testing.T) {
+	tests := []struct {
+		name        string
+		amount      money.Money
+		plan        InstallmentPlan
+		wantRate    string
+		wantTotal   money.Money
+		wantAmounts []int64
+		wantErr     error
+	}{
+		{
+			name: "no interest", amount: money.New(100000, "ARS"), plan: InstallmentPlan{Count: 3},
+			wantRate: "0.00", wantTotal: money.New(100000, "ARS"), wantAmounts: []int64{33334, 33333, 33333},
+		},
+		{
+			name: "with interest", amount: money.New(100000, "ARS"), plan: InstallmentPlan{Count: 6, InterestRate: "15.5"},
+			wantRate: "15.50", wantTotal: money.New(115500, "ARS"), wantAmounts: []int64{19250, 19250, 19250, 19250, 19250, 19250},
+		},
+		{
+			name: "rounded interest", amount: money.New(999, "ARS"), plan: InstallmentPlan{Count: 4, InterestRate: "12.5"},
+			wantRate: "12.50", wantTotal: money.New(1124, "ARS"), wantAmounts: []int64{281, 281, 281, 281},
+		},
+		{
+			name: "zero-decimal currency", amount: money.New(10000, "CLP"), plan: InstallmentPlan{Count: 12, InterestRate: "10"},
+			wantRate: "10.00", wantTotal: money.New(11000, "CLP"), wantAmounts: []int64{917, 917, 917, 917, 917, 917, 917, 917, 916, 916, 916, 916},
+		},
+		{name: "no installments", amount: money.New(1000, "ARS"), plan: InstallmentPlan{Count: 0}, wantErr: ErrInvalidInstallmentPlan},
+		{name: "too many installments", amount: money.New(1000, "ARS"), plan: InstallmentPlan{Count: 61}, wantErr: ErrInvalidInstallmentPlan},
+		{name: "negative rate", amount: money.New(1000, "ARS"), plan: InstallmentPlan{Count: 3, InterestRate: "-1"}, wantErr: ErrInvalidInstallmentPlan},
+		{name: "rate too precise", amount: money.New(1000, "ARS"), plan: InstallmentPlan{Count: 3, InterestRate: "1.005"}, wantErr: ErrInvalidInstallmentPlan},
+		{name: "rate not a number", amount: money.New(1000, "ARS"), plan: InstallmentPlan{Count: 3, InterestRate: "banana"}, wantErr: ErrInvalidInstallmentPlan},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewLocalStorage()
+			s := NewService(storage, nil, nil, nil, nil, nil, nil, nil, nil)
+
+			plan := tt.plan
+			input := &Sale{UserId: "1", Amount: tt.amount, Plan: &plan}
+			err := s.Create(input)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			got := input.Plan
+			require.Equal(t, tt.wantRate, got.InterestRate)
+			require.Equal(t, tt.wantTotal, got.Total)
+
+			var amounts []int64
+			sum := money.New(0, tt.amount.Currency)
+			for i, in := range got.Installments {
+				require.Equal(t, i+1, in.Number)
+				require.Equal(t, InstallmentPending, in.Status)
+				require.Equal(t, addMonths(input.CreatedAt, i+1), in.DueDate)
+				amounts = append(amounts, in.Amount.Minor)
+				sum, err = sum.Add(in.Amount)
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantAmounts, amounts)
+			require.Equal(t, got.Total, sum)
+
+			installments, err := s.Installments(input.ID)
+			require.NoError(t, err)
+			require.Equal(t, got.Installments, installments)
+		})
+	}
+}
+
+func TestAddMonths(t *testing.T) {
+	tests := []struct {
+		from   string
+		months int
+		want   string
+	}{
+		{from: "2026-01-15", months: 1, want: "2026-02-15"},
+		{from: "2026-01-31", months: 1, want: "2026-02-28"},
+		{from: "2028-01-31", months: 1, want: "2028-02-29"},
+		{from: "2026-01-31", months: 2, want: "2026-03-31"},
+		{from: "2026-11-30", months: 3, want: "2027-02-28"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from, func(t *testing.T) {
+			from, err := time.Parse(time.DateOnly, tt.from)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, addMonths(from.Add(15*time.Hour), tt.months).Format(time.DateOnly))
+		})
+	}
+}
+
+func TestService_UpdateInstallment(t *testing.T) {
+	past := time.Now().AddDate(0, -1, 0)
+	future := time.Now().AddDate(0, 1, 0)
+
+	tests := []struct {
+		name       string
+		saleStatus string
+		from       string
+		dueDate    time.Time
+		number     int
+		to         string
+		expected   int
+		wantErr    error
+	}{
+		{name: "pay", saleStatus: StatusApproved, from: InstallmentPending, dueDate: future, number: 1, to: InstallmentPaid},
+		{name: "pay overdue", saleStatus: StatusApproved, from: InstallmentOverdue, dueDate: past, number: 1, to: "PAID"},
+		{name: "mark overdue", saleStatus: StatusPartiallyRefunded, from: InstallmentPending, dueDate: past, number: 1, to: InstallmentOverdue},
+		{name: "overdue before due date", saleStatus: StatusApproved, from: InstallmentPending, dueDate: future, number: 1, to: InstallmentOverdue, wantErr: ErrInstallmentNotDue},
+		{name: "pay twice", saleStatus: StatusApproved, from: InstallmentPaid, dueDate: future, number: 1, to: InstallmentPaid, wantErr: ErrInstallmentTransition},
+		{name: "paid to overdue", saleStatus: StatusApproved, from: InstallmentPaid, dueDate: past, number: 1, to: InstallmentOverdue, wantErr: ErrInstallmentTransition},
+		{name: "back to pending", saleStatus: StatusApproved, from: InstallmentOverdue, dueDate: past, number: 1, to: InstallmentPending, wantErr: ErrInvalidInstallmentStatus},
+		{name: "unknown installment", saleStatus: StatusApproved, from: InstallmentPending, dueDate: future, number: 3, to: InstallmentPaid, wantErr: ErrInstallmentNotFound},
+		{name: "pending sale", saleStatus: StatusPending, from: InstallmentPending, dueDate: future, number: 1, to: InstallmentPaid, wantErr: ErrInstallmentsClosed},
+		{name: "stale version", saleStatus: StatusApproved, from: InstallmentPending, dueDate: future, number: 1, to: InstallmentPaid, expected: 2, wantErr: ErrVersionMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewLocalStorage()
+			require.NoError(t, storage.SetSale(&Sale{
+				ID: "1", UserId: "1", Amount: money.New(1000, "ARS"), Status: tt.saleStatus, Version: 1,
+				Plan: &InstallmentPlan{Count: 2, Installments: []Installment{
+					{Number: 1, DueDate: tt.dueDate, Amount: money.New(500, "ARS"), Status: tt.from},
+					{Number: 2, DueDate: tt.dueDate.AddDate(0, 1, 0), Amount: money.New(500, "ARS"), Status: InstallmentPending},
+				}},
+			}))
+			s := NewService(storage, nil, nil, nil, nil, nil, nil, nil, nil)
+
+			fields := &InstallmentFields{Status: &tt.to}
+			if tt.expected != 0 {
+				fields.ExpectedVersion = &tt.expected
+			}
+			got, updated, err := s.UpdateInstallment("1", tt.number, fields)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				stored, _ := storage.ReadSale("1")
+				require.Equal(t, tt.from, stored.Plan.Installments[0].Status)
+				require.Equal(t, 1, stored.Version)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, 2, updated.Version)
+			require.Equal(t, tt.number, got.Number)
+
+			stored, err := storage.ReadSale("1")
+			require.NoError(t, err)
+			installment := stored.Plan.Installments[tt.number-1]
+			require.Equal(t, got.Status, installment.Status)
+			require.Equal(t, got.Status == InstallmentPaid, installment.PaidAt != nil)
+			require.Equal(t, InstallmentPending, stored.Plan.Installments[1].Status)
+		})
+	}
+
+	t.Run("missing sale", func(t *testing.T) {
+		s := NewService(NewLocalStorage(), nil, nil, nil, nil, nil, nil, nil, nil)
+		paid := InstallmentPaid
+		_, _, err := s.UpdateInstallment("missing", 1, &InstallmentFields{Status: &paid})
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -0,0 +1,144 @@
+package sale
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Payment method types.
+const (
+	MethodCash     = "cash"
+	MethodDebit    = "debit"
+	MethodCredit   = "credit"
+	MethodTransfer = "transfer"
+	MethodWallet   = "wallet"
+	MethodAccount  = "account"
+)
+
+// Methods lists every payment method a sale can be paid with.
+var Methods = []string{MethodCash, MethodDebit, MethodCredit, MethodTransfer, MethodWallet, MethodAccount}
+
+// CardBrands lists the card brands accepted for debit and credit payments.
+var CardBrands = []string{"amex", "cabal", "maestro", "mastercard", "naranja", "visa"}
+
+// maxReferenceLength bounds transfer references and wallet names.
+const maxReferenceLength = 64
+
+// ErrInvalidPaymentMethod is returned when a payment method is unknown or
+// its fields do not follow the rules of the method.
+var ErrInvalidPaymentMethod = errors.New("invalid payment method")
+
+// PaymentMethod is how a sale was paid. Type is one of Methods, and only the
+// fields of that method may be set: CardBrand and CardLast4 for debit and
+// credit, TransferReference for transfers, and Wallet with an optional
+// WalletReference for wallets. Cash and account, which charges the sale to
+// the credit account of the user, take none.
+type PaymentMethod struct {
+	Type              string `json:"type"`
+	CardBrand         string `json:"card_brand,omitempty"`
+	CardLast4         string `json:"card_last4,omitempty"`
+	TransferReference string `json:"transfer_reference,omitempty"`
+	Wallet            string `json:"wallet,omitempty"`
+	WalletReference   string `json:"wallet_reference,omitempty"`
+}
+
+// methodRule is what a payment method requires and how it settles.
+type methodRule struct {
+	// validate checks the fields of the method. They are already trimmed.
+	validate func(m *PaymentMethod) error
+
+	// status is the initial status of sales paid with the method, or empty
+	// to ask the PaymentAuthorizer.
+	status string
+
+	// installments reports whether the method may pay in installments.
+	installments bool
+}
+
+// methodRules holds the rules of every payment method.
+var methodRules = map[string]methodRule{
+	MethodCash:     {validate: noFields, status: StatusApproved},
+	MethodDebit:    {validate: card},
+	MethodCredit:   {validate: card, installments: true},
+	MethodTransfer: {validate: transfer, status: StatusPending},
+	MethodWallet:   {validate: wallet},
+	MethodAccount:  {validate: noFields, status: StatusApproved},
+}
+
+// noFields only lets through methods without any detail.
+func noFields(m *PaymentMethod) error {
+	if *m != (PaymentMethod{Type: m.Type}) {
+		return fmt.Errorf("%w: %s takes no details", ErrInvalidPaymentMethod, m.Type)
+	}
+	return nil
+}
+
+// card requires a known brand and the last four digits of the card.
+func card(m *PaymentMethod) error {
+	if m.TransferReference != "" || m.Wallet != "" || m.WalletReference != "" {
+		return fmt.Errorf("%w: %s takes only card_brand and card_last4", ErrInvalidPaymentMethod, m.Type)
+	}
+	m.CardBrand = strings.ToLower(m.CardBrand)
+	if !slices.Contains(CardBrands, m.CardBrand) {
+		return fmt.Errorf("%w: card_brand %q, want one of %s", ErrInvalidPaymentMethod, m.CardBrand, strings.Join(CardBrands, ", "))
+	}
+	if len(m.CardLast4) != 4 || strings.Trim(m.CardLast4, "0123456789") != "" {
+		return fmt.Errorf("%w: card_last4 must be 4 digits", ErrInvalidPaymentMethod)
+	}
+	return nil
+}
+
+// transfer requires the reference of the bank transfer.
+func transfer(m *PaymentMethod) error {
+	if m.CardBrand != "" || m.CardLast4 != "" || m.Wallet != "" || m.WalletReference != "" {
+		return fmt.Errorf("%w: transfer takes only transfer_reference", ErrInvalidPaymentMethod)
+	}
+	if m.TransferReference == "" || len(m.TransferReference) > maxReferenceLength {
+		return fmt.Errorf("%w: transfer_reference must have 1 to %d characters", ErrInvalidPaymentMethod, maxReferenceLength)
+	}
+	return nil
+}
+
+// wallet requires the name of the wallet.
+func wallet(m *PaymentMethod) error {
+	if m.CardBrand != "" || m.CardLast4 != "" || m.TransferReference != "" {
+		return fmt.Errorf("%w: wallet takes only wallet and wallet_reference", ErrInvalidPaymentMethod)
+	}
+	m.Wallet = strings.ToLower(m.Wallet)
+	if m.Wallet == "" || len(m.Wallet) > maxReferenceLength || len(m.WalletReference) > maxReferenceLength {
+		return fmt.Errorf("%w: wallet must have 1 to %d characters", ErrInvalidPaymentMethod, maxReferenceLength)
+	}
+	return nil
+}
+
+// checkPayment normalizes and validates the payment method of a new sale, if
+// any, and returns its rule.
+// Returns ErrInvalidPaymentMethod for unknown methods, fields that break the
+// rules of the method, and installment plans paid with methods other than credit.
+func checkPayment(sale *Sale) (methodRule, error) {
+	m := sale.Payment
+	if m == nil {
+		return methodRule{}, nil
+	}
+
+	m.Type = strings.ToLower(strings.TrimSpace(m.Type))
+	m.CardBrand = strings.TrimSpace(m.CardBrand)
+	m.CardLast4 = strings.TrimSpace(m.CardLast4)
+	m.TransferReference = strings.TrimSpace(m.TransferReference)
+	m.Wallet = strings.TrimSpace(m.Wallet)
+	m.WalletReference = strings.TrimSpace(m.WalletReference)
+
+	rule, ok := methodRules[m.Type]
+	if !ok {
+		return methodRule{}, fmt.Errorf("%w: %q, want one of %s", ErrInvalidPaymentMethod, m.Type, strings.Join(Methods, ", "))
+	}
+	if err := rule.validate(m); err != nil {
+		return methodRule{}, err
+	}
+	if sale.Plan != nil && !rule.installments {
+		return methodRule{}, fmt.Errorf("%w: %s cannot pay in installments", ErrInvalidPaymentMethod, m.Type)
+	}
+	return rule, nil
+}
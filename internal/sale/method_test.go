@@ -0,0 +1,78 @@
+package sale
+
+import (
+	"API_VentasGO/internal/money"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_CreateWithPaymentMethod(t *testing.T) {
+	tests := []struct {
+		name       string
+		payment    *PaymentMethod
+		plan       *InstallmentPlan
+		wantStatus string
+		wantErr    error
+	}{
+		{name: "no method asks the authorizer", wantStatus: StatusRejected},
+		{name: "cash is approved", payment: &PaymentMethod{Type: "Cash"}, wantStatus: StatusApproved},
+		{name: "transfer starts pending", payment: &PaymentMethod{Type: MethodTransfer, TransferReference: "CBU-123"}, wantStatus: StatusPending},
+		{name: "debit asks the authorizer", payment: &PaymentMethod{Type: MethodDebit, CardBrand: "VISA", CardLast4: "4242"}, wantStatus: StatusRejected},
+		{name: "wallet asks the authorizer", payment: &PaymentMethod{Type: MethodWallet, Wallet: "MercadoPago"}, wantStatus: StatusRejected},
+		{name: "credit in installments", payment: &PaymentMethod{Type: MethodCredit, CardBrand: "mastercard", CardLast4: "0001"}, plan: &InstallmentPlan{Count: 3}, wantStatus: StatusRejected},
+		{name: "unknown method", payment: &PaymentMethod{Type: "cheque"}, wantErr: ErrInvalidPaymentMethod},
+		{name: "cash with card", payment: &PaymentMethod{Type: MethodCash, CardLast4: "4242"}, wantErr: ErrInvalidPaymentMethod},
+		{name: "unknown brand", payment: &PaymentMethod{Type: MethodCredit, CardBrand: "diners", CardLast4: "4242"}, wantErr: ErrInvalidPaymentMethod},
+		{name: "short last4", payment: &PaymentMethod{Type: MethodDebit, CardBrand: "visa", CardLast4: "424"}, wantErr: ErrInvalidPaymentMethod},
+		{name: "letters in last4", payment: &PaymentMethod{Type: MethodDebit, CardBrand: "visa", CardLast4: "42a2"}, wantErr: ErrInvalidPaymentMethod},
+		{name: "card with transfer reference", payment: &PaymentMethod{Type: MethodDebit, CardBrand: "visa", CardLast4: "4242", TransferReference: "x"}, wantErr: ErrInvalidPaymentMethod},
+		{name: "transfer without reference", payment: &PaymentMethod{Type: MethodTransfer, TransferReference: "  "}, wantErr: ErrInvalidPaymentMethod},
+		{name: "wallet without name", payment: &PaymentMethod{Type: MethodWallet}, wantErr: ErrInvalidPaymentMethod},
+		{name: "debit in installments", payment: &PaymentMethod{Type: MethodDebit, CardBrand: "visa", CardLast4: "4242"}, plan: &InstallmentPlan{Count: 3}, wantErr: ErrInvalidPaymentMethod},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(NewLocalStorage(), nil, nil, nil, nil, nil, nil, &RuleAuthorizer{Default: StatusRejected}, nil)
+
+			input := &Sale{UserId: "1", Amount: money.New(1000, "ARS"), Payment: tt.payment, Plan: tt.plan}
+			err := s.Create(input)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, input.Status)
+
+			got, err := s.Get(input.ID)
+			require.NoError(t, err)
+			require.Equal(t, input.Payment, got.Payment)
+		})
+	}
+
+	t.Run("normalized", func(t *testing.T) {
+		s := NewService(NewLocalStorage(), nil, nil, nil, nil, nil, nil, nil, nil)
+		input := &Sale{UserId: "1", Amount: money.New(1000, "ARS"), Payment: &PaymentMethod{Type: " Debit ", CardBrand: " VISA ", CardLast4: " 4242 "}}
+		require.NoError(t, s.Create(input))
+		require.Equal(t, &PaymentMethod{Type: MethodDebit, CardBrand: "visa", CardLast4: "4242"}, input.Payment)
+	})
+}
+
+func TestMetadata_ByMethod(t *testing.T) {
+	storage := NewLocalStorage()
+	s := NewService(storage, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	require.NoError(t, s.Create(&Sale{UserId: "1", Amount: money.New(1000, "ARS"), Payment: &PaymentMethod{Type: MethodCash}}))
+	require.NoError(t, s.Create(&Sale{UserId: "1", Amount: money.New(500, "ARS"), Payment: &PaymentMethod{Type: MethodCash}}))
+	require.NoError(t, s.Create(&Sale{UserId: "1", Amount: money.New(700, "ARS"), Payment: &PaymentMethod{Type: MethodTransfer, TransferReference: "T-1"}}))
+	require.NoError(t, s.Create(&Sale{UserId: "1", Amount: money.New(300, "ARS")}))
+
+	_, meta := s.GetUserSales("1", "")
+	require.Equal(t, 4, meta.Quantity)
+	require.Len(t, meta.ByMethod, 2)
+	require.Equal(t, 2, meta.ByMethod[MethodCash].Quantity)
+	require.Equal(t, money.New(1500, "ARS"), meta.ByMethod[MethodCash].Total_amount.Get("ARS"))
+	require.Equal(t, 1, meta.ByMethod[MethodTransfer].Quantity)
+	require.Equal(t, money.New(700, "ARS"), meta.ByMethod[MethodTransfer].Total_amount.Get("ARS"))
+}
@@ -0,0 +1,179 @@
+package sale
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidSort is returned when a page asks for an unknown sort field.
+var ErrInvalidSort = errors.New("invalid sort")
+
+// ErrInvalidLimit is returned when a page size is out of range.
+var ErrInvalidLimit = errors.New("invalid limit")
+
+// ErrInvalidCursor is returned when a cursor is malformed or was issued for
+// a different sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Sort fields.
+const (
+	SortCreatedAt = "created_at"
+	SortUpdatedAt = "updated_at"
+	SortAmount    = "amount"
+)
+
+const (
+	// DefaultLimit is the page size used when none is given.
+	DefaultLimit = 50
+
+	// MaxLimit is the largest page size allowed.
+	MaxLimit = 500
+)
+
+// Page selects a slice of a sorted list of sales.
+// Zero values mean created_at ascending, DefaultLimit and the first page.
+type Page struct {
+	Sort   string
+	Desc   bool
+	Limit  int
+	Cursor string
+}
+
+// SalesPage is a page of sales. Metadata summarizes the whole filtered set,
+// not only the page, and NextCursor is empty on the last page.
+type SalesPage struct {
+	Results    []*Sale
+	NextCursor string
+	Metadata   *Metadata
+}
+
+// cursor is the decoded form of Page.Cursor: the sort it was issued for and
+// the sort key of the last sale returned. Pages continue strictly after that
+// key, so sales inserted meanwhile never shift or repeat results.
+type cursor struct {
+	Sort     string `json:"s"`
+	Desc     bool   `json:"d,omitempty"`
+	Time     int64  `json:"t,omitempty"`
+	Currency string `json:"c,omitempty"`
+	Minor    int64  `json:"m,omitempty"`
+	ID       string `json:"i"`
+}
+
+// normalize validates the page and fills in its defaults.
+func (p Page) normalize() (Page, error) {
+	p.Sort = strings.ToLower(p.Sort)
+	switch p.Sort {
+	case "":
+		p.Sort = SortCreatedAt
+	case SortCreatedAt, SortUpdatedAt, SortAmount:
+	default:
+		return p, &FilterError{Param: "sort", Err: fmt.Errorf("%w: %q", ErrInvalidSort, p.Sort)}
+	}
+
+	switch {
+	case p.Limit == 0:
+		p.Limit = DefaultLimit
+	case p.Limit < 0 || p.Limit > MaxLimit:
+		return p, &FilterError{Param: "limit", Err: fmt.Errorf("%w: must be between 1 and %d", ErrInvalidLimit, MaxLimit)}
+	}
+
+	return p, nil
+}
+
+// cursorOf builds the cursor that resumes after sale.
+func cursorOf(p Page, sale *Sale) cursor {
+	c := cursor{Sort: p.Sort, Desc: p.Desc, ID: sale.ID}
+	switch p.Sort {
+	case SortCreatedAt:
+		c.Time = sale.CreatedAt.UnixNano()
+	case SortUpdatedAt:
+		c.Time = sale.UpdatedAt.UnixNano()
+	case SortAmount:
+		c.Currency = sale.Amount.Currency
+		c.Minor = sale.Amount.Minor
+	}
+	return c
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// compareCursors orders two sort keys of the same sort, ascending.
+func compareCursors(a, b cursor) int {
+	return cmp.Or(
+		cmp.Compare(a.Time, b.Time),
+		cmp.Compare(a.Currency, b.Currency),
+		cmp.Compare(a.Minor, b.Minor),
+		cmp.Compare(a.ID, b.ID),
+	)
+}
+
+// paginate sorts sales as the page asks and returns the page after its
+// cursor, plus the cursor of the following page, if any.
+func paginate(sales []*Sale, p Page) ([]*Sale, string, error) {
+	p, err := p.normalize()
+	if err != nil {
+		return nil, "", err
+	}
+
+	compare := func(a, b *Sale) int {
+		c := compareCursors(cursorOf(p, a), cursorOf(p, b))
+		if p.Desc {
+			return -c
+		}
+		return c
+	}
+
+	sorted := slices.Clone(sales)
+	slices.SortFunc(sorted, compare)
+
+	start := 0
+	if p.Cursor != "" {
+		after, err := decodeCursor(p.Cursor)
+		if err != nil {
+			return nil, "", &FilterError{Param: "cursor", Err: err}
+		}
+		if after.Sort != p.Sort || after.Desc != p.Desc {
+			return nil, "", &FilterError{Param: "cursor", Err: fmt.Errorf("%w: issued for another sort", ErrInvalidCursor)}
+		}
+
+		start, _ = slices.BinarySearchFunc(sorted, after, func(s *Sale, c cursor) int {
+			r := compareCursors(cursorOf(p, s), c)
+			if p.Desc {
+				return -r
+			}
+			return r
+		})
+		if start < len(sorted) && sorted[start].ID == after.ID {
+			start++
+		}
+	}
+
+	end := min(start+p.Limit, len(sorted))
+	page := sorted[start:end]
+
+	next := ""
+	if end < len(sorted) {
+		next = cursorOf(p, page[len(page)-1]).encode()
+	}
+	return page, next, nil
+}
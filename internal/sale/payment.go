@@ -0,0 +1,138 @@
+package sale
+
+import (
+	"API_VentasGO/internal/money"
+	"errors"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrPaymentUnavailable is returned when the payment gateway fails to answer.
+var ErrPaymentUnavailable = errors.New("payment gateway unavailable")
+
+// PaymentAuthorizer decides the initial status of a sale when it is created.
+type PaymentAuthorizer interface {
+	Authorize(sale *Sale) (string, error)
+}
+
+// AlwaysPending leaves every new sale pending, to be resolved later with Update.
+type AlwaysPending struct{}
+
+// Authorize always returns "pending".
+func (AlwaysPending) Authorize(*Sale) (string, error) {
+	return "pending", nil
+}
+
+// Rule matches sales by amount range and user.
+// Zero values mean "no restriction" for that field. Amount bounds only match
+// sales in their own currency.
+type Rule struct {
+	MinAmount money.Money `json:"min_amount"`
+	MaxAmount money.Money `json:"max_amount"`
+	UserIds   []string    `json:"user_ids"`
+	Status    string      `json:"status"`
+}
+
+// matches reports whether the sale satisfies every restriction of the rule.
+func (r Rule) matches(sale *Sale) bool {
+	if !r.MinAmount.IsZero() {
+		if c, err := sale.Amount.Cmp(r.MinAmount); err != nil || c < 0 {
+			return false
+		}
+	}
+	if !r.MaxAmount.IsZero() {
+		if c, err := sale.Amount.Cmp(r.MaxAmount); err != nil || c > 0 {
+			return false
+		}
+	}
+	if len(r.UserIds) > 0 && !slices.Contains(r.UserIds, sale.UserId) {
+		return false
+	}
+	return true
+}
+
+// RuleAuthorizer assigns the status of the first matching rule, or Default
+// when no rule matches.
+type RuleAuthorizer struct {
+	Rules   []Rule `json:"rules"`
+	Default string `json:"default"`
+}
+
+// Authorize returns the status of the first rule matching the sale.
+func (a *RuleAuthorizer) Authorize(sale *Sale) (string, error) {
+	for _, r := range a.Rules {
+		if r.matches(sale) {
+			return r.Status, nil
+		}
+	}
+	if a.Default == "" {
+		return "pending", nil
+	}
+	return a.Default, nil
+}
+
+// SimulatedGatewayConfig configures a SimulatedGateway.
+// The rates are probabilities in [0, 1]; whatever is left after failures,
+// rejections and pending answers is approved.
+type SimulatedGatewayConfig struct {
+	// Seed makes runs reproducible: the same seed yields the same answers.
+	Seed int64
+
+	// Latency is the minimum time every authorization takes, plus a random
+	// extra of up to Jitter.
+	Latency time.Duration
+	Jitter  time.Duration
+
+	FailureRate float64
+	RejectRate  float64
+	PendingRate float64
+}
+
+// SimulatedGateway imitates a remote payment gateway with configurable
+// latency and failure rates, driven by a seeded random source.
+type SimulatedGateway struct {
+	cfg   SimulatedGatewayConfig
+	sleep func(time.Duration)
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewSimulatedGateway creates a SimulatedGateway from its configuration.
+func NewSimulatedGateway(cfg SimulatedGatewayConfig) *SimulatedGateway {
+	return &SimulatedGateway{
+		cfg:   cfg,
+		sleep: time.Sleep,
+		rnd:   rand.New(rand.NewSource(cfg.Seed)),
+	}
+}
+
+// Authorize waits for the simulated latency and then fails, rejects, leaves
+// pending or approves the sale according to the configured rates.
+// Returns ErrPaymentUnavailable when the simulated gateway fails.
+func (g *SimulatedGateway) Authorize(*Sale) (string, error) {
+	g.mu.Lock()
+	delay := g.cfg.Latency
+	if g.cfg.Jitter > 0 {
+		delay += time.Duration(g.rnd.Int63n(int64(g.cfg.Jitter)))
+	}
+	roll := g.rnd.Float64()
+	g.mu.Unlock()
+
+	if delay > 0 {
+		g.sleep(delay)
+	}
+
+	switch {
+	case roll < g.cfg.FailureRate:
+		return "", ErrPaymentUnavailable
+	case roll < g.cfg.FailureRate+g.cfg.RejectRate:
+		return "rejected", nil
+	case roll < g.cfg.FailureRate+g.cfg.RejectRate+g.cfg.PendingRate:
+		return "pending", nil
+	default:
+		return "approved", nil
+	}
+}
@@ -0,0 +1,84 @@
+package sale
+
+import (
+	"API_VentasGO/internal/money"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleAuthorizer(t *testing.T) {
+	a := &RuleAuthorizer{
+		Rules: []Rule{
+			{UserIds: []string{"vip"}, Status: "approved"},
+			{MinAmount: money.New(100000, "ARS"), Status: "rejected"},
+			{MaxAmount: money.New(10000, "ARS"), Status: "approved"},
+		},
+		Default: "pending",
+	}
+
+	tests := []struct {
+		name string
+		sale *Sale
+		want string
+	}{
+		{name: "by user", sale: &Sale{UserId: "vip", Amount: money.New(500000, "ARS")}, want: "approved"},
+		{name: "above min amount", sale: &Sale{UserId: "1", Amount: money.New(500000, "ARS")}, want: "rejected"},
+		{name: "below max amount", sale: &Sale{UserId: "1", Amount: money.New(5000, "ARS")}, want: "approved"},
+		{name: "default", sale: &Sale{UserId: "1", Amount: money.New(50000, "ARS")}, want: "pending"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.Authorize(tt.sale)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSimulatedGateway_Reproducible(t *testing.T) {
+	cfg := SimulatedGatewayConfig{
+		Seed:        42,
+		Latency:     time.Millisecond,
+		Jitter:      time.Millisecond,
+		FailureRate: 0.2,
+		RejectRate:  0.3,
+		PendingRate: 0.2,
+	}
+
+	run := func() ([]string, []time.Duration) {
+		g := NewSimulatedGateway(cfg)
+		var delays []time.Duration
+		g.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+		var got []string
+		for i := 0; i < 50; i++ {
+			status, err := g.Authorize(&Sale{})
+			if err != nil {
+				require.ErrorIs(t, err, ErrPaymentUnavailable)
+				status = "failed"
+			}
+			got = append(got, status)
+		}
+		return got, delays
+	}
+
+	first, firstDelays := run()
+	second, secondDelays := run()
+	require.Equal(t, first, second)
+	require.Equal(t, firstDelays, secondDelays)
+	for _, d := range firstDelays {
+		require.GreaterOrEqual(t, d, cfg.Latency)
+		require.Less(t, d, cfg.Latency+cfg.Jitter)
+	}
+	require.Contains(t, first, "failed")
+	require.Contains(t, first, "approved")
+}
+
+func TestService_Create_PaymentUnavailable(t *testing.T) {
+	s := NewService(NewLocalStorage(), nil, nil, nil, nil, nil, nil, NewSimulatedGateway(SimulatedGatewayConfig{FailureRate: 1}), nil)
+
+	err := s.Create(&Sale{UserId: "1", Amount: money.New(1000, "ARS")})
+	require.ErrorIs(t, err, ErrPaymentUnavailable)
+}
@@ -0,0 +1,133 @@
+package sale
+
+import (
+	"API_VentasGO/internal/money"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrNotRefundable is returned when refunding a sale that was never approved
+// or is already fully refunded.
+var ErrNotRefundable = errors.New("sale is not refundable")
+
+// ErrInvalidRefund is returned when a refund amount is not greater than 0.
+var ErrInvalidRefund = errors.New("refund amount must be greater than 0")
+
+// ErrRefundExceedsBalance is returned when a refund is larger than what is
+// left to refund on the sale.
+var ErrRefundExceedsBalance = errors.New("refund exceeds the refundable amount")
+
+// RefundFields describes a refund to make on a sale.
+// A nil Amount refunds everything that is left. ExpectedVersion, when set,
+// makes the refund fail unless the sale is still at that version.
+type RefundFields struct {
+	Amount          *money.Money `json:"amount"`
+	Reason          string       `json:"reason"`
+	ExpectedVersion *int         `json:"expected_version"`
+}
+
+// Refundable returns what is left to refund on the sale.
+func (s *Sale) Refundable() money.Money {
+	left, _ := s.Amount.Sub(s.Refunded())
+	return left
+}
+
+// Refund gives back part or all of what is left of an approved sale, and
+// moves it to partially_refunded or refunded. Refunds of account sales are
+// credited back to the account. It returns the new refund and the updated
+// sale.
+// Returns ErrNotRefundable if the sale is not approved or partially refunded,
+// ErrInvalidRefund or money.ErrCurrencyMismatch for bad amounts,
+// ErrRefundExceedsBalance if the amount is more than what is left, and
+// ErrVersionMismatch like Update.
+func (s *Service) Refund(id string, fields *RefundFields) (*Refund, *Sale, error) {
+	for attempt := 1; ; attempt++ {
+		existing, err := s.storage.ReadSale(id)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if fields.ExpectedVersion != nil && *fields.ExpectedVersion != existing.Version {
+			return nil, nil, ErrVersionMismatch
+		}
+		version := existing.Version
+
+		if existing.Status != StatusApproved && existing.Status != StatusPartiallyRefunded {
+			return nil, nil, fmt.Errorf("%w: sale is %s", ErrNotRefundable, existing.Status)
+		}
+
+		left := existing.Refundable()
+		amount := left
+		if fields.Amount != nil {
+			amount = *fields.Amount
+		}
+		if !amount.IsPositive() {
+			return nil, nil, ErrInvalidRefund
+		}
+		cmp, err := amount.Cmp(left)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cmp > 0 {
+			return nil, nil, fmt.Errorf("%w: %s left", ErrRefundExceedsBalance, left)
+		}
+
+		now := time.Now()
+		refund := Refund{
+			ID:        uuid.NewString(),
+			SaleID:    existing.ID,
+			Amount:    amount,
+			Reason:    fields.Reason,
+			CreatedAt: now,
+		}
+		existing.Refunds = append(existing.Refunds, refund)
+
+		from := existing.Status
+		to := StatusPartiallyRefunded
+		if cmp == 0 {
+			to = StatusRefunded
+		}
+		if err := transition(existing, to); err != nil {
+			return nil, nil, err
+		}
+
+		existing.UpdatedAt = now
+		existing.Version++
+
+		err = s.storage.CompareAndSetSale(existing, version)
+		if errors.Is(err, ErrVersionMismatch) && fields.ExpectedVersion == nil && attempt < maxUpdateAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		s.refundAccount(existing, &refund)
+
+		for _, o := range s.observers {
+			if existing.Status != from {
+				if err := o.SaleStatusChanged(existing, from); err != nil {
+					s.Logger.Error("failed to notify sale status change", zap.Error(err), zap.String("sale_id", existing.ID))
+				}
+			}
+			if err := o.SaleRefunded(existing, &refund); err != nil {
+				s.Logger.Error("failed to notify sale refund", zap.Error(err), zap.String("sale_id", existing.ID))
+			}
+		}
+
+		return &refund, existing, nil
+	}
+}
+
+// Refunds returns the refunds of a sale, oldest first.
+// Returns ErrNotFound if the sale does not exist.
+func (s *Service) Refunds(id string) ([]Refund, error) {
+	sale, err := s.storage.ReadSale(id)
+	if err != nil {
+		return nil, err
+	}
+	return sale.Refunds, nil
+}
@@ -1,8 +1,8 @@
 package sale
 
 import (
-	"math/rand"
-	"os"
+	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -10,10 +10,20 @@ import (
 	"go.uber.org/zap"
 )
 
+// UserService is the view of the users that sale needs.
+// FindUser returns an error wrapping ErrUserNotFound when the user does not exist.
 type UserService interface {
 	FindUser(id string) error
 }
 
+// Observer is notified after a sale change has been stored.
+// Observers must not modify the sale they receive.
+type Observer interface {
+	SaleCreated(sale *Sale) error
+	SaleStatusChanged(sale *Sale, from string) error
+	SaleRefunded(sale *Sale, refund *Refund) error
+}
+
 // Service provides high-level sale management operations on a LocalStorage backend.
 type Service struct {
 	// storage is the underlying persistence for User entities.
@@ -23,25 +33,83 @@ type Service struct {
 	Logger *zap.Logger
 
 	userService UserService
+
+	// catalog prices the items of new sales.
+	catalog Catalog
+
+	// inventory holds the stock of the items of pending sales.
+	inventory Inventory
+
+	// taxes computes the taxes of new sales.
+	taxes TaxEngine
+
+	// promotions redeems the coupons of new sales.
+	promotions Promotions
+
+	// accounts holds the credit accounts account sales are charged to.
+	accounts Accounts
+
+	// authorizer decides the initial status of new sales.
+	authorizer PaymentAuthorizer
+
+	// observers are notified of every stored sale change.
+	observers []Observer
 }
 
 // NewService creates a new Service.
-func NewService(storage Storage, userService UserService, logger *zap.Logger) *Service {
+// A nil authorizer leaves every new sale pending, a nil catalog only
+// accepts sales without items, a nil inventory does not track stock, nil
+// taxes leave sales untaxed, nil promotions reject sales with coupons and
+// nil accounts reject sales paid with MethodAccount.
+func NewService(storage Storage, userService UserService, catalog Catalog, inventory Inventory, taxes TaxEngine, promotions Promotions, accounts Accounts, authorizer PaymentAuthorizer, logger *zap.Logger) *Service {
 	if logger == nil {
 		logger, _ = zap.NewProduction()
 		defer logger.Sync()
 	}
 
+	if authorizer == nil {
+		authorizer = AlwaysPending{}
+	}
+
 	return &Service{
 		storage:     storage,
 		userService: userService,
+		catalog:     catalog,
+		inventory:   inventory,
+		taxes:       taxes,
+		promotions:  promotions,
+		accounts:    accounts,
+		authorizer:  authorizer,
 		Logger:      logger,
 	}
 }
 
+// AddObserver registers o to be notified of sale creations and status changes.
+// It must be called before the service starts serving requests.
+func (s *Service) AddObserver(o Observer) {
+	s.observers = append(s.observers, o)
+}
+
 // Create adds a brand-new sale to the system.
+// When the sale has items, its Amount is computed from their catalog prices,
+// which are copied onto the items, and their stock is reserved before the
+// payment is authorized: approved sales commit it at once and rejected ones
+// give it back. A sale with a Discount code redeems that coupon, which takes
+// the discount off the Amount and is given back if the sale is rejected or
+// cannot be created. The Amount is then taxed and raised to the gross amount,
+// and a Plan with Count and InterestRate set gets its installment schedule
+// over it.
+// Its status is decided by its payment method: cash and account sales are
+// approved and transfers start pending, while cards, wallets and sales
+// without a method ask the PaymentAuthorizer. Account sales are charged to
+// the credit account of the user before they are stored.
 // It sets CreatedAt and UpdatedAt to the current time and initializes Version to 1.
-// Returns ErrEmptyID if sale.ID is empty.
+// Returns ErrEmptyID if sale.ID is empty, an error wrapping ErrOutOfStock
+// if an item is not available, ErrInvalidInstallmentPlan for plans that
+// cannot be scheduled, ErrInvalidPaymentMethod for payment methods that
+// break their rules, errors wrapping ErrCouponNotFound or
+// ErrCouponNotApplicable for coupons that cannot be used, and an error
+// wrapping ErrCreditLimitExceeded for account sales over the credit limit.
 func (s *Service) Create(sale *Sale) error {
 	if s.userService != nil {
 		if err := s.userService.FindUser(sale.UserId); err != nil {
@@ -50,23 +118,88 @@ func (s *Service) Create(sale *Sale) error {
 		}
 	}
 
-	if os.Getenv("MODO") != "testing" {
-		opciones := []string{"approved", "rejected", "pending"}
-		estado := rand.Intn(3)
-		sale.Status = opciones[estado]
-	} else {
-		sale.Status = "pending"
+	rule, err := checkPayment(sale)
+	if err != nil {
+		return err
+	}
+	if err := s.checkAccount(sale); err != nil {
+		return err
+	}
+
+	if len(sale.Items) > 0 {
+		if err := price(s.catalog, sale); err != nil {
+			return err
+		}
 	}
 
 	sale.ID = uuid.NewString()
+	if sale.Discount != nil {
+		if err := s.redeemCoupon(sale); err != nil {
+			return err
+		}
+	}
+
+	if s.taxes != nil {
+		if err := applyTaxes(s.taxes, sale); err != nil {
+			s.releaseCoupon(sale)
+			return err
+		}
+	}
+
 	now := time.Now()
+	if sale.Plan != nil {
+		if err := sale.Plan.schedule(sale.Amount, now); err != nil {
+			s.releaseCoupon(sale)
+			return err
+		}
+	}
+
+	if s.holdsStock(sale) {
+		if err := s.inventory.Reserve(sale.ID, sale.Items); err != nil {
+			s.releaseCoupon(sale)
+			return err
+		}
+	}
+
+	status := rule.status
+	if status == "" {
+		if status, err = s.authorizer.Authorize(sale); err != nil {
+			s.Logger.Error("failed to authorize payment", zap.Error(err))
+			s.releaseStock(sale)
+			s.releaseCoupon(sale)
+			return err
+		}
+	}
+	if !IsInitialStatus(status) {
+		s.releaseStock(sale)
+		s.releaseCoupon(sale)
+		return fmt.Errorf("%w: payment authorizer answered %q", ErrStatusNotFound, status)
+	}
+	sale.Status = status
+	if err := s.chargeAccount(sale); err != nil {
+		s.releaseStock(sale)
+		s.releaseCoupon(sale)
+		return err
+	}
+
 	sale.CreatedAt = now
 	sale.UpdatedAt = now
 	sale.Version = 1
 	if err := s.storage.SetSale(sale); err != nil {
 		s.Logger.Error("failed to set sale", zap.Error(err), zap.Any("sale", sale))
+		s.releaseStock(sale)
+		s.releaseCoupon(sale)
+		s.reverseAccount(sale)
 		return err
 	}
+	s.settleStock(sale)
+	s.settleCoupon(sale)
+
+	for _, o := range s.observers {
+		if err := o.SaleCreated(sale); err != nil {
+			s.Logger.Error("failed to notify sale creation", zap.Error(err), zap.String("sale_id", sale.ID))
+		}
+	}
 
 	return nil
 }
@@ -77,39 +210,94 @@ func (s *Service) Get(id string) (*Sale, error) {
 	return s.storage.ReadSale(id)
 }
 
-func (s *Service) GetUserSales(id string, status string) ([]*Sale, map[string]float32) {
-	if status == "" {
-		return s.storage.ReadSalesByUser(id)
+// GetUserSales returns every sale of a user, optionally filtered by status,
+// and their summary.
+func (s *Service) GetUserSales(id string, status string) ([]*Sale, *Metadata) {
+	criteria := Criteria{UserId: id}
+	if status != "" {
+		criteria.Statuses = []string{strings.ToLower(status)}
 	}
-	status = strings.ToLower(status)
-	return s.storage.ReadSalesByUserAndStatus(id, status)
+	return s.storage.ReadSales(criteria)
 }
 
+// ListSales returns one page of the sales matching the criteria. The
+// metadata of the page summarizes every matching sale.
+// Returns a *FilterError naming the offending parameter for invalid criteria
+// or pages.
+func (s *Service) ListSales(criteria Criteria, page Page) (*SalesPage, error) {
+	if err := criteria.Validate(); err != nil {
+		return nil, err
+	}
+
+	sales, meta := s.storage.ReadSales(criteria)
+
+	results, next, err := paginate(sales, page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SalesPage{
+		Results:    results,
+		NextCursor: next,
+		Metadata:   meta,
+	}, nil
+}
+
+// maxUpdateAttempts bounds how many times Update retries an update without
+// ExpectedVersion that lost a race against a concurrent one.
+const maxUpdateAttempts = 3
+
 // Update modifies an existing sale's data.
 // It updates Status, sets UpdatedAt to now and increments Version.
+// The change is applied with a compare-and-set on the version it read, so a
+// concurrent update is retried against the fresh sale instead of overwritten.
 // Returns ErrNotFound if the sale does not exist, or ErrEmptyID if sale.ID is empty.
-// Returns ErrNotValidOperation if the sale status is invalid for the operation.
+// Returns ErrStatusNotFound for unknown statuses, and a *TransitionError if the
+// status change is not allowed by the state machine.
+// Returns ErrVersionMismatch if sale.ExpectedVersion is set and the sale is
+// at another version.
 func (s *Service) Update(id string, sale *UpdateFields) (*Sale, error) {
-	existing, err := s.storage.ReadSale(id)
-	if err != nil {
-		return nil, err
+	for attempt := 1; ; attempt++ {
+		existing, err := s.storage.ReadSale(id)
+		if err != nil {
+			return nil, err
+		}
 
-	}
+		if sale.ExpectedVersion != nil && *sale.ExpectedVersion != existing.Version {
+			return nil, ErrVersionMismatch
+		}
+		version := existing.Version
 
-	if existing.Status != "pending" {
-		return nil, ErrInvalidStatus
-	}
+		from := existing.Status
+		if sale.Status != nil {
+			if err := transition(existing, strings.ToLower(*sale.Status)); err != nil {
+				return nil, err
+			}
+		}
 
-	if sale.Status != nil {
-		existing.Status = strings.ToLower(*sale.Status)
-	}
+		existing.UpdatedAt = time.Now()
+		existing.Version++
 
-	existing.UpdatedAt = time.Now()
-	existing.Version++
+		err = s.storage.CompareAndSetSale(existing, version)
+		if errors.Is(err, ErrVersionMismatch) && sale.ExpectedVersion == nil && attempt < maxUpdateAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
 
-	if err := s.storage.SetSale(existing); err != nil {
-		return nil, err
-	}
+		if existing.Status != from {
+			if from == StatusPending {
+				s.settleStock(existing)
+				s.settleCoupon(existing)
+			}
+			for _, o := range s.observers {
+				if err := o.SaleStatusChanged(existing, from); err != nil {
+					s.Logger.Error("failed to notify sale status change", zap.Error(err), zap.String("sale_id", existing.ID))
+				}
+			}
+		}
 
-	return existing, nil
+		return existing, nil
+	}
 }
@@ -1,8 +1,12 @@
 package sale
 
 import (
+	"API_VentasGO/internal/money"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -48,19 +52,19 @@ func TestService_Create(t *testing.T) {
 				storage: NewLocalStorage(),
 				userService: &mockUserService{
 					mockFindUser: func(id string) error {
-						return errors.New("user not found")
+						return ErrUserNotFound
 					},
 				},
 			},
 			args: args{
 				sale: &Sale{
 					UserId: "1000",
-					Amount: 1500,
+					Amount: money.New(150000, "ARS"),
 				},
 			},
 			wantErr: func(t *testing.T, err error) {
 				require.NotNil(t, err)
-				require.EqualError(t, err, "user not found")
+				require.ErrorIs(t, err, ErrUserNotFound)
 			},
 			wantSale: nil,
 		},
@@ -72,7 +76,7 @@ func TestService_Create(t *testing.T) {
 			args: args{
 				sale: &Sale{
 					UserId: "1",
-					Amount: 1500,
+					Amount: money.New(150000, "ARS"),
 				},
 			},
 			wantErr: func(t *testing.T, err error) {
@@ -90,7 +94,7 @@ func TestService_Create(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := NewService(tt.fields.storage, tt.fields.userService, nil)
+			s := NewService(tt.fields.storage, tt.fields.userService, nil, nil, nil, nil, nil, nil, nil)
 
 			err := s.Create(tt.args.sale)
 			if tt.wantErr != nil {
@@ -105,11 +109,11 @@ func TestService_Create(t *testing.T) {
 }
 
 type mockStorageSale struct {
-	mockSetSale                  func(sale *Sale) error
-	mockReadSale                 func(id string) (*Sale, error)
-	mockDeleteSale               func(id string) error
-	mockReadSalesByUser          func(id string) ([]*Sale, map[string]float32)
-	mockReadSalesByUserAndStatus func(id string, status string) ([]*Sale, map[string]float32)
+	mockSetSale           func(sale *Sale) error
+	mockReadSale          func(id string) (*Sale, error)
+	mockCompareAndSetSale func(sale *Sale, version int) error
+	mockDeleteSale        func(id string) error
+	mockReadSales         func(criteria Criteria) ([]*Sale, *Metadata)
 }
 
 func (m *mockStorageSale) SetSale(sale *Sale) error {
@@ -120,12 +124,12 @@ func (m *mockStorageSale) ReadSale(id string) (*Sale, error) {
 	return m.mockReadSale(id)
 }
 
-func (m *mockStorageSale) ReadSalesByUser(id string) ([]*Sale, map[string]float32) {
-	return m.mockReadSalesByUser(id)
+func (m *mockStorageSale) CompareAndSetSale(sale *Sale, version int) error {
+	return m.mockCompareAndSetSale(sale, version)
 }
 
-func (m *mockStorageSale) ReadSalesByUserAndStatus(id string, status string) ([]*Sale, map[string]float32) {
-	return m.mockReadSalesByUserAndStatus(id, status)
+func (m *mockStorageSale) ReadSales(criteria Criteria) ([]*Sale, *Metadata) {
+	return m.mockReadSales(criteria)
 }
 
 func (m *mockStorageSale) DeleteSale(id string) error {
@@ -142,3 +146,396 @@ func (m *mockUserService) FindUser(id string) error {
 	}
 	return nil
 }
+
+func TestService_Update(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		amount   int64
+		to       string
+		expected int
+		wantErr  func(t *testing.T, err error)
+	}{
+		{name: "pending to approved", from: StatusPending, amount: 10, to: "APPROVED"},
+		{name: "pending to rejected", from: StatusPending, amount: 10, to: StatusRejected},
+		{name: "pending to cancelled", from: StatusPending, amount: 10, to: StatusCancelled},
+		{
+			name: "refund without refunds", from: StatusApproved, amount: 10, to: StatusRefunded,
+			wantErr: func(t *testing.T, err error) {
+				require.ErrorIs(t, err, ErrInvalidStatus)
+				require.ErrorIs(t, err, ErrRefundRequired)
+			},
+		},
+		{
+			name: "partial refund without refunds", from: StatusApproved, amount: 10, to: StatusPartiallyRefunded,
+			wantErr: func(t *testing.T, err error) {
+				require.ErrorIs(t, err, ErrRefundRequired)
+			},
+		},
+		{
+			name: "unknown status", from: StatusPending, amount: 10, to: "banana",
+			wantErr: func(t *testing.T, err error) {
+				require.ErrorIs(t, err, ErrStatusNotFound)
+			},
+		},
+		{
+			name: "illegal move", from: StatusRejected, amount: 10, to: StatusApproved,
+			wantErr: func(t *testing.T, err error) {
+				var te *TransitionError
+				require.ErrorAs(t, err, &te)
+				require.Equal(t, StatusRejected, te.From)
+				require.Equal(t, StatusApproved, te.To)
+				require.ErrorIs(t, err, ErrInvalidStatus)
+			},
+		},
+		{
+			name: "guard vetoes", from: StatusPending, amount: 0, to: StatusApproved,
+			wantErr: func(t *testing.T, err error) {
+				require.ErrorIs(t, err, ErrInvalidStatus)
+				require.ErrorIs(t, err, ErrNonPositiveAmount)
+			},
+		},
+		{name: "matching version", from: StatusPending, amount: 10, to: StatusApproved, expected: 1},
+		{
+			name: "stale version", from: StatusPending, amount: 10, to: StatusApproved, expected: 2,
+			wantErr: func(t *testing.T, err error) {
+				require.ErrorIs(t, err, ErrVersionMismatch)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewLocalStorage()
+			require.NoError(t, storage.SetSale(&Sale{ID: "1", UserId: "1", Amount: money.New(tt.amount, "ARS"), Status: tt.from, Version: 1}))
+			s := NewService(storage, nil, nil, nil, nil, nil, nil, nil, nil)
+
+			fields := &UpdateFields{Status: &tt.to}
+			if tt.expected != 0 {
+				fields.ExpectedVersion = &tt.expected
+			}
+			got, err := s.Update("1", fields)
+			if tt.wantErr != nil {
+				tt.wantErr(t, err)
+				stored, _ := storage.ReadSale("1")
+				require.Equal(t, tt.from, stored.Status)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, strings.ToLower(tt.to), got.Status)
+			require.Equal(t, 2, got.Version)
+		})
+	}
+}
+
+func TestService_ListUserSales(t *testing.T) {
+	storage := NewLocalStorage()
+	s := NewService(storage, nil, nil, nil, nil, nil, nil, nil, nil)
+	base := time.Now()
+	for i := 0; i < 7; i++ {
+		require.NoError(t, storage.SetSale(&Sale{
+			ID:        fmt.Sprint(i),
+			UserId:    "u",
+			Amount:    money.New(int64(100*(i%3)+i), "ARS"),
+			Status:    StatusPending,
+			CreatedAt: base.Add(time.Duration(i) * time.Second),
+			UpdatedAt: base.Add(time.Duration(-i) * time.Second),
+		}))
+	}
+
+	walk := func(page Page) []string {
+		var ids []string
+		for {
+			got, err := s.ListSales(Criteria{UserId: "u"}, page)
+			require.NoError(t, err)
+			require.Equal(t, 7, got.Metadata.Quantity, "metadata covers the whole set")
+			for _, sale := range got.Results {
+				ids = append(ids, sale.ID)
+			}
+			if got.NextCursor == "" {
+				return ids
+			}
+			page.Cursor = got.NextCursor
+		}
+	}
+
+	t.Run("created_at asc", func(t *testing.T) {
+		require.Equal(t, []string{"0", "1", "2", "3", "4", "5", "6"}, walk(Page{Limit: 3}))
+	})
+	t.Run("updated_at asc", func(t *testing.T) {
+		require.Equal(t, []string{"6", "5", "4", "3", "2", "1", "0"}, walk(Page{Sort: SortUpdatedAt, Limit: 2}))
+	})
+	t.Run("amount desc", func(t *testing.T) {
+		// Amounts: 0:0 1:101 2:202 3:3 4:104 5:205 6:6
+		require.Equal(t, []string{"5", "2", "4", "1", "6", "3", "0"}, walk(Page{Sort: SortAmount, Desc: true, Limit: 4}))
+	})
+	t.Run("stable under inserts", func(t *testing.T) {
+		storage := NewLocalStorage()
+		s := NewService(storage, nil, nil, nil, nil, nil, nil, nil, nil)
+		for i := 0; i < 6; i++ {
+			require.NoError(t, storage.SetSale(&Sale{ID: fmt.Sprint(i), UserId: "u", Status: StatusPending, CreatedAt: base.Add(time.Duration(i) * time.Second)}))
+		}
+
+		page := Page{Limit: 2}
+		var ids []string
+		for n := 0; ; n++ {
+			got, err := s.ListSales(Criteria{UserId: "u"}, page)
+			require.NoError(t, err)
+			for _, sale := range got.Results {
+				ids = append(ids, sale.ID)
+			}
+			if got.NextCursor == "" {
+				break
+			}
+			page.Cursor = got.NextCursor
+			// An older sale inserted mid-walk must not shift the pages.
+			require.NoError(t, storage.SetSale(&Sale{ID: fmt.Sprintf("old-%d", n), UserId: "u", Status: StatusPending, CreatedAt: base.Add(-time.Hour)}))
+		}
+		require.Equal(t, []string{"0", "1", "2", "3", "4", "5"}, ids)
+	})
+	t.Run("invalid pages", func(t *testing.T) {
+		_, err := s.ListSales(Criteria{UserId: "u"}, Page{Sort: "banana"})
+		require.ErrorIs(t, err, ErrInvalidSort)
+		_, err = s.ListSales(Criteria{UserId: "u"}, Page{Limit: MaxLimit + 1})
+		require.ErrorIs(t, err, ErrInvalidLimit)
+		_, err = s.ListSales(Criteria{UserId: "u"}, Page{Cursor: "not a cursor"})
+		require.ErrorIs(t, err, ErrInvalidCursor)
+
+		got, err := s.ListSales(Criteria{UserId: "u"}, Page{Limit: 1})
+		require.NoError(t, err)
+		_, err = s.ListSales(Criteria{UserId: "u"}, Page{Sort: SortAmount, Cursor: got.NextCursor})
+		require.ErrorIs(t, err, ErrInvalidCursor)
+	})
+}
+
+type mockCatalog map[string]*Product
+
+func (m mockCatalog) FindProduct(id string) (*Product, error) {
+	p, ok := m[id]
+	if !ok {
+		return nil, ErrProductNotFound
+	}
+	return p, nil
+}
+
+func TestService_CreateWithItems(t *testing.T) {
+	catalog := mockCatalog{
+		"mate":     {ID: "mate", SKU: "MATE-01", Name: "Mate", Price: money.New(150000, "ARS"), Active: true},
+		"bombilla": {ID: "bombilla", SKU: "BOMB-01", Name: "Bombilla", Price: money.New(49999, "ARS"), Active: true},
+		"termo":    {ID: "termo", SKU: "TERM-01", Name: "Termo", Price: money.New(8000, "USD"), Active: true},
+		"yerba":    {ID: "yerba", SKU: "YERB-01", Name: "Yerba", Price: money.New(3000, "ARS")},
+	}
+
+	tests := []struct {
+		name       string
+		items      []Item
+		wantAmount money.Money
+		wantErr    error
+	}{
+		{
+			name:       "priced from catalog",
+			items:      []Item{{ProductID: "mate", Quantity: 1}, {ProductID: "bombilla", Quantity: 3}},
+			wantAmount: money.New(299997, "ARS"),
+		},
+		{
+			name:       "expected price matches",
+			items:      []Item{{ProductID: "mate", Quantity: 2, UnitPrice: money.New(150000, "ARS")}},
+			wantAmount: money.New(300000, "ARS"),
+		},
+		{name: "stale price", items: []Item{{ProductID: "mate", Quantity: 1, UnitPrice: money.New(100000, "ARS")}}, wantErr: ErrPriceChanged},
+		{name: "unknown product", items: []Item{{ProductID: "banana", Quantity: 1}}, wantErr: ErrProductNotFound},
+		{name: "inactive product", items: []Item{{ProductID: "yerba", Quantity: 1}}, wantErr: ErrProductInactive},
+		{name: "no quantity", items: []Item{{ProductID: "mate"}}, wantErr: ErrInvalidQuantity},
+		{name: "mixed currencies", items: []Item{{ProductID: "mate", Quantity: 1}, {ProductID: "termo", Quantity: 1}}, wantErr: money.ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewLocalStorage()
+			s := NewService(storage, nil, catalog, nil, nil, nil, nil, nil, nil)
+
+			input := &Sale{UserId: "1", Items: tt.items}
+			err := s.Create(input)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wantAmount, input.Amount)
+
+			got, err := storage.ReadSale(input.ID)
+			require.NoError(t, err)
+			require.Equal(t, input, got)
+			for i, item := range got.Items {
+				p := catalog[item.ProductID]
+				require.Equal(t, p.SKU, item.SKU)
+				require.Equal(t, p.Price, item.UnitPrice)
+				require.Equal(t, tt.items[i].Quantity, item.Quantity)
+			}
+
+			// Stored items do not share memory with the sale read back.
+			got.Items[0].Quantity = 100
+			again, err := storage.ReadSale(input.ID)
+			require.NoError(t, err)
+			require.Equal(t, tt.items[0].Quantity, again.Items[0].Quantity)
+		})
+	}
+
+	// Without a catalog only bare amounts are accepted.
+	err := NewService(NewLocalStorage(), nil, nil, nil, nil, nil, nil, nil, nil).Create(&Sale{UserId: "1", Items: []Item{{ProductID: "mate", Quantity: 1}}})
+	require.ErrorIs(t, err, ErrNotValidOperation)
+}
+
+// mockInventory records the stock calls it receives.
+type mockInventory struct {
+	calls      []string
+	outOfStock bool
+}
+
+func (m *mockInventory) Reserve(saleID string, items []Item) error {
+	if m.outOfStock {
+		return ErrOutOfStock
+	}
+	m.calls = append(m.calls, "reserve")
+	return nil
+}
+
+func (m *mockInventory) Commit(saleID string) error {
+	m.calls = append(m.calls, "commit")
+	return nil
+}
+
+func (m *mockInventory) Release(saleID string) error {
+	m.calls = append(m.calls, "release")
+	return nil
+}
+
+func TestService_StockFollowsStatus(t *testing.T) {
+	catalog := mockCatalog{"mate": {ID: "mate", Price: money.New(150000, "ARS"), Active: true}}
+
+	tests := []struct {
+		name      string
+		initial   string
+		to        string
+		wantCalls []string
+	}{
+		{name: "pending keeps the reservation", initial: StatusPending, wantCalls: []string{"reserve"}},
+		{name: "approved on creation commits", initial: StatusApproved, wantCalls: []string{"reserve", "commit"}},
+		{name: "rejected on creation releases", initial: StatusRejected, wantCalls: []string{"reserve", "release"}},
+		{name: "approval commits", initial: StatusPending, to: StatusApproved, wantCalls: []string{"reserve", "commit"}},
+		{name: "rejection releases", initial: StatusPending, to: StatusRejected, wantCalls: []string{"reserve", "release"}},
+		{name: "cancellation releases", initial: StatusPending, to: StatusCancelled, wantCalls: []string{"reserve", "release"}},
+		{name: "refund leaves stock alone", initial: StatusApproved, to: StatusRefunded, wantCalls: []string{"reserve", "commit"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inventory := &mockInventory{}
+			authorizer := &RuleAuthorizer{Default: tt.initial}
+			s := NewService(NewLocalStorage(), nil, catalog, inventory, nil, nil, nil, authorizer, nil)
+
+			input := &Sale{UserId: "1", Items: []Item{{ProductID: "mate", Quantity: 1}}}
+			require.NoError(t, s.Create(input))
+			switch tt.to {
+			case "":
+			case StatusRefunded:
+				_, _, err := s.Refund(input.ID, &RefundFields{})
+				require.NoError(t, err)
+			default:
+				_, err := s.Update(input.ID, &UpdateFields{Status: &tt.to})
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tt.wantCalls, inventory.calls)
+		})
+	}
+
+	t.Run("out of stock", func(t *testing.T) {
+		storage := NewLocalStorage()
+		s := NewService(storage, nil, catalog, &mockInventory{outOfStock: true}, nil, nil, nil, nil, nil)
+		err := s.Create(&Sale{UserId: "1", Items: []Item{{ProductID: "mate", Quantity: 1}}})
+		require.ErrorIs(t, err, ErrOutOfStock)
+		sales, _ := storage.ReadSales(Criteria{UserId: "1"})
+		require.Empty(t, sales)
+	})
+
+	t.Run("payment failure releases", func(t *testing.T) {
+		inventory := &mockInventory{}
+		s := NewService(NewLocalStorage(), nil, catalog, inventory, nil, nil, nil, NewSimulatedGateway(SimulatedGatewayConfig{FailureRate: 1}), nil)
+		err := s.Create(&Sale{UserId: "1", Items: []Item{{ProductID: "mate", Quantity: 1}}})
+		require.ErrorIs(t, err, ErrPaymentUnavailable)
+		require.Equal(t, []string{"reserve", "release"}, inventory.calls)
+	})
+}
+
+func TestService_Refund(t *testing.T) {
+	amount := func(cents int64) *money.Money {
+		m := money.New(cents, "ARS")
+		return &m
+	}
+	usd := money.New(100, "USD")
+
+	tests := []struct {
+		name       string
+		status     string
+		refunds    []*money.Money
+		wantStatus string
+		wantLeft   money.Money
+		wantErr    error
+	}{
+		{name: "partial", status: StatusApproved, refunds: []*money.Money{amount(400)}, wantStatus: StatusPartiallyRefunded, wantLeft: money.New(600, "ARS")},
+		{name: "full", status: StatusApproved, refunds: []*money.Money{nil}, wantStatus: StatusRefunded, wantLeft: money.New(0, "ARS")},
+		{name: "partial then rest", status: StatusApproved, refunds: []*money.Money{amount(400), amount(600)}, wantStatus: StatusRefunded, wantLeft: money.New(0, "ARS")},
+		{name: "partials then rest", status: StatusApproved, refunds: []*money.Money{amount(100), amount(200), nil}, wantStatus: StatusRefunded, wantLeft: money.New(0, "ARS")},
+		{name: "exceeding", status: StatusApproved, refunds: []*money.Money{amount(1001)}, wantErr: ErrRefundExceedsBalance},
+		{name: "exceeding what is left", status: StatusApproved, refunds: []*money.Money{amount(600), amount(401)}, wantErr: ErrRefundExceedsBalance},
+		{name: "zero", status: StatusApproved, refunds: []*money.Money{amount(0)}, wantErr: ErrInvalidRefund},
+		{name: "other currency", status: StatusApproved, refunds: []*money.Money{&usd}, wantErr: money.ErrCurrencyMismatch},
+		{name: "pending", status: StatusPending, refunds: []*money.Money{nil}, wantErr: ErrNotRefundable},
+		{name: "already refunded", status: StatusApproved, refunds: []*money.Money{nil, amount(1)}, wantErr: ErrNotRefundable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewLocalStorage()
+			require.NoError(t, storage.SetSale(&Sale{ID: "1", UserId: "1", Amount: money.New(1000, "ARS"), Status: tt.status, Version: 1}))
+			s := NewService(storage, nil, nil, nil, nil, nil, nil, nil, nil)
+
+			var err error
+			var got *Sale
+			for _, a := range tt.refunds {
+				var refund *Refund
+				refund, got, err = s.Refund("1", &RefundFields{Amount: a, Reason: "returned"})
+				if err != nil {
+					break
+				}
+				require.Equal(t, "1", refund.SaleID)
+				require.Equal(t, "returned", refund.Reason)
+			}
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, got.Status)
+			require.Equal(t, tt.wantLeft, got.Refundable())
+			require.Equal(t, 1+len(tt.refunds), got.Version)
+
+			refunds, err := s.Refunds("1")
+			require.NoError(t, err)
+			require.Len(t, refunds, len(tt.refunds))
+		})
+	}
+
+	t.Run("stale version", func(t *testing.T) {
+		storage := NewLocalStorage()
+		require.NoError(t, storage.SetSale(&Sale{ID: "1", UserId: "1", Amount: money.New(1000, "ARS"), Status: StatusApproved, Version: 2}))
+		s := NewService(storage, nil, nil, nil, nil, nil, nil, nil, nil)
+
+		stale := 1
+		_, _, err := s.Refund("1", &RefundFields{ExpectedVersion: &stale})
+		require.ErrorIs(t, err, ErrVersionMismatch)
+
+		_, _, err = s.Refund("missing", &RefundFields{})
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -0,0 +1,155 @@
+package sale
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Sale statuses.
+const (
+	StatusPending           = "pending"
+	StatusApproved          = "approved"
+	StatusRejected          = "rejected"
+	StatusCancelled         = "cancelled"
+	StatusRefunded          = "refunded"
+	StatusPartiallyRefunded = "partially_refunded"
+)
+
+// Statuses lists every status a sale can be in. Status filters, summary
+// counters and validation are all driven by this list.
+var Statuses = []string{
+	StatusPending,
+	StatusApproved,
+	StatusRejected,
+	StatusCancelled,
+	StatusRefunded,
+	StatusPartiallyRefunded,
+}
+
+// initialStatuses are the statuses a sale may be created with.
+var initialStatuses = []string{StatusPending, StatusApproved, StatusRejected}
+
+// ErrNonPositiveAmount is returned by guards when a sale has no amount to settle.
+var ErrNonPositiveAmount = errors.New("sale amount must be greater than 0")
+
+// ErrRefundRequired is returned by guards when a refund status does not match
+// the refunds of the sale. Those statuses are reached by refunding the sale.
+var ErrRefundRequired = errors.New("refund statuses follow the refunds of the sale")
+
+// Guard checks whether a sale may take a transition. A non-nil error vetoes it.
+type Guard func(sale *Sale) error
+
+// Transition is an allowed move between two statuses.
+type Transition struct {
+	From   string
+	To     string
+	Guards []Guard
+}
+
+// transitions is the sale status state machine.
+var transitions = []Transition{
+	{From: StatusPending, To: StatusApproved, Guards: []Guard{positiveAmount}},
+	{From: StatusPending, To: StatusRejected},
+	{From: StatusPending, To: StatusCancelled},
+	{From: StatusApproved, To: StatusRefunded, Guards: []Guard{positiveAmount, fullyRefunded}},
+	{From: StatusApproved, To: StatusPartiallyRefunded, Guards: []Guard{positiveAmount, partiallyRefunded}},
+	{From: StatusPartiallyRefunded, To: StatusPartiallyRefunded, Guards: []Guard{partiallyRefunded}},
+	{From: StatusPartiallyRefunded, To: StatusRefunded, Guards: []Guard{fullyRefunded}},
+}
+
+// positiveAmount only lets through sales with something to settle.
+func positiveAmount(sale *Sale) error {
+	if !sale.Amount.IsPositive() {
+		return ErrNonPositiveAmount
+	}
+	return nil
+}
+
+// fullyRefunded only lets through sales whose whole amount was refunded.
+func fullyRefunded(sale *Sale) error {
+	if sale.Refunded() != sale.Amount {
+		return ErrRefundRequired
+	}
+	return nil
+}
+
+// partiallyRefunded only lets through sales with part of their amount refunded.
+func partiallyRefunded(sale *Sale) error {
+	refunded := sale.Refunded()
+	if !refunded.IsPositive() || refunded.Minor >= sale.Amount.Minor {
+		return ErrRefundRequired
+	}
+	return nil
+}
+
+// TransitionError is returned when a sale cannot move between two statuses,
+// either because the move is not in the state machine or because a guard
+// vetoed it. It matches ErrInvalidStatus with errors.Is.
+type TransitionError struct {
+	From   string
+	To     string
+	Reason error
+}
+
+func (e *TransitionError) Error() string {
+	if e.Reason != nil {
+		return fmt.Sprintf("cannot change sale status from %s to %s: %v", e.From, e.To, e.Reason)
+	}
+	return fmt.Sprintf("cannot change sale status from %s to %s", e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() []error {
+	if e.Reason != nil {
+		return []error{ErrInvalidStatus, e.Reason}
+	}
+	return []error{ErrInvalidStatus}
+}
+
+// IsValidStatus reports whether status is a known sale status.
+func IsValidStatus(status string) bool {
+	return slices.Contains(Statuses, status)
+}
+
+// IsInitialStatus reports whether a sale may be created with status.
+func IsInitialStatus(status string) bool {
+	return slices.Contains(initialStatuses, status)
+}
+
+// CanTransition reports whether the state machine has a move from one status
+// to the other, without evaluating its guards.
+func CanTransition(from, to string) bool {
+	return findTransition(from, to) != nil
+}
+
+func findTransition(from, to string) *Transition {
+	for i := range transitions {
+		if transitions[i].From == from && transitions[i].To == to {
+			return &transitions[i]
+		}
+	}
+	return nil
+}
+
+// transition moves the sale to the given status.
+// Returns ErrStatusNotFound for unknown statuses and a *TransitionError for
+// illegal moves or vetoed guards.
+func transition(sale *Sale, to string) error {
+	if !IsValidStatus(to) {
+		return fmt.Errorf("%w: %q", ErrStatusNotFound, to)
+	}
+
+	t := findTransition(sale.Status, to)
+	if t == nil {
+		return &TransitionError{From: sale.Status, To: to}
+	}
+
+	for _, guard := range t.Guards {
+		if err := guard(sale); err != nil {
+			return &TransitionError{From: sale.Status, To: to, Reason: err}
+		}
+	}
+
+	sale.Status = to
+	return nil
+}
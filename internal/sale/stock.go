@@ -0,0 +1,56 @@
+package sale
+
+import (
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+// ErrOutOfStock is returned when a sale asks for more of a product than is available.
+var ErrOutOfStock = errors.New("out of stock")
+
+// Inventory is the view of the product stock that sale needs. Stock is
+// reserved under the ID of the sale that holds it.
+// Reserve returns an error wrapping ErrOutOfStock when a product falls short.
+type Inventory interface {
+	Reserve(saleID string, items []Item) error
+	Commit(saleID string) error
+	Release(saleID string) error
+}
+
+// holdsStock reports whether the sale has stock reserved through the inventory.
+func (s *Service) holdsStock(sale *Sale) bool {
+	return s.inventory != nil && len(sale.Items) > 0
+}
+
+// settleStock commits the stock held by a sale that left pending for
+// approved, and releases it if it was rejected or cancelled instead.
+// Failures are only logged, since the status change is already stored.
+func (s *Service) settleStock(sale *Sale) {
+	if !s.holdsStock(sale) {
+		return
+	}
+
+	var err error
+	switch sale.Status {
+	case StatusApproved:
+		err = s.inventory.Commit(sale.ID)
+	case StatusRejected, StatusCancelled:
+		err = s.inventory.Release(sale.ID)
+	default:
+		return
+	}
+	if err != nil {
+		s.Logger.Error("failed to settle sale stock", zap.Error(err), zap.String("sale_id", sale.ID), zap.String("status", sale.Status))
+	}
+}
+
+// releaseStock gives back the stock held by a sale that could not be created.
+func (s *Service) releaseStock(sale *Sale) {
+	if !s.holdsStock(sale) {
+		return
+	}
+	if err := s.inventory.Release(sale.ID); err != nil {
+		s.Logger.Error("failed to release sale stock", zap.Error(err), zap.String("sale_id", sale.ID))
+	}
+}
@@ -2,6 +2,9 @@ package sale
 
 import (
 	"errors"
+	"hash/fnv"
+	"slices"
+	"sync"
 )
 
 // ErrNotFound is returned when a sale with the given ID is not found.
@@ -13,33 +16,107 @@ var ErrStatusNotFound = errors.New("sale status not found")
 // ErrEmptyID is returned when trying to store a sale with an empty ID.
 var ErrEmptyID = errors.New("empty sale ID")
 
-// ErrInvalidAmoun is returned when trying to store a sale with an empty amount.
+// ErrInvalidAmoun is returned when trying to store a sale with an amount equal or lower than 0.
 var ErrInvalidAmoun = errors.New("amount equals or lower 0")
 
 // ErrInvalidStatus is returned when the user performs an invalid status.
 var ErrInvalidStatus = errors.New("invalid status")
 
+// ErrUserNotFound is returned when a sale references a user that does not exist.
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrVersionMismatch is returned when a sale changed since the version the
+// caller based its update on.
+var ErrVersionMismatch = errors.New("sale version mismatch")
+
 // ErrNo inValidOperation is returned when the user performs an invalid operation.
 var ErrNotValidOperation = errors.New("invalid operation")
 
 type Storage interface {
 	SetSale(sale *Sale) error
 	ReadSale(id string) (*Sale, error)
-	ReadSalesByUser(id string) ([]*Sale, map[string]float32)
-	ReadSalesByUserAndStatus(id string, status string) ([]*Sale, map[string]float32)
+	CompareAndSetSale(sale *Sale, version int) error
+	ReadSales(criteria Criteria) ([]*Sale, *Metadata)
 	DeleteSale(id string) error
 }
 
+// shardCount is the number of independent partitions of LocalStorage.
+const shardCount = 32
+
+// shard is a partition of LocalStorage guarded by its own lock.
+// All the sales of a given user live in the same shard, together with the
+// secondary indexes over them, so the indexes change atomically with the
+// sales they point to.
+type shard struct {
+	mu      sync.RWMutex
+	mapSale map[string]*Sale
+
+	// byUser lists the sales of each user ordered by CreatedAt.
+	byUser map[string]createdIndex
+
+	// byUserStatus lists the sales of each user and status ordered by CreatedAt.
+	byUserStatus map[string]map[string]createdIndex
+}
+
+func newShard() *shard {
+	return &shard{
+		mapSale:      make(map[string]*Sale),
+		byUser:       make(map[string]createdIndex),
+		byUserStatus: make(map[string]map[string]createdIndex),
+	}
+}
+
+// ownerShard is a partition of the sale ID to UserId lookup table.
+type ownerShard struct {
+	mu     sync.RWMutex
+	owners map[string]string
+}
+
 // LocalStorage provides an in-memory implementation for storing sales.
+// It is safe for concurrent use: sales are spread across shards by UserId so
+// traffic for different users does not contend on the same lock, and a
+// second set of shards keyed by sale ID records which user holds each sale.
+// Sales are copied on the way in and out, so callers never share memory with
+// the store.
 type LocalStorage struct {
-	mapSale map[string]*Sale
+	shards [shardCount]*shard
+	owners [shardCount]*ownerShard
 }
 
-// NewLocalStorage instantiates a new LocalStorage with an empty map.
+// NewLocalStorage instantiates a new LocalStorage with empty shards.
 func NewLocalStorage() *LocalStorage {
-	return &LocalStorage{
-		mapSale: make(map[string]*Sale),
+	l := &LocalStorage{}
+	for i := range l.shards {
+		l.shards[i] = newShard()
+		l.owners[i] = &ownerShard{owners: make(map[string]string)}
 	}
+	return l
+}
+
+// shardIndex hashes key into one of the shardCount partitions.
+func shardIndex(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32() % shardCount
+}
+
+// shardFor returns the shard that owns the sales of the given user.
+func (l *LocalStorage) shardFor(userId string) *shard {
+	return l.shards[shardIndex(userId)]
+}
+
+// ownersFor returns the owner shard that knows where the given sale lives.
+func (l *LocalStorage) ownersFor(id string) *ownerShard {
+	return l.owners[shardIndex(id)]
+}
+
+// owner returns the user that holds the sale with the given ID.
+func (l *LocalStorage) owner(id string) (string, bool) {
+	o := l.ownersFor(id)
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	userId, ok := o.owners[id]
+	return userId, ok
 }
 
 // Set stores or updates a sale in the local storage.
@@ -49,71 +126,120 @@ func (l *LocalStorage) SetSale(sale *Sale) error {
 		return ErrEmptyID
 	}
 
-	l.mapSale[sale.ID] = sale
+	s := sale.clone()
+	o := l.ownersFor(s.ID)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if prev, ok := o.owners[s.ID]; ok && prev != s.UserId {
+		sh := l.shardFor(prev)
+		sh.mu.Lock()
+		sh.remove(s.ID)
+		sh.mu.Unlock()
+	}
+	o.owners[s.ID] = s.UserId
+
+	sh := l.shardFor(s.UserId)
+	sh.mu.Lock()
+	sh.put(s)
+	sh.mu.Unlock()
+	return nil
+}
+
+// CompareAndSetSale atomically replaces a stored sale, but only if its stored
+// Version still equals version. The sale must keep its UserId.
+// Returns ErrNotFound if the sale does not exist, or ErrVersionMismatch if it
+// changed in the meantime.
+func (l *LocalStorage) CompareAndSetSale(sale *Sale, version int) error {
+	if sale.ID == "" {
+		return ErrEmptyID
+	}
+
+	userId, ok := l.owner(sale.ID)
+	if !ok || userId != sale.UserId {
+		return ErrNotFound
+	}
+
+	sh := l.shardFor(userId)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	current, ok := sh.mapSale[sale.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if current.Version != version {
+		return ErrVersionMismatch
+	}
+
+	sh.put(sale.clone())
 	return nil
 }
 
 // Read retrieves a sale from the local storage by ID.
 // Returns ErrNotFound if the sale is not found.
 func (l *LocalStorage) ReadSale(id string) (*Sale, error) {
-	s, ok := l.mapSale[id]
+	userId, ok := l.owner(id)
 	if !ok {
 		return nil, ErrNotFound
 	}
 
-	return s, nil
+	sh := l.shardFor(userId)
+	sh.mu.RLock()
+	s, ok := sh.mapSale[id]
+	sh.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return s.clone(), nil
 }
 
-func (l *LocalStorage) ReadSalesByUser(id string) ([]*Sale, map[string]float32) {
-	meta := map[string]float32{
-		"quantity":     0,
-		"approved":     0,
-		"pending":      0,
-		"rejected":     0,
-		"total_amount": 0,
+// ReadSales returns the sales matching the criteria, oldest first, and their
+// summary. It only visits the sales of the user in the requested statuses and
+// creation range, through the byUser and byUserStatus indexes.
+func (l *LocalStorage) ReadSales(criteria Criteria) ([]*Sale, *Metadata) {
+	sh := l.shardFor(criteria.UserId)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	var indexes []createdIndex
+	if len(criteria.Statuses) == 0 {
+		indexes = append(indexes, sh.byUser[criteria.UserId])
+	} else {
+		statuses := slices.Clone(criteria.Statuses)
+		slices.Sort(statuses)
+		for _, status := range slices.Compact(statuses) {
+			indexes = append(indexes, sh.byUserStatus[criteria.UserId][status])
+		}
 	}
-	var sales []*Sale
-	for _, sale := range l.mapSale {
-		if sale.UserId == id {
-			sales = append(sales, sale)
-			meta["quantity"]++
-			meta["total_amount"] += sale.Amount
-			switch sale.Status {
-			case "approved":
-				meta["approved"]++
-			case "rejected":
-				meta["rejected"]++
-			case "pending":
-				meta["pending"]++
+
+	var keys createdIndex
+	for _, idx := range indexes {
+		for _, k := range idx.between(criteria.CreatedFrom, criteria.CreatedTo) {
+			if criteria.matches(sh.mapSale[k.ID]) {
+				keys = append(keys, k)
 			}
 		}
 	}
-	return sales, meta
+	if len(indexes) > 1 {
+		slices.SortFunc(keys, compareKeys)
+	}
+
+	return sh.collect(keys)
 }
 
-func (l *LocalStorage) ReadSalesByUserAndStatus(id string, status string) ([]*Sale, map[string]float32) {
-	meta := map[string]float32{
-		"quantity":     0,
-		"approved":     0,
-		"pending":      0,
-		"rejected":     0,
-		"total_amount": 0,
-	}
+// collect returns copies of the sales listed in idx together with the
+// counters that summarize them. The caller must hold sh.mu.
+func (sh *shard) collect(idx createdIndex) ([]*Sale, *Metadata) {
+	meta := newMetadata()
 	var sales []*Sale
-	for _, sale := range l.mapSale {
-		if sale.UserId == id && sale.Status == status {
-			sales = append(sales, sale)
-			meta["quantity"]++
-			meta["total_amount"] += sale.Amount
-			switch sale.Status {
-			case "approved":
-				meta["approved"]++
-			case "rejected":
-				meta["rejected"]++
-			case "pending":
-				meta["pending"]++
-			}
-		}
+	if len(idx) > 0 {
+		sales = make([]*Sale, 0, len(idx))
+	}
+	for _, k := range idx {
+		c := sh.mapSale[k.ID].clone()
+		sales = append(sales, c)
+		meta.add(c)
 	}
 	return sales, meta
 }
@@ -121,11 +247,31 @@ func (l *LocalStorage) ReadSalesByUserAndStatus(id string, status string) ([]*Sa
 // Delete removes a sale from the local storage by ID.
 // Returns ErrNotFound if the sale does not exist.
 func (l *LocalStorage) DeleteSale(id string) error {
-	_, err := l.ReadSale(id)
-	if err != nil {
-		return err
+	o := l.ownersFor(id)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	userId, ok := o.owners[id]
+	if !ok {
+		return ErrNotFound
 	}
+	delete(o.owners, id)
 
-	delete(l.mapSale, id)
+	sh := l.shardFor(userId)
+	sh.mu.Lock()
+	sh.remove(id)
+	sh.mu.Unlock()
 	return nil
 }
+
+// all returns a copy of every stored sale.
+func (l *LocalStorage) all() []*Sale {
+	var sales []*Sale
+	for _, sh := range l.shards {
+		sh.mu.RLock()
+		for _, s := range sh.mapSale {
+			sales = append(sales, s.clone())
+		}
+		sh.mu.RUnlock()
+	}
+	return sales
+}
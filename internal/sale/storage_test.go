@@ -0,0 +1,208 @@
+package sale
+
+import (
+	"API_VentasGO/internal/money"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStorage_IndexesFollowChanges(t *testing.T) {
+	l := NewLocalStorage()
+	base := time.Now()
+	for i := 3; i >= 1; i-- {
+		require.NoError(t, l.SetSale(&Sale{
+			ID:        fmt.Sprint(i),
+			UserId:    "u",
+			Amount:    money.New(100, "ARS"),
+			Status:    StatusPending,
+			CreatedAt: base.Add(time.Duration(i) * time.Second),
+		}))
+	}
+	require.NoError(t, l.SetSale(&Sale{ID: "other", UserId: "v", Status: StatusPending, CreatedAt: base}))
+
+	ids := func(sales []*Sale) []string {
+		var out []string
+		for _, s := range sales {
+			out = append(out, s.ID)
+		}
+		return out
+	}
+
+	sales, meta := l.ReadSales(Criteria{UserId: "u"})
+	require.Equal(t, []string{"1", "2", "3"}, ids(sales))
+	require.Equal(t, 3, meta.Counts[StatusPending])
+
+	// A status change moves the sale between status indexes.
+	moved, err := l.ReadSale("2")
+	require.NoError(t, err)
+	moved.Status = StatusApproved
+	require.NoError(t, l.SetSale(moved))
+
+	sales, _ = l.ReadSales(Criteria{UserId: "u", Statuses: []string{StatusPending}})
+	require.Equal(t, []string{"1", "3"}, ids(sales))
+	sales, meta = l.ReadSales(Criteria{UserId: "u", Statuses: []string{StatusApproved}})
+	require.Equal(t, []string{"2"}, ids(sales))
+	require.Equal(t, money.New(100, "ARS"), meta.Total_amount.Get("ARS"))
+
+	require.NoError(t, l.DeleteSale("1"))
+	sales, _ = l.ReadSales(Criteria{UserId: "u"})
+	require.Equal(t, []string{"2", "3"}, ids(sales))
+	sales, _ = l.ReadSales(Criteria{UserId: "u", Statuses: []string{StatusPending}})
+	require.Equal(t, []string{"3"}, ids(sales))
+
+	// Moving a sale to another user moves it between shards and indexes.
+	moved.UserId = "v"
+	require.NoError(t, l.SetSale(moved))
+	sales, _ = l.ReadSales(Criteria{UserId: "u"})
+	require.Equal(t, []string{"3"}, ids(sales))
+	sales, _ = l.ReadSales(Criteria{UserId: "v"})
+	require.Equal(t, []string{"other", "2"}, ids(sales))
+	sales, _ = l.ReadSales(Criteria{UserId: "u", Statuses: []string{StatusApproved}})
+	require.Empty(t, sales)
+}
+
+const (
+	benchSales = 1_000_000
+	benchUsers = 10_000
+)
+
+var (
+	benchOnce    sync.Once
+	benchStorage *LocalStorage
+)
+
+// benchmarkStorage builds, once per run, a storage holding benchSales sales
+// spread evenly across benchUsers users.
+func benchmarkStorage() *LocalStorage {
+	benchOnce.Do(func() {
+		benchStorage = NewLocalStorage()
+		base := time.Now()
+		for i := 0; i < benchSales; i++ {
+			benchStorage.SetSale(&Sale{
+				ID:        fmt.Sprintf("sale-%d", i),
+				UserId:    fmt.Sprintf("user-%d", i%benchUsers),
+				Amount:    money.New(int64(i%10000+1), "ARS"),
+				Status:    Statuses[i%3],
+				CreatedAt: base.Add(time.Duration(i) * time.Millisecond),
+				Version:   1,
+			})
+		}
+	})
+	return benchStorage
+}
+
+func BenchmarkLocalStorage_ReadSales(b *testing.B) {
+	l := benchmarkStorage()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sales, _ := l.ReadSales(Criteria{UserId: fmt.Sprintf("user-%d", i%benchUsers)})
+		if len(sales) != benchSales/benchUsers {
+			b.Fatalf("got %d sales", len(sales))
+		}
+	}
+}
+
+func BenchmarkLocalStorage_ReadSales_Status(b *testing.B) {
+	l := benchmarkStorage()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sales, _ := l.ReadSales(Criteria{UserId: fmt.Sprintf("user-%d", i%benchUsers), Statuses: []string{StatusApproved}})
+		if len(sales) == 0 {
+			b.Fatal("got no sales")
+		}
+	}
+}
+
+func BenchmarkLocalStorage_SetSale(b *testing.B) {
+	l := benchmarkStorage()
+	s, err := l.ReadSale("sale-0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Status = Statuses[i%3]
+		if err := l.SetSale(s); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestLocalStorage_ReadSales_Criteria(t *testing.T) {
+	l := NewLocalStorage()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	statuses := []string{StatusPending, StatusApproved, StatusRejected, StatusCancelled}
+	for i := 0; i < 8; i++ {
+		require.NoError(t, l.SetSale(&Sale{
+			ID:        fmt.Sprint(i),
+			UserId:    "u",
+			Amount:    money.New(int64(i*100), "ARS"),
+			Status:    statuses[i%4],
+			CreatedAt: base.Add(time.Duration(i) * time.Hour),
+			UpdatedAt: base.Add(time.Duration(8-i) * time.Hour),
+		}))
+	}
+	require.NoError(t, l.SetSale(&Sale{ID: "usd", UserId: "u", Amount: money.New(300, "USD"), Status: StatusPending, CreatedAt: base}))
+
+	lo, hi := money.New(200, "ARS"), money.New(600, "ARS")
+	tests := []struct {
+		name     string
+		criteria Criteria
+		want     []string
+	}{
+		{name: "all", criteria: Criteria{}, want: []string{"0", "usd", "1", "2", "3", "4", "5", "6", "7"}},
+		{name: "statuses", criteria: Criteria{Statuses: []string{StatusApproved, StatusCancelled, StatusApproved}}, want: []string{"1", "3", "5", "7"}},
+		{name: "created range", criteria: Criteria{CreatedFrom: base.Add(2 * time.Hour), CreatedTo: base.Add(5 * time.Hour)}, want: []string{"2", "3", "4"}},
+		{name: "updated range", criteria: Criteria{UpdatedFrom: base.Add(6 * time.Hour)}, want: []string{"0", "1", "2"}},
+		{name: "amount range skips other currencies", criteria: Criteria{MinAmount: &lo, MaxAmount: &hi}, want: []string{"2", "3", "4", "5", "6"}},
+		{
+			name:     "combined",
+			criteria: Criteria{Statuses: []string{StatusPending, StatusRejected}, CreatedFrom: base.Add(time.Hour), MinAmount: &lo},
+			want:     []string{"2", "4", "6"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.criteria.UserId = "u"
+			require.NoError(t, tt.criteria.Validate())
+
+			sales, meta := l.ReadSales(tt.criteria)
+			var ids []string
+			for _, s := range sales {
+				ids = append(ids, s.ID)
+			}
+			require.Equal(t, tt.want, ids)
+			require.Equal(t, len(tt.want), meta.Quantity)
+		})
+	}
+}
+
+func TestCriteria_Validate(t *testing.T) {
+	now := time.Now()
+	ars, usd := money.New(100, "ARS"), money.New(100, "USD")
+	low := money.New(1, "ARS")
+
+	tests := []struct {
+		name      string
+		criteria  Criteria
+		wantParam string
+	}{
+		{name: "unknown status", criteria: Criteria{Statuses: []string{"banana"}}, wantParam: "status"},
+		{name: "created range", criteria: Criteria{CreatedFrom: now, CreatedTo: now}, wantParam: "created_from"},
+		{name: "updated range", criteria: Criteria{UpdatedFrom: now.Add(time.Hour), UpdatedTo: now}, wantParam: "updated_from"},
+		{name: "amount range", criteria: Criteria{MinAmount: &ars, MaxAmount: &low}, wantParam: "min_amount"},
+		{name: "amount currencies", criteria: Criteria{MinAmount: &ars, MaxAmount: &usd}, wantParam: "max_amount"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fe *FilterError
+			require.ErrorAs(t, tt.criteria.Validate(), &fe)
+			require.Equal(t, tt.wantParam, fe.Param)
+			require.Contains(t, fe.Error(), tt.wantParam)
+		})
+	}
+}
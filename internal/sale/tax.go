@@ -0,0 +1,69 @@
+package sale
+
+import (
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/tax"
+	"fmt"
+)
+
+// TaxEngine computes the taxes of a sale from its net lines and its buyer.
+type TaxEngine interface {
+	Compute(userID string, lines []tax.Line) ([]tax.TaxLine, error)
+}
+
+// Taxes breaks the amount of a sale down. Net is what the sale was priced
+// at, Tax the sum of its tax lines and Gross, which is also the Amount of the
+// sale, their sum.
+type Taxes struct {
+	Net   money.Money   `json:"net"`
+	Tax   money.Money   `json:"tax"`
+	Gross money.Money   `json:"gross"`
+	Lines []tax.TaxLine `json:"lines"`
+}
+
+// NetAndTax returns the net amount of the sale and the tax charged on it.
+// Sales without taxes are all net.
+func (s *Sale) NetAndTax() (net, tax money.Money) {
+	if s.Taxes == nil {
+		return s.Amount, money.Money{Currency: s.Amount.Currency}
+	}
+	return s.Taxes.Net, s.Taxes.Tax
+}
+
+// applyTaxes computes the taxes of a new sale, whose Amount is net, and
+// raises the Amount to the gross. Each item is taxed by its category, net of
+// its share of the discount, and a sale without items as a single
+// uncategorized line.
+func applyTaxes(engine TaxEngine, sale *Sale) error {
+	var lines []tax.Line
+	for _, item := range sale.Items {
+		lines = append(lines, tax.Line{Category: item.Category, Amount: item.Subtotal})
+	}
+	if len(lines) == 0 {
+		lines = []tax.Line{{Amount: sale.Amount}}
+	} else if sale.Discount != nil {
+		if err := discountLines(lines, sale.Discount.Amount); err != nil {
+			return err
+		}
+	}
+
+	taxLines, err := engine.Compute(sale.UserId, lines)
+	if err != nil {
+		return fmt.Errorf("computing taxes: %w", err)
+	}
+
+	total := money.Money{Currency: sale.Amount.Currency}
+	for _, line := range taxLines {
+		if total, err = total.Add(line.Amount); err != nil {
+			return err
+		}
+	}
+	gross, err := sale.Amount.Add(total)
+	if err != nil {
+		return err
+	}
+
+	sale.Taxes = &Taxes{Net: sale.Amount, Tax: total, Gross: gross, Lines: taxLines}
+	sale.Amount = gross
+	return nil
+}
@@ -0,0 +1,91 @@
+package sale
+
+import (
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/tax"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// mockTaxes computes taxes with rules for buyers of the given jurisdictions.
+type mockTaxes struct {
+	rules         *tax.Rules
+	jurisdictions map[string]string
+}
+
+func (m mockTaxes) Compute(userID string, lines []tax.Line) ([]tax.TaxLine, error) {
+	return m.rules.Compute(lines, m.jurisdictions[userID])
+}
+
+func TestService_CreateWithTaxes(t *testing.T) {
+	rules := &tax.Rules{
+		IVA:         tax.IVA{Default: "21", Categories: map[string]string{"food": "10.5", "books": tax.Exempt}},
+		Perceptions: []tax.Perception{{Name: "IIBB CABA", Jurisdiction: "CABA", Rate: "3"}},
+	}
+	require.NoError(t, rules.Validate())
+	taxes := mockTaxes{rules: rules, jurisdictions: map[string]string{"porteño": "CABA"}}
+	catalog := mockCatalog{
+		"yerba": {ID: "yerba", Category: "food", Price: money.New(200000, "ARS"), Active: true},
+		"libro": {ID: "libro", Category: "books", Price: money.New(1000000, "ARS"), Active: true},
+		"mate":  {ID: "mate", Price: money.New(150000, "ARS"), Active: true},
+	}
+
+	tests := []struct {
+		name      string
+		sale      *Sale
+		wantNet   int64
+		wantTax   int64
+		wantLines int
+	}{
+		{name: "amount", sale: &Sale{UserId: "1", Amount: money.New(10000, "ARS")}, wantNet: 10000, wantTax: 2100, wantLines: 1},
+		{name: "reduced rate", sale: &Sale{UserId: "1", Items: []Item{{ProductID: "yerba", Quantity: 2}}}, wantNet: 400000, wantTax: 42000, wantLines: 1},
+		{name: "exempt", sale: &Sale{UserId: "1", Items: []Item{{ProductID: "libro", Quantity: 1}}}, wantNet: 1000000, wantTax: 0, wantLines: 1},
+		{
+			name:    "mixed categories with perception",
+			sale:    &Sale{UserId: "porteño", Items: []Item{{ProductID: "yerba", Quantity: 1}, {ProductID: "libro", Quantity: 1}, {ProductID: "mate", Quantity: 1}}},
+			wantNet: 1350000, wantTax: 21000 + 0 + 31500 + 40500, wantLines: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(NewLocalStorage(), nil, catalog, nil, taxes, nil, nil, nil, nil)
+			require.NoError(t, s.Create(tt.sale))
+
+			got := tt.sale.Taxes
+			require.NotNil(t, got)
+			require.Equal(t, money.New(tt.wantNet, "ARS"), got.Net)
+			require.Equal(t, money.New(tt.wantTax, "ARS"), got.Tax)
+			require.Equal(t, money.New(tt.wantNet+tt.wantTax, "ARS"), got.Gross)
+			require.Equal(t, got.Gross, tt.sale.Amount)
+			require.Len(t, got.Lines, tt.wantLines)
+
+			stored, err := s.Get(tt.sale.ID)
+			require.NoError(t, err)
+			require.Equal(t, got, stored.Taxes)
+
+			_, meta := s.GetUserSales(tt.sale.UserId, "")
+			require.Equal(t, money.New(tt.wantNet, "ARS"), meta.Net_amount.Get("ARS"))
+			require.Equal(t, money.New(tt.wantTax, "ARS"), meta.Tax_amount.Get("ARS"))
+			require.Equal(t, money.New(tt.wantNet+tt.wantTax, "ARS"), meta.Gross_amount.Get("ARS"))
+		})
+	}
+
+	t.Run("installments over the gross amount", func(t *testing.T) {
+		s := NewService(NewLocalStorage(), nil, nil, nil, taxes, nil, nil, nil, nil)
+		input := &Sale{UserId: "1", Amount: money.New(10000, "ARS"), Plan: &InstallmentPlan{Count: 2}}
+		require.NoError(t, s.Create(input))
+		require.Equal(t, money.New(12100, "ARS"), input.Plan.Total)
+	})
+
+	t.Run("untaxed", func(t *testing.T) {
+		s := NewService(NewLocalStorage(), nil, nil, nil, nil, nil, nil, nil, nil)
+		input := &Sale{UserId: "1", Amount: money.New(10000, "ARS")}
+		require.NoError(t, s.Create(input))
+		require.Nil(t, input.Taxes)
+
+		net, tax := input.NetAndTax()
+		require.Equal(t, input.Amount, net)
+		require.True(t, tax.IsZero())
+	})
+}
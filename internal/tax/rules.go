@@ -0,0 +1,249 @@
+package tax
+
+import (
+	"API_VentasGO/internal/money"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// Tax line kinds.
+const (
+	// KindIVA is the value added tax of a product category.
+	KindIVA = "iva"
+
+	// KindPerception is a perception collected on behalf of the
+	// jurisdiction of the buyer.
+	KindPerception = "perception"
+)
+
+// Exempt is the IVA rate of categories that do not pay IVA.
+const Exempt = "exempt"
+
+// ErrInvalidRules is returned when a rules table has a rate that is not a
+// percentage between 0 and 100 with up to two decimals.
+var ErrInvalidRules = errors.New("invalid tax rules")
+
+// Rules is the tax rules table.
+//
+// Its JSON form is
+//
+//	{
+//	  "iva": {"default": "21", "categories": {"food": "10.5", "books": "exempt"}},
+//	  "perceptions": [{"name": "IIBB CABA", "jurisdiction": "CABA", "rate": "3"}]
+//	}
+//
+// Rates are percentages. Items of a category missing from the table pay the
+// default IVA rate, and every perception of the jurisdiction of the buyer is
+// charged on the net amount of the sale.
+type Rules struct {
+	IVA         IVA          `json:"iva"`
+	Perceptions []Perception `json:"perceptions"`
+}
+
+// IVA holds the IVA rate of each product category.
+type IVA struct {
+	Default    string            `json:"default"`
+	Categories map[string]string `json:"categories"`
+}
+
+// Perception is a perception charged to buyers of a jurisdiction.
+type Perception struct {
+	Name         string `json:"name"`
+	Jurisdiction string `json:"jurisdiction"`
+	Rate         string `json:"rate"`
+}
+
+// Line is a net amount of a product category to be taxed.
+type Line struct {
+	Category string
+	Amount   money.Money
+}
+
+// TaxLine is a tax computed on a sale. IVA lines carry the Category they
+// tax and perception lines the Jurisdiction they are collected for. Rate is
+// the percentage applied to Base, or Exempt.
+type TaxLine struct {
+	Kind         string      `json:"kind"`
+	Name         string      `json:"name"`
+	Category     string      `json:"category,omitempty"`
+	Jurisdiction string      `json:"jurisdiction,omitempty"`
+	Rate         string      `json:"rate"`
+	Base         money.Money `json:"base"`
+	Amount       money.Money `json:"amount"`
+}
+
+// Load reads a rules table from a JSON file.
+// Returns ErrInvalidRules if a rate of the table is not valid.
+func Load(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r Rules
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRules, err)
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Validate checks every rate of the table and normalizes categories and
+// jurisdictions to lower and upper case.
+// Returns ErrInvalidRules for rates that are not valid, and for categories
+// that are the same once normalized.
+func (r *Rules) Validate() error {
+	if r.IVA.Default == "" {
+		r.IVA.Default = "0"
+	}
+	if _, err := parseRate(r.IVA.Default, true); err != nil {
+		return fmt.Errorf("default IVA: %w", err)
+	}
+
+	categories := make(map[string]string, len(r.IVA.Categories))
+	for category, rate := range r.IVA.Categories {
+		if _, err := parseRate(rate, true); err != nil {
+			return fmt.Errorf("IVA of %s: %w", category, err)
+		}
+		key := normalizeCategory(category)
+		if _, ok := categories[key]; ok {
+			return fmt.Errorf("%w: more than one IVA rate for category %q", ErrInvalidRules, key)
+		}
+		categories[key] = rate
+	}
+	r.IVA.Categories = categories
+
+	for i := range r.Perceptions {
+		p := &r.Perceptions[i]
+		p.Jurisdiction = normalizeJurisdiction(p.Jurisdiction)
+		if p.Jurisdiction == "" {
+			return fmt.Errorf("%w: perception %q has no jurisdiction", ErrInvalidRules, p.Name)
+		}
+		if _, err := parseRate(p.Rate, false); err != nil {
+			return fmt.Errorf("perception %q: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// Compute returns the tax lines of a sale with the given net lines to a
+// buyer of jurisdiction: one IVA line per category, in order of appearance,
+// followed by the perceptions of the jurisdiction.
+// Returns money errors when the lines do not add up in a single currency.
+func (r *Rules) Compute(lines []Line, jurisdiction string) ([]TaxLine, error) {
+	var taxes []TaxLine
+	var net money.Money
+	for i, line := range lines {
+		category := normalizeCategory(line.Category)
+
+		var err error
+		if i == 0 {
+			net = line.Amount
+		} else if net, err = net.Add(line.Amount); err != nil {
+			return nil, err
+		}
+
+		found := false
+		for j := range taxes {
+			if taxes[j].Category == category {
+				if taxes[j].Base, err = taxes[j].Base.Add(line.Amount); err != nil {
+					return nil, err
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			taxes = append(taxes, TaxLine{Kind: KindIVA, Name: "IVA", Category: category, Rate: r.ivaRate(category), Base: line.Amount})
+		}
+	}
+
+	for i := range taxes {
+		amount, err := apply(taxes[i].Base, taxes[i].Rate)
+		if err != nil {
+			return nil, err
+		}
+		taxes[i].Amount = amount
+	}
+
+	if len(lines) == 0 {
+		return taxes, nil
+	}
+	jurisdiction = normalizeJurisdiction(jurisdiction)
+	for _, p := range r.Perceptions {
+		if jurisdiction == "" || p.Jurisdiction != jurisdiction {
+			continue
+		}
+		amount, err := apply(net, p.Rate)
+		if err != nil {
+			return nil, err
+		}
+		taxes = append(taxes, TaxLine{Kind: KindPerception, Name: p.Name, Jurisdiction: p.Jurisdiction, Rate: normalizeRate(p.Rate), Base: net, Amount: amount})
+	}
+	return taxes, nil
+}
+
+// ivaRate returns the IVA rate of a category.
+func (r *Rules) ivaRate(category string) string {
+	rate, ok := r.IVA.Categories[category]
+	if !ok {
+		rate = r.IVA.Default
+	}
+	return normalizeRate(rate)
+}
+
+// apply returns rate percent of base. Exempt rates are zero.
+func apply(base money.Money, rate string) (money.Money, error) {
+	if rate == Exempt {
+		return money.Money{Currency: base.Currency}, nil
+	}
+	pct, err := parseRate(rate, false)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return base.Percent(pct)
+}
+
+// parseRate reads a percentage between 0 and 100 with up to two decimals, or
+// Exempt when exempt is allowed, which is returned as zero.
+func parseRate(rate string, exempt bool) (*big.Rat, error) {
+	rate = strings.ToLower(strings.TrimSpace(rate))
+	if exempt && rate == Exempt {
+		return new(big.Rat), nil
+	}
+
+	pct, ok := new(big.Rat).SetString(rate)
+	if !ok || pct.Sign() < 0 || pct.Cmp(big.NewRat(100, 1)) > 0 {
+		return nil, fmt.Errorf("%w: rate %q must be a percentage between 0 and 100", ErrInvalidRules, rate)
+	}
+	if !new(big.Rat).Mul(pct, big.NewRat(100, 1)).IsInt() {
+		return nil, fmt.Errorf("%w: rate %q has more than two decimals", ErrInvalidRules, rate)
+	}
+	return pct, nil
+}
+
+// normalizeRate renders a valid rate with two decimals, or as Exempt.
+func normalizeRate(rate string) string {
+	if strings.ToLower(strings.TrimSpace(rate)) == Exempt {
+		return Exempt
+	}
+	pct, err := parseRate(rate, false)
+	if err != nil {
+		return rate
+	}
+	return pct.FloatString(2)
+}
+
+func normalizeCategory(category string) string {
+	return strings.ToLower(strings.TrimSpace(category))
+}
+
+func normalizeJurisdiction(jurisdiction string) string {
+	return strings.ToUpper(strings.TrimSpace(jurisdiction))
+}
@@ -0,0 +1,122 @@
+package tax
+
+import (
+	"API_VentasGO/internal/money"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testRules(t *testing.T) *Rules {
+	r := &Rules{
+		IVA: IVA{Default: "21", Categories: map[string]string{"Food": "10.5", "books": "exempt"}},
+		Perceptions: []Perception{
+			{Name: "IIBB CABA", Jurisdiction: "caba", Rate: "3"},
+			{Name: "IIBB BA", Jurisdiction: "BA", Rate: "2.5"},
+		},
+	}
+	require.NoError(t, r.Validate())
+	return r
+}
+
+func TestRules_Compute(t *testing.T) {
+	ars := func(minor int64) money.Money { return money.New(minor, "ARS") }
+
+	tests := []struct {
+		name         string
+		lines        []Line
+		jurisdiction string
+		want         []TaxLine
+		wantErr      error
+	}{
+		{
+			name:  "default rate",
+			lines: []Line{{Amount: ars(10000)}},
+			want:  []TaxLine{{Kind: KindIVA, Name: "IVA", Rate: "21.00", Base: ars(10000), Amount: ars(2100)}},
+		},
+		{
+			name:  "categories are grouped",
+			lines: []Line{{Category: "food", Amount: ars(1000)}, {Category: "tools", Amount: ars(2000)}, {Category: " FOOD ", Amount: ars(999)}},
+			want: []TaxLine{
+				{Kind: KindIVA, Name: "IVA", Category: "food", Rate: "10.50", Base: ars(1999), Amount: ars(210)},
+				{Kind: KindIVA, Name: "IVA", Category: "tools", Rate: "21.00", Base: ars(2000), Amount: ars(420)},
+			},
+		},
+		{
+			name:  "exempt",
+			lines: []Line{{Category: "books", Amount: ars(5000)}},
+			want:  []TaxLine{{Kind: KindIVA, Name: "IVA", Category: "books", Rate: Exempt, Base: ars(5000), Amount: ars(0)}},
+		},
+		{
+			name:         "perception of the buyer",
+			lines:        []Line{{Category: "books", Amount: ars(5000)}, {Amount: ars(5000)}},
+			jurisdiction: "CABA",
+			want: []TaxLine{
+				{Kind: KindIVA, Name: "IVA", Category: "books", Rate: Exempt, Base: ars(5000), Amount: ars(0)},
+				{Kind: KindIVA, Name: "IVA", Rate: "21.00", Base: ars(5000), Amount: ars(1050)},
+				{Kind: KindPerception, Name: "IIBB CABA", Jurisdiction: "CABA", Rate: "3.00", Base: ars(10000), Amount: ars(300)},
+			},
+		},
+		{
+			name:         "other jurisdiction",
+			lines:        []Line{{Amount: ars(10000)}},
+			jurisdiction: "Cordoba",
+			want:         []TaxLine{{Kind: KindIVA, Name: "IVA", Rate: "21.00", Base: ars(10000), Amount: ars(2100)}},
+		},
+		{
+			name:    "mixed currencies",
+			lines:   []Line{{Amount: ars(100)}, {Amount: money.New(100, "USD")}},
+			wantErr: money.ErrCurrencyMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testRules(t).Compute(tt.lines, tt.jurisdiction)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr error
+	}{
+		{name: "valid", content: `{"iva": {"default": "21", "categories": {"food": "10.5", "books": "exempt"}}, "perceptions": [{"name": "IIBB CABA", "jurisdiction": "CABA", "rate": "3"}]}`},
+		{name: "no default", content: `{"iva": {"categories": {"food": "10.5"}}}`},
+		{name: "negative rate", content: `{"iva": {"default": "-21"}}`, wantErr: ErrInvalidRules},
+		{name: "rate over 100", content: `{"iva": {"default": "121"}}`, wantErr: ErrInvalidRules},
+		{name: "categories equal once normalized", content: `{"iva": {"default": "21", "categories": {"Food": "10.5", "food ": "21"}}}`, wantErr: ErrInvalidRules},
+		{name: "too many decimals", content: `{"iva": {"default": "21", "categories": {"food": "10.555"}}}`, wantErr: ErrInvalidRules},
+		{name: "exempt perception", content: `{"iva": {"default": "21"}, "perceptions": [{"name": "x", "jurisdiction": "CABA", "rate": "exempt"}]}`, wantErr: ErrInvalidRules},
+		{name: "perception without jurisdiction", content: `{"iva": {"default": "21"}, "perceptions": [{"name": "x", "rate": "3"}]}`, wantErr: ErrInvalidRules},
+		{name: "not JSON", content: `iva: 21`, wantErr: ErrInvalidRules},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "taxes.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o644))
+
+			r, err := Load(path)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.NotNil(t, r)
+		})
+	}
+
+	_, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
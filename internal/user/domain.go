@@ -3,20 +3,69 @@ package user
 import "time"
 
 // User represents a system user with metadata for auditing and versioning.
+// Jurisdiction is where the user pays taxes, such as CABA; it decides the
+// perceptions charged on their sales.
+// Email and Credentials are set for users who registered to log in. Email
+// cannot change afterwards, and Credentials are never rendered as JSON.
 type User struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Address   string    `json:"address"`
-	NickName  string    `json:"nickname"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Version   int       `json:"version"`
+	ID           string       `json:"id"`
+	Name         string       `json:"name"`
+	Address      string       `json:"address"`
+	NickName     string       `json:"nickname"`
+	Jurisdiction string       `json:"jurisdiction"`
+	Email        string       `json:"email,omitempty"`
+	Credentials  *Credentials `json:"-"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	Version      int          `json:"version"`
+}
+
+// Credentials are the login state of a user: the hash of their password,
+// the failed logins since the last successful one and their refresh token
+// sessions.
+type Credentials struct {
+	PasswordHash string    `json:"password_hash"`
+	FailedLogins int       `json:"failed_logins,omitempty"`
+	LockedUntil  time.Time `json:"locked_until,omitempty"`
+	Sessions     []Session `json:"sessions,omitempty"`
+}
+
+// Session is a chain of refresh tokens started by a login. Each refresh
+// replaces TokenHash, the SHA-256 of the only token of the chain still
+// accepted.
+type Session struct {
+	ID        string     `json:"id"`
+	TokenHash string     `json:"token_hash"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// clone returns a deep copy of c.
+func (c *Credentials) clone() *Credentials {
+	if c == nil {
+		return nil
+	}
+	cp := *c
+	cp.Sessions = make([]Session, len(c.Sessions))
+	for i, s := range c.Sessions {
+		if s.RevokedAt != nil {
+			at := *s.RevokedAt
+			s.RevokedAt = &at
+		}
+		cp.Sessions[i] = s
+	}
+	return &cp
 }
 
 // UpdateFields represents the optional fields for updating a User.
 // A nil pointer means “no change” for that field.
+// ExpectedVersion, when set, makes the update fail unless the user is still
+// at that version.
 type UpdateFields struct {
-	Name     *string `json:"name"`
-	Address  *string `json:"address"`
-	NickName *string `json:"nickname"`
+	Name            *string `json:"name"`
+	Address         *string `json:"address"`
+	NickName        *string `json:"nickname"`
+	Jurisdiction    *string `json:"jurisdiction"`
+	ExpectedVersion *int    `json:"expected_version"`
 }
@@ -0,0 +1,228 @@
+package user
+
+import (
+	"API_VentasGO/internal/wal"
+	"encoding/json"
+	"sync"
+)
+
+// record is a single entry of the users write-ahead log.
+type record struct {
+	Op          string       `json:"op"`
+	ID          string       `json:"id"`
+	User        *stored      `json:"user,omitempty"`
+	Credentials *Credentials `json:"credentials,omitempty"`
+}
+
+// stored is a user as kept in the log and snapshots, which unlike its JSON
+// form carries its credentials.
+type stored struct {
+	*User
+	Credentials *Credentials `json:"credentials,omitempty"`
+}
+
+// storedOf returns the stored form of u.
+func storedOf(u *User) *stored {
+	return &stored{User: u, Credentials: u.Credentials}
+}
+
+// user returns the user of s, with its credentials.
+func (s *stored) user() *User {
+	u := *s.User
+	u.Credentials = s.Credentials
+	return &u
+}
+
+const (
+	opSet         = "set"
+	opCredentials = "credentials"
+	opDelete      = "delete"
+)
+
+// FileStorage is a durable Storage backed by a write-ahead log.
+// Every Set and Delete is appended to the log before it is applied in memory,
+// the log is periodically compacted into a snapshot, and both are replayed
+// when the storage is opened again.
+type FileStorage struct {
+	// mu serializes writes so the log order matches the memory order.
+	mu  sync.Mutex
+	mem *LocalStorage
+	log *wal.Log
+}
+
+// NewFileStorage opens the users stored in dir, replaying the snapshot and
+// the log to restore the state left by the previous run.
+func NewFileStorage(dir string, opts wal.Options) (*FileStorage, error) {
+	log, err := wal.Open(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FileStorage{
+		mem: NewLocalStorage(),
+		log: log,
+	}
+
+	if err := log.Replay(f.loadSnapshot, f.apply); err != nil {
+		log.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// loadSnapshot restores every user stored in a snapshot.
+func (f *FileStorage) loadSnapshot(data []byte) error {
+	var users []*stored
+	if err := json.Unmarshal(data, &users); err != nil {
+		return err
+	}
+	for _, u := range users {
+		if err := f.mem.Set(u.user()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply replays a single log record over the in-memory state.
+func (f *FileStorage) apply(data []byte) error {
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return err
+	}
+
+	switch r.Op {
+	case opSet:
+		return f.mem.Set(r.User.user())
+	case opCredentials:
+		return f.mem.UpdateCredentials(r.ID, func(c *Credentials) error {
+			*c = *r.Credentials
+			return nil
+		})
+	case opDelete:
+		if err := f.mem.Delete(r.ID); err != nil && err != ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// write appends r to the log, applies it in memory and compacts the log
+// when it grew past the configured threshold.
+func (f *FileStorage) write(r record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := f.log.Append(data); err != nil {
+		return err
+	}
+	if err := f.apply(data); err != nil {
+		return err
+	}
+
+	f.log.Compact(func() ([]byte, error) {
+		users := f.mem.all()
+		snapshot := make([]*stored, len(users))
+		for i, u := range users {
+			snapshot[i] = storedOf(u)
+		}
+		return json.Marshal(snapshot)
+	})
+	return nil
+}
+
+// Set durably stores or updates a user.
+// Returns ErrEmptyID if the user has an empty ID, or ErrDuplicateEmail if
+// another user has its email.
+func (f *FileStorage) Set(user *User) error {
+	if user.ID == "" {
+		return ErrEmptyID
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if user.Email != "" {
+		if other, err := f.mem.ReadByEmail(user.Email); err == nil && other.ID != user.ID {
+			return ErrDuplicateEmail
+		}
+	}
+	return f.write(record{Op: opSet, ID: user.ID, User: storedOf(user)})
+}
+
+// CompareAndSet durably replaces a user, but only if its stored Version
+// still equals version. The stored Email and Credentials are kept.
+// Returns ErrNotFound if the user does not exist, or ErrVersionMismatch if it
+// changed in the meantime.
+func (f *FileStorage) CompareAndSet(user *User, version int) error {
+	if user.ID == "" {
+		return ErrEmptyID
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	current, err := f.mem.Read(user.ID)
+	if err != nil {
+		return err
+	}
+	if current.Version != version {
+		return ErrVersionMismatch
+	}
+	u := *user
+	u.Email = current.Email
+	u.Credentials = current.Credentials
+	return f.write(record{Op: opSet, ID: user.ID, User: storedOf(&u)})
+}
+
+// UpdateCredentials durably applies update to a copy of the credentials of
+// a user, unless update returns an error.
+// Returns ErrNotFound if the user does not exist, or ErrNoCredentials if it
+// has no credentials.
+func (f *FileStorage) UpdateCredentials(id string, update func(c *Credentials) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	current, err := f.mem.Read(id)
+	if err != nil {
+		return err
+	}
+	if current.Credentials == nil {
+		return ErrNoCredentials
+	}
+	if err := update(current.Credentials); err != nil {
+		return err
+	}
+	return f.write(record{Op: opCredentials, ID: id, Credentials: current.Credentials})
+}
+
+// Read retrieves a user by ID.
+// Returns ErrNotFound if the user is not found.
+func (f *FileStorage) Read(id string) (*User, error) {
+	return f.mem.Read(id)
+}
+
+// ReadByEmail retrieves the user of an email.
+// Returns ErrNotFound if no user has it.
+func (f *FileStorage) ReadByEmail(email string) (*User, error) {
+	return f.mem.ReadByEmail(email)
+}
+
+// Delete durably removes a user by ID.
+// Returns ErrNotFound if the user does not exist.
+func (f *FileStorage) Delete(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.mem.Read(id); err != nil {
+		return err
+	}
+	return f.write(record{Op: opDelete, ID: id})
+}
+
+// Close flushes and closes the underlying log.
+func (f *FileStorage) Close() error {
+	return f.log.Close()
+}
@@ -0,0 +1,91 @@
+package user
+
+import (
+	"API_VentasGO/internal/wal"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStorage_SurvivesRestartAndTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	opts := wal.Options{Sync: wal.SyncAlways, SnapshotEvery: 3}
+
+	storage, err := NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	s := NewService(storage, nil)
+
+	kept := &User{Name: "Ayrton"}
+	require.NoError(t, s.Create(kept))
+	deleted := &User{Name: "Chiche"}
+	require.NoError(t, s.Create(deleted))
+	name := "Senna"
+	_, err = s.Update(kept.ID, &UpdateFields{Name: &name})
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(deleted.ID))
+	last := &User{Name: "Pringles"}
+	require.NoError(t, s.Create(last))
+	require.NoError(t, storage.Close())
+
+	// Kill the last write mid-record.
+	path := filepath.Join(dir, "wal.log")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-5))
+
+	storage, err = NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	got, err := storage.Read(kept.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Senna", got.Name)
+	require.Equal(t, 2, got.Version)
+
+	_, err = storage.Read(deleted.ID)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = storage.Read(last.ID)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// The storage keeps accepting writes after recovery.
+	require.NoError(t, NewService(storage, nil).Create(last))
+	_, err = storage.Read(last.ID)
+	require.NoError(t, err)
+}
+
+func TestFileStorage_KeepsCredentials(t *testing.T) {
+	dir := t.TempDir()
+	opts := wal.Options{Sync: wal.SyncAlways, SnapshotEvery: 2}
+
+	storage, err := NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	now := time.Now()
+	s := loginService(t, storage, &now)
+
+	u := &User{Name: "Ayrton", Email: "ayrton@example.com"}
+	require.NoError(t, s.Register(u, "correct horse"))
+	_, token, err := s.Login(u.Email, "correct horse")
+	require.NoError(t, err)
+	_, _, err = s.Login(u.Email, "wrong horse")
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+	require.ErrorIs(t, s.Register(&User{Email: u.Email}, "correct horse"), ErrDuplicateEmail)
+	require.NoError(t, storage.Close())
+
+	storage, err = NewFileStorage(dir, opts)
+	require.NoError(t, err)
+	defer storage.Close()
+	s = loginService(t, storage, &now)
+
+	got, err := storage.ReadByEmail(u.Email)
+	require.NoError(t, err)
+	require.Equal(t, u.ID, got.ID)
+	require.Equal(t, 1, got.Credentials.FailedLogins)
+	_, _, err = s.Refresh(token)
+	require.NoError(t, err)
+	_, _, err = s.Login(u.Email, "correct horse")
+	require.NoError(t, err)
+}
@@ -0,0 +1,323 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidEmail is returned when registering a malformed email.
+var ErrInvalidEmail = errors.New("invalid email")
+
+// ErrInvalidPassword is returned when registering a password that is too
+// short or too long.
+var ErrInvalidPassword = fmt.Errorf("password must have between %d and %d characters", minPasswordLength, maxPasswordLength)
+
+// ErrInvalidCredentials is returned when logging in with an unknown email or
+// a wrong password.
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+// ErrAccountLocked is returned when logging in to an account locked after
+// repeated failed logins.
+var ErrAccountLocked = errors.New("account locked")
+
+// ErrInvalidRefreshToken is returned for refresh tokens that are malformed,
+// unknown, expired, revoked or already rotated.
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// LockedError is returned when logging in to an account locked until Until.
+// It wraps ErrAccountLocked.
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%v until %s", ErrAccountLocked, e.Until.Format(time.RFC3339))
+}
+
+func (e *LockedError) Unwrap() error {
+	return ErrAccountLocked
+}
+
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 256
+
+	// maxSessions is how many refresh token sessions a user keeps; logging
+	// in again drops the oldest.
+	maxSessions = 10
+)
+
+// LoginOptions configures logins and refresh tokens.
+type LoginOptions struct {
+	// MaxFailures is how many failed logins in a row lock the account.
+	// Zero never locks it.
+	MaxFailures int
+
+	// Lockout is how long a locked account rejects logins.
+	Lockout time.Duration
+
+	// RefreshTTL is how long the refresh tokens of a login are accepted.
+	RefreshTTL time.Duration
+}
+
+// DefaultLoginOptions locks accounts for 15 minutes after 5 failed logins,
+// and keeps sessions for 30 days.
+func DefaultLoginOptions() LoginOptions {
+	return LoginOptions{MaxFailures: 5, Lockout: 15 * time.Minute, RefreshTTL: 30 * 24 * time.Hour}
+}
+
+// SetLoginOptions replaces the login options. It is meant to be called
+// before the service is used.
+func (s *Service) SetLoginOptions(opts LoginOptions) {
+	s.login = opts
+}
+
+// Register creates a user who logs in with user.Email and password. The
+// email is stored in lower case and the password as a salted PBKDF2 hash.
+// Returns ErrInvalidEmail, ErrInvalidPassword, or ErrDuplicateEmail if
+// another user has the email.
+func (s *Service) Register(user *User, password string) error {
+	email, err := normalizeEmail(user.Email)
+	if err != nil {
+		return err
+	}
+	if n := utf8.RuneCountInString(password); n < minPasswordLength || n > maxPasswordLength {
+		return ErrInvalidPassword
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.Email = email
+	user.Credentials = &Credentials{PasswordHash: hash}
+	return s.Create(user)
+}
+
+// normalizeEmail returns email in lower case.
+// Returns ErrInvalidEmail if it is not a plain address.
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", fmt.Errorf("%w: %q", ErrInvalidEmail, email)
+	}
+	return email, nil
+}
+
+// Login checks the email and password of a user and starts a session,
+// returning the user and the first refresh token of the session.
+// Returns ErrInvalidCredentials for unknown emails and wrong passwords, and
+// a *LockedError once MaxFailures logins in a row failed.
+func (s *Service) Login(email, password string) (*User, string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	u, err := s.storage.ReadByEmail(email)
+	if errors.Is(err, ErrNotFound) || (err == nil && u.Credentials == nil) {
+		checkPassword(dummyHash(), password)
+		return nil, "", ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := s.now()
+	if now.Before(u.Credentials.LockedUntil) {
+		return nil, "", &LockedError{Until: u.Credentials.LockedUntil}
+	}
+
+	if !checkPassword(u.Credentials.PasswordHash, password) {
+		err := s.storage.UpdateCredentials(u.ID, func(c *Credentials) error {
+			c.FailedLogins++
+			if s.login.MaxFailures > 0 && c.FailedLogins >= s.login.MaxFailures {
+				c.FailedLogins = 0
+				c.LockedUntil = now.Add(s.login.Lockout)
+				s.logger.Warn("account locked after failed logins", zap.String("user_id", u.ID), zap.Time("until", c.LockedUntil))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		return nil, "", ErrInvalidCredentials
+	}
+
+	secret, err := newTokenSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	session := Session{
+		ID:        uuid.NewString(),
+		TokenHash: hashToken(secret),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.login.RefreshTTL),
+	}
+	err = s.storage.UpdateCredentials(u.ID, func(c *Credentials) error {
+		if now.Before(c.LockedUntil) {
+			return &LockedError{Until: c.LockedUntil}
+		}
+		c.FailedLogins = 0
+		c.Sessions = append(liveSessions(c.Sessions, now), session)
+		if n := len(c.Sessions) - maxSessions; n > 0 {
+			c.Sessions = c.Sessions[n:]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return u, refreshToken(u.ID, session.ID, secret), nil
+}
+
+// Refresh rotates a refresh token: it returns the user of the token and the
+// token that replaces it. Presenting a token that was already rotated
+// revokes its whole session, since either it or its successor leaked.
+// Returns ErrInvalidRefreshToken for tokens that are not accepted.
+func (s *Service) Refresh(token string) (*User, string, error) {
+	userID, sessionID, secret, ok := parseRefreshToken(token)
+	if !ok {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	next, err := newTokenSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := s.now()
+	reused := false
+	err = s.storage.UpdateCredentials(userID, func(c *Credentials) error {
+		session, err := activeSession(c, sessionID, now)
+		if err != nil {
+			return err
+		}
+		if !tokenMatches(session.TokenHash, secret) {
+			session.RevokedAt = &now
+			reused = true
+			return nil
+		}
+		session.TokenHash = hashToken(next)
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrNoCredentials) {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if reused {
+		s.logger.Warn("rotated refresh token reused: session revoked", zap.String("user_id", userID), zap.String("session_id", sessionID))
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	u, err := s.storage.Read(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	return u, refreshToken(userID, sessionID, next), nil
+}
+
+// Revoke ends the session of a refresh token, or every session of its user
+// when all is true. Revoking a revoked session does nothing.
+// Returns ErrInvalidRefreshToken for tokens that are not the current one of
+// their session.
+func (s *Service) Revoke(token string, all bool) error {
+	userID, sessionID, secret, ok := parseRefreshToken(token)
+	if !ok {
+		return ErrInvalidRefreshToken
+	}
+
+	now := s.now()
+	err := s.storage.UpdateCredentials(userID, func(c *Credentials) error {
+		session, err := activeSession(c, sessionID, now)
+		if err != nil {
+			return err
+		}
+		if !tokenMatches(session.TokenHash, secret) {
+			return ErrInvalidRefreshToken
+		}
+		for i := range c.Sessions {
+			if (all || c.Sessions[i].ID == sessionID) && c.Sessions[i].RevokedAt == nil {
+				c.Sessions[i].RevokedAt = &now
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrNoCredentials) {
+		return ErrInvalidRefreshToken
+	}
+	return err
+}
+
+// activeSession returns the session id of c.
+// Returns ErrInvalidRefreshToken if it does not exist, expired or was revoked.
+func activeSession(c *Credentials, id string, now time.Time) (*Session, error) {
+	for i := range c.Sessions {
+		session := &c.Sessions[i]
+		if session.ID != id {
+			continue
+		}
+		if session.RevokedAt != nil || !now.Before(session.ExpiresAt) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return session, nil
+	}
+	return nil, ErrInvalidRefreshToken
+}
+
+// liveSessions returns the sessions that are neither expired nor revoked.
+func liveSessions(sessions []Session, now time.Time) []Session {
+	live := make([]Session, 0, len(sessions)+1)
+	for _, session := range sessions {
+		if session.RevokedAt == nil && now.Before(session.ExpiresAt) {
+			live = append(live, session)
+		}
+	}
+	return live
+}
+
+// newTokenSecret returns 32 random bytes in base64url.
+func newTokenSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex SHA-256 of a token secret. Secrets are random,
+// so a fast hash is enough to keep stored sessions from being replayed.
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenMatches reports, in constant time, whether secret hashes to hash.
+func tokenMatches(hash, secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(hashToken(secret))) == 1
+}
+
+// refreshToken renders a refresh token as <user ID>.<session ID>.<secret>.
+func refreshToken(userID, sessionID, secret string) string {
+	return userID + "." + sessionID + "." + secret
+}
+
+// parseRefreshToken splits a token rendered by refreshToken.
+func parseRefreshToken(token string) (userID, sessionID, secret string, ok bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], parts[2], true
+}
@@ -0,0 +1,176 @@
+package user
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// loginService returns a Service whose clock is *now, with a cheap password
+// hash so tests run fast.
+func loginService(t *testing.T, storage Storage, now *time.Time) *Service {
+	iterations := passwordIterations
+	passwordIterations = 1000
+	t.Cleanup(func() { passwordIterations = iterations })
+
+	s := NewService(storage, nil)
+	s.SetLoginOptions(LoginOptions{MaxFailures: 3, Lockout: time.Minute, RefreshTTL: time.Hour})
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+func TestService_Register(t *testing.T) {
+	now := time.Now()
+	s := loginService(t, NewLocalStorage(), &now)
+
+	u := &User{Name: "Ayrton", Email: " Ayrton@Example.com "}
+	require.NoError(t, s.Register(u, "correct horse"))
+	require.Equal(t, "ayrton@example.com", u.Email)
+	require.True(t, strings.HasPrefix(u.Credentials.PasswordHash, passwordScheme+"$"))
+	require.NotContains(t, u.Credentials.PasswordHash, "correct horse")
+
+	data, err := json.Marshal(u)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "password")
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  error
+	}{
+		{name: "duplicate email", email: "AYRTON@example.com", password: "correct horse", wantErr: ErrDuplicateEmail},
+		{name: "malformed email", email: "ayrton", password: "correct horse", wantErr: ErrInvalidEmail},
+		{name: "display name", email: "Ayrton <a@example.com>", password: "correct horse", wantErr: ErrInvalidEmail},
+		{name: "short password", email: "b@example.com", password: "short", wantErr: ErrInvalidPassword},
+		{name: "long password", email: "b@example.com", password: strings.Repeat("x", 257), wantErr: ErrInvalidPassword},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorIs(t, s.Register(&User{Email: tt.email}, tt.password), tt.wantErr)
+		})
+	}
+}
+
+func TestService_Login(t *testing.T) {
+	now := time.Now()
+	s := loginService(t, NewLocalStorage(), &now)
+	u := &User{Name: "Ayrton", Email: "ayrton@example.com"}
+	require.NoError(t, s.Register(u, "correct horse"))
+	require.NoError(t, s.Create(&User{Name: "No password"}))
+
+	got, token, err := s.Login("AYRTON@example.com", "correct horse")
+	require.NoError(t, err)
+	require.Equal(t, u.ID, got.ID)
+	require.True(t, strings.HasPrefix(token, u.ID+"."))
+
+	_, _, err = s.Login("nobody@example.com", "correct horse")
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// A success resets the failures, so only MaxFailures in a row lock.
+	for i := 0; i < 2; i++ {
+		_, _, err = s.Login(u.Email, "wrong horse")
+		require.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	_, _, err = s.Login(u.Email, "correct horse")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, _, err = s.Login(u.Email, "wrong horse")
+		require.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+
+	_, _, err = s.Login(u.Email, "correct horse")
+	var locked *LockedError
+	require.ErrorAs(t, err, &locked)
+	require.ErrorIs(t, err, ErrAccountLocked)
+	require.Equal(t, now.Add(time.Minute), locked.Until)
+
+	now = now.Add(time.Minute)
+	_, _, err = s.Login(u.Email, "correct horse")
+	require.NoError(t, err)
+}
+
+func TestService_Refresh(t *testing.T) {
+	now := time.Now()
+	s := loginService(t, NewLocalStorage(), &now)
+	u := &User{Name: "Ayrton", Email: "ayrton@example.com"}
+	require.NoError(t, s.Register(u, "correct horse"))
+
+	_, first, err := s.Login(u.Email, "correct horse")
+	require.NoError(t, err)
+	got, second, err := s.Refresh(first)
+	require.NoError(t, err)
+	require.Equal(t, u.ID, got.ID)
+	require.NotEqual(t, first, second)
+
+	// Reusing a rotated token revokes the session, successor included.
+	_, _, err = s.Refresh(first)
+	require.ErrorIs(t, err, ErrInvalidRefreshToken)
+	_, _, err = s.Refresh(second)
+	require.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	// Sessions expire.
+	_, token, err := s.Login(u.Email, "correct horse")
+	require.NoError(t, err)
+	now = now.Add(time.Hour)
+	_, _, err = s.Refresh(token)
+	require.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	for _, token := range []string{"", "a.b", u.ID + ".nope.secret", "nobody.nope.secret"} {
+		_, _, err = s.Refresh(token)
+		require.ErrorIs(t, err, ErrInvalidRefreshToken, token)
+	}
+}
+
+func TestService_Revoke(t *testing.T) {
+	now := time.Now()
+	s := loginService(t, NewLocalStorage(), &now)
+	u := &User{Name: "Ayrton", Email: "ayrton@example.com"}
+	require.NoError(t, s.Register(u, "correct horse"))
+
+	_, phone, err := s.Login(u.Email, "correct horse")
+	require.NoError(t, err)
+	_, laptop, err := s.Login(u.Email, "correct horse")
+	require.NoError(t, err)
+	_, tablet, err := s.Login(u.Email, "correct horse")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Revoke(phone, false))
+	_, _, err = s.Refresh(phone)
+	require.ErrorIs(t, err, ErrInvalidRefreshToken)
+	_, laptop, err = s.Refresh(laptop)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Revoke(laptop, true))
+	_, _, err = s.Refresh(tablet)
+	require.ErrorIs(t, err, ErrInvalidRefreshToken)
+	require.ErrorIs(t, s.Revoke(laptop, false), ErrInvalidRefreshToken)
+}
+
+func TestService_UpdateKeepsCredentials(t *testing.T) {
+	now := time.Now()
+	s := loginService(t, NewLocalStorage(), &now)
+	u := &User{Name: "Ayrton", Email: "ayrton@example.com"}
+	require.NoError(t, s.Register(u, "correct horse"))
+
+	// An update based on a read from before the login keeps its session.
+	stale, err := s.storage.Read(u.ID)
+	require.NoError(t, err)
+	_, token, err := s.Login(u.Email, "correct horse")
+	require.NoError(t, err)
+	stale.Name = "Senna"
+	stale.Email = "other@example.com"
+	stale.Credentials = nil
+	stale.Version++
+	require.NoError(t, s.storage.CompareAndSet(stale, 1))
+
+	got, err := s.Get(u.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Senna", got.Name)
+	require.Equal(t, "ayrton@example.com", got.Email)
+	_, _, err = s.Refresh(token)
+	require.NoError(t, err)
+}
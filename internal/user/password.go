@@ -0,0 +1,73 @@
+package user
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// passwordScheme prefixes the encoded password hashes.
+const passwordScheme = "pbkdf2-sha256"
+
+// passwordIterations is the PBKDF2 work factor of new hashes. Tests lower it.
+var passwordIterations = 600_000
+
+const (
+	saltLength = 16
+	keyLength  = 32
+)
+
+// hashPassword returns the salted PBKDF2-SHA256 hash of password, encoded as
+// pbkdf2-sha256$<iterations>$<salt>$<key> with base64 salt and key.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, keyLength)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s$%d$%s$%s", passwordScheme, passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// checkPassword reports whether password matches the encoded hash, comparing
+// the keys in constant time.
+func checkPassword(encoded, password string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// dummyHash is checked against the passwords of logins for unknown emails, so
+// they take as long as the others and do not reveal which emails exist.
+var dummyHash = sync.OnceValue(func() string {
+	h, _ := hashPassword("not the password of anyone")
+	return h
+})
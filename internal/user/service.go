@@ -1,6 +1,7 @@
 package user
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,6 +15,12 @@ type Service struct {
 
 	// logger is our observability component to log.
 	logger *zap.Logger
+
+	// login configures Login and Refresh.
+	login LoginOptions
+
+	// now returns the current time. Tests replace it.
+	now func() time.Time
 }
 
 // NewService creates a new Service.
@@ -26,6 +33,8 @@ func NewService(storage Storage, logger *zap.Logger) *Service {
 	return &Service{
 		storage: storage,
 		logger:  logger,
+		login:   DefaultLoginOptions(),
+		now:     time.Now,
 	}
 }
 
@@ -53,35 +62,58 @@ func (s *Service) Get(id string) (*User, error) {
 	return s.storage.Read(id)
 }
 
+// maxUpdateAttempts bounds how many times Update retries an update without
+// ExpectedVersion that lost a race against a concurrent one.
+const maxUpdateAttempts = 3
+
 // Update modifies an existing user's data.
-// It updates Name, Address, NickName, sets UpdatedAt to now and increments Version.
+// It updates Name, Address, NickName, Jurisdiction, sets UpdatedAt to now and increments Version.
+// The change is applied with a compare-and-set on the version it read, so
+// concurrent updates never overwrite each other silently.
 // Returns ErrNotFound if the user does not exist, or ErrEmptyID if user.ID is empty.
+// Returns ErrVersionMismatch if user.ExpectedVersion is set and the user is
+// at another version.
 func (s *Service) Update(id string, user *UpdateFields) (*User, error) {
-	existing, err := s.storage.Read(id)
-	if err != nil {
-		return nil, err
-	}
-
-	if user.Name != nil {
-		existing.Name = *user.Name
-	}
-
-	if user.Address != nil {
-		existing.Address = *user.Address
+	for attempt := 1; ; attempt++ {
+		existing, err := s.storage.Read(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if user.ExpectedVersion != nil && *user.ExpectedVersion != existing.Version {
+			return nil, ErrVersionMismatch
+		}
+		version := existing.Version
+
+		if user.Name != nil {
+			existing.Name = *user.Name
+		}
+
+		if user.Address != nil {
+			existing.Address = *user.Address
+		}
+
+		if user.NickName != nil {
+			existing.NickName = *user.NickName
+		}
+
+		if user.Jurisdiction != nil {
+			existing.Jurisdiction = *user.Jurisdiction
+		}
+
+		existing.UpdatedAt = time.Now()
+		existing.Version++
+
+		err = s.storage.CompareAndSet(existing, version)
+		if errors.Is(err, ErrVersionMismatch) && user.ExpectedVersion == nil && attempt < maxUpdateAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return existing, nil
 	}
-
-	if user.NickName != nil {
-		existing.NickName = *user.NickName
-	}
-
-	existing.UpdatedAt = time.Now()
-	existing.Version++
-
-	if err := s.storage.Set(existing); err != nil {
-		return nil, err
-	}
-
-	return existing, nil
 }
 
 // Delete removes a user from the system by its ID.
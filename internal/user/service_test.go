@@ -111,9 +111,10 @@ func TestService_Create(t *testing.T) {
 }
 
 type MockStorage struct {
-	mockSet    func(user *User) error
-	mockRead   func(id string) (*User, error)
-	mockDelete func(id string) error
+	mockSet           func(user *User) error
+	mockRead          func(id string) (*User, error)
+	mockCompareAndSet func(user *User, version int) error
+	mockDelete        func(id string) error
 }
 
 func (m *MockStorage) Set(user *User) error {
@@ -124,6 +125,81 @@ func (m *MockStorage) Read(id string) (*User, error) {
 	return m.mockRead(id)
 }
 
+func (m *MockStorage) CompareAndSet(user *User, version int) error {
+	return m.mockCompareAndSet(user, version)
+}
+
+func (m *MockStorage) ReadByEmail(email string) (*User, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockStorage) UpdateCredentials(id string, update func(c *Credentials) error) error {
+	return ErrNoCredentials
+}
+
 func (m *MockStorage) Delete(id string) error {
 	return m.mockDelete(id)
 }
+
+func TestService_Update(t *testing.T) {
+	version := func(v int) *int { return &v }
+	name := "Chiche"
+
+	tests := []struct {
+		name     string
+		expected *int
+		wantErr  error
+	}{
+		{name: "unconditional"},
+		{name: "matching version", expected: version(1)},
+		{name: "stale version", expected: version(2), wantErr: ErrVersionMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewLocalStorage()
+			require.NoError(t, storage.Set(&User{ID: "1", Name: "Ayrton", Version: 1}))
+			s := NewService(storage, nil)
+
+			got, err := s.Update("1", &UpdateFields{Name: &name, ExpectedVersion: tt.expected})
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				stored, _ := storage.Read("1")
+				require.Equal(t, "Ayrton", stored.Name)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, name, got.Name)
+			require.Equal(t, 2, got.Version)
+		})
+	}
+}
+
+func TestService_Update_RetriesLostRace(t *testing.T) {
+	storage := NewLocalStorage()
+	require.NoError(t, storage.Set(&User{ID: "1", Name: "Ayrton", Version: 1}))
+
+	// The first write loses against a concurrent update that bumps the version.
+	lost := false
+	s := NewService(&MockStorage{
+		mockRead: storage.Read,
+		mockCompareAndSet: func(user *User, version int) error {
+			if !lost {
+				lost = true
+				require.NoError(t, storage.Set(&User{ID: "1", Name: "Other", Version: 2}))
+			}
+			return storage.CompareAndSet(user, version)
+		},
+	}, nil)
+
+	address := "Pringles"
+	got, err := s.Update("1", &UpdateFields{Address: &address})
+	require.NoError(t, err)
+	require.Equal(t, "Other", got.Name)
+	require.Equal(t, 3, got.Version)
+
+	// A conditional update that loses the race is reported, not retried.
+	lost = false
+	_, err = s.Update("1", &UpdateFields{Address: &address, ExpectedVersion: &got.Version})
+	require.ErrorIs(t, err, ErrVersionMismatch)
+}
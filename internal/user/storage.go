@@ -2,6 +2,8 @@ package user
 
 import (
 	"errors"
+	"hash/fnv"
+	"sync"
 )
 
 // ErrNotFound is returned when a user with the given ID is not found.
@@ -10,55 +12,214 @@ var ErrNotFound = errors.New("user not found")
 // ErrEmptyID is returned when trying to store a user with an empty ID.
 var ErrEmptyID = errors.New("empty user ID")
 
+// ErrVersionMismatch is returned when a user changed since the version the
+// caller based its update on.
+var ErrVersionMismatch = errors.New("user version mismatch")
+
+// ErrDuplicateEmail is returned when storing a user with the email of another.
+var ErrDuplicateEmail = errors.New("email already registered")
+
+// ErrNoCredentials is returned when updating the credentials of a user who
+// never registered a password.
+var ErrNoCredentials = errors.New("user has no credentials")
+
+// Storage persists users. Emails are unique among the stored users.
+// CompareAndSet keeps the stored Email and Credentials: credentials change
+// only through UpdateCredentials, so a profile update never undoes a
+// concurrent login or revocation.
 type Storage interface {
 	Set(user *User) error
 	Read(id string) (*User, error)
+	ReadByEmail(email string) (*User, error)
+	CompareAndSet(user *User, version int) error
+	UpdateCredentials(id string, update func(c *Credentials) error) error
 	Delete(id string) error
 }
 
+// shardCount is the number of independent partitions of LocalStorage.
+const shardCount = 32
+
+// shard is a partition of LocalStorage guarded by its own lock.
+type shard struct {
+	mu sync.RWMutex
+	m  map[string]*User
+}
+
 // LocalStorage provides an in-memory implementation for storing users.
+// It is safe for concurrent use: users are spread across shards by ID so
+// requests for different users do not contend on the same lock.
+// Users are copied on the way in and out, so callers never share memory
+// with the store.
 type LocalStorage struct {
-	m map[string]*User
+	shards [shardCount]*shard
+
+	// emailMu guards emails, the ID of the user of each email. It is
+	// taken before any shard lock.
+	emailMu sync.RWMutex
+	emails  map[string]string
 }
 
-// NewLocalStorage instantiates a new LocalStorage with an empty map.
+// NewLocalStorage instantiates a new LocalStorage with empty shards.
 func NewLocalStorage() *LocalStorage {
-	return &LocalStorage{
-		m: make(map[string]*User),
+	l := &LocalStorage{emails: make(map[string]string)}
+	for i := range l.shards {
+		l.shards[i] = &shard{m: make(map[string]*User)}
 	}
+	return l
+}
+
+// shardFor returns the shard that owns the given user ID.
+func (l *LocalStorage) shardFor(id string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return l.shards[h.Sum32()%shardCount]
 }
 
 // Set stores or updates a user in the local storage.
-// Returns ErrEmptyID if the user has an empty ID.
+// Returns ErrEmptyID if the user has an empty ID, or ErrDuplicateEmail if
+// another user has its email.
 func (l *LocalStorage) Set(user *User) error {
 	if user.ID == "" {
 		return ErrEmptyID
 	}
 
-	l.m[user.ID] = user
+	u := *user
+	u.Credentials = user.Credentials.clone()
+	if u.Email != "" {
+		l.emailMu.Lock()
+		defer l.emailMu.Unlock()
+		if id, ok := l.emails[u.Email]; ok && id != u.ID {
+			return ErrDuplicateEmail
+		}
+		l.emails[u.Email] = u.ID
+	}
+
+	sh := l.shardFor(user.ID)
+	sh.mu.Lock()
+	sh.m[user.ID] = &u
+	sh.mu.Unlock()
+	return nil
+}
+
+// CompareAndSet atomically replaces a stored user, but only if its stored
+// Version still equals version. The stored Email and Credentials are kept.
+// Returns ErrNotFound if the user does not exist, or ErrVersionMismatch if it
+// changed in the meantime.
+func (l *LocalStorage) CompareAndSet(user *User, version int) error {
+	if user.ID == "" {
+		return ErrEmptyID
+	}
+
+	sh := l.shardFor(user.ID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	current, ok := sh.m[user.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if current.Version != version {
+		return ErrVersionMismatch
+	}
+
+	u := *user
+	u.Email = current.Email
+	u.Credentials = current.Credentials
+	sh.m[user.ID] = &u
+	return nil
+}
+
+// UpdateCredentials atomically applies update to a copy of the credentials
+// of a user, and stores the result unless update returns an error.
+// Returns ErrNotFound if the user does not exist, or ErrNoCredentials if it
+// has no credentials.
+func (l *LocalStorage) UpdateCredentials(id string, update func(c *Credentials) error) error {
+	sh := l.shardFor(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	current, ok := sh.m[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if current.Credentials == nil {
+		return ErrNoCredentials
+	}
+
+	c := current.Credentials.clone()
+	if err := update(c); err != nil {
+		return err
+	}
+	u := *current
+	u.Credentials = c
+	sh.m[id] = &u
 	return nil
 }
 
 // Read retrieves a user from the local storage by ID.
 // Returns ErrNotFound if the user is not found.
 func (l *LocalStorage) Read(id string) (*User, error) {
-	u, ok := l.m[id]
+	sh := l.shardFor(id)
+	sh.mu.RLock()
+	u, ok := sh.m[id]
+	sh.mu.RUnlock()
 
 	if !ok {
 		return nil, ErrNotFound
 	}
 
-	return u, nil
+	c := *u
+	c.Credentials = u.Credentials.clone()
+	return &c, nil
+}
+
+// ReadByEmail retrieves the user of an email.
+// Returns ErrNotFound if no user has it.
+func (l *LocalStorage) ReadByEmail(email string) (*User, error) {
+	l.emailMu.RLock()
+	id, ok := l.emails[email]
+	l.emailMu.RUnlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return l.Read(id)
 }
 
 // Delete removes a user from the local storage by ID.
 // Returns ErrNotFound if the user does not exist.
 func (l *LocalStorage) Delete(id string) error {
-	_, err := l.Read(id)
-	if err != nil {
-		return err
+	sh := l.shardFor(id)
+	sh.mu.Lock()
+	u, ok := sh.m[id]
+	if !ok {
+		sh.mu.Unlock()
+		return ErrNotFound
 	}
+	delete(sh.m, id)
+	sh.mu.Unlock()
 
-	delete(l.m, id)
+	if u.Email != "" {
+		l.emailMu.Lock()
+		if l.emails[u.Email] == id {
+			delete(l.emails, u.Email)
+		}
+		l.emailMu.Unlock()
+	}
 	return nil
 }
+
+// all returns a copy of every stored user.
+func (l *LocalStorage) all() []*User {
+	var users []*User
+	for _, sh := range l.shards {
+		sh.mu.RLock()
+		for _, u := range sh.m {
+			c := *u
+			c.Credentials = u.Credentials.clone()
+			users = append(users, &c)
+		}
+		sh.mu.RUnlock()
+	}
+	return users
+}
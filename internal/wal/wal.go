@@ -0,0 +1,377 @@
+package wal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrClosed is returned when operating on a Log that was already closed.
+var ErrClosed = errors.New("wal closed")
+
+// ErrCorruptSnapshot is returned by Replay when the snapshot fails its
+// checksum. Snapshots are replaced atomically, so this is never a torn write.
+var ErrCorruptSnapshot = errors.New("wal snapshot corrupted")
+
+// SyncPolicy decides when appended records are flushed to stable storage.
+type SyncPolicy int
+
+const (
+	// SyncAlways fsyncs the log after every append. A record is durable
+	// as soon as Append returns.
+	SyncAlways SyncPolicy = iota
+
+	// SyncInterval fsyncs the log periodically in the background. A crash
+	// may lose at most the records appended during the last interval.
+	SyncInterval
+
+	// SyncNever leaves flushing to the operating system.
+	SyncNever
+)
+
+const (
+	logFile      = "wal.log"
+	snapshotFile = "snapshot"
+
+	// headerSize is the size of a record header: payload length, CRC32 and
+	// sequence number.
+	headerSize = 16
+
+	// maxRecordSize bounds the payload length read from a header, so a
+	// corrupted length never triggers a huge allocation.
+	maxRecordSize = 64 << 20
+)
+
+// Options configures a Log.
+type Options struct {
+	// Sync is the fsync policy for appended records.
+	Sync SyncPolicy
+
+	// SyncEvery is the flush period when Sync is SyncInterval.
+	// Defaults to one second.
+	SyncEvery time.Duration
+
+	// SnapshotEvery is the number of appended records after which the
+	// owner should compact the log into a snapshot. Zero disables it.
+	SnapshotEvery int
+
+	// Logger receives the errors of Compact. Defaults to a production logger.
+	Logger *zap.Logger
+}
+
+// Log is an append-only write-ahead log with a single snapshot.
+// Every record is framed with its length and a CRC32 checksum, so a record
+// torn by a crash is detected and discarded on the next Open. Records are
+// numbered, and the snapshot keeps the number of the last record it covers,
+// so Replay never applies a record twice.
+type Log struct {
+	mu      sync.Mutex
+	dir     string
+	opts    Options
+	file    *os.File
+	records int
+	seq     uint64
+	closed  bool
+	done    chan struct{}
+	logger  *zap.Logger
+}
+
+// Open opens or creates the log stored in dir.
+// Call Replay before appending to restore the previous state.
+func Open(dir string, opts Options) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, logFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger, _ = zap.NewProduction()
+	}
+
+	l := &Log{
+		dir:    dir,
+		opts:   opts,
+		file:   f,
+		done:   make(chan struct{}),
+		logger: logger.With(zap.String("wal", dir)),
+	}
+
+	if opts.Sync == SyncInterval {
+		every := opts.SyncEvery
+		if every <= 0 {
+			every = time.Second
+		}
+		go l.syncLoop(every)
+	}
+
+	return l, nil
+}
+
+// Replay feeds the snapshot, if any, to snapshot and then every valid record
+// appended after it to record, in the order they were appended. A torn or
+// corrupted tail is truncated so that later appends start from the last good
+// record.
+func (l *Log) Replay(snapshot func(data []byte) error, record func(data []byte) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var covered uint64
+	data, err := os.ReadFile(filepath.Join(l.dir, snapshotFile))
+	switch {
+	case err == nil:
+		seq, state, err := readRecord(bytes.NewReader(data))
+		if err != nil {
+			return ErrCorruptSnapshot
+		}
+		if err := snapshot(state); err != nil {
+			return err
+		}
+		covered = seq
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(l.file)
+	var offset int64
+	l.records = 0
+	l.seq = covered
+	for {
+		seq, payload, err := readRecord(r)
+		if err != nil {
+			// io.EOF means a clean end; anything else is a torn tail.
+			break
+		}
+		// The log still holds the records of the snapshot when a crash
+		// interrupted Snapshot before it emptied the log.
+		if seq > covered {
+			if err := record(payload); err != nil {
+				return err
+			}
+		}
+		offset += int64(headerSize + len(payload))
+		l.records++
+		l.seq = max(l.seq, seq)
+	}
+
+	if err := l.file.Truncate(offset); err != nil {
+		return err
+	}
+	_, err = l.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// readRecord reads one framed record and its sequence number. It returns
+// io.EOF at a clean end of log and io.ErrUnexpectedEOF for a torn or
+// corrupted record.
+func readRecord(r io.Reader) (uint64, []byte, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, nil, io.EOF
+		}
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+
+	size := binary.BigEndian.Uint32(header[0:4])
+	sum := binary.BigEndian.Uint32(header[4:8])
+	if size > maxRecordSize {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	h := crc32.NewIEEE()
+	h.Write(header[8:])
+	h.Write(payload)
+	if h.Sum32() != sum {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+
+	return binary.BigEndian.Uint64(header[8:16]), payload, nil
+}
+
+// frame encodes data as a record numbered seq. The checksum covers both.
+func frame(seq uint64, data []byte) []byte {
+	buf := make([]byte, headerSize+len(data))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint64(buf[8:16], seq)
+	copy(buf[headerSize:], data)
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(buf[8:]))
+	return buf
+}
+
+// Append writes data as a new record and flushes it according to the
+// sync policy.
+func (l *Log) Append(data []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+
+	buf := frame(l.seq+1, data)
+	offset, err := l.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(buf); err != nil {
+		return l.rollback(offset, err)
+	}
+	if l.opts.Sync == SyncAlways {
+		if err := l.file.Sync(); err != nil {
+			return l.rollback(offset, err)
+		}
+	}
+	l.records++
+	l.seq++
+	return nil
+}
+
+// rollback truncates the log back to offset after a failed append, so a
+// partial record does not hide the records appended after it from Replay.
+// It returns err, joined with the truncation error if any.
+func (l *Log) rollback(offset int64, err error) error {
+	if terr := l.file.Truncate(offset); terr != nil {
+		return errors.Join(err, terr)
+	}
+	if _, serr := l.file.Seek(offset, io.SeekStart); serr != nil {
+		return errors.Join(err, serr)
+	}
+	return err
+}
+
+// ShouldSnapshot reports whether enough records were appended since the last
+// snapshot to compact the log.
+func (l *Log) ShouldSnapshot() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.opts.SnapshotEvery > 0 && l.records >= l.opts.SnapshotEvery
+}
+
+// Compact snapshots the data returned by state when ShouldSnapshot reports
+// true. Errors are logged rather than returned: the records appended so far
+// are already durable in the log, so the write that triggered the compaction
+// succeeded, and compaction is tried again after the next append.
+func (l *Log) Compact(state func() ([]byte, error)) {
+	if !l.ShouldSnapshot() {
+		return
+	}
+	data, err := state()
+	if err == nil {
+		err = l.Snapshot(data)
+	}
+	if err != nil {
+		l.logger.Error("failed to compact the log", zap.Error(err))
+	}
+}
+
+// Snapshot atomically replaces the snapshot with data and empties the log.
+// data must describe the state after every record appended so far.
+func (l *Log) Snapshot(data []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+
+	tmp := filepath.Join(l.dir, snapshotFile+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(frame(l.seq, data)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(l.dir, snapshotFile)); err != nil {
+		return err
+	}
+	if err := syncDir(l.dir); err != nil {
+		return err
+	}
+
+	// Replay skips the records the snapshot covers, so a crash between the
+	// rename and the truncation neither loses them nor applies them twice.
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	l.records = 0
+	return l.file.Sync()
+}
+
+// Close flushes and closes the log.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	close(l.done)
+
+	if err := l.file.Sync(); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+// syncLoop flushes the log every period until it is closed.
+func (l *Log) syncLoop(every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-t.C:
+			l.mu.Lock()
+			if !l.closed {
+				l.file.Sync()
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// syncDir fsyncs a directory so a rename inside it is durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,216 @@
+package wal
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func collect(t *testing.T, l *Log) (snapshot string, records []string) {
+	err := l.Replay(func(data []byte) error {
+		snapshot = string(data)
+		return nil
+	}, func(data []byte) error {
+		records = append(records, string(data))
+		return nil
+	})
+	require.NoError(t, err)
+	return snapshot, records
+}
+
+func TestLog_RecoversFromTornWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := Open(dir, Options{Sync: SyncAlways})
+	require.NoError(t, err)
+	_, records := collect(t, l)
+	require.Empty(t, records)
+	require.NoError(t, l.Append([]byte("first")))
+	require.NoError(t, l.Append([]byte("second")))
+	require.NoError(t, l.Append([]byte("third")))
+	require.NoError(t, l.Close())
+
+	// Simulate a crash in the middle of writing the third record.
+	path := filepath.Join(dir, logFile)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-2))
+
+	l, err = Open(dir, Options{Sync: SyncAlways})
+	require.NoError(t, err)
+	_, records = collect(t, l)
+	require.Equal(t, []string{"first", "second"}, records)
+
+	// The torn tail is discarded, so new appends are readable.
+	require.NoError(t, l.Append([]byte("fourth")))
+	require.NoError(t, l.Close())
+
+	l, err = Open(dir, Options{Sync: SyncAlways})
+	require.NoError(t, err)
+	_, records = collect(t, l)
+	require.Equal(t, []string{"first", "second", "fourth"}, records)
+	require.NoError(t, l.Close())
+}
+
+func TestLog_DiscardsCorruptedRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := Open(dir, Options{Sync: SyncNever})
+	require.NoError(t, err)
+	collect(t, l)
+	require.NoError(t, l.Append([]byte("first")))
+	require.NoError(t, l.Append([]byte("second")))
+	require.NoError(t, l.Close())
+
+	// Flip the last payload byte so the checksum no longer matches.
+	path := filepath.Join(dir, logFile)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)-1] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	l, err = Open(dir, Options{Sync: SyncNever})
+	require.NoError(t, err)
+	_, records := collect(t, l)
+	require.Equal(t, []string{"first"}, records)
+	require.NoError(t, l.Close())
+}
+
+func TestLog_RollsBackFailedAppend(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := Open(dir, Options{Sync: SyncNever})
+	require.NoError(t, err)
+	collect(t, l)
+	require.NoError(t, l.Append([]byte("first")))
+
+	// Simulate an append that wrote part of its record before failing.
+	offset, err := l.file.Seek(0, io.SeekCurrent)
+	require.NoError(t, err)
+	_, err = l.file.Write([]byte{0, 0, 0, 9, 1, 2})
+	require.NoError(t, err)
+	failed := errors.New("disk full")
+	require.ErrorIs(t, l.rollback(offset, failed), failed)
+
+	require.NoError(t, l.Append([]byte("second")))
+	require.NoError(t, l.Close())
+
+	l, err = Open(dir, Options{Sync: SyncNever})
+	require.NoError(t, err)
+	_, records := collect(t, l)
+	require.Equal(t, []string{"first", "second"}, records)
+	require.NoError(t, l.Close())
+}
+
+func TestLog_Snapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := Open(dir, Options{Sync: SyncInterval, SnapshotEvery: 2})
+	require.NoError(t, err)
+	collect(t, l)
+	require.NoError(t, l.Append([]byte("first")))
+	require.False(t, l.ShouldSnapshot())
+	require.NoError(t, l.Append([]byte("second")))
+	require.True(t, l.ShouldSnapshot())
+
+	require.NoError(t, l.Snapshot([]byte("state")))
+	require.False(t, l.ShouldSnapshot())
+	require.NoError(t, l.Append([]byte("third")))
+	require.NoError(t, l.Close())
+
+	l, err = Open(dir, Options{Sync: SyncAlways})
+	require.NoError(t, err)
+	snapshot, records := collect(t, l)
+	require.Equal(t, "state", snapshot)
+	require.Equal(t, []string{"third"}, records)
+	require.NoError(t, l.Close())
+}
+
+func TestLog_SkipsRecordsCoveredBySnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := Open(dir, Options{Sync: SyncAlways})
+	require.NoError(t, err)
+	collect(t, l)
+	require.NoError(t, l.Append([]byte("first")))
+	require.NoError(t, l.Append([]byte("second")))
+
+	// Simulate a crash after the snapshot was renamed into place but before
+	// the log was emptied.
+	path := filepath.Join(dir, logFile)
+	log, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, l.Snapshot([]byte("state")))
+	require.NoError(t, l.Close())
+	require.NoError(t, os.WriteFile(path, log, 0o644))
+
+	l, err = Open(dir, Options{Sync: SyncAlways})
+	require.NoError(t, err)
+	snapshot, records := collect(t, l)
+	require.Equal(t, "state", snapshot)
+	require.Empty(t, records)
+
+	// Records appended after recovery are numbered after the snapshot.
+	require.NoError(t, l.Append([]byte("third")))
+	require.NoError(t, l.Close())
+
+	l, err = Open(dir, Options{Sync: SyncAlways})
+	require.NoError(t, err)
+	snapshot, records = collect(t, l)
+	require.Equal(t, "state", snapshot)
+	require.Equal(t, []string{"third"}, records)
+	require.NoError(t, l.Close())
+}
+
+func TestLog_RejectsCorruptedSnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := Open(dir, Options{Sync: SyncAlways})
+	require.NoError(t, err)
+	collect(t, l)
+	require.NoError(t, l.Snapshot([]byte("state")))
+	require.NoError(t, l.Close())
+
+	path := filepath.Join(dir, snapshotFile)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)-1] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	l, err = Open(dir, Options{Sync: SyncAlways})
+	require.NoError(t, err)
+	defer l.Close()
+	err = l.Replay(func([]byte) error { return nil }, func([]byte) error { return nil })
+	require.ErrorIs(t, err, ErrCorruptSnapshot)
+}
+
+func TestLog_CompactLogsFailures(t *testing.T) {
+	dir := t.TempDir()
+	core, logs := observer.New(zap.ErrorLevel)
+
+	l, err := Open(dir, Options{Sync: SyncNever, SnapshotEvery: 1, Logger: zap.New(core)})
+	require.NoError(t, err)
+	collect(t, l)
+	require.NoError(t, l.Append([]byte("first")))
+
+	l.Compact(func() ([]byte, error) { return nil, errors.New("marshal failed") })
+	require.Equal(t, 1, logs.Len())
+	require.True(t, l.ShouldSnapshot())
+
+	l.Compact(func() ([]byte, error) { return []byte("state"), nil })
+	require.False(t, l.ShouldSnapshot())
+	require.NoError(t, l.Close())
+
+	l, err = Open(dir, Options{Sync: SyncNever})
+	require.NoError(t, err)
+	snapshot, records := collect(t, l)
+	require.Equal(t, "state", snapshot)
+	require.Empty(t, records)
+	require.NoError(t, l.Close())
+}
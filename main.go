@@ -9,7 +9,9 @@ import (
 
 func main() {
 	r := gin.Default()
-	api.InitRoutes(r)
+	if err := api.InitRoutes(r); err != nil {
+		panic(fmt.Errorf("error trying to init routes: %v", err))
+	}
 
 	if err := r.Run(":9090"); err != nil {
 		panic(fmt.Errorf("error trying to start server: %v", err))
@@ -2,14 +2,23 @@ package tests
 
 import (
 	"API_VentasGO/api"
+	"API_VentasGO/internal/auth"
+	"API_VentasGO/internal/money"
+	"API_VentasGO/internal/product"
 	"API_VentasGO/internal/sale"
 	"API_VentasGO/internal/user"
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -17,7 +26,27 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// testAPIKey authenticates the requests of the integration tests.
+const testAPIKey = "integration-tests-key"
+
+// authorized adds the test API key to a request.
+func authorized(req *http.Request) *http.Request {
+	req.Header.Set(auth.APIKeyHeader, testAPIKey)
+	return req
+}
+
+// signToken returns an HS256 token with claims signed with secret.
+func signToken(secret string, claims map[string]any) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload, _ := json.Marshal(claims)
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
 func TestMain(m *testing.M) {
+	os.Setenv("AUTH_API_KEYS", `[{"name":"integration-tests","key":"`+testAPIKey+`","roles":["admin"]}]`)
 	go func() {
 		//gin.setMode(gin.TestMode)
 		r := gin.Default()
@@ -33,12 +62,15 @@ func TestIntegrationCreateAndGet(t *testing.T) {
 	app := gin.Default()
 	api.InitRoutes(app)
 
-	resp, err := http.Post("http://localhost:9090/users", "application/json",
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:9090/users",
 		bytes.NewBufferString(`{
 		"name":"Ayrton",
 		"address":"Pringles",
 		"nickname":"Chiche"
 	}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(authorized(req))
 
 	require.NoError(t, err)
 	require.Equal(t, http.StatusCreated, resp.StatusCode)
@@ -54,7 +86,9 @@ func TestIntegrationCreateAndGet(t *testing.T) {
 	require.NotEmpty(t, resUser.CreatedAt)
 	require.NotEmpty(t, resUser.UpdatedAt)
 
-	resp2, err := http.Get("http://localhost:9090/users/" + resUser.ID)
+	req, err = http.NewRequest(http.MethodGet, "http://localhost:9090/users/"+resUser.ID, nil)
+	require.NoError(t, err)
+	resp2, err := http.DefaultClient.Do(authorized(req))
 	require.NoError(t, err)
 	require.Equal(t, http.StatusOK, resp2.StatusCode)
 
@@ -65,16 +99,8 @@ func TestIntegrationCreateAndGet(t *testing.T) {
 	require.Equal(t, resUser.ID, getUser.ID)
 }
 
-func engineMiddleware(engine *gin.Engine) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Set("engine", engine)
-		c.Next()
-	}
-}
-
 func TestIntegrationPostAndPathAndGetSale(t *testing.T) {
 	app := gin.Default()
-	app.Use(engineMiddleware(app))
 	api.InitRoutes(app)
 
 	reqUser := map[string]interface{}{
@@ -86,7 +112,7 @@ func TestIntegrationPostAndPathAndGetSale(t *testing.T) {
 	jsonUser, _ := json.Marshal(reqUser)
 	resq, err := http.NewRequest(http.MethodPost, "http://localhost:9090/users", bytes.NewReader(jsonUser))
 	resp := httptest.NewRecorder()
-	app.ServeHTTP(resp, resq)
+	app.ServeHTTP(resp, authorized(resq))
 
 	require.NoError(t, err)
 	require.Equal(t, http.StatusCreated, resp.Code)
@@ -103,8 +129,6 @@ func TestIntegrationPostAndPathAndGetSale(t *testing.T) {
 	require.NotEmpty(t, resUser.CreatedAt)
 	require.NotEmpty(t, resUser.UpdatedAt)
 
-	os.Setenv("MODO", "testing")
-
 	saleData := map[string]interface{}{
 		"user_id": resUser.ID,
 		"amount":  15000,
@@ -112,7 +136,7 @@ func TestIntegrationPostAndPathAndGetSale(t *testing.T) {
 	jsonSale, _ := json.Marshal(saleData)
 	resq, err = http.NewRequest(http.MethodPost, "http://localhost:9090/sales", bytes.NewReader(jsonSale))
 	resp = httptest.NewRecorder()
-	app.ServeHTTP(resp, resq)
+	app.ServeHTTP(resp, authorized(resq))
 	require.NoError(t, err)
 	require.NoError(t, err)
 	require.Equal(t, http.StatusCreated, resp.Code)
@@ -122,7 +146,7 @@ func TestIntegrationPostAndPathAndGetSale(t *testing.T) {
 	fmt.Println("VENTA ACTUAL: ", resSale)
 	defer resq.Body.Close()
 	require.Equal(t, resUser.ID, resSale.UserId)
-	require.Equal(t, float32(15000), resSale.Amount)
+	require.Equal(t, money.New(1500000, "ARS"), resSale.Amount)
 	require.NotEmpty(t, resSale.Status)
 	require.Equal(t, "pending", resSale.Status)
 	require.Equal(t, 1, resSale.Version)
@@ -136,7 +160,7 @@ func TestIntegrationPostAndPathAndGetSale(t *testing.T) {
 	reqJson, _ := json.Marshal(saleDataToUpdate)
 	resq, err = http.NewRequest(http.MethodPatch, "/sales/"+resSale.ID, bytes.NewReader(reqJson))
 	resp = httptest.NewRecorder()
-	app.ServeHTTP(resp, resq)
+	app.ServeHTTP(resp, authorized(resq))
 
 	var resSaleUpdated sale.Sale
 	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resSaleUpdated))
@@ -149,3 +173,1553 @@ func TestIntegrationPostAndPathAndGetSale(t *testing.T) {
 	status := []string{"approved", "rejected"}
 	require.Contains(t, status, resSaleUpdated.Status)
 }
+
+func TestIntegrationCreateSaleUnknownUser(t *testing.T) {
+	app := gin.Default()
+	api.InitRoutes(app)
+
+	saleData := map[string]interface{}{
+		"user_id": "does-not-exist",
+		"amount":  15000,
+	}
+	jsonSale, _ := json.Marshal(saleData)
+	resq, err := http.NewRequest(http.MethodPost, "/sales", bytes.NewReader(jsonSale))
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	app.ServeHTTP(resp, authorized(resq))
+
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	require.Contains(t, resp.Body.String(), sale.ErrUserNotFound.Error())
+}
+
+func TestIntegrationUpdateSaleInvalidStatus(t *testing.T) {
+	app := gin.Default()
+	api.InitRoutes(app)
+
+	serve := func(method, path string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := authorized(httptest.NewRequest(method, path, bytes.NewReader(payload)))
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve(http.MethodPost, "/users", map[string]string{"name": "Ayrton"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resUser user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resUser))
+
+	resp = serve(http.MethodPost, "/sales", map[string]any{"user_id": resUser.ID, "amount": 100})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resSale sale.Sale
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resSale))
+
+	resp = serve(http.MethodPatch, "/sales/"+resSale.ID, map[string]string{"status": "banana"})
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serve(http.MethodPatch, "/sales/"+resSale.ID, map[string]string{"status": "refunded"})
+	require.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = serve(http.MethodPatch, "/sales/"+resSale.ID, map[string]string{"status": "cancelled"})
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	resp = serve(http.MethodGet, "/sales?user_id="+resUser.ID+"&status=cancelled", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var list struct {
+		Metadata map[string]any `json:"metadata"`
+		Results  []*sale.Sale   `json:"results"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	require.Len(t, list.Results, 1)
+	require.EqualValues(t, 1, list.Metadata["cancelled"])
+}
+
+func TestIntegrationSummariesSurviveRestart(t *testing.T) {
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	start := func() func(method, path string, body any) *httptest.ResponseRecorder {
+		app := gin.New()
+		require.NoError(t, api.InitRoutes(app))
+		return func(method, path string, body any) *httptest.ResponseRecorder {
+			payload, _ := json.Marshal(body)
+			req := authorized(httptest.NewRequest(method, path, bytes.NewReader(payload)))
+			resp := httptest.NewRecorder()
+			app.ServeHTTP(resp, req)
+			return resp
+		}
+	}
+
+	serve := start()
+	resp := serve(http.MethodPost, "/users", map[string]string{"name": "Ayrton"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resUser user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resUser))
+	var resSale sale.Sale
+	for range 2 {
+		resp = serve(http.MethodPost, "/sales", map[string]any{"user_id": resUser.ID, "amount": 100})
+		require.Equal(t, http.StatusCreated, resp.Code)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&resSale))
+	}
+
+	// The summaries are rebuilt from the sales left by the previous run.
+	serve = start()
+	resp = serve(http.MethodPatch, "/sales/"+resSale.ID, map[string]string{"status": "cancelled"})
+	require.Equal(t, http.StatusOK, resp.Code)
+	resp = serve(http.MethodPost, "/sales", map[string]any{"user_id": resUser.ID, "amount": 50})
+	require.Equal(t, http.StatusCreated, resp.Code)
+
+	resp = serve(http.MethodGet, "/sales?user_id="+resUser.ID, nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var list struct {
+		Metadata map[string]any `json:"metadata"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	require.EqualValues(t, 3, list.Metadata["quantity"])
+	require.EqualValues(t, 2, list.Metadata["pending"])
+	require.EqualValues(t, 1, list.Metadata["cancelled"])
+	require.Equal(t, []any{map[string]any{"currency": "ARS", "minor_units": float64(25000), "value": "250.00"}}, list.Metadata["total_amount"])
+}
+
+func TestIntegrationListSalesPaginated(t *testing.T) {
+	app := gin.Default()
+	api.InitRoutes(app)
+
+	serve := func(method, path string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := authorized(httptest.NewRequest(method, path, bytes.NewReader(payload)))
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve(http.MethodPost, "/users", map[string]string{"name": "Ayrton"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resUser user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resUser))
+
+	for _, amount := range []string{"10", "30", "20"} {
+		resp = serve(http.MethodPost, "/sales", map[string]any{"user_id": resUser.ID, "amount": amount})
+		require.Equal(t, http.StatusCreated, resp.Code)
+	}
+
+	type listResponse struct {
+		Metadata   map[string]any `json:"metadata"`
+		Results    []*sale.Sale   `json:"results"`
+		NextCursor *string        `json:"next_cursor"`
+	}
+
+	var amounts []string
+	path := "/sales?user_id=" + resUser.ID + "&sort=amount&order=desc&limit=2"
+	for {
+		resp = serve(http.MethodGet, path, nil)
+		require.Equal(t, http.StatusOK, resp.Code)
+		var list listResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+		require.EqualValues(t, 3, list.Metadata["quantity"])
+		for _, s := range list.Results {
+			amounts = append(amounts, s.Amount.Decimal())
+		}
+		if list.NextCursor == nil {
+			break
+		}
+		path = "/sales?user_id=" + resUser.ID + "&sort=amount&order=desc&limit=2&cursor=" + *list.NextCursor
+	}
+	require.Equal(t, []string{"30.00", "20.00", "10.00"}, amounts)
+
+	resp = serve(http.MethodGet, "/sales?user_id="+resUser.ID+"&limit=abc", nil)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	resp = serve(http.MethodGet, "/sales?user_id="+resUser.ID+"&sort=banana", nil)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestIntegrationListSalesInvalidFilters(t *testing.T) {
+	app := gin.Default()
+	api.InitRoutes(app)
+
+	resp := httptest.NewRecorder()
+	app.ServeHTTP(resp, authorized(httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(`{"name":"Ayrton"}`))))
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resUser user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resUser))
+
+	for query, param := range map[string]string{
+		"&created_from=yesterday":                        "created_from",
+		"&updated_to=2025-13-01":                         "updated_to",
+		"&min_amount=abc":                                "min_amount",
+		"&status=pending&status=banana":                  "status",
+		"&created_from=2025-02-01&created_to=2025-01-01": "created_from",
+		"&min_amount=10&max_amount=5":                    "min_amount",
+		"&limit=0.5":                                     "limit",
+		"&order=up":                                      "order",
+	} {
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, authorized(httptest.NewRequest(http.MethodGet, "/sales?user_id="+resUser.ID+query, nil)))
+		require.Equal(t, http.StatusBadRequest, resp.Code, query)
+		require.Contains(t, resp.Body.String(), param, query)
+	}
+
+	resp = httptest.NewRecorder()
+	app.ServeHTTP(resp, authorized(httptest.NewRequest(http.MethodGet, "/sales?user_id="+resUser.ID+"&status=pending&status=approved&created_from=2000-01-01&min_amount=0", nil)))
+	require.Equal(t, http.StatusOK, resp.Code)
+}
+
+func TestIntegrationConditionalUpdates(t *testing.T) {
+	app := gin.Default()
+	api.InitRoutes(app)
+
+	serve := func(method, path string, body any, header map[string]string) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := authorized(httptest.NewRequest(method, path, bytes.NewReader(payload)))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve(http.MethodPost, "/users", map[string]string{"name": "Ayrton"}, nil)
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resUser user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resUser))
+
+	resp = serve(http.MethodGet, "/users/"+resUser.ID, nil, nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, `"1"`, resp.Header().Get("ETag"))
+
+	resp = serve(http.MethodPatch, "/users/"+resUser.ID, map[string]string{"name": "Chiche"}, map[string]string{"If-Match": `"1"`})
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, `"2"`, resp.Header().Get("ETag"))
+
+	resp = serve(http.MethodPatch, "/users/"+resUser.ID, map[string]string{"name": "Stale"}, map[string]string{"If-Match": `"1"`})
+	require.Equal(t, http.StatusPreconditionFailed, resp.Code)
+
+	resp = serve(http.MethodPatch, "/users/"+resUser.ID, map[string]any{"name": "Stale", "expected_version": 1}, nil)
+	require.Equal(t, http.StatusPreconditionFailed, resp.Code)
+
+	resp = serve(http.MethodPatch, "/users/"+resUser.ID, map[string]any{"expected_version": 1}, map[string]string{"If-Match": `"2"`})
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serve(http.MethodPost, "/sales", map[string]any{"user_id": resUser.ID, "amount": 100}, nil)
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resSale sale.Sale
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resSale))
+
+	resp = serve(http.MethodGet, "/sales/"+resSale.ID, nil, nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	tag := resp.Header().Get("ETag")
+	require.Equal(t, `"1"`, tag)
+
+	resp = serve(http.MethodPatch, "/sales/"+resSale.ID, map[string]string{"status": "approved"}, map[string]string{"If-Match": tag})
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	resp = serve(http.MethodPatch, "/sales/"+resSale.ID, map[string]string{"status": "refunded"}, map[string]string{"If-Match": tag})
+	require.Equal(t, http.StatusPreconditionFailed, resp.Code)
+
+	resp = serve(http.MethodPatch, "/sales/"+resSale.ID, map[string]string{"status": "refunded"}, map[string]string{"If-Match": "banana"})
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serve(http.MethodGet, "/sales/"+resSale.ID, nil, nil)
+	var stored sale.Sale
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stored))
+	require.Equal(t, sale.StatusApproved, stored.Status)
+	require.Equal(t, 2, stored.Version)
+
+	// A null body is an empty update, still subject to If-Match.
+	resp = serve(http.MethodPatch, "/users/"+resUser.ID, nil, map[string]string{"If-Match": `"1"`})
+	require.Equal(t, http.StatusPreconditionFailed, resp.Code)
+	resp = serve(http.MethodPatch, "/sales/"+resSale.ID, nil, map[string]string{"If-Match": `"1"`})
+	require.Equal(t, http.StatusPreconditionFailed, resp.Code)
+}
+
+func TestIntegrationIdempotentCreate(t *testing.T) {
+	app := gin.Default()
+	api.InitRoutes(app)
+
+	serve := func(path, key, body string) *httptest.ResponseRecorder {
+		req := authorized(httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body)))
+		req.Header.Set("Idempotency-Key", key)
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve("/users", "user-1", `{"name":"Ayrton"}`)
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resUser user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resUser))
+
+	replay := serve("/users", "user-1", `{ "name": "Ayrton" }`)
+	require.Equal(t, http.StatusCreated, replay.Code)
+	require.Equal(t, "true", replay.Header().Get("Idempotent-Replayed"))
+	var replayed user.User
+	require.NoError(t, json.NewDecoder(replay.Body).Decode(&replayed))
+	require.Equal(t, resUser.ID, replayed.ID)
+	// Replays carry the request ID of the retry, not of the first request.
+	require.NotEmpty(t, replay.Header().Get("X-Request-ID"))
+	require.NotEqual(t, resp.Header().Get("X-Request-ID"), replay.Header().Get("X-Request-ID"))
+	require.Len(t, replay.Header().Values("X-Request-ID"), 1)
+
+	resp = serve("/users", "user-1", `{"name":"Chiche"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+
+	// Concurrent retries of a checkout create a single sale.
+	body := fmt.Sprintf(`{"user_id":%q,"amount":100}`, resUser.ID)
+	ids := make(chan string, 10)
+	done := make(chan struct{})
+	for range 10 {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			resp := serve("/sales", "checkout-1", body)
+			if resp.Code != http.StatusCreated {
+				t.Errorf("create sale: got status %d", resp.Code)
+				return
+			}
+			var s sale.Sale
+			if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+				t.Error(err)
+				return
+			}
+			ids <- s.ID
+		}()
+	}
+	for range 10 {
+		<-done
+	}
+	close(ids)
+
+	first := <-ids
+	for id := range ids {
+		require.Equal(t, first, id)
+	}
+
+	resp = httptest.NewRecorder()
+	app.ServeHTTP(resp, authorized(httptest.NewRequest(http.MethodGet, "/sales?user_id="+resUser.ID, nil)))
+	require.Equal(t, http.StatusOK, resp.Code)
+	var list struct {
+		Results []*sale.Sale `json:"results"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	require.Len(t, list.Results, 1)
+}
+
+func TestIntegrationIdempotencyKeysArePerResource(t *testing.T) {
+	app := gin.New()
+	require.NoError(t, api.InitRoutes(app))
+
+	serve := func(method, path, key string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := authorized(httptest.NewRequest(method, path, bytes.NewReader(payload)))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve(http.MethodPost, "/users", "", map[string]string{"name": "Ayrton"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resUser user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resUser))
+
+	var sales []sale.Sale
+	for range 2 {
+		resp := serve(http.MethodPost, "/sales", "", map[string]any{"user_id": resUser.ID, "amount": "100"})
+		require.Equal(t, http.StatusCreated, resp.Code)
+		var created sale.Sale
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+		if created.Status == sale.StatusPending {
+			resp = serve(http.MethodPatch, "/sales/"+created.ID, "", map[string]string{"status": sale.StatusApproved})
+			require.Equal(t, http.StatusOK, resp.Code)
+		}
+		sales = append(sales, created)
+	}
+
+	// One key sent to two sales refunds each of them.
+	refund := map[string]any{"amount": "10", "reason": "damaged"}
+	var refunds []sale.Refund
+	for _, s := range sales {
+		resp := serve(http.MethodPost, "/sales/"+s.ID+"/refunds", "refund-1", refund)
+		require.Equal(t, http.StatusCreated, resp.Code)
+		require.Empty(t, resp.Header().Get("Idempotent-Replayed"))
+		var r sale.Refund
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&r))
+		require.Equal(t, s.ID, r.SaleID)
+		refunds = append(refunds, r)
+	}
+	require.NotEqual(t, refunds[0].ID, refunds[1].ID)
+
+	// Retrying on the first sale still replays its own refund.
+	resp = serve(http.MethodPost, "/sales/"+sales[0].ID+"/refunds", "refund-1", refund)
+	require.Equal(t, http.StatusCreated, resp.Code)
+	require.Equal(t, "true", resp.Header().Get("Idempotent-Replayed"))
+	var replayed sale.Refund
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&replayed))
+	require.Equal(t, refunds[0].ID, replayed.ID)
+
+	for _, s := range sales {
+		resp := serve(http.MethodGet, "/sales/"+s.ID+"/refunds", "", nil)
+		require.Equal(t, http.StatusOK, resp.Code)
+		var list struct {
+			Results []sale.Refund `json:"results"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+		require.Len(t, list.Results, 1)
+	}
+
+	// The same holds for the payments of two users.
+	resp = serve(http.MethodPost, "/users", "", map[string]string{"name": "Chiche"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var other user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&other))
+	for _, u := range []user.User{resUser, other} {
+		resp := serve(http.MethodPost, "/users/"+u.ID+"/payments", "payment-1", map[string]any{"amount": "0"})
+		require.Empty(t, resp.Header().Get("Idempotent-Replayed"), u.ID)
+	}
+}
+
+func TestIntegrationSaleWithItems(t *testing.T) {
+	app := gin.Default()
+	api.InitRoutes(app)
+
+	serve := func(method, path string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := authorized(httptest.NewRequest(method, path, bytes.NewReader(payload)))
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve(http.MethodPost, "/users", map[string]string{"name": "Ayrton"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resUser user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resUser))
+
+	sku := fmt.Sprintf("MATE-%d", time.Now().UnixNano())
+	resp = serve(http.MethodPost, "/products", map[string]any{"sku": sku, "name": "Mate", "price": "1500.00"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var mate product.Product
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&mate))
+	require.True(t, mate.Active)
+
+	resp = serve(http.MethodPost, "/products", map[string]any{"sku": sku, "name": "Otro", "price": 1})
+	require.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = serve(http.MethodPost, "/products/"+mate.ID+"/stock", map[string]any{"quantity": 10, "reason": "received"})
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	resp = serve(http.MethodPost, "/sales", map[string]any{
+		"user_id": resUser.ID,
+		"items":   []map[string]any{{"product_id": mate.ID, "quantity": 2, "unit_price": "1500"}},
+	})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resSale sale.Sale
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resSale))
+	require.Equal(t, money.New(300000, "ARS"), resSale.Amount)
+	require.Len(t, resSale.Items, 1)
+	require.Equal(t, sku, resSale.Items[0].SKU)
+
+	// A later price change neither rewrites the sale nor lets stale prices through.
+	resp = serve(http.MethodPatch, "/products/"+mate.ID, map[string]any{"price": "1800"})
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	resp = serve(http.MethodGet, "/sales/"+resSale.ID, nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var stored sale.Sale
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stored))
+	require.Equal(t, money.New(150000, "ARS"), stored.Items[0].UnitPrice)
+	require.Equal(t, money.New(300000, "ARS"), stored.Amount)
+
+	resp = serve(http.MethodPost, "/sales", map[string]any{
+		"user_id": resUser.ID,
+		"items":   []map[string]any{{"product_id": mate.ID, "quantity": 1, "unit_price": "1500"}},
+	})
+	require.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = serve(http.MethodPost, "/sales", map[string]any{
+		"user_id": resUser.ID,
+		"amount":  100,
+		"items":   []map[string]any{{"product_id": mate.ID, "quantity": 1}},
+	})
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serve(http.MethodPost, "/sales", map[string]any{
+		"user_id": resUser.ID,
+		"items":   []map[string]any{{"product_id": "banana", "quantity": 1}},
+	})
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serve(http.MethodPatch, "/products/"+mate.ID, map[string]any{"active": false})
+	require.Equal(t, http.StatusOK, resp.Code)
+	resp = serve(http.MethodPost, "/sales", map[string]any{
+		"user_id": resUser.ID,
+		"items":   []map[string]any{{"product_id": mate.ID, "quantity": 1}},
+	})
+	require.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = serve(http.MethodDelete, "/products/"+mate.ID, nil)
+	require.Equal(t, http.StatusNoContent, resp.Code)
+	resp = serve(http.MethodGet, "/products/"+mate.ID, nil)
+	require.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestIntegrationStockReservation(t *testing.T) {
+	app := gin.New()
+	api.InitRoutes(app)
+
+	serve := func(method, path string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := authorized(httptest.NewRequest(method, path, bytes.NewReader(payload)))
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+	type stockBody struct {
+		Stock struct {
+			OnHand    int64 `json:"on_hand"`
+			Reserved  int64 `json:"reserved"`
+			Available int64 `json:"available"`
+		} `json:"stock"`
+		Movements []struct {
+			Kind string `json:"kind"`
+		} `json:"movements"`
+	}
+	readStock := func(id string) stockBody {
+		resp := serve(http.MethodGet, "/products/"+id+"/stock", nil)
+		require.Equal(t, http.StatusOK, resp.Code)
+		var body stockBody
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return body
+	}
+
+	resp := serve(http.MethodPost, "/users", map[string]string{"name": "Ayrton"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resUser user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resUser))
+
+	resp = serve(http.MethodPost, "/products", map[string]any{"sku": fmt.Sprintf("TERMO-%d", time.Now().UnixNano()), "name": "Termo", "price": 100})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var termo product.Product
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&termo))
+
+	const stock = 20
+	resp = serve(http.MethodPost, "/products/"+termo.ID+"/stock", map[string]any{"quantity": stock, "reason": "received"})
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	// Many more buyers than units: exactly stock sales go through.
+	sold := make(chan string, 3*stock)
+	var wg sync.WaitGroup
+	for range 3 * stock {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := serve(http.MethodPost, "/sales", map[string]any{
+				"user_id": resUser.ID,
+				"items":   []map[string]any{{"product_id": termo.ID, "quantity": 1}},
+			})
+			switch resp.Code {
+			case http.StatusCreated:
+				var s sale.Sale
+				if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+					t.Error(err)
+					return
+				}
+				sold <- s.ID
+			case http.StatusConflict:
+			default:
+				t.Errorf("create sale: got status %d", resp.Code)
+			}
+		}()
+	}
+	wg.Wait()
+	close(sold)
+	require.Len(t, sold, stock)
+
+	got := readStock(termo.ID)
+	require.EqualValues(t, stock, got.Stock.OnHand)
+	require.EqualValues(t, stock, got.Stock.Reserved)
+	require.EqualValues(t, 0, got.Stock.Available)
+
+	// Approving commits the units, rejecting and cancelling give them back.
+	statuses := []string{sale.StatusApproved, sale.StatusRejected, sale.StatusCancelled, sale.StatusApproved}
+	i := 0
+	for id := range sold {
+		resp := serve(http.MethodPatch, "/sales/"+id, map[string]string{"status": statuses[i%len(statuses)]})
+		require.Equal(t, http.StatusOK, resp.Code)
+		i++
+	}
+
+	got = readStock(termo.ID)
+	require.EqualValues(t, stock/2, got.Stock.OnHand)
+	require.EqualValues(t, 0, got.Stock.Reserved)
+	require.EqualValues(t, stock/2, got.Stock.Available)
+	require.Len(t, got.Movements, 1+2*stock)
+	require.Equal(t, "adjust", got.Movements[0].Kind)
+
+	resp = serve(http.MethodPost, "/products/"+termo.ID+"/stock", map[string]any{"quantity": -stock, "reason": "lost"})
+	require.Equal(t, http.StatusConflict, resp.Code)
+	resp = serve(http.MethodPost, "/products/"+termo.ID+"/stock", map[string]any{"quantity": 0})
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	resp = serve(http.MethodGet, "/products/banana/stock", nil)
+	require.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestIntegrationRefunds(t *testing.T) {
+	app := gin.New()
+	api.InitRoutes(app)
+
+	serve := func(method, path string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := authorized(httptest.NewRequest(method, path, bytes.NewReader(payload)))
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve(http.MethodPost, "/users", map[string]string{"name": "Ayrton"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resUser user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resUser))
+
+	resp = serve(http.MethodPost, "/sales", map[string]any{"user_id": resUser.ID, "amount": "100"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var created sale.Sale
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+
+	// Only approved sales can be refunded.
+	if created.Status == sale.StatusPending {
+		resp = serve(http.MethodPost, "/sales/"+created.ID+"/refunds", map[string]any{})
+		require.Equal(t, http.StatusConflict, resp.Code)
+		resp = serve(http.MethodPatch, "/sales/"+created.ID, map[string]string{"status": sale.StatusApproved})
+		require.Equal(t, http.StatusOK, resp.Code)
+	}
+
+	resp = serve(http.MethodPost, "/sales/"+created.ID+"/refunds", map[string]any{"amount": "30.50", "reason": "damaged"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var refund sale.Refund
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&refund))
+	require.Equal(t, money.New(3050, "ARS"), refund.Amount)
+	require.Equal(t, created.ID, refund.SaleID)
+
+	resp = serve(http.MethodGet, "/sales/"+created.ID, nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var got sale.Sale
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	require.Equal(t, sale.StatusPartiallyRefunded, got.Status)
+
+	resp = serve(http.MethodPost, "/sales/"+created.ID+"/refunds", map[string]any{"amount": "70"})
+	require.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+	resp = serve(http.MethodPost, "/sales/"+created.ID+"/refunds", map[string]any{"amount": "-1"})
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	resp = serve(http.MethodPost, "/sales/"+created.ID+"/refunds", map[string]any{"amount": "1", "currency": "USD"})
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	resp = serve(http.MethodPost, "/sales/"+created.ID+"/refunds", map[string]any{"expected_version": 1})
+	require.Equal(t, http.StatusPreconditionFailed, resp.Code)
+
+	// Without amount, the rest is refunded.
+	resp = serve(http.MethodPost, "/sales/"+created.ID+"/refunds", map[string]any{})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&refund))
+	require.Equal(t, money.New(6950, "ARS"), refund.Amount)
+
+	resp = serve(http.MethodGet, "/sales/"+created.ID+"/refunds", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var list struct {
+		Results []sale.Refund `json:"results"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	require.Len(t, list.Results, 2)
+
+	resp = serve(http.MethodPost, "/sales/"+created.ID+"/refunds", map[string]any{})
+	require.Equal(t, http.StatusConflict, resp.Code)
+	resp = serve(http.MethodGet, "/sales/missing/refunds", nil)
+	require.Equal(t, http.StatusNotFound, resp.Code)
+
+	// The summary no longer counts the refunded amount.
+	resp = serve(http.MethodGet, "/sales?user_id="+resUser.ID, nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var summary struct {
+		Metadata struct {
+			Quantity     int          `json:"quantity"`
+			Total_amount money.Totals `json:"total_amount"`
+		} `json:"metadata"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&summary))
+	require.Equal(t, 1, summary.Metadata.Quantity)
+	require.True(t, summary.Metadata.Total_amount.Get("ARS").IsZero())
+}
+
+func TestIntegrationInstallments(t *testing.T) {
+	app := gin.New()
+	api.InitRoutes(app)
+
+	serve := func(method, path string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := authorized(httptest.NewRequest(method, path, bytes.NewReader(payload)))
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve(http.MethodPost, "/users", map[string]string{"name": "Ayrton"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resUser user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resUser))
+
+	resp = serve(http.MethodPost, "/sales", map[string]any{
+		"user_id":          resUser.ID,
+		"amount":           "1000",
+		"installment_plan": map[string]any{"count": 3, "interest_rate": 10},
+	})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var created sale.Sale
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	require.NotNil(t, created.Plan)
+	require.Equal(t, "10.00", created.Plan.InterestRate)
+	require.Equal(t, money.New(110000, "ARS"), created.Plan.Total)
+
+	resp = serve(http.MethodGet, "/sales/"+created.ID+"/installments", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var list struct {
+		Results []sale.Installment `json:"results"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	require.Len(t, list.Results, 3)
+	require.Equal(t, money.New(36667, "ARS"), list.Results[0].Amount)
+	require.Equal(t, money.New(36667, "ARS"), list.Results[1].Amount)
+	require.Equal(t, money.New(36666, "ARS"), list.Results[2].Amount)
+
+	if created.Status == sale.StatusPending {
+		resp = serve(http.MethodPatch, "/sales/"+created.ID+"/installments/1", map[string]string{"status": "paid"})
+		require.Equal(t, http.StatusConflict, resp.Code)
+		resp = serve(http.MethodPatch, "/sales/"+created.ID, map[string]string{"status": sale.StatusApproved})
+		require.Equal(t, http.StatusOK, resp.Code)
+	}
+
+	resp = serve(http.MethodPatch, "/sales/"+created.ID+"/installments/1", map[string]string{"status": "paid"})
+	require.Equal(t, http.StatusOK, resp.Code)
+	var paid sale.Installment
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&paid))
+	require.Equal(t, sale.InstallmentPaid, paid.Status)
+	require.NotNil(t, paid.PaidAt)
+	require.NotEmpty(t, resp.Header().Get("ETag"))
+
+	resp = serve(http.MethodPatch, "/sales/"+created.ID+"/installments/1", map[string]string{"status": "paid"})
+	require.Equal(t, http.StatusConflict, resp.Code)
+	resp = serve(http.MethodPatch, "/sales/"+created.ID+"/installments/2", map[string]string{"status": "overdue"})
+	require.Equal(t, http.StatusConflict, resp.Code)
+	resp = serve(http.MethodPatch, "/sales/"+created.ID+"/installments/2", map[string]string{"status": "banana"})
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	resp = serve(http.MethodPatch, "/sales/"+created.ID+"/installments/4", map[string]string{"status": "paid"})
+	require.Equal(t, http.StatusNotFound, resp.Code)
+	resp = serve(http.MethodPatch, "/sales/"+created.ID+"/installments/first", map[string]string{"status": "paid"})
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	resp = serve(http.MethodPatch, "/sales/"+created.ID+"/installments/2", map[string]any{"status": "paid", "expected_version": 1})
+	require.Equal(t, http.StatusPreconditionFailed, resp.Code)
+
+	resp = serve(http.MethodPost, "/sales", map[string]any{
+		"user_id":          resUser.ID,
+		"amount":           "1000",
+		"installment_plan": map[string]any{"count": 0},
+	})
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	// Rates may be sent as strings too.
+	resp = serve(http.MethodPost, "/sales", map[string]any{
+		"user_id":          resUser.ID,
+		"amount":           "1000",
+		"installment_plan": map[string]any{"count": 2, "interest_rate": "5.5"},
+	})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	require.Equal(t, "5.50", created.Plan.InterestRate)
+	resp = serve(http.MethodGet, "/sales/missing/installments", nil)
+	require.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestIntegrationPaymentMethods(t *testing.T) {
+	app := gin.New()
+	api.InitRoutes(app)
+
+	serve := func(method, path string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := authorized(httptest.NewRequest(method, path, bytes.NewReader(payload)))
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve(http.MethodPost, "/users", map[string]string{"name": "Ayrton"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resUser user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resUser))
+
+	tests := []struct {
+		name       string
+		payment    map[string]any
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "cash", payment: map[string]any{"type": "cash"}, wantCode: http.StatusCreated, wantStatus: sale.StatusApproved},
+		{name: "transfer", payment: map[string]any{"type": "transfer", "transfer_reference": "CBU-0001"}, wantCode: http.StatusCreated, wantStatus: sale.StatusPending},
+		{name: "debit", payment: map[string]any{"type": "debit", "card_brand": "visa", "card_last4": "4242"}, wantCode: http.StatusCreated},
+		{name: "unknown", payment: map[string]any{"type": "cheque"}, wantCode: http.StatusBadRequest},
+		{name: "card without last4", payment: map[string]any{"type": "credit", "card_brand": "visa"}, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serve(http.MethodPost, "/sales", map[string]any{"user_id": resUser.ID, "amount": "100", "payment_method": tt.payment})
+			require.Equal(t, tt.wantCode, resp.Code)
+			if tt.wantCode != http.StatusCreated {
+				return
+			}
+
+			var created sale.Sale
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+			require.NotNil(t, created.Payment)
+			require.Equal(t, tt.payment["type"], created.Payment.Type)
+			if tt.wantStatus != "" {
+				require.Equal(t, tt.wantStatus, created.Status)
+			}
+		})
+	}
+
+	resp = serve(http.MethodGet, "/sales?user_id="+resUser.ID, nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var summary struct {
+		Metadata struct {
+			Quantity int `json:"quantity"`
+			ByMethod map[string]struct {
+				Quantity     int          `json:"quantity"`
+				Total_amount money.Totals `json:"total_amount"`
+			} `json:"by_method"`
+		} `json:"metadata"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&summary))
+	require.Equal(t, 3, summary.Metadata.Quantity)
+	require.Len(t, summary.Metadata.ByMethod, 3)
+	require.Equal(t, 1, summary.Metadata.ByMethod[sale.MethodCash].Quantity)
+	require.Equal(t, money.New(10000, "ARS"), summary.Metadata.ByMethod[sale.MethodCash].Total_amount.Get("ARS"))
+}
+
+func TestIntegrationTaxes(t *testing.T) {
+	rules := filepath.Join(t.TempDir(), "taxes.json")
+	require.NoError(t, os.WriteFile(rules, []byte(`{
+		"iva": {"default": "21", "categories": {"food": "10.5", "books": "exempt"}},
+		"perceptions": [{"name": "IIBB CABA", "jurisdiction": "CABA", "rate": "3"}]
+	}`), 0o644))
+	t.Setenv("TAX_RULES", rules)
+
+	app := gin.New()
+	require.NoError(t, api.InitRoutes(app))
+
+	serve := func(method, path string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := authorized(httptest.NewRequest(method, path, bytes.NewReader(payload)))
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve(http.MethodPost, "/users", map[string]string{"name": "Ayrton", "jurisdiction": "CABA"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resUser user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resUser))
+
+	resp = serve(http.MethodPost, "/products", map[string]any{"sku": fmt.Sprintf("YERBA-%d", time.Now().UnixNano()), "name": "Yerba", "category": "Food", "price": 2000})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var yerba product.Product
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&yerba))
+	require.Equal(t, "food", yerba.Category)
+	resp = serve(http.MethodPost, "/products/"+yerba.ID+"/stock", map[string]any{"quantity": 10})
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	resp = serve(http.MethodPost, "/sales", map[string]any{
+		"user_id": resUser.ID,
+		"items":   []map[string]any{{"product_id": yerba.ID, "quantity": 2}},
+	})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var created sale.Sale
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	require.NotNil(t, created.Taxes)
+	require.Equal(t, "food", created.Items[0].Category)
+	require.Equal(t, money.New(400000, "ARS"), created.Taxes.Net)
+	require.Equal(t, money.New(42000+12000, "ARS"), created.Taxes.Tax)
+	require.Equal(t, money.New(454000, "ARS"), created.Amount)
+	require.Len(t, created.Taxes.Lines, 2)
+
+	resp = serve(http.MethodPost, "/sales", map[string]any{"user_id": resUser.ID, "amount": "100"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+
+	resp = serve(http.MethodGet, "/sales?user_id="+resUser.ID, nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var summary struct {
+		Metadata struct {
+			Net_amount   money.Totals `json:"net_amount"`
+			Tax_amount   money.Totals `json:"tax_amount"`
+			Gross_amount money.Totals `json:"gross_amount"`
+		} `json:"metadata"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&summary))
+	require.Equal(t, money.New(410000, "ARS"), summary.Metadata.Net_amount.Get("ARS"))
+	require.Equal(t, money.New(54000+2100+300, "ARS"), summary.Metadata.Tax_amount.Get("ARS"))
+	require.Equal(t, money.New(410000+56400, "ARS"), summary.Metadata.Gross_amount.Get("ARS"))
+}
+
+func TestIntegrationCoupons(t *testing.T) {
+	app := gin.New()
+	require.NoError(t, api.InitRoutes(app))
+
+	serve := func(method, path string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := authorized(httptest.NewRequest(method, path, bytes.NewReader(payload)))
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve(http.MethodPost, "/users", map[string]string{"name": "Ayrton"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resUser user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resUser))
+
+	code := fmt.Sprintf("verano-%d", time.Now().UnixNano())
+	resp = serve(http.MethodPost, "/coupons", map[string]any{"code": code, "kind": "percent", "percent": 10, "min_purchase": "50", "max_uses": 1})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	resp = serve(http.MethodPost, "/coupons", map[string]any{"code": code, "kind": "fixed", "amount": "5"})
+	require.Equal(t, http.StatusConflict, resp.Code)
+	resp = serve(http.MethodPost, "/coupons", map[string]any{"code": "BAD", "kind": "percent", "percent": "150"})
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serve(http.MethodPost, "/sales", map[string]any{"user_id": resUser.ID, "amount": "100", "coupon_code": code})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var created sale.Sale
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	require.Equal(t, money.New(9000, "ARS"), created.Amount)
+	require.NotNil(t, created.Discount)
+	require.Equal(t, money.New(1000, "ARS"), created.Discount.Amount)
+
+	tests := []struct {
+		name     string
+		body     map[string]any
+		wantCode int
+	}{
+		{name: "usage limit", body: map[string]any{"user_id": resUser.ID, "amount": "100", "coupon_code": code}, wantCode: http.StatusConflict},
+		{name: "below minimum", body: map[string]any{"user_id": resUser.ID, "amount": "40", "coupon_code": code}, wantCode: http.StatusConflict},
+		{name: "unknown code", body: map[string]any{"user_id": resUser.ID, "amount": "100", "coupon_code": "NOPE"}, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serve(http.MethodPost, "/sales", tt.body)
+			require.Equal(t, tt.wantCode, resp.Code)
+		})
+	}
+
+	// Cancelling the sale gives its use back.
+	resp = serve(http.MethodPatch, "/sales/"+created.ID, map[string]any{"status": sale.StatusCancelled})
+	require.Equal(t, http.StatusOK, resp.Code)
+	resp = serve(http.MethodGet, "/coupons/"+code, nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var coupon struct {
+		Uses int `json:"uses"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&coupon))
+	require.Zero(t, coupon.Uses)
+
+	resp = serve(http.MethodPost, "/sales", map[string]any{"user_id": resUser.ID, "amount": "100", "coupon_code": code})
+	require.Equal(t, http.StatusCreated, resp.Code)
+
+	// Only the count of redemptions is exposed, not the sales that used it.
+	resp = serve(http.MethodGet, "/coupons/"+code, nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var fields map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&fields))
+	require.Equal(t, float64(1), fields["uses"])
+	require.NotContains(t, fields, "redemptions")
+
+	resp = serve(http.MethodGet, "/coupons/NOPE", nil)
+	require.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestIntegrationCreditAccounts(t *testing.T) {
+	t.Setenv("CREDIT_LIMIT", "1000")
+
+	app := gin.New()
+	require.NoError(t, api.InitRoutes(app))
+
+	serve := func(method, path string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := authorized(httptest.NewRequest(method, path, bytes.NewReader(payload)))
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve(http.MethodPost, "/users", map[string]string{"name": "Ayrton"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resUser user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resUser))
+	onAccount := map[string]any{"type": sale.MethodAccount}
+
+	resp = serve(http.MethodPost, "/sales", map[string]any{"user_id": resUser.ID, "amount": "600", "payment_method": onAccount})
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var created sale.Sale
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	require.Equal(t, sale.StatusApproved, created.Status)
+
+	resp = serve(http.MethodPost, "/sales", map[string]any{"user_id": resUser.ID, "amount": "500", "payment_method": onAccount})
+	require.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = serve(http.MethodPost, "/sales/"+created.ID+"/refunds", map[string]any{"amount": "100"})
+	require.Equal(t, http.StatusCreated, resp.Code)
+
+	tests := []struct {
+		name     string
+		body     map[string]any
+		wantCode int
+	}{
+		{name: "payment", body: map[string]any{"amount": "200", "reference": "recibo-1"}, wantCode: http.StatusCreated},
+		{name: "repeated reference", body: map[string]any{"amount": "200", "reference": "recibo-1"}, wantCode: http.StatusConflict},
+		{name: "more than owed", body: map[string]any{"amount": "301"}, wantCode: http.StatusConflict},
+		{name: "zero", body: map[string]any{"amount": "0"}, wantCode: http.StatusBadRequest},
+		{name: "another currency", body: map[string]any{"amount": "10", "currency": "USD"}, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serve(http.MethodPost, "/users/"+resUser.ID+"/payments", tt.body)
+			require.Equal(t, tt.wantCode, resp.Code)
+		})
+	}
+
+	resp = serve(http.MethodGet, "/users/"+resUser.ID+"/account", nil)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var statement struct {
+		Balance   money.Money `json:"balance"`
+		Available money.Money `json:"available"`
+		Entries   []struct {
+			Kind   string      `json:"kind"`
+			Amount money.Money `json:"amount"`
+		} `json:"entries"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&statement))
+	require.Equal(t, money.New(30000, "ARS"), statement.Balance)
+	require.Equal(t, money.New(70000, "ARS"), statement.Available)
+	require.Len(t, statement.Entries, 3)
+	require.Equal(t, "charge", statement.Entries[0].Kind)
+	require.Equal(t, money.New(-10000, "ARS"), statement.Entries[1].Amount)
+	require.Equal(t, "payment", statement.Entries[2].Kind)
+
+	resp = serve(http.MethodGet, "/users/nope/account", nil)
+	require.Equal(t, http.StatusNotFound, resp.Code)
+	resp = serve(http.MethodPost, "/users/nope/payments", map[string]any{"amount": "10"})
+	require.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestIntegrationAuthentication(t *testing.T) {
+	secret := "0123456789abcdef0123456789abcdef"
+	t.Setenv("AUTH_JWT_SECRET", secret)
+	t.Setenv("AUTH_JWT_ISSUER", "ventas")
+
+	app := gin.New()
+	require.NoError(t, api.InitRoutes(app))
+
+	token := func(claims map[string]any) string { return signToken(secret, claims) }
+	valid := token(map[string]any{"sub": "ana", "iss": "ventas", "roles": []string{"cashier"}, "exp": time.Now().Add(time.Hour).Unix()})
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		wantCode int
+	}{
+		{name: "API key", headers: map[string]string{auth.APIKeyHeader: testAPIKey}, wantCode: http.StatusCreated},
+		{name: "bearer token", headers: map[string]string{"Authorization": "Bearer " + valid}, wantCode: http.StatusCreated},
+		{name: "no credentials", wantCode: http.StatusUnauthorized},
+		{name: "unknown API key", headers: map[string]string{auth.APIKeyHeader: "not-the-integration-key"}, wantCode: http.StatusUnauthorized},
+		{name: "expired token", headers: map[string]string{"Authorization": "Bearer " + token(map[string]any{"sub": "ana", "iss": "ventas", "exp": time.Now().Add(-time.Hour).Unix()})}, wantCode: http.StatusUnauthorized},
+		{name: "other issuer", headers: map[string]string{"Authorization": "Bearer " + token(map[string]any{"sub": "ana", "iss": "otros", "exp": time.Now().Add(time.Hour).Unix()})}, wantCode: http.StatusUnauthorized},
+		{name: "tampered token", headers: map[string]string{"Authorization": "Bearer " + valid + "x"}, wantCode: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(`{"name":"Ayrton"}`))
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			resp := httptest.NewRecorder()
+			app.ServeHTTP(resp, req)
+
+			require.Equal(t, tt.wantCode, resp.Code)
+			if tt.wantCode == http.StatusUnauthorized {
+				require.NotEmpty(t, resp.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	// Idempotency keys of different principals do not collide.
+	var ids []string
+	for _, set := range []func(*http.Request){
+		func(req *http.Request) { authorized(req) },
+		func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+valid) },
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(`{"name":"Ayrton"}`))
+		req.Header.Set("Idempotency-Key", "same-key")
+		set(req)
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusCreated, resp.Code)
+
+		var u user.User
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&u))
+		ids = append(ids, u.ID)
+	}
+	require.NotEqual(t, ids[0], ids[1])
+
+	t.Run("no credentials configured", func(t *testing.T) {
+		t.Setenv("AUTH_API_KEYS", "")
+		t.Setenv("AUTH_JWT_SECRET", "")
+		require.Error(t, api.InitRoutes(gin.New()))
+
+		t.Setenv("AUTH_DISABLED", "true")
+		app := gin.New()
+		require.NoError(t, api.InitRoutes(app))
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(`{"name":"Ayrton"}`)))
+		require.Equal(t, http.StatusCreated, resp.Code)
+	})
+}
+
+func TestIntegrationAuthorization(t *testing.T) {
+	secret := "0123456789abcdef0123456789abcdef"
+	t.Setenv("AUTH_JWT_SECRET", secret)
+	t.Setenv("AUTH_API_KEYS", `[
+		{"name":"integration-tests","key":"`+testAPIKey+`","roles":["admin"]},
+		{"name":"till-1","key":"cashier-key-0123456789","roles":["cashier"]},
+		{"name":"floor-1","key":"supervisor-key-0123456789","roles":["supervisor"]}
+	]`)
+
+	app := gin.New()
+	require.NoError(t, api.InitRoutes(app))
+
+	do := func(method, path, body string, credentials func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		credentials(req)
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+	apiKey := func(key string) func(*http.Request) {
+		return func(req *http.Request) { req.Header.Set(auth.APIKeyHeader, key) }
+	}
+	admin := apiKey(testAPIKey)
+	bearer := func(subject string) func(*http.Request) {
+		token := signToken(secret, map[string]any{"sub": subject, "roles": []string{"user"}, "exp": time.Now().Add(time.Hour).Unix()})
+		return func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+token) }
+	}
+
+	// fixtures creates, as admin, a user with a pending sale and an approved
+	// sale in two installments, a product and a coupon, and returns a
+	// replacer of their placeholders.
+	var n int
+	fixtures := func(t *testing.T) *strings.Replacer {
+		n++
+		create := func(path, body string, v any) {
+			resp := do(http.MethodPost, path, body, admin)
+			require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+		}
+
+		var u user.User
+		create("/users", `{"name":"Ayrton"}`, &u)
+		var pending, approved sale.Sale
+		create("/sales", `{"user_id":"`+u.ID+`","amount":"100"}`, &pending)
+		create("/sales", `{"user_id":"`+u.ID+`","amount":"100","installment_plan":{"count":2}}`, &approved)
+		resp := do(http.MethodPatch, "/sales/"+approved.ID, `{"status":"approved"}`, admin)
+		require.Equal(t, http.StatusOK, resp.Code)
+		var p product.Product
+		create("/products", fmt.Sprintf(`{"sku":"AUTHZ-%d","name":"Mate","price":"10"}`, n), &p)
+		coupon := fmt.Sprintf("AUTHZ-%d", n)
+		create("/coupons", `{"code":"`+coupon+`","kind":"fixed","amount":"5"}`, &map[string]any{})
+
+		return strings.NewReplacer("{user}", u.ID, "{pending}", pending.ID, "{approved}", approved.ID,
+			"{product}", p.ID, "{coupon}", coupon)
+	}
+
+	// want holds the status answered to each principal: a cashier, a
+	// supervisor, an admin, the user of the fixtures and another user.
+	type want struct{ cashier, supervisor, admin, owner, stranger int }
+	const forbidden = http.StatusForbidden
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   want
+	}{
+		{http.MethodPost, "/users", `{"name":"Ana"}`, want{201, 201, 201, forbidden, forbidden}},
+		{http.MethodGet, "/users/{user}", "", want{200, 200, 200, 200, forbidden}},
+		{http.MethodPatch, "/users/{user}", `{"name":"Ana"}`, want{200, 200, 200, forbidden, forbidden}},
+		{http.MethodDelete, "/users/{user}", "", want{forbidden, forbidden, 204, forbidden, forbidden}},
+		{http.MethodGet, "/users/{user}/account", "", want{200, 200, 200, 200, forbidden}},
+		// Authorized payments are refused, since nothing is owed.
+		{http.MethodPost, "/users/{user}/payments", `{"amount":"10"}`, want{409, 409, 409, forbidden, forbidden}},
+
+		{http.MethodPost, "/products", `{"sku":"AUTHZ-NEW","name":"Yerba","price":"5"}`, want{forbidden, forbidden, 201, forbidden, forbidden}},
+		{http.MethodGet, "/products/{product}", "", want{200, 200, 200, forbidden, forbidden}},
+		{http.MethodPatch, "/products/{product}", `{"name":"Bombilla"}`, want{forbidden, forbidden, 200, forbidden, forbidden}},
+		{http.MethodDelete, "/products/{product}", "", want{forbidden, forbidden, 204, forbidden, forbidden}},
+		{http.MethodGet, "/products/{product}/stock", "", want{200, 200, 200, forbidden, forbidden}},
+		{http.MethodPost, "/products/{product}/stock", `{"quantity":5,"reason":"count"}`, want{forbidden, 200, 200, forbidden, forbidden}},
+
+		{http.MethodPost, "/coupons", `{"code":"AUTHZ-NEW","kind":"fixed","amount":"5"}`, want{forbidden, forbidden, 201, forbidden, forbidden}},
+		{http.MethodGet, "/coupons/{coupon}", "", want{200, 200, 200, forbidden, forbidden}},
+
+		{http.MethodPost, "/sales", `{"user_id":"{user}","amount":"100"}`, want{201, 201, 201, forbidden, forbidden}},
+		{http.MethodGet, "/sales?user_id={user}", "", want{200, 200, 200, 200, forbidden}},
+		{http.MethodPatch, "/sales/{pending}", `{"status":"approved"}`, want{forbidden, 200, 200, forbidden, forbidden}},
+		{http.MethodPatch, "/sales/{pending}", `{"status":"APPROVED"}`, want{forbidden, 200, 200, forbidden, forbidden}},
+		{http.MethodPatch, "/sales/{pending}", `{"status":"rejected"}`, want{forbidden, 200, 200, forbidden, forbidden}},
+		{http.MethodPatch, "/sales/{pending}", `{"status":"cancelled"}`, want{forbidden, 200, 200, forbidden, forbidden}},
+		{http.MethodGet, "/sales/{pending}", "", want{200, 200, 200, 200, forbidden}},
+		{http.MethodPost, "/sales/{approved}/refunds", `{"amount":"10"}`, want{forbidden, 201, 201, forbidden, forbidden}},
+		{http.MethodGet, "/sales/{approved}/refunds", "", want{200, 200, 200, 200, forbidden}},
+		{http.MethodGet, "/sales/{approved}/installments", "", want{200, 200, 200, 200, forbidden}},
+		{http.MethodPatch, "/sales/{approved}/installments/1", `{"status":"paid"}`, want{forbidden, 200, 200, forbidden, forbidden}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path+" "+tt.body, func(t *testing.T) {
+			for _, principal := range []struct {
+				name        string
+				wantCode    int
+				credentials func(r *strings.Replacer) func(*http.Request)
+			}{
+				{"cashier", tt.want.cashier, func(*strings.Replacer) func(*http.Request) { return apiKey("cashier-key-0123456789") }},
+				{"supervisor", tt.want.supervisor, func(*strings.Replacer) func(*http.Request) { return apiKey("supervisor-key-0123456789") }},
+				{"admin", tt.want.admin, func(*strings.Replacer) func(*http.Request) { return admin }},
+				{"owner", tt.want.owner, func(r *strings.Replacer) func(*http.Request) { return bearer(r.Replace("{user}")) }},
+				{"stranger", tt.want.stranger, func(*strings.Replacer) func(*http.Request) { return bearer("someone-else") }},
+			} {
+				t.Run(principal.name, func(t *testing.T) {
+					r := fixtures(t)
+					resp := do(tt.method, r.Replace(tt.path), r.Replace(tt.body), principal.credentials(r))
+					require.Equal(t, principal.wantCode, resp.Code, resp.Body.String())
+
+					if principal.wantCode == http.StatusForbidden {
+						var denied struct {
+							Permission string `json:"permission"`
+						}
+						require.NoError(t, json.NewDecoder(resp.Body).Decode(&denied))
+						require.NotEmpty(t, denied.Permission)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestIntegrationLogin(t *testing.T) {
+	t.Setenv("AUTH_JWT_SECRET", "0123456789abcdef0123456789abcdef")
+	t.Setenv("AUTH_LOCKOUT_AFTER", "3")
+
+	app := gin.New()
+	require.NoError(t, api.InitRoutes(app))
+
+	// serve sends a request with the given bearer token, or without
+	// credentials when token is empty.
+	serve := func(method, path string, body any, token string) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+	type tokens struct {
+		AccessToken  string    `json:"access_token"`
+		TokenType    string    `json:"token_type"`
+		RefreshToken string    `json:"refresh_token"`
+		User         user.User `json:"user"`
+	}
+	decode := func(resp *httptest.ResponseRecorder) tokens {
+		var tk tokens
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&tk))
+		return tk
+	}
+
+	email := fmt.Sprintf("ayrton-%d@example.com", time.Now().UnixNano())
+	resp := serve(http.MethodPost, "/auth/register", map[string]string{"name": "Ayrton", "email": email, "password": "correct horse"}, "")
+	require.Equal(t, http.StatusCreated, resp.Code)
+	require.NotContains(t, resp.Body.String(), "password")
+	var registered user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&registered))
+
+	register := []struct {
+		name     string
+		body     map[string]string
+		wantCode int
+	}{
+		{name: "duplicate email", body: map[string]string{"email": strings.ToUpper(email), "password": "correct horse"}, wantCode: http.StatusConflict},
+		{name: "malformed email", body: map[string]string{"email": "ayrton", "password": "correct horse"}, wantCode: http.StatusBadRequest},
+		{name: "short password", body: map[string]string{"email": "x" + email, "password": "short"}, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range register {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.wantCode, serve(http.MethodPost, "/auth/register", tt.body, "").Code)
+		})
+	}
+
+	resp = serve(http.MethodPost, "/auth/login", map[string]string{"email": email, "password": "wrong horse"}, "")
+	require.Equal(t, http.StatusUnauthorized, resp.Code)
+	resp = serve(http.MethodPost, "/auth/login", map[string]string{"email": email, "password": "correct horse"}, "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, "no-store", resp.Header().Get("Cache-Control"))
+	login := decode(resp)
+	require.Equal(t, "Bearer", login.TokenType)
+	require.Equal(t, registered.ID, login.User.ID)
+
+	// The access token reads only what belongs to the user.
+	access := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode int
+	}{
+		{name: "own user", method: http.MethodGet, path: "/users/" + registered.ID, wantCode: http.StatusOK},
+		{name: "own sales", method: http.MethodGet, path: "/sales?user_id=" + registered.ID, wantCode: http.StatusOK},
+		{name: "other user", method: http.MethodGet, path: "/users/someone-else", wantCode: http.StatusForbidden},
+		{name: "create sale", method: http.MethodPost, path: "/sales", wantCode: http.StatusForbidden},
+	}
+	for _, tt := range access {
+		t.Run(tt.name, func(t *testing.T) {
+			body := map[string]any{"user_id": registered.ID, "amount": "100"}
+			require.Equal(t, tt.wantCode, serve(tt.method, tt.path, body, login.AccessToken).Code)
+		})
+	}
+
+	// Refresh tokens rotate, and reusing a rotated one ends the session.
+	resp = serve(http.MethodPost, "/auth/refresh", map[string]string{"refresh_token": login.RefreshToken}, "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	refreshed := decode(resp)
+	require.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+	require.Equal(t, http.StatusOK, serve(http.MethodGet, "/users/"+registered.ID, nil, refreshed.AccessToken).Code)
+	resp = serve(http.MethodPost, "/auth/refresh", map[string]string{"refresh_token": login.RefreshToken}, "")
+	require.Equal(t, http.StatusUnauthorized, resp.Code)
+	resp = serve(http.MethodPost, "/auth/refresh", map[string]string{"refresh_token": refreshed.RefreshToken}, "")
+	require.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	// Logging out revokes the session.
+	resp = serve(http.MethodPost, "/auth/login", map[string]string{"email": email, "password": "correct horse"}, "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	session := decode(resp)
+	resp = serve(http.MethodPost, "/auth/logout", map[string]string{"refresh_token": session.RefreshToken}, "")
+	require.Equal(t, http.StatusNoContent, resp.Code)
+	resp = serve(http.MethodPost, "/auth/refresh", map[string]string{"refresh_token": session.RefreshToken}, "")
+	require.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	// Three failed logins in a row lock the account.
+	for i := 0; i < 3; i++ {
+		resp = serve(http.MethodPost, "/auth/login", map[string]string{"email": email, "password": "wrong horse"}, "")
+		require.Equal(t, http.StatusUnauthorized, resp.Code)
+	}
+	resp = serve(http.MethodPost, "/auth/login", map[string]string{"email": email, "password": "correct horse"}, "")
+	require.Equal(t, http.StatusTooManyRequests, resp.Code)
+	require.NotEmpty(t, resp.Header().Get("Retry-After"))
+
+	resp = serve(http.MethodPost, "/auth/login", map[string]string{"email": "nobody@example.com", "password": "correct horse"}, "")
+	require.Equal(t, http.StatusUnauthorized, resp.Code)
+}
+
+func TestIntegrationRateLimits(t *testing.T) {
+	limits := filepath.Join(t.TempDir(), "limits.json")
+	require.NoError(t, os.WriteFile(limits, []byte(`{
+		"default": {"rate": 0.001, "burst": 1000},
+		"routes": {"POST /sales": {"rate": 0.001, "burst": 2}},
+		"quotas": {"key:partner": 3}
+	}`), 0o600))
+	t.Setenv("RATE_LIMITS", limits)
+	t.Setenv("AUTH_API_KEYS", `[
+		{"name":"integration-tests","key":"`+testAPIKey+`","roles":["admin"]},
+		{"name":"partner","key":"partner-key-0123456789","roles":["admin"]}
+	]`)
+
+	app := gin.New()
+	require.NoError(t, api.InitRoutes(app))
+
+	serve := func(method, path string, body any, key string) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set(auth.APIKeyHeader, key)
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve(http.MethodPost, "/users", map[string]string{"name": "Ayrton"}, testAPIKey)
+	require.Equal(t, http.StatusCreated, resp.Code)
+	require.Equal(t, "1000", resp.Header().Get("RateLimit-Limit"))
+	var resUser user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resUser))
+	sale := map[string]any{"user_id": resUser.ID, "amount": "100"}
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		key           string
+		wantCode      int
+		wantLimit     string
+		wantRemaining string
+	}{
+		{name: "first sale", method: http.MethodPost, path: "/sales", key: testAPIKey, wantCode: http.StatusCreated, wantLimit: "2", wantRemaining: "1"},
+		{name: "second sale", method: http.MethodPost, path: "/sales", key: testAPIKey, wantCode: http.StatusCreated, wantLimit: "2", wantRemaining: "0"},
+		{name: "burst spent", method: http.MethodPost, path: "/sales", key: testAPIKey, wantCode: http.StatusTooManyRequests, wantLimit: "2", wantRemaining: "0"},
+		{name: "other routes keep their bucket", method: http.MethodGet, path: "/users/" + resUser.ID, key: testAPIKey, wantCode: http.StatusOK, wantLimit: "1000", wantRemaining: "998"},
+		{name: "other clients keep their bucket", method: http.MethodPost, path: "/sales", key: "partner-key-0123456789", wantCode: http.StatusCreated, wantLimit: "2", wantRemaining: "1"},
+		{name: "quota", method: http.MethodGet, path: "/users/" + resUser.ID, key: "partner-key-0123456789", wantCode: http.StatusOK, wantLimit: "1000", wantRemaining: "999"},
+		{name: "quota spent", method: http.MethodGet, path: "/users/" + resUser.ID, key: "partner-key-0123456789", wantCode: http.StatusOK, wantLimit: "1000", wantRemaining: "998"},
+		{name: "quota exceeded", method: http.MethodGet, path: "/users/" + resUser.ID, key: "partner-key-0123456789", wantCode: http.StatusTooManyRequests, wantLimit: "3", wantRemaining: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serve(tt.method, tt.path, sale, tt.key)
+			require.Equal(t, tt.wantCode, resp.Code)
+			require.Equal(t, tt.wantLimit, resp.Header().Get("RateLimit-Limit"))
+			require.Equal(t, tt.wantRemaining, resp.Header().Get("RateLimit-Remaining"))
+			require.NotEmpty(t, resp.Header().Get("RateLimit-Reset"))
+			if tt.wantCode == http.StatusTooManyRequests {
+				require.NotEmpty(t, resp.Header().Get("Retry-After"))
+			}
+		})
+	}
+
+	t.Run("clients without credentials are told apart by IP", func(t *testing.T) {
+		t.Setenv("AUTH_DISABLED", "true")
+		app := gin.New()
+		require.NoError(t, api.InitRoutes(app))
+
+		post := func(ip, forwarded string) int {
+			req := httptest.NewRequest(http.MethodPost, "/sales", bytes.NewBufferString(`{}`))
+			req.RemoteAddr = ip + ":4321"
+			if forwarded != "" {
+				req.Header.Set("X-Forwarded-For", forwarded)
+			}
+			resp := httptest.NewRecorder()
+			app.ServeHTTP(resp, req)
+			return resp.Code
+		}
+		require.Equal(t, http.StatusBadRequest, post("192.0.2.1", ""))
+		require.Equal(t, http.StatusBadRequest, post("192.0.2.1", ""))
+		// X-Forwarded-For of untrusted clients is ignored.
+		require.Equal(t, http.StatusTooManyRequests, post("192.0.2.1", "198.51.100.7"))
+		require.Equal(t, http.StatusBadRequest, post("192.0.2.2", ""))
+	})
+
+	t.Run("failed authentications are throttled per IP", func(t *testing.T) {
+		failures := filepath.Join(t.TempDir(), "limits.json")
+		require.NoError(t, os.WriteFile(failures, []byte(`{"auth_failures": {"rate": 0.001, "burst": 2}}`), 0o600))
+		t.Setenv("RATE_LIMITS", failures)
+		app := gin.New()
+		require.NoError(t, api.InitRoutes(app))
+
+		get := func(ip, key string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/users/missing", nil)
+			req.RemoteAddr = ip + ":4321"
+			req.Header.Set(auth.APIKeyHeader, key)
+			resp := httptest.NewRecorder()
+			app.ServeHTTP(resp, req)
+			return resp
+		}
+		require.Equal(t, http.StatusUnauthorized, get("192.0.2.1", "wrong-key-0123456789").Code)
+		require.Equal(t, http.StatusUnauthorized, get("192.0.2.1", "wrong-key-0123456789").Code)
+		// Not even a valid key gets through once the IP is throttled.
+		resp := get("192.0.2.1", testAPIKey)
+		require.Equal(t, http.StatusTooManyRequests, resp.Code)
+		require.NotEmpty(t, resp.Header().Get("Retry-After"))
+		require.Contains(t, resp.Body.String(), "too_many_auth_failures")
+		// Other IP addresses get past authentication to the handler.
+		require.Equal(t, http.StatusNotFound, get("192.0.2.2", testAPIKey).Code)
+	})
+}
+
+func TestIntegrationProblems(t *testing.T) {
+	app := gin.New()
+	require.NoError(t, api.InitRoutes(app))
+
+	resp := httptest.NewRecorder()
+	app.ServeHTTP(resp, authorized(httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(`{"name":"Ayrton"}`))))
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var resUser user.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&resUser))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		anonymous  bool
+		wantStatus int
+		wantCode   string
+		wantField  string
+	}{
+		{name: "unknown user in sales listing", method: http.MethodGet, path: "/sales?user_id=does-not-exist", wantStatus: http.StatusNotFound, wantCode: "user_not_found"},
+		{name: "non-positive amount", method: http.MethodPost, path: "/sales", body: `{"user_id":"` + resUser.ID + `","amount":0}`, wantStatus: http.StatusBadRequest, wantCode: "invalid_amount", wantField: "amount"},
+		{name: "malformed amount", method: http.MethodPost, path: "/sales", body: `{"user_id":"` + resUser.ID + `","amount":"abc"}`, wantStatus: http.StatusBadRequest, wantCode: "invalid_amount", wantField: "amount"},
+		{name: "amount in another currency", method: http.MethodPost, path: "/sales", body: `{"user_id":"` + resUser.ID + `","currency":"ARS","amount":{"value":"10","currency":"USD"}}`, wantStatus: http.StatusBadRequest, wantCode: "currency_mismatch", wantField: "amount"},
+		{name: "amount with items", method: http.MethodPost, path: "/sales", body: `{"user_id":"` + resUser.ID + `","amount":10,"items":[{"product_id":"p","quantity":1}]}`, wantStatus: http.StatusBadRequest, wantCode: "amount_not_allowed", wantField: "amount"},
+		{name: "unit price of an item", method: http.MethodPost, path: "/sales", body: `{"user_id":"` + resUser.ID + `","items":[{"product_id":"p","quantity":1,"unit_price":"abc"}]}`, wantStatus: http.StatusBadRequest, wantCode: "invalid_amount", wantField: "items[0].unit_price"},
+		{name: "unknown user in a sale", method: http.MethodPost, path: "/sales", body: `{"user_id":"does-not-exist","amount":10}`, wantStatus: http.StatusBadRequest, wantCode: "unknown_user", wantField: "user_id"},
+		{name: "wrong type in body", method: http.MethodPost, path: "/users", body: `{"name":42}`, wantStatus: http.StatusBadRequest, wantCode: "invalid_body", wantField: "name"},
+		{name: "interest rate with a stray quote", method: http.MethodPost, path: "/sales", body: `{"user_id":"` + resUser.ID + `","amount":10,"installment_plan":{"count":3,"interest_rate":"\"0.05"}}`, wantStatus: http.StatusBadRequest, wantCode: "invalid_body"},
+		{name: "interest rate of the wrong type", method: http.MethodPost, path: "/sales", body: `{"user_id":"` + resUser.ID + `","amount":10,"installment_plan":{"count":3,"interest_rate":true}}`, wantStatus: http.StatusBadRequest, wantCode: "invalid_body"},
+		{name: "coupon percent that is not a number", method: http.MethodPost, path: "/coupons", body: `{"code":"BAD","kind":"percent","percent":"ten"}`, wantStatus: http.StatusBadRequest, wantCode: "invalid_body"},
+		{name: "malformed body", method: http.MethodPost, path: "/users", body: `{`, wantStatus: http.StatusBadRequest, wantCode: "invalid_body"},
+		{name: "invalid filter", method: http.MethodGet, path: "/sales?user_id=" + resUser.ID + "&order=up", wantStatus: http.StatusBadRequest, wantCode: "invalid_field", wantField: "order"},
+		{name: "unknown sale", method: http.MethodGet, path: "/sales/does-not-exist", wantStatus: http.StatusNotFound, wantCode: "sale_not_found"},
+		{name: "unknown status", method: http.MethodPatch, path: "/sales/does-not-exist", body: `{"status":"banana"}`, wantStatus: http.StatusNotFound, wantCode: "sale_not_found"},
+		{name: "installment number", method: http.MethodPatch, path: "/sales/does-not-exist/installments/first", body: `{}`, wantStatus: http.StatusBadRequest, wantCode: "invalid_parameter", wantField: "number"},
+		{name: "unknown route", method: http.MethodGet, path: "/nowhere", wantStatus: http.StatusNotFound, wantCode: "route_not_found"},
+		{name: "missing credentials", method: http.MethodGet, path: "/users/" + resUser.ID, anonymous: true, wantStatus: http.StatusUnauthorized, wantCode: "missing_credentials"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			if !tt.anonymous {
+				authorized(req)
+			}
+			resp := httptest.NewRecorder()
+			app.ServeHTTP(resp, req)
+
+			require.Equal(t, tt.wantStatus, resp.Code, resp.Body.String())
+			require.Equal(t, "application/problem+json", resp.Header().Get("Content-Type"))
+			var problem api.Problem
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+			require.Equal(t, tt.wantStatus, problem.Status)
+			require.Equal(t, http.StatusText(tt.wantStatus), problem.Title)
+			require.Equal(t, tt.wantCode, problem.Code)
+			require.NotEmpty(t, problem.Detail)
+			require.Equal(t, resp.Header().Get("X-Request-ID"), problem.RequestID)
+			require.NotEmpty(t, problem.RequestID)
+			if tt.wantField == "" {
+				require.Empty(t, problem.Fields)
+			} else {
+				require.Len(t, problem.Fields, 1)
+				require.Equal(t, tt.wantField, problem.Fields[0].Field)
+			}
+		})
+	}
+
+	t.Run("client request IDs are kept when valid", func(t *testing.T) {
+		for id, keep := range map[string]bool{"req-42.a:b_c": true, "bad id\n": false, strings.Repeat("x", 129): false} {
+			req := authorized(httptest.NewRequest(http.MethodGet, "/sales/does-not-exist", nil))
+			req.Header.Set("X-Request-ID", id)
+			resp := httptest.NewRecorder()
+			app.ServeHTTP(resp, req)
+
+			var problem api.Problem
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+			require.Equal(t, keep, problem.RequestID == id)
+			require.Equal(t, resp.Header().Get("X-Request-ID"), problem.RequestID)
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"API_VentasGO/api"
+	"API_VentasGO/internal/sale"
+	"API_VentasGO/internal/user"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStressConcurrentSales hammers POST /sales and PATCH /sales/:id from many
+// goroutines at once. Run it with `go test -race ./tests/...` to make sure the
+// storages are safe for concurrent use.
+func TestStressConcurrentSales(t *testing.T) {
+	app := gin.New()
+	require.NoError(t, api.InitRoutes(app))
+
+	const (
+		users        = 8
+		workers      = 16
+		salesPerUser = 25
+	)
+
+	serve := func(method, path string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := authorized(httptest.NewRequest(method, path, bytes.NewReader(payload)))
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	userIDs := make([]string, users)
+	for i := range userIDs {
+		resp := serve(http.MethodPost, "/users", map[string]string{"name": "Ayrton"})
+		require.Equal(t, http.StatusCreated, resp.Code)
+
+		var u user.User
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&u))
+		userIDs[i] = u.ID
+	}
+
+	jobs := make(chan string)
+	created := make(chan string, users*salesPerUser)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for userID := range jobs {
+				resp := serve(http.MethodPost, "/sales", map[string]any{"user_id": userID, "amount": 10})
+				if resp.Code != http.StatusCreated {
+					t.Errorf("create sale: got status %d", resp.Code)
+					continue
+				}
+
+				var s sale.Sale
+				if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+					t.Error(err)
+					continue
+				}
+				created <- s.ID
+
+				// Two concurrent updates over the same sale.
+				var inner sync.WaitGroup
+				for _, status := range []string{"approved", "rejected"} {
+					inner.Add(1)
+					go func(status string) {
+						defer inner.Done()
+						serve(http.MethodPatch, "/sales/"+s.ID, map[string]string{"status": status})
+					}(status)
+				}
+				serve(http.MethodGet, "/sales?user_id="+userID, nil)
+				inner.Wait()
+			}
+		}()
+	}
+
+	for n := 0; n < salesPerUser; n++ {
+		for _, id := range userIDs {
+			jobs <- id
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(created)
+
+	require.Len(t, created, users*salesPerUser)
+	for id := range created {
+		resp := serve(http.MethodGet, "/sales/"+id, nil)
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		// Exactly one of the concurrent updates wins, the other one is
+		// retried against the settled sale and refused.
+		var s sale.Sale
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&s))
+		require.Contains(t, []string{sale.StatusApproved, sale.StatusRejected}, s.Status)
+		require.Equal(t, 2, s.Version)
+	}
+
+	for _, id := range userIDs {
+		resp := serve(http.MethodGet, "/sales?user_id="+id, nil)
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		var body struct {
+			Results []*sale.Sale `json:"results"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		require.Len(t, body.Results, salesPerUser)
+	}
+}